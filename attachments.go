@@ -21,6 +21,15 @@ type Attachment struct {
 	// and might be modified by the pdf reader.
 	Description string
 
+	// MimeType is the optional media type of the content, for example
+	// "text/xml". PDF/A documents default to "application/octet-stream".
+	MimeType string
+
+	// Relationship is the optional relationship of the attachment to the
+	// document: "Source", "Data", "Alternative", "Supplement" or
+	// "Unspecified". PDF/A-3 documents default to "Unspecified".
+	Relationship string
+
 	objectNumber int // filled when content is included
 }
 
@@ -36,14 +45,27 @@ func checksum(data []byte) string {
 
 // Writes a compressed file like object as ``/EmbeddedFile``. Compressing is
 // done with deflate. Includes length, compressed length and MD5 checksum.
-func (f *Fpdf) writeCompressedFileObject(content []byte) {
+func (f *Fpdf) writeCompressedFileObject(content []byte, mimeType string) {
 	lenUncompressed := len(content)
-	sum := checksum(content)
 	compressed := sliceCompress(content)
 	lenCompressed := len(compressed)
 	f.newobj()
-	f.outf("<< /Type /EmbeddedFile /Length %d /Filter /FlateDecode /Params << /CheckSum <%s> /Size %d >> >>\n",
-		lenCompressed, sum, lenUncompressed)
+	sum := "<" + checksum(content) + ">"
+	if f.protect.encrypted {
+		// Like all strings, the checksum is subject to encryption
+		tmp := md5.Sum(content)
+		sum = f.textstring(string(tmp[:]))
+	}
+	var subtype string
+	if mimeType != "" {
+		subtype = " /Subtype " + formName(mimeType)
+	}
+	var modDate string
+	if f.pdfa.part > 0 {
+		modDate = " /ModDate " + f.textstring("D:"+f.pdfaDateStr(f.pdfaModTime()))
+	}
+	f.outf("<< /Type /EmbeddedFile%s /Length %d /Filter /FlateDecode /Params << /CheckSum %s /Size %d%s >> >>\n",
+		subtype, f.protect.cipherLen(lenCompressed), sum, lenUncompressed, modDate)
 	f.putstream(compressed)
 	f.out("endobj")
 }
@@ -55,13 +77,26 @@ func (f *Fpdf) embed(a *Attachment) {
 	}
 	oldState := f.state
 	f.state = 1 // we write file content in the main buffer
-	f.writeCompressedFileObject(a.Content)
+	mimeType, relationship := a.MimeType, a.Relationship
+	if f.pdfa.part > 0 && mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if f.pdfa.part == 3 && relationship == "" {
+		relationship = "Unspecified"
+	}
+	f.writeCompressedFileObject(a.Content, mimeType)
 	streamID := f.n
 	f.newobj()
-	f.outf("<< /Type /Filespec /F () /UF %s /EF << /F %d 0 R >> /Desc %s\n>>",
+	var afRel string
+	if relationship != "" {
+		afRel = " /AFRelationship " + formName(relationship)
+	}
+	f.outf("<< /Type /Filespec /F %s /UF %s /EF << /F %d 0 R >> /Desc %s%s\n>>",
+		f.textstring(a.Filename),
 		f.textstring(utf8toutf16(a.Filename)),
 		streamID,
-		f.textstring(utf8toutf16(a.Description)))
+		f.textstring(utf8toutf16(a.Description)),
+		afRel)
 	f.out("endobj")
 	a.objectNumber = f.n
 	f.state = oldState
@@ -0,0 +1,782 @@
+package gofpdf
+
+import (
+	"sort"
+	"unicode"
+)
+
+// The Unicode Bidirectional Algorithm (UAX #9) lays out text that mixes
+// left-to-right and right-to-left scripts. Text in UTF-8 fonts is measured
+// and broken into lines in logical order; each line is then split into runs
+// of one direction, which are put in display order when the line is written
+// to the page. The base direction of a paragraph is that of its first strong
+// character unless RTL mode is on.
+
+// Bidirectional character types
+const (
+	bidiL = iota
+	bidiR
+	bidiAL
+	bidiEN
+	bidiES
+	bidiET
+	bidiAN
+	bidiCS
+	bidiNSM
+	bidiBN
+	bidiB
+	bidiS
+	bidiWS
+	bidiON
+	bidiLRE
+	bidiLRO
+	bidiRLE
+	bidiRLO
+	bidiPDF
+	bidiLRI
+	bidiRLI
+	bidiFSI
+	bidiPDI
+)
+
+// bidiMaxDepth is the deepest embedding level allowed
+const bidiMaxDepth = 125
+
+type bidiRangeType struct {
+	lo, hi rune
+	class  int
+}
+
+// bidiRanges lists the characters outside of the right-to-left blocks whose
+// type cannot be derived from their general category.
+var bidiRanges = []bidiRangeType{
+	{0x0085, 0x0085, bidiB},
+	{0x00A0, 0x00A0, bidiCS},
+	{0x00A2, 0x00A5, bidiET},
+	{0x00B0, 0x00B1, bidiET},
+	{0x00B2, 0x00B3, bidiEN},
+	{0x00B9, 0x00B9, bidiEN},
+	{0x09F2, 0x09F3, bidiET},
+	{0x09FB, 0x09FB, bidiET},
+	{0x0AF1, 0x0AF1, bidiET},
+	{0x0BF9, 0x0BF9, bidiET},
+	{0x0E3F, 0x0E3F, bidiET},
+	{0x17DB, 0x17DB, bidiET},
+	{0x200E, 0x200E, bidiL},
+	{0x200F, 0x200F, bidiR},
+	{0x2029, 0x2029, bidiB},
+	{0x202A, 0x202A, bidiLRE},
+	{0x202B, 0x202B, bidiRLE},
+	{0x202C, 0x202C, bidiPDF},
+	{0x202D, 0x202D, bidiLRO},
+	{0x202E, 0x202E, bidiRLO},
+	{0x202F, 0x202F, bidiCS},
+	{0x2030, 0x2034, bidiET},
+	{0x2044, 0x2044, bidiCS},
+	{0x2066, 0x2066, bidiLRI},
+	{0x2067, 0x2067, bidiRLI},
+	{0x2068, 0x2068, bidiFSI},
+	{0x2069, 0x2069, bidiPDI},
+	{0x2070, 0x2070, bidiEN},
+	{0x2074, 0x2079, bidiEN},
+	{0x207A, 0x207B, bidiES},
+	{0x2080, 0x2089, bidiEN},
+	{0x208A, 0x208B, bidiES},
+	{0x20A0, 0x20CF, bidiET},
+	{0x212E, 0x212E, bidiET},
+	{0x2212, 0x2212, bidiES},
+	{0x2213, 0x2213, bidiET},
+	{0x2488, 0x249B, bidiEN},
+	{0xA838, 0xA839, bidiET},
+	{0xFE50, 0xFE50, bidiCS},
+	{0xFE52, 0xFE52, bidiCS},
+	{0xFE55, 0xFE55, bidiCS},
+	{0xFE5F, 0xFE5F, bidiET},
+	{0xFE62, 0xFE63, bidiES},
+	{0xFE69, 0xFE6A, bidiET},
+	{0xFF03, 0xFF05, bidiET},
+	{0xFF0B, 0xFF0B, bidiES},
+	{0xFF0C, 0xFF0C, bidiCS},
+	{0xFF0D, 0xFF0D, bidiES},
+	{0xFF0E, 0xFF0F, bidiCS},
+	{0xFF10, 0xFF19, bidiEN},
+	{0xFF1A, 0xFF1A, bidiCS},
+	{0xFFE0, 0xFFE1, bidiET},
+	{0xFFE5, 0xFFE6, bidiET},
+	{0x1D7CE, 0x1D7FF, bidiEN},
+}
+
+// bidiArabicRanges lists the characters of the Arabic blocks whose type is
+// not AL.
+var bidiArabicRanges = []bidiRangeType{
+	{0x0600, 0x0605, bidiAN},
+	{0x0609, 0x060A, bidiET},
+	{0x060C, 0x060C, bidiCS},
+	{0x061C, 0x061C, bidiAL},
+	{0x0660, 0x0669, bidiAN},
+	{0x066A, 0x066A, bidiET},
+	{0x066B, 0x066C, bidiAN},
+	{0x06DD, 0x06DD, bidiAN},
+	{0x06F0, 0x06F9, bidiEN},
+	{0x0890, 0x0891, bidiAN},
+	{0x08E2, 0x08E2, bidiAN},
+	{0xFD3E, 0xFD3F, bidiON},
+}
+
+// bidiClassOf returns the bidirectional type of r. Types are derived from
+// the block and general category of r, with the exceptions listed in
+// bidiRanges; this matches the Unicode Character Database for the characters
+// found in practice.
+func bidiClassOf(r rune) int {
+	if r < 0x80 {
+		switch {
+		case r >= '0' && r <= '9':
+			return bidiEN
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+			return bidiL
+		case r == ' ', r == '\f':
+			return bidiWS
+		case r == '\t', r == '\v', r == 0x1F:
+			return bidiS
+		case r == '\n', r == '\r', r >= 0x1C && r <= 0x1E:
+			return bidiB
+		case r < 0x20, r == 0x7F:
+			return bidiBN
+		case r == '+', r == '-':
+			return bidiES
+		case r == ',', r == '.', r == '/', r == ':':
+			return bidiCS
+		case r >= '#' && r <= '%':
+			return bidiET
+		}
+		return bidiON
+	}
+	switch {
+	case r >= 0x0600 && r <= 0x07BF, r >= 0x0860 && r <= 0x08FF,
+		r >= 0xFB50 && r <= 0xFDFF, r >= 0xFE70 && r <= 0xFEFF,
+		r >= 0x10D00 && r <= 0x10D3F, r >= 0x1EC70 && r <= 0x1EEFF:
+		for _, rg := range bidiArabicRanges {
+			if r >= rg.lo && r <= rg.hi {
+				return rg.class
+			}
+		}
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me):
+			return bidiNSM
+		case unicode.Is(unicode.Cf, r):
+			return bidiBN
+		case unicode.Is(unicode.Nd, r):
+			return bidiAN
+		}
+		return bidiAL
+	case r >= 0x0590 && r <= 0x05FF, r >= 0x07C0 && r <= 0x085F,
+		r >= 0xFB1D && r <= 0xFB4F, r >= 0x10800 && r <= 0x10FFF,
+		r >= 0x1E800 && r <= 0x1EFFF:
+		if r == 0xFB29 {
+			return bidiES
+		}
+		if unicode.In(r, unicode.Mn, unicode.Me) {
+			return bidiNSM
+		}
+		return bidiR
+	}
+	for _, rg := range bidiRanges {
+		if r >= rg.lo && r <= rg.hi {
+			return rg.class
+		}
+	}
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return bidiNSM
+	case unicode.In(r, unicode.Cf, unicode.Cc):
+		return bidiBN
+	case unicode.In(r, unicode.Zs, unicode.Zl):
+		return bidiWS
+	case unicode.Is(unicode.Zp, r):
+		return bidiB
+	case unicode.In(r, unicode.P, unicode.S, unicode.No):
+		return bidiON
+	}
+	return bidiL
+}
+
+// bidiMirrors maps characters to their mirror image, which is shown in
+// right-to-left text.
+var bidiMirrors = map[rune]rune{
+	'(': ')', ')': '(', '<': '>', '>': '<', '[': ']', ']': '[', '{': '}',
+	'}': '{', '«': '»', '»': '«', '‹': '›', '›': '‹', '⁅': '⁆', '⁆': '⁅',
+	'⁽': '⁾', '⁾': '⁽', '₍': '₎', '₎': '₍', '≤': '≥', '≥': '≤', '≪': '≫',
+	'≫': '≪', '∈': '∋', '∋': '∈', '⊂': '⊃', '⊃': '⊂', '⊆': '⊇', '⊇': '⊆',
+	'〈': '〉', '〉': '〈', '《': '》', '》': '《', '「': '」', '」': '「',
+	'『': '』', '』': '『', '【': '】', '】': '【', '〔': '〕', '〕': '〔',
+	'〖': '〗', '〗': '〖', '〘': '〙', '〙': '〘', '〚': '〛', '〛': '〚',
+	'（': '）', '）': '（', '＜': '＞', '＞': '＜', '［': '］', '］': '［',
+	'｛': '｝', '｝': '｛', '｟': '｠', '｠': '｟', '｢': '｣', '｣': '｢',
+}
+
+// bidiBrackets maps opening paired brackets to their closing bracket
+var bidiBrackets = map[rune]rune{
+	'(': ')', '[': ']', '{': '}', '⁅': '⁆', '⁽': '⁾', '₍': '₎', '〈': '〉',
+	'《': '》', '「': '」', '『': '』', '【': '】', '〔': '〕', '〖': '〗',
+	'〘': '〙', '〚': '〛', '（': '）', '［': '］', '｛': '｝', '｟': '｠',
+	'｢': '｣',
+}
+
+// bidiFirstStrong returns 0 if the first strong character of txt, not
+// counting isolated text, is left-to-right, 1 if it is right-to-left and -1
+// if there is none. The search ends at the first paragraph separator.
+func bidiFirstStrong(txt []rune) int {
+	depth := 0
+	for _, r := range txt {
+		switch bidiClassOf(r) {
+		case bidiL:
+			if depth == 0 {
+				return 0
+			}
+		case bidiR, bidiAL:
+			if depth == 0 {
+				return 1
+			}
+		case bidiLRI, bidiRLI, bidiFSI:
+			depth++
+		case bidiPDI:
+			if depth > 0 {
+				depth--
+			}
+		case bidiB:
+			return -1
+		}
+	}
+	return -1
+}
+
+// bidiParagraph returns the value of bidiPara for the paragraph that starts
+// with txt: 2 in right-to-left mode or if the paragraph starts with
+// right-to-left text, 1 otherwise.
+func (f *Fpdf) bidiParagraph(txt []rune) int {
+	if f.isRTL || bidiFirstStrong(txt) == 1 {
+		return 2
+	}
+	return 1
+}
+
+// bidiNextLevel returns the least odd (rtl) or even level greater than level
+func bidiNextLevel(level int, rtl bool) int {
+	if rtl {
+		return (level + 1) | 1
+	}
+	return (level + 2) &^ 1
+}
+
+func bidiIsolate(class int) bool {
+	return class == bidiLRI || class == bidiRLI || class == bidiFSI
+}
+
+// bidiStrong returns the strong direction, bidiL or bidiR, that a resolved
+// type counts as in rules N0 to N2, or -1 for neutral types.
+func bidiStrong(class int) int {
+	switch class {
+	case bidiL:
+		return bidiL
+	case bidiR, bidiAL, bidiEN, bidiAN:
+		return bidiR
+	}
+	return -1
+}
+
+// bidiResolve returns the embedding level of each character of a line of
+// text in a paragraph of the given embedding level, applying rules X1 to I2
+// and L1 of UAX #9.
+func bidiResolve(text []rune, level int) []int {
+	n := len(text)
+	orig := make([]int, n)
+	for j, r := range text {
+		orig[j] = bidiClassOf(r)
+	}
+	cls := append([]int(nil), orig...)
+	// BD9: match isolate initiators with their PDI
+	matchPDI := make([]int, n)
+	matchInit := make([]int, n)
+	var open []int
+	for j, c := range orig {
+		matchPDI[j] = -1
+		matchInit[j] = -1
+		switch {
+		case bidiIsolate(c):
+			open = append(open, j)
+		case c == bidiPDI && len(open) > 0:
+			o := open[len(open)-1]
+			open = open[:len(open)-1]
+			matchPDI[o] = j
+			matchInit[j] = o
+		case c == bidiB:
+			open = open[:0]
+		}
+	}
+	// X1 to X8: explicit levels and directions
+	type statusType struct {
+		level    int
+		override int
+		isolate  bool
+	}
+	levels := make([]int, n)
+	removed := make([]bool, n)
+	stack := []statusType{{level, bidiON, false}}
+	overflowIsolates, overflowEmbeddings, validIsolates := 0, 0, 0
+	for j, c := range orig {
+		top := stack[len(stack)-1]
+		levels[j] = top.level
+		switch c {
+		case bidiRLE, bidiLRE, bidiRLO, bidiLRO:
+			removed[j] = true
+			next := bidiNextLevel(top.level, c == bidiRLE || c == bidiRLO)
+			if next <= bidiMaxDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				override := bidiON
+				if c == bidiRLO {
+					override = bidiR
+				} else if c == bidiLRO {
+					override = bidiL
+				}
+				stack = append(stack, statusType{next, override, false})
+			} else if overflowIsolates == 0 {
+				overflowEmbeddings++
+			}
+		case bidiRLI, bidiLRI, bidiFSI:
+			if top.override != bidiON {
+				cls[j] = top.override
+			}
+			rtl := c == bidiRLI
+			if c == bidiFSI {
+				end := n
+				if matchPDI[j] >= 0 {
+					end = matchPDI[j]
+				}
+				rtl = bidiFirstStrong(text[j+1:end]) == 1
+			}
+			next := bidiNextLevel(top.level, rtl)
+			if next <= bidiMaxDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				validIsolates++
+				stack = append(stack, statusType{next, bidiON, true})
+			} else {
+				overflowIsolates++
+			}
+		case bidiPDI:
+			if overflowIsolates > 0 {
+				overflowIsolates--
+			} else if validIsolates > 0 {
+				overflowEmbeddings = 0
+				for !stack[len(stack)-1].isolate {
+					stack = stack[:len(stack)-1]
+				}
+				stack = stack[:len(stack)-1]
+				validIsolates--
+			}
+			top = stack[len(stack)-1]
+			levels[j] = top.level
+			if top.override != bidiON {
+				cls[j] = top.override
+			}
+		case bidiPDF:
+			removed[j] = true
+			switch {
+			case overflowIsolates > 0:
+			case overflowEmbeddings > 0:
+				overflowEmbeddings--
+			case !top.isolate && len(stack) >= 2:
+				stack = stack[:len(stack)-1]
+			}
+		case bidiB:
+			levels[j] = level
+		case bidiBN:
+			removed[j] = true
+		default:
+			if top.override != bidiON {
+				cls[j] = top.override
+			}
+		}
+	}
+	// X9, X10: level runs, which are joined into isolating run sequences
+	var runs [][]int
+	runAt := make(map[int]int)
+	last := -1
+	for j := 0; j < n; j++ {
+		if removed[j] {
+			continue
+		}
+		if last < 0 || levels[j] != levels[last] {
+			runAt[j] = len(runs)
+			runs = append(runs, nil)
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], j)
+		last = j
+	}
+	for _, run := range runs {
+		if orig[run[0]] == bidiPDI && matchInit[run[0]] >= 0 {
+			continue
+		}
+		seq := append([]int(nil), run...)
+		for {
+			end := seq[len(seq)-1]
+			if !bidiIsolate(orig[end]) || matchPDI[end] < 0 {
+				break
+			}
+			r, ok := runAt[matchPDI[end]]
+			if !ok {
+				break
+			}
+			seq = append(seq, runs[r]...)
+		}
+		bidiResolveSequence(text, orig, cls, levels, removed, seq, level)
+	}
+	// Characters removed by X9 take the level of the preceding character
+	for j := range levels {
+		if removed[j] {
+			if j > 0 {
+				levels[j] = levels[j-1]
+			} else {
+				levels[j] = level
+			}
+		}
+	}
+	// L1: separators and trailing white space revert to the paragraph level
+	trailing := true
+	for j := n - 1; j >= 0; j-- {
+		switch c := orig[j]; {
+		case c == bidiS || c == bidiB:
+			levels[j] = level
+			trailing = true
+		case trailing && (c == bidiWS || bidiIsolate(c) || c == bidiPDI || removed[j]):
+			levels[j] = level
+		default:
+			trailing = false
+		}
+	}
+	return levels
+}
+
+// bidiResolveSequence applies rules W1 to I2 to the isolating run sequence
+// made of the characters at positions seq.
+func bidiResolveSequence(text []rune, orig, cls, levels []int, removed []bool, seq []int, paraLevel int) {
+	n := len(seq)
+	seqLevel := levels[seq[0]]
+	direction := func(level int) int {
+		if level%2 == 1 {
+			return bidiR
+		}
+		return bidiL
+	}
+	// Start and end of sequence types
+	prev := paraLevel
+	for j := seq[0] - 1; j >= 0; j-- {
+		if !removed[j] {
+			prev = levels[j]
+			break
+		}
+	}
+	next := paraLevel
+	if end := seq[n-1]; !bidiIsolate(orig[end]) {
+		for j := end + 1; j < len(levels); j++ {
+			if !removed[j] {
+				next = levels[j]
+				break
+			}
+		}
+	}
+	sos := direction(max(seqLevel, prev))
+	eos := direction(max(seqLevel, next))
+	types := make([]int, n)
+	for k, j := range seq {
+		types[k] = cls[j]
+	}
+	// W1: non-spacing marks
+	for k, t := range types {
+		if t == bidiNSM {
+			switch {
+			case k == 0:
+				types[k] = sos
+			case bidiIsolate(types[k-1]) || types[k-1] == bidiPDI:
+				types[k] = bidiON
+			default:
+				types[k] = types[k-1]
+			}
+		}
+	}
+	// W2, W3: European numbers after Arabic letters
+	strong := sos
+	for k, t := range types {
+		switch t {
+		case bidiL, bidiR, bidiAL:
+			strong = t
+		case bidiEN:
+			if strong == bidiAL {
+				types[k] = bidiAN
+			}
+		}
+	}
+	for k, t := range types {
+		if t == bidiAL {
+			types[k] = bidiR
+		}
+	}
+	// W4: single separators between numbers
+	for k := 1; k < n-1; k++ {
+		switch {
+		case types[k] == bidiES && types[k-1] == bidiEN && types[k+1] == bidiEN:
+			types[k] = bidiEN
+		case types[k] == bidiCS && types[k-1] == types[k+1] &&
+			(types[k-1] == bidiEN || types[k-1] == bidiAN):
+			types[k] = types[k-1]
+		}
+	}
+	// W5: terminators adjacent to European numbers
+	for k := 0; k < n; k++ {
+		if types[k] != bidiET {
+			continue
+		}
+		end := k
+		for end < n && types[end] == bidiET {
+			end++
+		}
+		if (k > 0 && types[k-1] == bidiEN) || (end < n && types[end] == bidiEN) {
+			for m := k; m < end; m++ {
+				types[m] = bidiEN
+			}
+		}
+		k = end
+	}
+	// W6, W7
+	strong = sos
+	for k, t := range types {
+		switch t {
+		case bidiES, bidiET, bidiCS:
+			types[k] = bidiON
+		case bidiL, bidiR:
+			strong = t
+		case bidiEN:
+			if strong == bidiL {
+				types[k] = bidiL
+			}
+		}
+	}
+	// N0: paired brackets
+	e := direction(seqLevel)
+	type openType struct {
+		close rune
+		pos   int
+	}
+	var open []openType
+	var pairs [][2]int
+	for k, j := range seq {
+		if types[k] != bidiON {
+			continue
+		}
+		r := text[j]
+		if c, ok := bidiBrackets[r]; ok {
+			if len(open) == 63 {
+				break
+			}
+			open = append(open, openType{c, k})
+			continue
+		}
+		for d := len(open) - 1; d >= 0; d-- {
+			if open[d].close == r {
+				pairs = append(pairs, [2]int{open[d].pos, k})
+				open = open[:d]
+				break
+			}
+		}
+	}
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a][0] < pairs[b][0] })
+	for _, p := range pairs {
+		var hasE, hasOpposite bool
+		for k := p[0] + 1; k < p[1]; k++ {
+			if s := bidiStrong(types[k]); s == e {
+				hasE = true
+				break
+			} else if s >= 0 {
+				hasOpposite = true
+			}
+		}
+		set := -1
+		if hasE {
+			set = e
+		} else if hasOpposite {
+			set = sos
+			for k := p[0] - 1; k >= 0; k-- {
+				if s := bidiStrong(types[k]); s >= 0 {
+					set = s
+					break
+				}
+			}
+		}
+		if set >= 0 {
+			for _, b := range p {
+				types[b] = set
+				for k := b + 1; k < n && orig[seq[k]] == bidiNSM; k++ {
+					types[k] = set
+				}
+			}
+		}
+	}
+	// N1, N2: other neutrals
+	for k := 0; k < n; k++ {
+		if bidiStrong(types[k]) >= 0 {
+			continue
+		}
+		end := k
+		for end < n && bidiStrong(types[end]) < 0 {
+			end++
+		}
+		before, after := sos, eos
+		if k > 0 {
+			before = bidiStrong(types[k-1])
+		}
+		if end < n {
+			after = bidiStrong(types[end])
+		}
+		set := e
+		if before == after {
+			set = before
+		}
+		for m := k; m < end; m++ {
+			types[m] = set
+		}
+		k = end
+	}
+	// I1, I2: implicit levels
+	for k, j := range seq {
+		t := types[k]
+		cls[j] = t
+		if levels[j]%2 == 0 {
+			switch t {
+			case bidiR:
+				levels[j]++
+			case bidiAN, bidiEN:
+				levels[j] += 2
+			}
+		} else if t == bidiL || t == bidiEN || t == bidiAN {
+			levels[j]++
+		}
+	}
+}
+
+// bidiRunType is a run of text at one embedding level. Its characters are
+// in logical order, with mirrored characters replaced in right-to-left runs.
+type bidiRunType struct {
+	text []rune
+	rtl  bool
+}
+
+// bidiRuns returns the directional runs of a line of text in display order,
+// as specified by rule L2 of UAX #9. The base direction is that of the
+// current paragraph, if any, otherwise that of the first strong character of
+// txt; it is always right-to-left in RTL mode.
+func (f *Fpdf) bidiRuns(txt string) []bidiRunType {
+	text := []rune(txt)
+	level := 0
+	switch {
+	case f.isRTL:
+		level = 1
+	case f.bidiPara > 0:
+		level = f.bidiPara - 1
+	case bidiFirstStrong(text) == 1:
+		level = 1
+	}
+	if level == 0 {
+		simple := true
+		for _, r := range text {
+			switch bidiClassOf(r) {
+			case bidiR, bidiAL, bidiAN, bidiRLE, bidiRLO, bidiRLI, bidiFSI:
+				simple = false
+			}
+		}
+		if simple {
+			return []bidiRunType{{text: text}}
+		}
+	}
+	levels := bidiResolve(text, level)
+	type levelRunType struct {
+		start, end, level int
+	}
+	var runs []levelRunType
+	maxLevel, minOdd := 0, bidiMaxDepth+1
+	for j, lvl := range levels {
+		if len(runs) == 0 || runs[len(runs)-1].level != lvl {
+			runs = append(runs, levelRunType{j, j, lvl})
+		}
+		runs[len(runs)-1].end = j + 1
+		if lvl > maxLevel {
+			maxLevel = lvl
+		}
+		if lvl%2 == 1 && lvl < minOdd {
+			minOdd = lvl
+		}
+	}
+	for lvl := maxLevel; lvl >= minOdd; lvl-- {
+		for a := 0; a < len(runs); {
+			if runs[a].level < lvl {
+				a++
+				continue
+			}
+			b := a
+			for b < len(runs) && runs[b].level >= lvl {
+				b++
+			}
+			for c, d := a, b-1; c < d; c, d = c+1, d-1 {
+				runs[c], runs[d] = runs[d], runs[c]
+			}
+			a = b
+		}
+	}
+	list := make([]bidiRunType, len(runs))
+	for j, run := range runs {
+		list[j].text = text[run.start:run.end]
+		if run.level%2 == 1 {
+			list[j].rtl = true
+			list[j].text = append([]rune(nil), list[j].text...)
+			for k, r := range list[j].text {
+				if m, ok := bidiMirrors[r]; ok {
+					list[j].text[k] = m
+				}
+			}
+		}
+	}
+	return list
+}
+
+// bidiVisual returns a line of text in the current font reordered for
+// display.
+func (f *Fpdf) bidiVisual(txt string) string {
+	if !f.isCurrentUTF8 {
+		return txt
+	}
+	runs := f.bidiRuns(txt)
+	if len(runs) == 1 && !runs[0].rtl {
+		return txt
+	}
+	var out []rune
+	for _, run := range runs {
+		if run.rtl {
+			for k := len(run.text) - 1; k >= 0; k-- {
+				out = append(out, run.text[k])
+			}
+		} else {
+			out = append(out, run.text...)
+		}
+	}
+	return string(out)
+}
+
+// textTJ returns the elements of a TJ array that shows a line of text, given
+// in logical order, reordered for display and kerned or shaped.
+// wordSpacing, in thousandths of the font size, is added before each space,
+// and charSpacing between characters.
+func (f *Fpdf) textTJ(txt string, wordSpacing, charSpacing float64) string {
+	if f.fallbackRuns([]rune(txt)) != nil {
+		return f.fallbackTJ(txt, wordSpacing, charSpacing)
+	}
+	if f.shapeActive() {
+		return f.shapeTJ(f.bidiRuns(txt), wordSpacing, charSpacing)
+	}
+	return f.kernTJ([]rune(f.bidiVisual(txt)), wordSpacing, charSpacing)
+}
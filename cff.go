@@ -0,0 +1,531 @@
+package gofpdf
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// OpenType fonts with PostScript outlines keep their glyphs in a CFF table
+// (Adobe Technical Note #5176). A subset of such a font is embedded as a
+// CID-keyed CFF font program whose charset maps each glyph to its CID, so
+// that the CIDs of the text, which are the code points of its characters,
+// select glyphs without a CIDToGIDMap.
+
+// cffStandardStrings is the number of strings predefined by the CFF
+// specification; the strings of a font have SIDs that follow them.
+const cffStandardStrings = 391
+
+// CFF DICT operators
+const (
+	cffOpFontBBox    = 5
+	cffOpCharset     = 15
+	cffOpCharStrings = 17
+	cffOpPrivate     = 18
+	cffOpSubrs       = 19
+	cffOpCharType    = 1206
+	cffOpFontMatrix  = 1207
+	cffOpROS         = 1230
+	cffOpCIDCount    = 1234
+	cffOpFDArray     = 1236
+	cffOpFDSelect    = 1237
+	cffOpFontName    = 1238
+)
+
+// cffDictEntryType is an operator of a CFF DICT with its operands, which
+// are kept in their original encoding.
+type cffDictEntryType struct {
+	op       int
+	operands [][]byte
+}
+
+// cffFontDictType holds the Font DICT and Private DICT of a group of glyphs
+// along with their local subroutines.
+type cffFontDictType struct {
+	fontDict []cffDictEntryType
+	private  []cffDictEntryType
+	subrs    []byte // Local Subr INDEX
+}
+
+// cffFontType holds the parts of a CFF font that are needed to subset it
+type cffFontType struct {
+	name        []byte
+	top         []cffDictEntryType
+	gsubrs      []byte // Global Subr INDEX
+	charStrings [][]byte
+	fdSelect    []int // Font DICT of each glyph, nil if the font is not CID-keyed
+	fonts       []cffFontDictType
+}
+
+var errCFF = fmt.Errorf("invalid CFF table")
+
+// parseCFF parses the CFF table of an OpenType font
+func parseCFF(data []byte) (cff *cffFontType, err error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("font has no CFF table")
+	}
+	if data[0] != 1 {
+		return nil, fmt.Errorf("unsupported CFF version %d", data[0])
+	}
+	var names, tops [][]byte
+	var pos int
+	names, pos, err = cffIndex(data, int(data[2]))
+	if err == nil {
+		tops, pos, err = cffIndex(data, pos)
+	}
+	if err == nil {
+		// Strings are not needed: the subset has strings of its own
+		_, pos, err = cffIndex(data, pos)
+	}
+	cff = new(cffFontType)
+	if err == nil {
+		start := pos
+		_, pos, err = cffIndex(data, pos)
+		cff.gsubrs = data[start:pos]
+	}
+	if err == nil && (len(names) == 0 || len(tops) == 0) {
+		err = errCFF
+	}
+	if err != nil {
+		return nil, err
+	}
+	cff.name = names[0]
+	cff.top, err = cffParseDict(tops[0])
+	if err != nil {
+		return nil, err
+	}
+	if ops := cffOperands(cff.top, cffOpCharType); len(ops) == 1 && cffInt(ops[0]) != 2 {
+		return nil, fmt.Errorf("unsupported CFF charstring type %d", cffInt(ops[0]))
+	}
+	ops := cffOperands(cff.top, cffOpCharStrings)
+	if len(ops) != 1 {
+		return nil, errCFF
+	}
+	cff.charStrings, _, err = cffIndex(data, cffInt(ops[0]))
+	if err != nil {
+		return nil, err
+	}
+	if ops = cffOperands(cff.top, cffOpFDArray); len(ops) == 1 {
+		var fontDicts [][]byte
+		fontDicts, _, err = cffIndex(data, cffInt(ops[0]))
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range fontDicts {
+			var fd cffFontDictType
+			fd.fontDict, err = cffParseDict(b)
+			if err == nil {
+				err = fd.parsePrivate(data, fd.fontDict)
+			}
+			if err != nil {
+				return nil, err
+			}
+			cff.fonts = append(cff.fonts, fd)
+		}
+		ops = cffOperands(cff.top, cffOpFDSelect)
+		if len(ops) != 1 {
+			return nil, errCFF
+		}
+		cff.fdSelect, err = cffFDSelect(data, cffInt(ops[0]), len(cff.charStrings), len(cff.fonts))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var fd cffFontDictType
+		if err = fd.parsePrivate(data, cff.top); err != nil {
+			return nil, err
+		}
+		cff.fonts = []cffFontDictType{fd}
+	}
+	return cff, nil
+}
+
+// parsePrivate parses the Private DICT referred to by dict and the local
+// subroutines that follow it.
+func (fd *cffFontDictType) parsePrivate(data []byte, dict []cffDictEntryType) (err error) {
+	ops := cffOperands(dict, cffOpPrivate)
+	if len(ops) != 2 {
+		return nil
+	}
+	size, offset := cffInt(ops[0]), cffInt(ops[1])
+	if size < 0 || offset < 0 || offset+size > len(data) {
+		return errCFF
+	}
+	fd.private, err = cffParseDict(data[offset : offset+size])
+	if err != nil {
+		return
+	}
+	if ops = cffOperands(fd.private, cffOpSubrs); len(ops) == 1 {
+		start := offset + cffInt(ops[0])
+		var end int
+		_, end, err = cffIndex(data, start)
+		if err == nil {
+			fd.subrs = data[start:end]
+		}
+	}
+	return
+}
+
+// cffIndex returns the items of the INDEX that starts at pos in data and
+// the position that follows it.
+func cffIndex(data []byte, pos int) (items [][]byte, end int, err error) {
+	if pos < 0 || pos+2 > len(data) {
+		return nil, 0, errCFF
+	}
+	count := int(binary.BigEndian.Uint16(data[pos:]))
+	if count == 0 {
+		return nil, pos + 2, nil
+	}
+	if pos+3 > len(data) {
+		return nil, 0, errCFF
+	}
+	offSize := int(data[pos+2])
+	start := pos + 3
+	base := start + (count+1)*offSize - 1
+	if offSize < 1 || offSize > 4 || base >= len(data) {
+		return nil, 0, errCFF
+	}
+	offsets := make([]int, count+1)
+	for j := range offsets {
+		for k := 0; k < offSize; k++ {
+			offsets[j] = offsets[j]<<8 | int(data[start+j*offSize+k])
+		}
+		offsets[j] += base
+	}
+	end = offsets[count]
+	if end > len(data) {
+		return nil, 0, errCFF
+	}
+	items = make([][]byte, count)
+	for j := range items {
+		if offsets[j] < base+1 || offsets[j] > offsets[j+1] {
+			return nil, 0, errCFF
+		}
+		items[j] = data[offsets[j]:offsets[j+1]]
+	}
+	return
+}
+
+// cffIndexBytes returns an INDEX made of items
+func cffIndexBytes(items [][]byte) []byte {
+	if len(items) == 0 {
+		return []byte{0, 0}
+	}
+	size := 1
+	for _, item := range items {
+		size += len(item)
+	}
+	offSize := 1
+	for size>>(8*uint(offSize)) > 0 {
+		offSize++
+	}
+	b := []byte{byte(len(items) >> 8), byte(len(items)), byte(offSize)}
+	offset := 1
+	for j := 0; j <= len(items); j++ {
+		for k := offSize - 1; k >= 0; k-- {
+			b = append(b, byte(offset>>(8*uint(k))))
+		}
+		if j < len(items) {
+			offset += len(items[j])
+		}
+	}
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+// cffParseDict returns the entries of a DICT
+func cffParseDict(data []byte) (list []cffDictEntryType, err error) {
+	var operands [][]byte
+	for j := 0; j < len(data); {
+		var n int
+		switch b := data[j]; {
+		case b <= 21:
+			op := int(b)
+			j++
+			if b == 12 {
+				if j == len(data) {
+					return nil, errCFF
+				}
+				op = 1200 + int(data[j])
+				j++
+			}
+			list = append(list, cffDictEntryType{op, operands})
+			operands = nil
+			continue
+		case b == 28:
+			n = 3
+		case b == 29:
+			n = 5
+		case b == 30:
+			// Real number: nibbles up to one equal to 0xf
+			n = 1
+			for j+n < len(data) {
+				c := data[j+n]
+				n++
+				if c>>4 == 0xf || c&0xf == 0xf {
+					break
+				}
+			}
+		case b >= 32 && b <= 246:
+			n = 1
+		case b >= 247 && b <= 254:
+			n = 2
+		default:
+			return nil, errCFF
+		}
+		if j+n > len(data) {
+			return nil, errCFF
+		}
+		operands = append(operands, data[j:j+n])
+		j += n
+	}
+	return
+}
+
+// cffDictBytes returns the encoding of a DICT
+func cffDictBytes(list []cffDictEntryType) []byte {
+	var b []byte
+	for _, e := range list {
+		for _, operand := range e.operands {
+			b = append(b, operand...)
+		}
+		if e.op >= 1200 {
+			b = append(b, 12, byte(e.op-1200))
+		} else {
+			b = append(b, byte(e.op))
+		}
+	}
+	return b
+}
+
+// cffOperands returns the operands of op in list, or nil if it is absent
+func cffOperands(list []cffDictEntryType, op int) [][]byte {
+	for _, e := range list {
+		if e.op == op {
+			return e.operands
+		}
+	}
+	return nil
+}
+
+// cffInt returns the value of an integer operand; real numbers yield 0
+func cffInt(b []byte) int {
+	switch v := b[0]; {
+	case v == 28:
+		return int(int16(binary.BigEndian.Uint16(b[1:])))
+	case v == 29:
+		return int(int32(binary.BigEndian.Uint32(b[1:])))
+	case v >= 32 && v <= 246:
+		return int(v) - 139
+	case v >= 247 && v <= 250:
+		return (int(v)-247)*256 + int(b[1]) + 108
+	case v >= 251 && v <= 254:
+		return -(int(v)-251)*256 - int(b[1]) - 108
+	}
+	return 0
+}
+
+// cffIntBytes returns the five-byte encoding of an integer operand, whose
+// size does not depend on its value.
+func cffIntBytes(v int) []byte {
+	return []byte{29, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// cffFDSelect returns the Font DICT index of each of the n glyphs of a
+// CID-keyed font from the FDSelect structure at pos.
+func cffFDSelect(data []byte, pos, n, fontCount int) ([]int, error) {
+	if pos < 0 || pos >= len(data) {
+		return nil, errCFF
+	}
+	sel := make([]int, n)
+	switch data[pos] {
+	case 0:
+		if pos+1+n > len(data) {
+			return nil, errCFF
+		}
+		for j := range sel {
+			sel[j] = int(data[pos+1+j])
+		}
+	case 3:
+		if pos+3 > len(data) {
+			return nil, errCFF
+		}
+		count := int(binary.BigEndian.Uint16(data[pos+1:]))
+		p := pos + 3
+		if p+count*3+2 > len(data) {
+			return nil, errCFF
+		}
+		for r := 0; r < count; r++ {
+			first := int(binary.BigEndian.Uint16(data[p+r*3:]))
+			last := int(binary.BigEndian.Uint16(data[p+r*3+3:]))
+			for g := first; g < last && g < n; g++ {
+				sel[g] = int(data[p+r*3+2])
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported CFF FDSelect format %d", data[pos])
+	}
+	for _, fd := range sel {
+		if fd >= fontCount {
+			return nil, errCFF
+		}
+	}
+	return sel, nil
+}
+
+// subset returns a CID-keyed CFF font made of .notdef and the glyphs of
+// cidGlyphs, which maps CIDs to glyph IDs of the font. The glyphs are
+// numbered in the order of their CIDs: glyphs lists the original glyph ID
+// of each glyph of the subset and codes maps each CID to its glyph.
+func (cff *cffFontType) subset(cidGlyphs map[int]int) (data []byte, glyphs []int, codes map[int]int) {
+	glyphs = []int{0}
+	cids := []int{0}
+	codes = make(map[int]int)
+	for _, cid := range keySortInt(cidGlyphs) {
+		gid := cidGlyphs[cid]
+		if cid <= 0 || cid > 0xFFFF || gid < 0 || gid >= len(cff.charStrings) {
+			continue
+		}
+		codes[cid] = len(glyphs)
+		glyphs = append(glyphs, gid)
+		cids = append(cids, cid)
+	}
+	// Font DICTs used by the glyphs of the subset
+	fontIndex := make(map[int]int)
+	var fonts []int
+	fdSelect := []byte{0}
+	charStrings := make([][]byte, len(glyphs))
+	charset := []byte{0}
+	for j, gid := range glyphs {
+		fd := 0
+		if cff.fdSelect != nil {
+			fd = cff.fdSelect[gid]
+		}
+		if _, ok := fontIndex[fd]; !ok {
+			fontIndex[fd] = len(fonts)
+			fonts = append(fonts, fd)
+		}
+		fdSelect = append(fdSelect, byte(fontIndex[fd]))
+		charStrings[j] = cff.charStrings[gid]
+		if j > 0 {
+			charset = append(charset, byte(cids[j]>>8), byte(cids[j]))
+		}
+	}
+	charStringIndex := cffIndexBytes(charStrings)
+	// Private DICTs, each followed by its local subroutines
+	privates := make([][]byte, len(fonts))
+	privateSizes := make([]int, len(fonts))
+	for j, fd := range fonts {
+		var list []cffDictEntryType
+		for _, e := range cff.fonts[fd].private {
+			if e.op != cffOpSubrs {
+				list = append(list, e)
+			}
+		}
+		b := cffDictBytes(list)
+		if subrs := cff.fonts[fd].subrs; subrs != nil {
+			// Subrs is relative to the start of the Private DICT
+			b = append(append(b, cffIntBytes(len(b)+6)...), cffOpSubrs)
+			privateSizes[j] = len(b)
+			b = append(b, subrs...)
+		} else {
+			privateSizes[j] = len(b)
+		}
+		privates[j] = b
+	}
+	fontDictIndex := func(offset int) []byte {
+		list := make([][]byte, len(fonts))
+		for j, fd := range fonts {
+			var dict []cffDictEntryType
+			for _, e := range cff.fonts[fd].fontDict {
+				if e.op != cffOpPrivate && e.op != cffOpFontName {
+					dict = append(dict, e)
+				}
+			}
+			dict = append(dict, cffDictEntryType{cffOpPrivate,
+				[][]byte{cffIntBytes(privateSizes[j]), cffIntBytes(offset)}})
+			list[j] = cffDictBytes(dict)
+			offset += len(privates[j])
+		}
+		return cffIndexBytes(list)
+	}
+	topIndex := func(charsetOffset, charStringsOffset, fdArrayOffset, fdSelectOffset int) []byte {
+		dict := []cffDictEntryType{
+			{cffOpROS, [][]byte{cffIntBytes(cffStandardStrings), cffIntBytes(cffStandardStrings + 1), cffIntBytes(0)}},
+			{cffOpCIDCount, [][]byte{cffIntBytes(cids[len(cids)-1] + 1)}},
+		}
+		for _, op := range []int{cffOpFontBBox, cffOpFontMatrix} {
+			if ops := cffOperands(cff.top, op); ops != nil {
+				dict = append(dict, cffDictEntryType{op, ops})
+			}
+		}
+		dict = append(dict,
+			cffDictEntryType{cffOpCharset, [][]byte{cffIntBytes(charsetOffset)}},
+			cffDictEntryType{cffOpCharStrings, [][]byte{cffIntBytes(charStringsOffset)}},
+			cffDictEntryType{cffOpFDArray, [][]byte{cffIntBytes(fdArrayOffset)}},
+			cffDictEntryType{cffOpFDSelect, [][]byte{cffIntBytes(fdSelectOffset)}})
+		return cffIndexBytes([][]byte{cffDictBytes(dict)})
+	}
+	nameIndex := cffIndexBytes([][]byte{cff.name})
+	stringIndex := cffIndexBytes([][]byte{[]byte("Adobe"), []byte("Identity")})
+	// Every offset has a fixed size, so the layout can be computed before
+	// the offsets are known
+	charsetOffset := 4 + len(nameIndex) + len(topIndex(0, 0, 0, 0)) + len(stringIndex) + len(cff.gsubrs)
+	fdSelectOffset := charsetOffset + len(charset)
+	charStringsOffset := fdSelectOffset + len(fdSelect)
+	fdArrayOffset := charStringsOffset + len(charStringIndex)
+	privateOffset := fdArrayOffset + len(fontDictIndex(0))
+
+	data = []byte{1, 0, 4, 4}
+	data = append(data, nameIndex...)
+	data = append(data, topIndex(charsetOffset, charStringsOffset, fdArrayOffset, fdSelectOffset)...)
+	data = append(data, stringIndex...)
+	data = append(data, cff.gsubrs...)
+	data = append(data, charset...)
+	data = append(data, fdSelect...)
+	data = append(data, charStringIndex...)
+	data = append(data, fontDictIndex(privateOffset)...)
+	for _, b := range privates {
+		data = append(data, b...)
+	}
+	return
+}
+
+// generateCutCFF returns an OpenType font with the CFF outlines of the
+// glyphs of usedRunes and of the CIDs allocated by text shaping, numbered
+// as in the embedded CFF font program.
+func (utf *utf8FontFile) generateCutCFF(usedRunes map[int]int, metricsCount int) []byte {
+	cff, err := parseCFF(utf.getTableData("CFF "))
+	if err != nil {
+		return nil
+	}
+	cffData, glyphs, codes := cff.subset(utf.usedSymbols(usedRunes))
+	utf.CodeSymbolDictionary = codes
+
+	utf.setOutTable("CFF ", cffData)
+	utf.setOutTable("name", utf.getTableData("name"))
+	utf.setOutTable("OS/2", utf.getTableData("OS/2"))
+	postTable := utf.getTableData("post")
+	postTable = append(append([]byte{0x00, 0x03, 0x00, 0x00}, postTable[4:16]...), make([]byte, 16)...)
+	utf.setOutTable("post", postTable)
+
+	cmapCollection := make(map[int]int)
+	for cid, symbol := range codes {
+		if _, OK := utf.shapedGlyphs[cid]; !OK {
+			cmapCollection[cid] = symbol
+		}
+	}
+	utf.setOutTable("cmap", utf.generateCMAPTable(cmapCollection, len(glyphs)))
+
+	hmtxData := make([]byte, 0)
+	for _, gid := range glyphs {
+		hmtxData = append(hmtxData, utf.getMetrics(metricsCount, gid)...)
+	}
+	utf.setOutTable("hmtx", hmtxData)
+	// Tables are copied so that the font file itself is left unchanged
+	utf.setOutTable("head", append([]byte(nil), utf.getTableData("head")...))
+	utf.setOutTable("hhea", utf.insertUint16(append([]byte(nil), utf.getTableData("hhea")...), 34, len(glyphs)))
+	utf.setOutTable("maxp", utf.insertUint16(append([]byte(nil), utf.getTableData("maxp")...), 4, len(glyphs)))
+	return utf.assembleTables()
+}
@@ -0,0 +1,85 @@
+package gofpdf
+
+// columnsType holds the layout set with SetColumns().
+type columnsType struct {
+	n                int     // number of columns, 0 if text is not laid out in columns
+	gutter           float64 // space between columns
+	current          int     // index of the current column
+	top              float64 // ordinate of the top of the columns on the current page
+	lMargin, rMargin float64 // margins of the page
+}
+
+// SetColumns lays out following text in n columns of equal width, separated
+// by gutter in the unit of measure specified in New(), between the left and
+// right margins of the page. The columns start at the current ordinate and,
+// on following pages, below the header.
+//
+// The left and right margins are those of the current column, so that
+// CellFormat() with a width of 0, MultiCell(), Write() and the methods of
+// HTMLBasicType fill the column. When text reaches the page break trigger
+// (see SetAutoPageBreak()), it continues at the top of the next column. In
+// the last column, AcceptPageBreak() is called as usual and text continues
+// in the first column of the new page. The page margins are used while the
+// header and footer are output.
+//
+// Call SetColumns() with n less than 2 to restore the page margins and end
+// the column layout; the current position moves to the left margin.
+func (f *Fpdf) SetColumns(n int, gutter float64) {
+	if f.columns.n > 1 {
+		f.lMargin, f.rMargin = f.columns.lMargin, f.columns.rMargin
+	}
+	if n < 2 {
+		f.columns = columnsType{}
+		f.x = f.lMargin
+		return
+	}
+	f.columns = columnsType{n: n, gutter: gutter, top: f.y, lMargin: f.lMargin, rMargin: f.rMargin}
+	f.setColumn(0)
+	f.x = f.lMargin
+}
+
+// GetColumn returns the index, starting at 0, of the column in which text is
+// currently laid out. See SetColumns().
+func (f *Fpdf) GetColumn() int {
+	return f.columns.current
+}
+
+// setColumn sets the margins of column col.
+func (f *Fpdf) setColumn(col int) {
+	c := &f.columns
+	w := (f.w - c.lMargin - c.rMargin - c.gutter*float64(c.n-1)) / float64(c.n)
+	c.current = col
+	f.lMargin = c.lMargin + float64(col)*(w+c.gutter)
+	f.rMargin = f.w - f.lMargin - w
+}
+
+// nextColumn moves to the top of the next column, at the same distance from
+// its left margin, and returns true unless the current column is the last
+// one or text is not laid out in columns.
+func (f *Fpdf) nextColumn() bool {
+	c := &f.columns
+	if c.n < 2 || !f.autoPageBreak || c.current >= c.n-1 {
+		return false
+	}
+	dx := f.x - f.lMargin
+	f.restoreBreakTrigger()
+	f.setColumn(c.current + 1)
+	f.x, f.y = f.lMargin+dx, c.top
+	return true
+}
+
+// columnsPageMargins restores the page margins for the footer and header.
+func (f *Fpdf) columnsPageMargins() {
+	if f.columns.n > 1 {
+		f.lMargin, f.rMargin = f.columns.lMargin, f.columns.rMargin
+	}
+}
+
+// columnsNewPage starts the columns of a new page at the current ordinate.
+func (f *Fpdf) columnsNewPage() {
+	if f.columns.n > 1 {
+		f.columns.top = f.y
+		f.setColumn(0)
+		f.x = f.lMargin
+	}
+}
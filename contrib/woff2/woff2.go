@@ -0,0 +1,24 @@
+// Package woff2 allows fonts in the WOFF2 format to be used in documents
+// generated with gofpdf. It relies on the github.com/andybalholm/brotli
+// package to decompress the font data, which keeps gofpdf itself free of
+// dependencies outside the Go standard library.
+package woff2
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/jbuchbinder/gofpdf"
+)
+
+// Decode returns a reader of the Brotli-decompressed data read from r. It can
+// be passed to Fpdf.SetWOFF2Decoder().
+func Decode(r io.Reader) io.Reader {
+	return brotli.NewReader(r)
+}
+
+// Register sets the WOFF2 decoder of pdf so that WOFF2 fonts can be added
+// with AddUTF8Font() and its variants.
+func Register(pdf *gofpdf.Fpdf) {
+	pdf.SetWOFF2Decoder(Decode)
+}
@@ -0,0 +1,83 @@
+package woff2_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/jbuchbinder/gofpdf"
+	"github.com/jbuchbinder/gofpdf/contrib/woff2"
+	"github.com/jbuchbinder/gofpdf/internal/example"
+)
+
+// woff2Font wraps the tables of a TrueType font in a WOFF2 file with
+// untransformed tables.
+func woff2Font(t *testing.T, fileStr string) []byte {
+	data, err := ioutil.ReadFile(example.FontFile(fileStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	be := binary.BigEndian
+	var dir, stream, buf bytes.Buffer
+	w := brotli.NewWriter(&stream)
+	count := int(be.Uint16(data[4:]))
+	for j := 0; j < count; j++ {
+		rec := data[12+16*j:]
+		tag := string(rec[:4])
+		table := data[be.Uint32(rec[8:]) : be.Uint32(rec[8:])+be.Uint32(rec[12:])]
+		// Transform version 3 is the null transform of glyf and loca, 0
+		// that of the other tables
+		if tag == "glyf" || tag == "loca" {
+			dir.WriteByte(0xff)
+		} else {
+			dir.WriteByte(0x3f)
+		}
+		dir.WriteString(tag)
+		n := len(table)
+		var b []byte
+		for b = []byte{byte(n & 0x7f)}; n > 0x7f; b = append([]byte{byte(n&0x7f) | 0x80}, b...) {
+			n >>= 7
+		}
+		dir.Write(b)
+		w.Write(table)
+	}
+	w.Close()
+	buf.WriteString("wOF2")
+	binary.Write(&buf, be, []uint32{0x00010000, 0})
+	binary.Write(&buf, be, []uint16{uint16(count), 0})
+	binary.Write(&buf, be, []uint32{0, uint32(stream.Len()), 0, 0, 0, 0, 0, 0})
+	buf.Write(dir.Bytes())
+	buf.Write(stream.Bytes())
+	return buf.Bytes()
+}
+
+// TestRegister checks that a WOFF2 font loads once the decoder is registered
+// and has the metrics of the original font.
+func TestRegister(t *testing.T) {
+	font := woff2Font(t, "DejaVuSansCondensed.ttf")
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes("woff2", "", font)
+	if !pdf.Err() {
+		t.Fatalf("WOFF2 font loaded without a decoder")
+	}
+
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	woff2.Register(pdf)
+	pdf.SetFontLocation(example.FontDir())
+	pdf.AddUTF8Font("dejavu", "", "DejaVuSansCondensed.ttf")
+	pdf.AddUTF8FontFromBytes("woff2", "", font)
+	pdf.AddPage()
+	str := "Grüße, ABC xyz"
+	pdf.SetFont("dejavu", "", 14)
+	ref := pdf.GetStringWidth(str)
+	pdf.SetFont("woff2", "", 14)
+	pdf.Cell(0, 8, str)
+	if wd := pdf.GetStringWidth(str); wd != ref {
+		t.Fatalf("width %.3f, expecting %.3f", wd, ref)
+	}
+	if err := pdf.Output(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -273,6 +273,10 @@ type linkType struct {
 	x, y, wd, ht float64
 	link         int    // Auto-generated internal link ID or...
 	linkStr      string // ...application-provided external link string
+	contentsStr  string // description of the link in a tagged document
+	structElem   int    // index of the Link structure element, 0 if not tagged
+	structKey    int    // key in the structure parent tree
+	objNum       int    // object number of a tagged link annotation
 }
 
 type intLinkType struct {
@@ -299,6 +303,7 @@ type InitType struct {
 	SizeStr        string
 	Size           SizeType
 	FontDirStr     string
+	ConformanceStr string // optional archival conformance level, see SetConformance()
 }
 
 // FontLoader is used to read fonts (JSON font specification and zlib compressed font binaries)
@@ -310,6 +315,16 @@ type FontLoader interface {
 	Open(name string) (io.Reader, error)
 }
 
+// HyphenationLoader is used to read hyphenation patterns from arbitrary
+// locations (e.g. files, zip files, embedded resources).
+//
+// Open provides an io.Reader for the patterns of the specified language, as
+// passed to SetHyphenation(). Open returns an error if the patterns cannot be
+// opened.
+type HyphenationLoader interface {
+	Open(lang string) (io.Reader, error)
+}
+
 // Pdf defines the interface used for various methods. It is implemented by the
 // main FPDF instance as well as templates.
 type Pdf interface {
@@ -500,6 +515,7 @@ type PageBox struct {
 type Fpdf struct {
 	isCurrentUTF8    bool                       // is current font used in utf-8 mode
 	isRTL            bool                       // is is right to left mode enabled
+	bidiPara         int                        // direction of the paragraph being written: 0 none, 1 left to right, 2 right to left
 	page             int                        // current page number
 	n                int                        // current object number
 	offsets          []int                      // array of object offsets
@@ -535,6 +551,7 @@ type Fpdf struct {
 	lineWidth        float64                    // line width in user unit
 	fontpath         string                     // path containing fonts
 	fontLoader       FontLoader                 // used to load font files from arbitrary locations
+	woff2Decoder     func(io.Reader) io.Reader  // decompresses the Brotli stream of WOFF2 fonts, nil if WOFF2 is not supported
 	coreFonts        map[string]bool            // array of core font names
 	fonts            map[string]fontDefType     // array of used fonts
 	fontFiles        map[string]fontFileType    // array of font files
@@ -567,6 +584,7 @@ type Fpdf struct {
 	zoomMode         string                     // zoom display mode
 	layoutMode       string                     // layout display mode
 	xmp              []byte                     // XMP metadata
+	nXmp             int                        // XMP metadata object number
 	producer         string                     // producer
 	title            string                     // title
 	subject          string                     // subject
@@ -592,8 +610,33 @@ type Fpdf struct {
 	err              error                      // Set if error occurs during life cycle of instance
 	protect          protectType                // document protection structure
 	layer            layerRecType               // manages optional layers in document
+	form             formRecType                // interactive form fields
+	signature        *signatureRecType          // digital signature, nil if document is not signed
+	pdfa             pdfaRecType                // PDF/A conformance
+	tag              tagRecType                 // logical structure of a tagged document
 	catalogSort      bool                       // sort resource catalogs in document
+	kerning          bool                       // apply kerning to text in UTF-8 fonts
+	shaping          bool                       // apply OpenType shaping to text in UTF-8 fonts
+	fontFallbacks    map[string][]string        // fallback font families by font family
+	verticalFonts    map[string]int             // object numbers of the Identity-V fonts of fonts used for vertical text, by font key
+	hyphenLoader     HyphenationLoader          // used to load hyphenation patterns
+	hyphenators      map[string]*hyphenatorType // hyphenation patterns by language
+	hyphenator       *hyphenatorType            // hyphenation patterns of the current language, nil if hyphenation is off
+	hyphenLang       string                     // current hyphenation language
+	charSpacing      float64                    // character spacing in user units
+	hScaling         float64                    // horizontal scaling of text in percent
+	textRise         float64                    // text rise in user units
+	justifyWord      float64                    // maximum word spacing before characters are spread apart in justified UTF-8 text
+	justifyChar      float64                    // maximum spacing between characters in justified UTF-8 text
+	columns          columnsType                // column layout of text
+	toc              tocRecType                 // table of contents
+	footnotes        footnoteRecType            // footnotes of the current page
+	widowLines       int                        // minimum number of lines of MultiCell() text at the top of a page
+	orphanLines      int                        // minimum number of lines of MultiCell() text at the bottom of a page
+	breakDelta       float64                    // distance by which the page break trigger is raised until the next page break
+	exclusions       []exclusionType            // areas of the current page that text flows around
 	nJs              int                        // JavaScript object number
+	nFirstPage       int                        // object number of the first page
 	javascript       *string                    // JavaScript code to include in the PDF
 	colorFlag        bool                       // indicates whether fill and text colors are different
 	color            struct {
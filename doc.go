@@ -170,8 +170,14 @@ helvetica, times, zapfdingbats) in your documents other than calling
 SetFont().
 
 You should use AddUTF8Font() or AddUTF8FontFromBytes() to add a TrueType
-UTF-8 encoded font. Use RTL() and LTR() methods switch between
-“right-to-left” and “left-to-right” mode.
+UTF-8 encoded font; OpenType fonts with CFF outlines (.otf) work as well.
+WOFF web fonts are decoded when loaded, as are WOFF2 ones once the Brotli
+decoder of the contrib/woff2 package is registered, and AddUTF8FontFace()
+selects a font of a TrueType collection (.ttc). Text that mixes left-to-right
+and right-to-left scripts is laid out with the Unicode Bidirectional
+Algorithm; the direction of each paragraph is that of its first letter. Use
+RTL() to give all paragraphs a “right-to-left” base direction and LTR() to
+return to the default.
 
 In order to use a different non-UTF-8 TrueType or Type1 font, you will
 need to generate a font definition file and, if the font will be
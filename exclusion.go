@@ -0,0 +1,84 @@
+package gofpdf
+
+import (
+	"math"
+)
+
+// exclusionType is an area of the current page that text flows around.
+type exclusionType struct {
+	x, y, w, h float64
+}
+
+// AddExclusion adds a rectangular area of the current page that text flows
+// around. Lines of text output with MultiCell(), Write() and the methods of
+// HTMLBasicType that intersect the area are shortened so that they fit in
+// the widest space left on their side of the area; text output with Write()
+// continues on the current line in the space that follows the current
+// position. Lines that would be narrower than three times the font size
+// move below the area. x, y, w and h are in the unit of measure specified in
+// New(). The areas are removed when a new page is added, or with
+// ClearExclusions().
+//
+// See ImageOptions() for images that text flows around.
+func (f *Fpdf) AddExclusion(x, y, w, h float64) {
+	if w > 0 && h > 0 {
+		f.exclusions = append(f.exclusions, exclusionType{x, y, w, h})
+	}
+}
+
+// ClearExclusions removes the areas added with AddExclusion().
+func (f *Fpdf) ClearExclusions() {
+	f.exclusions = nil
+}
+
+// lineSpace returns the abscissa and the width of the space that the
+// exclusion areas leave between x0 and x1 for a line of height h at the
+// current ordinate. If cont is true, the line continues text that ends at x0,
+// and the first space that starts at or after x0 is returned. Otherwise, the
+// widest space is returned, and if it is too narrow the current ordinate is
+// moved below the areas that intersect the line.
+func (f *Fpdf) lineSpace(x0, x1, h float64, cont bool) (x, w float64) {
+	for len(f.exclusions) > 0 && f.y+h <= f.pageBreakTrigger {
+		// Free spaces between the areas that intersect the line
+		spaces := [][2]float64{{x0, x1}}
+		below := 0.0
+		for _, ex := range f.exclusions {
+			if ex.y >= f.y+h || ex.y+ex.h <= f.y || ex.x >= x1 || ex.x+ex.w <= x0 {
+				continue
+			}
+			if below == 0 || ex.y+ex.h < below {
+				below = ex.y + ex.h
+			}
+			var list [][2]float64
+			for _, sp := range spaces {
+				if ex.x > sp[0] {
+					list = append(list, [2]float64{sp[0], math.Min(ex.x, sp[1])})
+				}
+				if ex.x+ex.w < sp[1] {
+					list = append(list, [2]float64{math.Max(ex.x+ex.w, sp[0]), sp[1]})
+				}
+			}
+			spaces = list
+		}
+		if below == 0 {
+			break
+		}
+		if cont {
+			if len(spaces) > 0 {
+				return spaces[0][0], spaces[0][1] - spaces[0][0]
+			}
+			return x0, 0
+		}
+		best := -1
+		for j, sp := range spaces {
+			if best < 0 || sp[1]-sp[0] > spaces[best][1]-spaces[best][0] {
+				best = j
+			}
+		}
+		if best >= 0 && spaces[best][1]-spaces[best][0] >= math.Min(3*f.fontSize, x1-x0) {
+			return spaces[best][0], spaces[best][1] - spaces[best][0]
+		}
+		f.y = below
+	}
+	return x0, x1 - x0
+}
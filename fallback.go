@@ -0,0 +1,209 @@
+package gofpdf
+
+import "unicode"
+
+// SetFontFallback sets the font families that supply the characters missing
+// from the fonts of family familyStr. When text is shown in one of these
+// fonts, each character that the font lacks is shown in the first fallback
+// font that has it, in the current style if that font is available in it
+// and otherwise in the regular style. Text is split into runs of characters
+// shown in the same font as needed. Fallbacks are taken into account by
+// Cell(), MultiCell(), Write(), Text(), GetStringWidth() and the methods that
+// split text into lines.
+//
+// Fallbacks apply to fonts added with AddUTF8Font() and related methods;
+// fallback families that are not such fonts are ignored. They need not be
+// added before this method is called. Calling SetFontFallback() without
+// fallbacks removes those of familyStr.
+func (f *Fpdf) SetFontFallback(familyStr string, fallbacks ...string) {
+	familyStr = fontFamilyEscape(familyStr)
+	if f.fontFallbacks == nil {
+		f.fontFallbacks = make(map[string][]string)
+	}
+	if len(fallbacks) == 0 {
+		delete(f.fontFallbacks, getFontKey(familyStr, ""))
+		return
+	}
+	list := make([]string, len(fallbacks))
+	for j, fallback := range fallbacks {
+		list[j] = fontFamilyEscape(fallback)
+	}
+	f.fontFallbacks[getFontKey(familyStr, "")] = list
+}
+
+// fallbackFonts returns the fallback fonts of the current font.
+func (f *Fpdf) fallbackFonts() (list []fontDefType) {
+	if !f.isCurrentUTF8 {
+		return
+	}
+	for _, familyStr := range f.fontFallbacks[f.fontFamily] {
+		font, ok := f.fonts[getFontKey(familyStr, f.fontStyle)]
+		if !ok {
+			font, ok = f.fonts[getFontKey(familyStr, "")]
+		}
+		if ok && font.Tp == "UTF8" && font.Name != f.currentFont.Name {
+			list = append(list, font)
+		}
+	}
+	return
+}
+
+// fontHasRune returns true if font has a glyph for r.
+func fontHasRune(font fontDefType, r rune) bool {
+	return r > 0 && int(r) < len(font.Cw) && font.Cw[r] != 0
+}
+
+// fallbackRunType is a run of text shown in one font
+type fallbackRunType struct {
+	text []rune
+	font fontDefType
+}
+
+// fallbackRuns splits txt into runs of characters shown in the current font
+// and in its fallback fonts. It returns nil if all of txt is shown in the
+// current font. A combining mark stays in the font of the character it
+// follows if that font has it.
+func (f *Fpdf) fallbackRuns(txt []rune) []fallbackRunType {
+	fonts := f.fallbackFonts()
+	if len(fonts) == 0 {
+		return nil
+	}
+	var runs []fallbackRunType
+	found := false
+	for j, r := range txt {
+		font := f.currentFont
+		switch {
+		case fontHasRune(font, r):
+		case j > 0 && unicode.Is(unicode.M, r) && fontHasRune(runs[len(runs)-1].font, r):
+			font = runs[len(runs)-1].font
+		default:
+			for _, fallback := range fonts {
+				if fontHasRune(fallback, r) {
+					font = fallback
+					found = true
+					break
+				}
+			}
+		}
+		if len(runs) > 0 && runs[len(runs)-1].font.Name == font.Name {
+			runs[len(runs)-1].text = append(runs[len(runs)-1].text, r)
+		} else {
+			runs = append(runs, fallbackRunType{text: []rune{r}, font: font})
+		}
+	}
+	if !found {
+		return nil
+	}
+	return runs
+}
+
+// withFont calls fn with font as the current font.
+func (f *Fpdf) withFont(font fontDefType, fn func()) {
+	current := f.currentFont
+	f.currentFont = font
+	fn()
+	f.currentFont = current
+}
+
+// textAdvances returns the widths of the characters of txt in thousandths
+// of the font size, including kerning, if text is shaped or shown in
+// fallback fonts, and nil otherwise.
+func (f *Fpdf) textAdvances(txt []rune) []int {
+	runs := f.fallbackRuns(txt)
+	if runs == nil {
+		return f.shapeAdvances(txt)
+	}
+	list := make([]int, 0, len(txt))
+	for _, run := range runs {
+		f.withFont(run.font, func() {
+			if adv := f.shapeAdvances(run.text); adv != nil {
+				list = append(list, adv...)
+				return
+			}
+			cw := f.currentFont.Cw
+			for j, r := range run.text {
+				w := 0
+				if j > 0 {
+					w = f.kern(run.text[j-1], r)
+				}
+				switch {
+				case fontHasRune(f.currentFont, r):
+					if cw[r] != 65535 {
+						w += cw[r]
+					}
+				case f.currentFont.Desc.MissingWidth != 0:
+					w += f.currentFont.Desc.MissingWidth
+				default:
+					w += 500
+				}
+				list = append(list, w)
+			}
+		})
+	}
+	return list
+}
+
+// textTJActive returns true if txt is shown with a TJ array, because it is
+// kerned, shaped or shown in fallback fonts.
+func (f *Fpdf) textTJActive(txt string) bool {
+	return f.kernActive() || f.shapeActive() || f.fallbackRuns([]rune(txt)) != nil
+}
+
+// fallbackTJ returns the elements of a TJ array that shows a line of text,
+// given in logical order, in the current font and its fallback fonts. The
+// font is switched between the TJ arrays of runs in different fonts and
+// restored at the end.
+func (f *Fpdf) fallbackTJ(txt string, wordSpacing, charSpacing float64) string {
+	var s fmtBuffer
+	current := f.currentFont
+	font := current
+	first := true
+	for _, run := range f.bidiRuns(txt) {
+		runs := f.fallbackRuns(run.text)
+		if runs == nil {
+			runs = []fallbackRunType{{text: run.text, font: current}}
+		}
+		if run.rtl {
+			for j := 0; j < len(runs)/2; j++ {
+				runs[j], runs[len(runs)-1-j] = runs[len(runs)-1-j], runs[j]
+			}
+		}
+		for _, fr := range runs {
+			if fr.font.Name != font.Name {
+				font = fr.font
+				s.printf("] TJ /F%s %.2f Tf [", font.i, f.fontSizePt)
+			}
+			visual := fr.text
+			if run.rtl {
+				visual = make([]rune, len(fr.text))
+				for j, r := range fr.text {
+					visual[len(visual)-1-j] = r
+				}
+			}
+			if !first {
+				adj := charSpacing
+				if visual[0] == ' ' {
+					adj += wordSpacing
+				}
+				if adj != 0 {
+					s.printf(" %.3f ", -adj)
+				}
+			}
+			first = false
+			f.withFont(font, func() {
+				if f.shapeActive() {
+					s.printf("%s", f.shapeTJ([]bidiRunType{{text: fr.text, rtl: run.rtl}}, wordSpacing, charSpacing))
+					return
+				}
+				for _, r := range visual {
+					f.currentFont.usedRunes[int(r)] = int(r)
+				}
+				s.printf("%s", f.kernTJ(visual, wordSpacing, charSpacing))
+			})
+		}
+	}
+	if font.Name != current.Name {
+		s.printf("] TJ /F%s %.2f Tf [", current.i, f.fontSizePt)
+	}
+	return s.String()
+}
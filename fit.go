@@ -0,0 +1,173 @@
+package gofpdf
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// FitNone indicates that the text fits in the box at the current font
+	// size
+	FitNone = iota
+	// FitShrink indicates that the font size was reduced so that the text
+	// fits in the box
+	FitShrink
+	// FitTruncate indicates that the text was truncated and ends with an
+	// ellipsis
+	FitTruncate
+)
+
+// FitOptions specifies how text is fitted into a box by FitText() and
+// MultiCellFit().
+type FitOptions struct {
+	MinFontSize float64 // smallest font size in points to which the text may be reduced, or 0 to keep the current font size
+	MaxLines    int     // maximum number of lines, or 0 for as many lines as the height of the box allows
+	LineHt      float64 // height of the lines as a multiple of their font size, or 0 for 1.25
+	Ellipsis    string  // text that ends truncated text, or empty for "..."
+}
+
+// FitText breaks txtStr into lines that fit in a box of width w and height h,
+// in the unit of measure specified in New(), using the current font. Lines
+// are broken as by SplitLines(), which accounts for the cell margin (see
+// SetCellMargin()). The font size and the current position are not changed.
+//
+// If the lines are too many for the box, or more than opts.MaxLines, the font
+// size is reduced in steps of half a point, down to opts.MinFontSize, until
+// they fit. If the text still does not fit, the lines that fit at the
+// smallest size are kept and the last of them ends with opts.Ellipsis.
+//
+// The lines are returned with the font size in points at which they fit and
+// the strategy that was applied: FitNone, FitShrink or FitTruncate.
+func (f *Fpdf) FitText(w, h float64, txtStr string, opts FitOptions) (lines []string, sizePt float64, strategy int) {
+	if f.err != nil {
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.SetErrorf("font has not been set; unable to fit text")
+		return
+	}
+	if w == 0 {
+		w = f.w - f.rMargin - f.x
+	}
+	factor := opts.LineHt
+	if factor <= 0 {
+		factor = 1.25
+	}
+	origPt, orig := f.fontSizePt, f.fontSize
+	defer func() {
+		f.fontSizePt, f.fontSize = origPt, orig
+	}()
+	split := func(size float64) (list []string, n int) {
+		// The font size is set without output while the text is measured
+		f.fontSizePt, f.fontSize = size, size/f.k
+		for _, line := range f.SplitLines([]byte(txtStr), w) {
+			list = append(list, string(line))
+		}
+		n = int(h/(f.fontSize*factor) + 1e-9)
+		if opts.MaxLines > 0 && opts.MaxLines < n {
+			n = opts.MaxLines
+		}
+		return
+	}
+	sizePt = origPt
+	lines, n := split(sizePt)
+	if len(lines) <= n {
+		return
+	}
+	for opts.MinFontSize > 0 && sizePt > opts.MinFontSize {
+		sizePt = math.Max(sizePt-0.5, opts.MinFontSize)
+		lines, n = split(sizePt)
+		if len(lines) <= n {
+			strategy = FitShrink
+			return
+		}
+	}
+	strategy = FitTruncate
+	if n < 1 {
+		n = 1
+	}
+	lines = lines[:n]
+	lines[n-1] = f.fitEllipsis(lines[n-1], w, opts.Ellipsis)
+	return
+}
+
+// fitEllipsis removes characters from the end of line until it fits in a cell
+// of width w followed by ellipsis, and appends ellipsis.
+func (f *Fpdf) fitEllipsis(line string, w float64, ellipsis string) string {
+	if ellipsis == "" {
+		ellipsis = "..."
+	}
+	wmax := w - 2*f.cMargin
+	if f.isCurrentUTF8 {
+		s := []rune(line)
+		for len(s) > 0 && f.GetStringWidth(string(s)+ellipsis) > wmax {
+			s = s[:len(s)-1]
+		}
+		line = string(s)
+	} else {
+		for len(line) > 0 && f.GetStringWidth(line+ellipsis) > wmax {
+			line = line[:len(line)-1]
+		}
+	}
+	return strings.TrimRight(line, " \t") + ellipsis
+}
+
+// MultiCellFit outputs txtStr in a box of width w and height h at the
+// current position, fitted into the box with FitText(). A width of zero
+// indicates a box that reaches to the right margin.
+//
+// borderStr and fill apply to the box as for CellFormat(). alignStr is "L",
+// "C" or "R" for the horizontal alignment of the lines, followed by "T", "M"
+// or "B" for their vertical alignment in the box; the default is "LT". If the
+// box does not fit above the page break trigger, a page break occurs first as
+// for CellFormat(). The current position after the call is the left margin,
+// below the box.
+//
+// The font size at which the text is output and the strategy that was applied
+// (FitNone, FitShrink or FitTruncate) are returned; the current font size is
+// not changed. The MultiCellFit() example demonstrates this method.
+func (f *Fpdf) MultiCellFit(w, h float64, txtStr, borderStr, alignStr string, fill bool, opts FitOptions) (sizePt float64, strategy int) {
+	if f.err != nil {
+		return
+	}
+	if w == 0 {
+		w = f.w - f.rMargin - f.x
+	}
+	// The box itself handles the page break
+	f.CellFormat(w, h, "", borderStr, 0, "", fill, 0, "")
+	if f.err != nil {
+		return
+	}
+	x, y := f.x-w, f.y
+	var lines []string
+	lines, sizePt, strategy = f.FitText(w, h, txtStr, opts)
+	if f.err != nil {
+		return
+	}
+	factor := opts.LineHt
+	if factor <= 0 {
+		factor = 1.25
+	}
+	origPt := f.fontSizePt
+	f.SetFontSize(sizePt)
+	lineHt := f.fontSize * factor
+	textHt := float64(len(lines)) * lineHt
+	ty := y
+	switch {
+	case strings.Contains(alignStr, "M"):
+		ty = y + (h-textHt)/2
+	case strings.Contains(alignStr, "B"):
+		ty = y + h - textHt
+	}
+	alignStr = strings.NewReplacer("T", "", "M", "", "B", "").Replace(alignStr)
+	autoBreak := f.autoPageBreak
+	f.autoPageBreak = false
+	for j, line := range lines {
+		f.SetXY(x, ty+float64(j)*lineHt)
+		f.CellFormat(w, lineHt, line, "", 0, alignStr, false, 0, "")
+	}
+	f.autoPageBreak = autoBreak
+	f.SetFontSize(origPt)
+	f.SetXY(f.lMargin, y+h)
+	return
+}
@@ -0,0 +1,361 @@
+package gofpdf
+
+import (
+	"strconv"
+)
+
+const (
+	// FootnoteNumberDocument numbers footnotes throughout the document, or
+	// until ResetFootnoteNumber() is called
+	FootnoteNumberDocument = iota
+	// FootnoteNumberPage restarts the numbering of footnotes on each page
+	FootnoteNumberPage
+)
+
+// FootnoteOptions specifies the appearance and the numbering of footnotes.
+// See SetFootnoteOptions().
+type FootnoteOptions struct {
+	FontFamily string  // font family of the notes, or empty for the font family that is current when the first note is added
+	FontSize   float64 // font size in points of the notes, or 0 for 80 percent of the font size that is current when the first note is added
+	LineHt     float64 // height of the lines of the notes, or 0 for 1.25 times their font size
+	Numbering  int     // FootnoteNumberDocument or FootnoteNumberPage
+}
+
+type footnoteLineType struct {
+	num    string // number of the note on its first line, empty on the other lines
+	text   string
+	indent float64 // distance between the number and the text of the note
+}
+
+// footnoteRecType holds the footnotes of the current page and the endnotes
+// that have not been output yet.
+type footnoteRecType struct {
+	opts     FootnoteOptions
+	number   int                // number of the last note
+	page     int                // page of the last note
+	lines    []footnoteLineType // lines of the notes of the current page
+	carry    []footnoteLineType // lines of notes carried over to the next page
+	area     float64            // height reserved for the notes on the current page
+	endnotes []string           // text of the endnotes to be output by PutEndnotes()
+}
+
+// SetFootnoteOptions sets the font and the numbering of the footnotes added
+// with Footnote() and AddFootnote(). The font applies to the endnotes output
+// by PutEndnotes() as well. It should be called before the first note is
+// added.
+func (f *Fpdf) SetFootnoteOptions(opts FootnoteOptions) {
+	f.footnotes.opts = opts
+}
+
+// ResetFootnoteNumber restarts the numbering of footnotes, so that the next
+// note is number 1. Call it at the start of a section to number the notes of
+// each section separately.
+func (f *Fpdf) ResetFootnoteNumber() {
+	f.footnotes.number = 0
+}
+
+// Footnote writes the superscript number of a new footnote at the current
+// position, in the same way as SubWrite(), and adds the note to the page on
+// which the number is written. ht is the line height of the text in which
+// the number is written, as for Write(). See AddFootnote().
+//
+// The Footnote() example demonstrates this method.
+func (f *Fpdf) Footnote(ht float64, txtStr string) {
+	if f.err != nil {
+		return
+	}
+	num := f.footnoteNumber()
+	f.SubWrite(ht, strconv.Itoa(num), f.fontSizePt*0.6, f.fontSizePt*0.4, 0, "")
+	f.footnoteAdd(num, txtStr, ht)
+}
+
+// AddFootnote adds a footnote with the specified text to the current page
+// and returns its number. Use it to insert the number in text output with
+// MultiCell() or Cell(); Footnote() writes the number itself.
+//
+// The notes are output at the bottom of the page, above the bottom margin,
+// below a short separating line. Each note that is added raises the ordinate
+// at which an automatic page break occurs by the height of the note, so that
+// the body of the page does not overlap the notes. If the note does not fit
+// below the current line, its remaining lines, and those of the notes that
+// follow on the same page, are carried over to the bottom of the next page.
+// Notes are numbered as set with SetFootnoteOptions() and
+// ResetFootnoteNumber().
+func (f *Fpdf) AddFootnote(txtStr string) (num int) {
+	if f.err != nil {
+		return
+	}
+	num = f.footnoteNumber()
+	f.footnoteAdd(num, txtStr, 0)
+	return
+}
+
+// Endnote writes the superscript number of a new endnote at the current
+// position, in the same way as Footnote(). See AddEndnote().
+//
+// The PutEndnotes() example demonstrates this method.
+func (f *Fpdf) Endnote(ht float64, txtStr string) {
+	if f.err != nil {
+		return
+	}
+	num := f.AddEndnote(txtStr)
+	f.SubWrite(ht, strconv.Itoa(num), f.fontSizePt*0.6, f.fontSizePt*0.4, 0, "")
+}
+
+// AddEndnote adds an endnote with the specified text and returns its number.
+// Use it to insert the number in text output with MultiCell() or Cell();
+// Endnote() writes the number itself. Unlike footnotes, endnotes are
+// collected until PutEndnotes() outputs them, typically at the end of a
+// chapter or of the document. They are numbered from 1 after each call to
+// PutEndnotes().
+func (f *Fpdf) AddEndnote(txtStr string) (num int) {
+	if f.err != nil {
+		return
+	}
+	f.footnoteDefaults()
+	f.footnotes.endnotes = append(f.footnotes.endnotes, txtStr)
+	return len(f.footnotes.endnotes)
+}
+
+// PutEndnotes outputs the endnotes added with Endnote() and AddEndnote()
+// since the previous call, and restarts their numbering. The notes begin at
+// the left margin of the current line and are set in the font of the
+// footnotes (see SetFootnoteOptions()), each preceded by its number and
+// indented. Page breaks occur as for MultiCell(). The current position after
+// the call is the left margin, below the last note.
+//
+// The PutEndnotes() example demonstrates this method.
+func (f *Fpdf) PutEndnotes() {
+	fn := &f.footnotes
+	if f.err != nil || len(fn.endnotes) == 0 {
+		return
+	}
+	if f.page <= 0 {
+		f.SetErrorf("cannot output endnotes without first adding a page")
+		return
+	}
+	st := f.saveParaState()
+	margin, rise := f.cMargin, f.textRise
+	f.cMargin = 0
+	f.footnoteFont()
+	size := f.fontSizePt
+	lineHt := f.footnoteLineHt()
+	w := f.w - f.lMargin - f.rMargin
+	// Numbers of up to two digits have the same indent
+	indent := f.GetStringWidth("00 ")
+	if wd := f.GetStringWidth(strconv.Itoa(len(fn.endnotes)) + " "); wd > indent {
+		indent = wd
+	}
+	for j, txtStr := range fn.endnotes {
+		tagged := f.tagAuto(false)
+		f.SetX(f.lMargin)
+		for k, line := range f.footnoteSplit(txtStr, w-indent) {
+			if k == 0 {
+				// The number cell breaks the page if the line does not fit
+				f.SetFontSize(size * 0.7)
+				f.SetTextRise(size * 0.3 / f.k)
+				f.CellFormat(indent, lineHt, strconv.Itoa(j+1), "", 0, "L", false, 0, "")
+				f.SetTextRise(rise)
+				f.SetFontSize(size)
+			} else {
+				f.SetX(f.lMargin + indent)
+			}
+			f.CellFormat(w-indent, lineHt, line, "", 1, "L", false, 0, "")
+		}
+		if tagged {
+			f.tagAutoEnd(0)
+		}
+	}
+	f.cMargin = margin
+	f.restoreParaState(st)
+	fn.endnotes = nil
+}
+
+// footnoteNumber returns the number of the next note.
+func (f *Fpdf) footnoteNumber() int {
+	fn := &f.footnotes
+	if fn.opts.Numbering == FootnoteNumberPage && fn.page != f.page {
+		fn.number = 0
+	}
+	return fn.number + 1
+}
+
+// footnoteMargins returns the left and right margins of the page, which
+// differ from the current margins when text is laid out in columns.
+func (f *Fpdf) footnoteMargins() (left, right float64) {
+	if f.columns.n > 1 {
+		return f.columns.lMargin, f.columns.rMargin
+	}
+	return f.lMargin, f.rMargin
+}
+
+// footnoteDefaults sets the font of the notes that is not specified by the
+// options to the current font.
+func (f *Fpdf) footnoteDefaults() {
+	fn := &f.footnotes
+	if fn.opts.FontFamily == "" {
+		fn.opts.FontFamily = f.fontFamily
+	}
+	if fn.opts.FontSize == 0 {
+		fn.opts.FontSize = f.fontSizePt * 0.8
+	}
+}
+
+// footnoteFont selects the font of the notes.
+func (f *Fpdf) footnoteFont() {
+	f.SetFont(f.footnotes.opts.FontFamily, "", f.footnotes.opts.FontSize)
+}
+
+// footnoteAdd adds note num to the current page. The body of the page needs
+// ht below the current ordinate.
+func (f *Fpdf) footnoteAdd(num int, txtStr string, ht float64) {
+	fn := &f.footnotes
+	if f.page <= 0 {
+		f.SetErrorf("cannot add footnote without first adding a page")
+		return
+	}
+	f.footnoteDefaults()
+	fn.number, fn.page = num, f.page
+	st := f.saveParaState()
+	margin := f.cMargin
+	f.cMargin = 0
+	f.footnoteFont()
+	numStr := strconv.Itoa(num)
+	left, right := f.footnoteMargins()
+	// Numbers of up to two digits have the same indent
+	indent := f.GetStringWidth("00 ")
+	if w := f.GetStringWidth(numStr + " "); w > indent {
+		indent = w
+	}
+	var lines []footnoteLineType
+	for j, txt := range f.footnoteSplit(txtStr, f.w-left-right-indent) {
+		line := footnoteLineType{text: txt, indent: indent}
+		if j == 0 {
+			line.num = numStr
+		}
+		lines = append(lines, line)
+	}
+	f.cMargin = margin
+	f.restoreParaState(st)
+	lineHt := f.footnoteLineHt()
+	if len(fn.carry) == 0 {
+		// Lines that fit between the body and the bottom margin stay on this
+		// page
+		sep := 0.0
+		if len(fn.lines) == 0 {
+			sep = lineHt
+		}
+		room := f.h - f.bMargin - f.y - ht - fn.area - sep
+		if f.columns.n > 1 && f.columns.current > 0 {
+			// The previous columns reach the bottom of the body
+			room = 0
+		}
+		count := int(room / lineHt)
+		if count > len(lines) {
+			count = len(lines)
+		}
+		if count > 0 {
+			fn.lines = append(fn.lines, lines[:count]...)
+			f.footnoteReserve(sep + float64(count)*lineHt)
+			lines = lines[count:]
+		}
+	}
+	fn.carry = append(fn.carry, lines...)
+}
+
+// footnoteSplit breaks the text of a note into lines of width w.
+func (f *Fpdf) footnoteSplit(txtStr string, w float64) (list []string) {
+	if f.isCurrentUTF8 {
+		list = f.SplitText(txtStr, w)
+	} else {
+		for _, line := range f.SplitLines([]byte(txtStr), w) {
+			list = append(list, string(line))
+		}
+	}
+	if len(list) == 0 {
+		list = []string{""}
+	}
+	return
+}
+
+// footnoteLineHt returns the height of the lines of the notes.
+func (f *Fpdf) footnoteLineHt() float64 {
+	if f.footnotes.opts.LineHt > 0 {
+		return f.footnotes.opts.LineHt
+	}
+	return f.footnotes.opts.FontSize * 1.25 / f.k
+}
+
+// footnoteReserve enlarges the area of the notes of the current page by ht.
+func (f *Fpdf) footnoteReserve(ht float64) {
+	f.footnotes.area += ht
+	f.pageBreakTrigger -= ht
+}
+
+// footnotesPut outputs the notes of the current page as the page ends.
+func (f *Fpdf) footnotesPut() {
+	fn := &f.footnotes
+	if f.page <= 0 || len(fn.lines) == 0 {
+		return
+	}
+	st := f.saveParaState()
+	x, y, margin, rise := f.x, f.y, f.cMargin, f.textRise
+	autoBreak := f.autoPageBreak
+	f.autoPageBreak = false
+	left, right := f.footnoteMargins()
+	lineHt := f.footnoteLineHt()
+	f.cMargin = 0
+	f.footnoteFont()
+	size := f.fontSizePt
+	// The area starts with a separating line
+	top := f.h - f.bMargin - fn.area
+	f.Line(left, top+lineHt/2, left+(f.w-left-right)/3, top+lineHt/2)
+	top += lineHt
+	for j, line := range fn.lines {
+		ly := top + float64(j)*lineHt
+		if line.num != "" {
+			f.SetFontSize(size * 0.7)
+			f.SetTextRise(size * 0.3 / f.k)
+			f.SetXY(left, ly)
+			f.CellFormat(line.indent, lineHt, line.num, "", 0, "L", false, 0, "")
+			f.SetTextRise(rise)
+			f.SetFontSize(size)
+		}
+		f.SetXY(left+line.indent, ly)
+		f.CellFormat(f.w-left-right-line.indent, lineHt, line.text, "", 0, "L", false, 0, "")
+	}
+	f.cMargin, f.autoPageBreak = margin, autoBreak
+	f.restoreParaState(st)
+	f.x, f.y = x, y
+	f.pageBreakTrigger += fn.area
+	fn.lines, fn.area = nil, 0
+}
+
+// footnotesNewPage reserves the area of the notes carried over to the new
+// page.
+func (f *Fpdf) footnotesNewPage() {
+	fn := &f.footnotes
+	if len(fn.carry) == 0 {
+		return
+	}
+	lineHt := f.footnoteLineHt()
+	// The notes carried over leave room for at least one line of the body
+	count := int((f.h - f.bMargin - f.y - f.fontSize*2 - lineHt) / lineHt)
+	if count < 1 {
+		count = 1
+	}
+	if count > len(fn.carry) {
+		count = len(fn.carry)
+	}
+	fn.lines = append(fn.lines, fn.carry[:count]...)
+	fn.carry = fn.carry[count:]
+	f.footnoteReserve(float64(count+1) * lineHt)
+}
+
+// footnotesFlush adds pages for the notes that are still carried over as
+// the document is closed.
+func (f *Fpdf) footnotesFlush() {
+	for len(f.footnotes.carry) > 0 && f.err == nil {
+		f.AddPage()
+	}
+}
@@ -0,0 +1,588 @@
+package gofpdf
+
+import (
+	"math"
+	"strings"
+)
+
+// Field flags, see section 12.7.3.1 of the PDF specification
+const (
+	formFlagReadOnly    = 1 << 0
+	formFlagRequired    = 1 << 1
+	formFlagMultiline   = 1 << 12
+	formFlagNoToggleOff = 1 << 14
+	formFlagRadio       = 1 << 15
+	formFlagCombo       = 1 << 17
+)
+
+const (
+	formFieldText = iota
+	formFieldCheckBox
+	formFieldRadio
+	formFieldChoice
+	formFieldSignature
+)
+
+// formPadding is the inner padding, in points, of text based field appearances
+const formPadding = 2
+
+// FormFieldOptions holds the optional settings of the interactive form fields
+// created with AddTextField(), AddCheckBox(), AddRadioGroup() and
+// AddChoiceField().
+type FormFieldOptions struct {
+	// Value is the initial and default value of the field. For a radio group
+	// it is the value of the selected button and for a choice field it should
+	// be one of the choices.
+	Value string
+	// ReadOnly prevents the user from changing the value of the field.
+	ReadOnly bool
+	// Required marks the field as one that must have a value when the form
+	// is submitted.
+	Required bool
+	// MaxLen is the maximum number of characters of a text field. Zero means
+	// no limit.
+	MaxLen int
+	// Multiline lets the value of a text field span several lines.
+	Multiline bool
+	// AlignStr specifies the alignment of the text in a text field: "L"
+	// (default), "C" or "R".
+	AlignStr string
+	// Border draws the border of the field with the current draw color and
+	// line width.
+	Border bool
+	// Fill paints the background of the field with the current fill color.
+	Fill bool
+}
+
+// RadioButtonType describes one button of a radio group. Value is the export
+// value of the button, (X, Y) is its upper left corner and Size its width and
+// height.
+type RadioButtonType struct {
+	Value string
+	X, Y  float64
+	Size  float64
+}
+
+type formWidgetType struct {
+	x, y, w, h  float64 // pdf coordinates (y diff and scaling done)
+	onStr       string  // name of the "on" appearance state; empty for text based fields
+	apOn, apOff []byte  // appearance streams; apOff is only used by buttons
+	objNum      int     // widget object number
+}
+
+type formFieldType struct {
+	kind    int
+	name    string
+	page    int
+	opts    FormFieldOptions
+	flags   int
+	utf8    bool   // values are encoded for a UTF-8 font
+	da      string // default appearance for text based fields
+	mk      string // appearance characteristics dictionary
+	choices []string
+	widgets []formWidgetType
+	objNum  int // field object number; same as the widget for single widget fields
+}
+
+type formRecType struct {
+	fields []formFieldType
+	names  map[string]bool
+}
+
+// AddTextField adds an interactive text field to the current page. The field
+// occupies the rectangle with upper left corner (x, y), width w and height h.
+// name identifies the field in the form and must be unique within the
+// document. The value, if any, is displayed with the current font, font size
+// and text color. Set opts.MaxLen to limit the length of the value and
+// opts.Multiline to allow line breaks.
+//
+// The AddTextField() example demonstrates this method along with the other
+// form field methods.
+func (f *Fpdf) AddTextField(name string, x, y, w, h float64, opts FormFieldOptions) {
+	if !f.formCheck(name, true) {
+		return
+	}
+	fld := f.formNewField(formFieldText, name, opts)
+	if opts.Multiline {
+		fld.flags |= formFlagMultiline
+	}
+	wPt, hPt := w*f.k, h*f.k
+	var s fmtBuffer
+	s.printf("/Tx BMC\n")
+	f.formBackground(&s, wPt, hPt, opts, false)
+	f.formTextLines(&s, wPt, hPt, f.formLines(opts.Value, w, opts.Multiline), opts.AlignStr, -1)
+	s.printf("EMC\n")
+	fld.widgets = []formWidgetType{{x: x * f.k, y: f.hPt - y*f.k, w: wPt, h: hPt, apOn: s.Bytes()}}
+	f.form.fields = append(f.form.fields, fld)
+}
+
+// AddCheckBox adds an interactive check box to the current page. The box
+// occupies the square with upper left corner (x, y) and width and height
+// size. name identifies the field in the form and must be unique within the
+// document. checked specifies the initial and default state of the box. The
+// check mark is drawn with the current text color.
+//
+// The AddTextField() example demonstrates this method.
+func (f *Fpdf) AddCheckBox(name string, x, y, size float64, checked bool, opts FormFieldOptions) {
+	if !f.formCheck(name, false) {
+		return
+	}
+	fld := f.formNewField(formFieldCheckBox, name, opts)
+	fld.opts.Value = "Off"
+	if checked {
+		fld.opts.Value = "Yes"
+	}
+	wd := f.formButton(x, y, size, opts, false)
+	wd.onStr = "Yes"
+	fld.widgets = []formWidgetType{wd}
+	f.form.fields = append(f.form.fields, fld)
+}
+
+// AddRadioGroup adds a group of interactive radio buttons to the current
+// page. Only one button of the group can be selected at a time. name
+// identifies the group in the form and must be unique within the document.
+// Each button is described by an entry in buttons; the button whose value
+// equals opts.Value is initially selected. The selection dot is drawn with
+// the current text color.
+//
+// The AddTextField() example demonstrates this method.
+func (f *Fpdf) AddRadioGroup(name string, buttons []RadioButtonType, opts FormFieldOptions) {
+	if !f.formCheck(name, false) {
+		return
+	}
+	if len(buttons) == 0 {
+		f.SetErrorf("radio group %s has no buttons", name)
+		return
+	}
+	fld := f.formNewField(formFieldRadio, name, opts)
+	fld.flags |= formFlagRadio | formFlagNoToggleOff
+	found := false
+	for _, b := range buttons {
+		if b.Value == "" || b.Value == "Off" {
+			f.SetErrorf("invalid value \"%s\" for button of radio group %s", b.Value, name)
+			return
+		}
+		wd := f.formButton(b.X, b.Y, b.Size, opts, true)
+		wd.onStr = b.Value
+		fld.widgets = append(fld.widgets, wd)
+		found = found || b.Value == opts.Value
+	}
+	if !found {
+		fld.opts.Value = "Off"
+	}
+	f.form.fields = append(f.form.fields, fld)
+}
+
+// AddChoiceField adds an interactive choice field to the current page. The
+// field occupies the rectangle with upper left corner (x, y), width w and
+// height h. name identifies the field in the form and must be unique within
+// the document. choices lists the options the user can choose from. If combo
+// is true, a drop-down list is created, otherwise a scrollable list box. The
+// option that equals opts.Value is initially selected. The options are
+// displayed with the current font, font size and text color.
+//
+// The AddTextField() example demonstrates this method.
+func (f *Fpdf) AddChoiceField(name string, x, y, w, h float64, choices []string, combo bool, opts FormFieldOptions) {
+	if !f.formCheck(name, true) {
+		return
+	}
+	fld := f.formNewField(formFieldChoice, name, opts)
+	fld.choices = choices
+	wPt, hPt := w*f.k, h*f.k
+	var s fmtBuffer
+	s.printf("/Tx BMC\n")
+	f.formBackground(&s, wPt, hPt, opts, false)
+	if combo {
+		fld.flags |= formFlagCombo
+		f.formTextLines(&s, wPt, hPt, []string{opts.Value}, "L", -1)
+	} else {
+		selected := -1
+		for j, c := range choices {
+			if c == opts.Value {
+				selected = j
+			}
+		}
+		f.formTextLines(&s, wPt, hPt, choices, "L", selected)
+	}
+	s.printf("EMC\n")
+	fld.widgets = []formWidgetType{{x: x * f.k, y: f.hPt - y*f.k, w: wPt, h: hPt, apOn: s.Bytes()}}
+	f.form.fields = append(f.form.fields, fld)
+}
+
+// formCheck returns true if a field with the specified name can be added to
+// the current page, otherwise it sets the error state.
+func (f *Fpdf) formCheck(name string, needFont bool) bool {
+	if f.err != nil {
+		return false
+	}
+	if f.page <= 0 {
+		f.SetErrorf("cannot add form field %s without first adding a page", name)
+		return false
+	}
+	if name == "" || strings.Contains(name, ".") {
+		f.SetErrorf("invalid form field name \"%s\"", name)
+		return false
+	}
+	if f.form.names[name] {
+		f.SetErrorf("form field %s is already defined", name)
+		return false
+	}
+	if needFont && f.currentFont.Name == "" {
+		f.SetErrorf("font has not been set; unable to add form field %s", name)
+		return false
+	}
+	if f.form.names == nil {
+		f.form.names = make(map[string]bool)
+	}
+	f.form.names[name] = true
+	return true
+}
+
+func (f *Fpdf) formNewField(kind int, name string, opts FormFieldOptions) (fld formFieldType) {
+	fld.kind = kind
+	fld.name = name
+	fld.page = f.page
+	fld.opts = opts
+	fld.utf8 = f.isCurrentUTF8
+	if opts.ReadOnly {
+		fld.flags |= formFlagReadOnly
+	}
+	if opts.Required {
+		fld.flags |= formFlagRequired
+	}
+	if f.currentFont.Name != "" {
+		fld.da = sprintf("/F%s %.2f Tf %s", f.currentFont.i, f.fontSizePt, f.color.text.str)
+	}
+	var mk fmtBuffer
+	if opts.Border {
+		mk.printf("/BC [%.3f %.3f %.3f] ", f.color.draw.r, f.color.draw.g, f.color.draw.b)
+	}
+	if opts.Fill {
+		mk.printf("/BG [%.3f %.3f %.3f] ", f.color.fill.r, f.color.fill.g, f.color.fill.b)
+	}
+	if mk.Len() > 0 {
+		fld.mk = "<<" + strings.TrimSpace(mk.String()) + ">>"
+	}
+	return
+}
+
+// formButton returns a check box or radio button widget with its "on" and
+// "off" appearances.
+func (f *Fpdf) formButton(x, y, size float64, opts FormFieldOptions, round bool) (wd formWidgetType) {
+	sz := size * f.k
+	wd.x, wd.y, wd.w, wd.h = x*f.k, f.hPt-y*f.k, sz, sz
+	var on, off fmtBuffer
+	f.formBackground(&off, sz, sz, opts, round)
+	f.formBackground(&on, sz, sz, opts, round)
+	on.printf("q %s ", f.color.text.str)
+	if round {
+		formCircle(&on, sz/2, sz/2, sz/4)
+		on.printf("f Q\n")
+	} else {
+		// Check mark defined in a unit square
+		pts := []float64{0.18, 0.52, 0.30, 0.62, 0.42, 0.46, 0.74, 0.84, 0.86, 0.75, 0.42, 0.22}
+		for j := 0; j < len(pts); j += 2 {
+			op := "l"
+			if j == 0 {
+				op = "m"
+			}
+			on.printf("%.2f %.2f %s ", pts[j]*sz, pts[j+1]*sz, op)
+		}
+		on.printf("h f Q\n")
+	}
+	wd.apOn, wd.apOff = on.Bytes(), off.Bytes()
+	return
+}
+
+// formCircle appends a circle path, approximated with four Bézier curves, to
+// s. Coordinates are in points.
+func formCircle(s *fmtBuffer, cx, cy, r float64) {
+	c := r * 4 * (math.Sqrt2 - 1) / 3
+	s.printf("%.2f %.2f m ", cx+r, cy)
+	s.printf("%.2f %.2f %.2f %.2f %.2f %.2f c ", cx+r, cy+c, cx+c, cy+r, cx, cy+r)
+	s.printf("%.2f %.2f %.2f %.2f %.2f %.2f c ", cx-c, cy+r, cx-r, cy+c, cx-r, cy)
+	s.printf("%.2f %.2f %.2f %.2f %.2f %.2f c ", cx-r, cy-c, cx-c, cy-r, cx, cy-r)
+	s.printf("%.2f %.2f %.2f %.2f %.2f %.2f c ", cx+c, cy-r, cx+r, cy-c, cx+r, cy)
+}
+
+// formBackground appends the background and border of a field appearance to
+// s.
+func (f *Fpdf) formBackground(s *fmtBuffer, w, h float64, opts FormFieldOptions, round bool) {
+	if !opts.Fill && !opts.Border {
+		return
+	}
+	lw := f.lineWidth * f.k
+	s.printf("q ")
+	if opts.Fill {
+		s.printf("%s ", f.color.fill.str)
+	}
+	if opts.Border {
+		s.printf("%.2f w %s ", lw, f.color.draw.str)
+	} else {
+		lw = 0
+	}
+	if round {
+		formCircle(s, w/2, h/2, (math.Min(w, h)-lw)/2)
+	} else {
+		s.printf("%.2f %.2f %.2f %.2f re ", lw/2, lw/2, w-lw, h-lw)
+	}
+	switch {
+	case opts.Fill && opts.Border:
+		s.printf("B")
+	case opts.Fill:
+		s.printf("f")
+	default:
+		s.printf("S")
+	}
+	s.printf(" Q\n")
+}
+
+// formLines splits the value of a text field into the lines that are shown
+// in its appearance.
+func (f *Fpdf) formLines(value string, w float64, multiline bool) []string {
+	if value == "" {
+		return nil
+	}
+	if !multiline {
+		return []string{value}
+	}
+	return f.SplitText(value, w-2*formPadding/f.k)
+}
+
+// formTextLines appends the text of a field appearance to s using the
+// current font. If multiple lines are given, they start at the top of the
+// field, otherwise the single line is centered vertically. The line with
+// index selected, if any, is highlighted.
+func (f *Fpdf) formTextLines(s *fmtBuffer, w, h float64, lines []string, alignStr string, selected int) {
+	fs := f.fontSizePt
+	lineHt := fs * 1.15
+	s.printf("q %.2f %.2f %.2f %.2f re W n\n", formPadding/2.0, formPadding/2.0, w-formPadding, h-formPadding)
+	for j, line := range lines {
+		var y float64
+		if len(lines) == 1 && selected < 0 {
+			y = h/2 - 0.3*fs
+		} else {
+			y = h - formPadding - 0.8*fs - float64(j)*lineHt
+		}
+		if j == selected {
+			s.printf("q 0.600 0.753 0.855 rg %.2f %.2f %.2f %.2f re f Q\n",
+				formPadding/2.0, y-0.25*fs-(lineHt-fs)/2, w-formPadding, lineHt)
+		}
+		x := float64(formPadding)
+		switch alignStr {
+		case "R":
+			x = w - formPadding - f.GetStringWidth(line)*f.k
+		case "C":
+			x = (w - f.GetStringWidth(line)*f.k) / 2
+		}
+		s.printf("BT /F%s %.2f Tf %s %.2f %.2f Td (%s) Tj ET\n",
+			f.currentFont.i, fs, f.color.text.str, x, y, f.formEncode(line))
+	}
+	s.printf("Q\n")
+}
+
+// formEncode returns the escaped representation of s for use in a content
+// stream with the current font.
+func (f *Fpdf) formEncode(s string) string {
+	if f.isCurrentUTF8 {
+		for _, uni := range s {
+			f.currentFont.usedRunes[int(uni)] = int(uni)
+		}
+		return f.escape(utf8toutf16(s, false))
+	}
+	return f.escape(s)
+}
+
+// formString returns a text string in the encoding of the field.
+func (f *Fpdf) formString(fld formFieldType, s string) string {
+	if fld.utf8 {
+		return f.textstring(utf8toutf16(s))
+	}
+	return f.textstring(s)
+}
+
+// formName returns s as a PDF name object, escaping characters as needed.
+func formName(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		if c < '!' || c > '~' || strings.IndexByte("#%()<>[]{}/", c) >= 0 {
+			b.WriteString(sprintf("#%02X", c))
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// formAssignObjNums determines the object numbers of the form fields, their
+// widgets and appearance streams, beginning with n. This is needed to
+// reference the widgets from the pages before the fields are written. The
+// object numbers are assigned in the order used by formPutFields(). The
+// next unused object number is returned.
+func (f *Fpdf) formAssignObjNums(n int) int {
+	for j := range f.form.fields {
+		fld := &f.form.fields[j]
+		if fld.kind == formFieldRadio {
+			fld.objNum = n
+			n++
+		}
+		for k := range fld.widgets {
+			wd := &fld.widgets[k]
+			wd.objNum = n
+			if fld.kind != formFieldRadio {
+				fld.objNum = n
+			}
+			n++
+			if wd.onStr == "" {
+				n++
+			} else {
+				n += 2
+			}
+			if fld.kind == formFieldSignature {
+				// Signature dictionary
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// formPutAnnotations appends the references to the widgets of the specified
+// page to out.
+func (f *Fpdf) formPutAnnotations(out *fmtBuffer, page int) {
+	for _, fld := range f.form.fields {
+		if fld.page == page {
+			for _, wd := range fld.widgets {
+				out.printf("%d 0 R ", wd.objNum)
+			}
+		}
+	}
+}
+
+// formPageHasWidgets returns true if the specified page contains form
+// fields.
+func (f *Fpdf) formPageHasWidgets(page int) bool {
+	for _, fld := range f.form.fields {
+		if fld.page == page {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fpdf) formPutAppearance(w, h float64, b []byte) {
+	f.newobj()
+	filter := ""
+	if f.compress {
+		b = sliceCompress(b)
+		filter = "/Filter /FlateDecode "
+	}
+	f.outf("<</Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Resources 2 0 R %s/Length %d>>",
+		w, h, filter, f.protect.cipherLen(len(b)))
+	f.putstream(b)
+	f.out("endobj")
+}
+
+// formPutFields writes the form fields, their widgets and appearance
+// streams.
+func (f *Fpdf) formPutFields() {
+	for _, fld := range f.form.fields {
+		if fld.kind == formFieldRadio {
+			f.newobj()
+			f.outf("<</FT /Btn /T %s /Ff %d", f.textstring(utf8toutf16(fld.name)), fld.flags)
+			f.outf("/V %s /DV %s", formName(fld.opts.Value), formName(fld.opts.Value))
+			var kids fmtBuffer
+			for _, wd := range fld.widgets {
+				kids.printf("%d 0 R ", wd.objNum)
+			}
+			f.outf("/Kids [%s]>>", kids.String())
+			f.out("endobj")
+		}
+		for _, wd := range fld.widgets {
+			f.newobj()
+			annotFlags := 4 // print
+			if fld.kind == formFieldSignature {
+				annotFlags |= 128 // locked
+			}
+			f.outf("<</Type /Annot /Subtype /Widget /Rect [%.2f %.2f %.2f %.2f] /F %d",
+				wd.x, wd.y-wd.h, wd.x+wd.w, wd.y, annotFlags)
+			if fld.mk != "" {
+				f.outf("/MK %s", fld.mk)
+			}
+			if fld.kind == formFieldRadio {
+				f.outf("/Parent %d 0 R", fld.objNum)
+			} else {
+				f.outf("/T %s", f.textstring(utf8toutf16(fld.name)))
+				if fld.flags != 0 {
+					f.outf("/Ff %d", fld.flags)
+				}
+			}
+			switch fld.kind {
+			case formFieldText, formFieldChoice:
+				if fld.kind == formFieldText {
+					f.out("/FT /Tx")
+					if fld.opts.MaxLen > 0 {
+						f.outf("/MaxLen %d", fld.opts.MaxLen)
+					}
+					switch fld.opts.AlignStr {
+					case "C":
+						f.out("/Q 1")
+					case "R":
+						f.out("/Q 2")
+					}
+				} else {
+					f.out("/FT /Ch")
+					var opt fmtBuffer
+					for _, c := range fld.choices {
+						opt.printf("%s ", f.formString(fld, c))
+					}
+					f.outf("/Opt [%s]", opt.String())
+				}
+				f.outf("/DA %s", f.textstring(fld.da))
+				if fld.opts.Value != "" {
+					v := f.formString(fld, fld.opts.Value)
+					f.outf("/V %s /DV %s", v, v)
+				}
+				f.outf("/AP <</N %d 0 R>>>>", f.n+1)
+			case formFieldSignature:
+				f.outf("/FT /Sig /V %d 0 R /AP <</N %d 0 R>>>>", f.n+2, f.n+1)
+			default:
+				state := "/Off"
+				if fld.opts.Value == wd.onStr {
+					state = formName(wd.onStr)
+				}
+				if fld.kind == formFieldCheckBox {
+					f.outf("/FT /Btn /V %s /DV %s", state, state)
+				}
+				f.outf("/AS %s /AP <</N <<%s %d 0 R /Off %d 0 R>>>>>>", state, formName(wd.onStr), f.n+1, f.n+2)
+			}
+			f.out("endobj")
+			f.formPutAppearance(wd.w, wd.h, wd.apOn)
+			if wd.onStr != "" {
+				f.formPutAppearance(wd.w, wd.h, wd.apOff)
+			}
+			if fld.kind == formFieldSignature {
+				f.signaturePutDict()
+			}
+		}
+	}
+}
+
+// formPutCatalog writes the interactive form dictionary of the document
+// catalog.
+func (f *Fpdf) formPutCatalog() {
+	if len(f.form.fields) > 0 {
+		var fields fmtBuffer
+		for _, fld := range f.form.fields {
+			fields.printf("%d 0 R ", fld.objNum)
+		}
+		sigFlags := ""
+		if f.signature != nil {
+			// SignaturesExist and AppendOnly
+			sigFlags = " /SigFlags 3"
+		}
+		f.outf("/AcroForm <</Fields [%s] /DR 2 0 R%s>>", fields.String(), sigFlags)
+	}
+}
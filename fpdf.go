@@ -23,6 +23,7 @@ package gofpdf
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -40,6 +41,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 var gl struct {
@@ -208,6 +210,7 @@ func fpdfNew(orientationStr, unitStr, sizeStr, fontDirStr string, size SizeType)
 	f.creationDate = gl.creationDate
 	f.modDate = gl.modDate
 	f.userUnderlineThickness = 1
+	f.hScaling = 100
 	return
 }
 
@@ -216,7 +219,11 @@ func fpdfNew(orientationStr, unitStr, sizeStr, fontDirStr string, size SizeType)
 // alternative to New() that provides additional customization. The PageSize()
 // example demonstrates this method.
 func NewCustom(init *InitType) (f *Fpdf) {
-	return fpdfNew(init.OrientationStr, init.UnitStr, init.SizeStr, init.FontDirStr, init.Size)
+	f = fpdfNew(init.OrientationStr, init.UnitStr, init.SizeStr, init.FontDirStr, init.Size)
+	if init.ConformanceStr != "" {
+		f.SetConformance(init.ConformanceStr)
+	}
+	return
 }
 
 // New returns a pointer to a new Fpdf instance. Its methods are subsequently
@@ -425,6 +432,15 @@ func (f *Fpdf) SetFontLoader(loader FontLoader) {
 	f.fontLoader = loader
 }
 
+// SetWOFF2Decoder sets the function used to decompress the Brotli-compressed
+// tables of fonts in the WOFF2 format. Brotli is not part of the Go standard
+// library, so WOFF2 fonts cannot be loaded until a decoder has been set; the
+// contrib/woff2 package provides one. decoder returns a reader of the
+// decompressed data read from r.
+func (f *Fpdf) SetWOFF2Decoder(decoder func(r io.Reader) io.Reader) {
+	f.woff2Decoder = decoder
+}
+
 // SetHeaderFuncMode sets the function that lets the application render the
 // page header. See SetHeaderFunc() for more details. The value for homeMode
 // should be set to true to have the current position set to the left and top
@@ -505,7 +521,7 @@ func (f *Fpdf) GetAutoPageBreak() (auto bool, margin float64) {
 func (f *Fpdf) SetAutoPageBreak(auto bool, margin float64) {
 	f.autoPageBreak = auto
 	f.bMargin = margin
-	f.pageBreakTrigger = f.h - margin
+	f.pageBreakTrigger = f.h - margin - f.footnotes.area
 }
 
 // SetDisplayMode sets advisory display directives for the document viewer.
@@ -638,12 +654,19 @@ func (f *Fpdf) AliasNbPages(aliasStr string) {
 	f.aliasNbPagesStr = aliasStr
 }
 
-// RTL enables right-to-left mode
+// RTL enables right-to-left mode. Paragraphs of UTF-8 text then have a
+// right-to-left base direction, and Text() places the string to the left of
+// x. Left-to-right runs within a paragraph, such as numbers and Latin words,
+// are still laid out left to right.
 func (f *Fpdf) RTL() {
 	f.isRTL = true
 }
 
-// LTR disables right-to-left mode
+// LTR disables right-to-left mode. The base direction of each paragraph of
+// UTF-8 text is then that of its first strong character, as specified by the
+// Unicode Bidirectional Algorithm; start a paragraph with U+200E (left-to-right
+// mark) or U+200F (right-to-left mark) to choose its direction explicitly.
+// This is the default mode.
 func (f *Fpdf) LTR() {
 	f.isRTL = false
 }
@@ -663,6 +686,8 @@ func (f *Fpdf) Close() {
 			f.err = fmt.Errorf("clip procedure must be explicitly ended")
 		} else if f.transformNest > 0 {
 			f.err = fmt.Errorf("transformation procedure must be explicitly ended")
+		} else {
+			f.tagEndDoc()
 		}
 	}
 	if f.err != nil {
@@ -677,7 +702,15 @@ func (f *Fpdf) Close() {
 			return
 		}
 	}
+	f.footnotesFlush()
+	f.tocPut()
+	if f.err != nil {
+		return
+	}
 	// Page footer
+	f.tagPageBreak(true)
+	f.columnsPageMargins()
+	f.footnotesPut()
 	f.inFooter = true
 	if f.footerFnc != nil {
 		f.footerFnc()
@@ -688,6 +721,7 @@ func (f *Fpdf) Close() {
 
 	// Close page
 	f.endpage()
+	f.tocMove()
 	// Close document
 	f.enddoc()
 	return
@@ -726,6 +760,8 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 	if f.state == 0 {
 		f.open()
 	}
+	// The footer and header are artifacts
+	f.tagPageBreak(true)
 	familyStr := f.fontFamily
 	style := f.fontStyle
 	if f.underline {
@@ -740,7 +776,10 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 	fc := f.color.fill
 	tc := f.color.text
 	cf := f.colorFlag
+	cs, hs, rise := f.charSpacing, f.hScaling, f.textRise
 
+	f.columnsPageMargins()
+	f.footnotesPut()
 	if f.page > 0 {
 		f.inFooter = true
 		// Page footer avoid double call on footer.
@@ -768,6 +807,8 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 	if len(f.dashArray) > 0 {
 		f.outputDashPattern()
 	}
+	// Set text state
+	f.outTextState()
 	// 	Set font
 	if familyStr != "" {
 		f.SetFont(familyStr, style, fontsize)
@@ -795,6 +836,16 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 			f.SetHomeXY()
 		}
 	}
+	f.tagPageBreak(false)
+	f.restoreBreakTrigger()
+	f.exclusions = nil
+	f.columnsNewPage()
+	f.footnotesNewPage()
+	// Restore text state
+	if f.charSpacing != cs || f.hScaling != hs || f.textRise != rise {
+		f.charSpacing, f.hScaling, f.textRise = cs, hs, rise
+		f.outf("%.5f Tc %.2f Tz %.2f Ts", cs*f.k, hs, rise*f.k)
+	}
 	// 	Restore line width
 	if f.lineWidth != lw {
 		f.lineWidth = lw
@@ -953,32 +1004,54 @@ func (f *Fpdf) GetStringWidth(s string) float64 {
 }
 
 // GetStringSymbolWidth returns the length of a string in glyf units. A font must be
-// currently selected.
+// currently selected. The length includes the character spacing and the
+// horizontal scaling of the text.
 func (f *Fpdf) GetStringSymbolWidth(s string) int {
 	if f.err != nil {
 		return 0
 	}
+	return f.hScaled(f.symbolWidth(s))
+}
+
+// runeWidth returns the width of r in the current UTF-8 font in glyf units,
+// without character spacing and kerning.
+func (f *Fpdf) runeWidth(r rune) int {
+	cw := f.currentFont.Cw
+	switch {
+	case int(r) < len(cw) && cw[r] > 0:
+		if cw[r] != 65535 {
+			return cw[r]
+		}
+		return 0
+	case f.currentFont.Desc.MissingWidth != 0:
+		return f.currentFont.Desc.MissingWidth
+	}
+	return 500
+}
+
+// symbolWidth returns the length of a string in glyf units, including the
+// character spacing but not the horizontal scaling of the text.
+func (f *Fpdf) symbolWidth(s string) int {
 	w := 0
-	if f.isCurrentUTF8 {
+	cs := f.charSpacingUnits()
+	if adv := f.textAdvances([]rune(s)); adv != nil {
+		for _, a := range adv {
+			w += a + cs
+		}
+	} else if f.isCurrentUTF8 {
 		unicode := []rune(s)
-		for _, char := range unicode {
-			intChar := int(char)
-			if len(f.currentFont.Cw) >= intChar && f.currentFont.Cw[intChar] > 0 {
-				if f.currentFont.Cw[intChar] != 65535 {
-					w += f.currentFont.Cw[intChar]
-				}
-			} else if f.currentFont.Desc.MissingWidth != 0 {
-				w += f.currentFont.Desc.MissingWidth
-			} else {
-				w += 500
+		for i, char := range unicode {
+			if i > 0 {
+				w += f.kern(unicode[i-1], char)
 			}
+			w += cs + f.runeWidth(char)
 		}
 	} else {
 		for _, ch := range []byte(s) {
 			if ch == 0 {
 				break
 			}
-			w += f.currentFont.Cw[ch]
+			w += f.currentFont.Cw[ch] + cs
 		}
 	}
 	return w
@@ -1604,9 +1677,10 @@ func (f *Fpdf) ClipPolygon(points []PointType, outline bool) {
 }
 
 // ClipEnd ends a clipping operation that was started with a call to
-// ClipRect(), ClipRoundedRect(), ClipText(), ClipEllipse(), ClipCircle() or
-// ClipPolygon(). Clipping operations can be nested. The document cannot be
-// successfully output while a clipping operation is active.
+// ClipRect(), ClipRoundedRect(), ClipText(), ClipEllipse(), ClipCircle(),
+// ClipPolygon() or PathType.ClipPath(). Clipping operations can be nested.
+// The document cannot be successfully output while a clipping operation is
+// active.
 //
 // The ClipText() example demonstrates this method.
 func (f *Fpdf) ClipEnd() {
@@ -1641,7 +1715,7 @@ func (f *Fpdf) ClipEnd() {
 // definition file to be added. The file will be loaded from the font directory
 // specified in the call to New() or SetFontLocation().
 func (f *Fpdf) AddFont(familyStr, styleStr, fileStr string) {
-	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, false)
+	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, false, 0)
 }
 
 // AddUTF8Font imports a TrueType font with utf-8 symbols and makes it available.
@@ -1649,6 +1723,13 @@ func (f *Fpdf) AddFont(familyStr, styleStr, fileStr string) {
 // utility. It is not necessary to call this function for the core PDF fonts
 // (courier, helvetica, times, zapfdingbats).
 //
+// OpenType fonts with PostScript (CFF) outlines, usually found in .otf
+// files, are supported as well; they are embedded as CID-keyed CFF fonts.
+// Fonts compressed in the WOFF and WOFF2 formats used on the web are
+// decoded when loaded; WOFF2 fonts require a decoder to be set with
+// SetWOFF2Decoder(). To use a font of a TrueType collection (.ttc) other
+// than the first one, call AddUTF8FontFace.
+//
 // The JSON definition file (and the font file itself when embedding) must be
 // present in the font directory. If it is not found, the error "Could not
 // include font definition file" is set.
@@ -1665,10 +1746,18 @@ func (f *Fpdf) AddFont(familyStr, styleStr, fileStr string) {
 // definition file to be added. The file will be loaded from the font directory
 // specified in the call to New() or SetFontLocation().
 func (f *Fpdf) AddUTF8Font(familyStr, styleStr, fileStr string) {
-	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, true)
+	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, true, 0)
+}
+
+// AddUTF8FontFace imports the font numbered face, counting from zero, of a
+// TrueType collection (.ttc) or WOFF2 collection file and makes it available
+// in the same way as AddUTF8Font. Face 0 is the only face of a file that
+// holds a single font. An error is set if the file has no such face.
+func (f *Fpdf) AddUTF8FontFace(familyStr, styleStr, fileStr string, face int) {
+	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, true, face)
 }
 
-func (f *Fpdf) addFont(familyStr, styleStr, fileStr string, isUTF8 bool) {
+func (f *Fpdf) addFont(familyStr, styleStr, fileStr string, isUTF8 bool, face int) {
 	if fileStr == "" {
 		if isUTF8 {
 			fileStr = strings.Replace(familyStr, " ", "", -1) + strings.ToLower(styleStr) + ".ttf"
@@ -1682,15 +1771,13 @@ func (f *Fpdf) addFont(familyStr, styleStr, fileStr string, isUTF8 bool) {
 		if ok {
 			return
 		}
-		var ttfStat os.FileInfo
 		var err error
 		fileStr = path.Join(f.fontpath, fileStr)
-		ttfStat, err = os.Stat(fileStr)
+		_, err = os.Stat(fileStr)
 		if err != nil {
 			f.SetError(err)
 			return
 		}
-		originalSize := ttfStat.Size()
 		Type := "UTF8"
 		var utf8Bytes []byte
 		utf8Bytes, err = ioutil.ReadFile(fileStr)
@@ -1698,6 +1785,12 @@ func (f *Fpdf) addFont(familyStr, styleStr, fileStr string, isUTF8 bool) {
 			f.SetError(err)
 			return
 		}
+		utf8Bytes, err = sfntFont(utf8Bytes, face, f.woff2Decoder)
+		if err != nil {
+			f.SetError(err)
+			return
+		}
+		originalSize := int64(len(utf8Bytes))
 		reader := fileReader{readerPosition: 0, array: utf8Bytes}
 		utf8File := newUTF8Font(&reader)
 		err = utf8File.parseFile()
@@ -1791,12 +1884,13 @@ func makeSubsetRange(end int) map[int]int {
 //
 // zFileBytes contain all bytes of Z file.
 func (f *Fpdf) AddFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFileBytes []byte) {
-	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, jsonFileBytes, zFileBytes, nil)
+	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, jsonFileBytes, zFileBytes, nil, 0)
 }
 
 // AddUTF8FontFromBytes  imports a TrueType font with utf-8 symbols from static
 // bytes within the executable and makes it available for use in the generated
-// document.
+// document. OpenType fonts with CFF outlines and fonts in the WOFF and WOFF2
+// formats are supported as well; see AddUTF8Font.
 //
 // family specifies the font family. The name can be chosen arbitrarily. If it
 // is a standard family name, it will override the corresponding font. This
@@ -1810,10 +1904,17 @@ func (f *Fpdf) AddFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFile
 //
 // zFileBytes contain all bytes of Z file.
 func (f *Fpdf) AddUTF8FontFromBytes(familyStr, styleStr string, utf8Bytes []byte) {
-	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, nil, nil, utf8Bytes)
+	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, nil, nil, utf8Bytes, 0)
+}
+
+// AddUTF8FontFaceFromBytes imports the font numbered face, counting from
+// zero, of a TrueType collection (.ttc) or WOFF2 collection held in
+// utf8Bytes. See AddUTF8FontFace and AddUTF8FontFromBytes.
+func (f *Fpdf) AddUTF8FontFaceFromBytes(familyStr, styleStr string, utf8Bytes []byte, face int) {
+	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, nil, nil, utf8Bytes, face)
 }
 
-func (f *Fpdf) addFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFileBytes, utf8Bytes []byte) {
+func (f *Fpdf) addFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFileBytes, utf8Bytes []byte, face int) {
 	if f.err != nil {
 		return
 	}
@@ -1834,11 +1935,16 @@ func (f *Fpdf) addFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFile
 		// }
 
 		Type := "UTF8"
+		utf8Bytes, err := sfntFont(utf8Bytes, face, f.woff2Decoder)
+		if err != nil {
+			f.SetError(err)
+			return
+		}
 		reader := fileReader{readerPosition: 0, array: utf8Bytes}
 
 		utf8File := newUTF8Font(&reader)
 
-		err := utf8File.parseFile()
+		err = utf8File.parseFile()
 		if err != nil {
 			fmt.Printf("get metrics Error: %e\n", err)
 			return
@@ -2068,6 +2174,10 @@ func (f *Fpdf) SetFont(familyStr, styleStr string, size float64) {
 		}
 		_, ok = f.coreFonts[familyStr]
 		if ok {
+			if f.pdfa.part > 0 {
+				f.err = fmt.Errorf("%s requires embedded fonts; core font %s cannot be used", f.pdfa.levelStr, familyStr)
+				return
+			}
 			if familyStr == "symbol" {
 				familyStr = "zapfdingbats"
 			}
@@ -2160,14 +2270,15 @@ func (f *Fpdf) SetLink(link int, y float64, page int) {
 }
 
 // newLink adds a new clickable link on current page
-func (f *Fpdf) newLink(x, y, w, h float64, link int, linkStr string) {
+func (f *Fpdf) newLink(x, y, w, h float64, link int, linkStr, contentsStr string) {
 	// linkList, ok := f.pageLinks[f.page]
 	// if !ok {
 	// linkList = make([]linkType, 0, 8)
 	// f.pageLinks[f.page] = linkList
 	// }
-	f.pageLinks[f.page] = append(f.pageLinks[f.page],
-		linkType{x * f.k, f.hPt - y*f.k, w * f.k, h * f.k, link, linkStr})
+	f.pageLinks[f.page] = append(f.pageLinks[f.page], linkType{x: x * f.k, y: f.hPt - y*f.k,
+		wd: w * f.k, ht: h * f.k, link: link, linkStr: linkStr, contentsStr: contentsStr})
+	f.tagLink()
 }
 
 // Link puts a link on a rectangular area of the page. Text or image links are
@@ -2175,15 +2286,18 @@ func (f *Fpdf) newLink(x, y, w, h float64, link int, linkStr string) {
 // for instance to define a clickable area inside an image. link is the value
 // returned by AddLink().
 func (f *Fpdf) Link(x, y, w, h float64, link int) {
-	f.newLink(x, y, w, h, link, "")
+	f.newLink(x, y, w, h, link, "", "")
 }
 
 // LinkString puts a link on a rectangular area of the page. Text or image
 // links are generally put via Cell(), Write() or Image(), but this method can
 // be useful for instance to define a clickable area inside an image. linkStr
 // is the target URL.
+//
+// In a tagged document the link is put in the open Link element, if any,
+// whose alternate description (see BeginTagExt()) describes the link.
 func (f *Fpdf) LinkString(x, y, w, h float64, linkStr string) {
-	f.newLink(x, y, w, h, 0, linkStr)
+	f.newLink(x, y, w, h, 0, linkStr, "")
 }
 
 // Bookmark sets a bookmark that will be displayed in a sidebar outline. txtStr
@@ -2207,19 +2321,23 @@ func (f *Fpdf) Bookmark(txtStr string, level int, y float64) {
 // or Write() which are the standard methods to print text.
 func (f *Fpdf) Text(x, y float64, txtStr string) {
 	var txt2 string
+	visualStr := txtStr
 	if f.isCurrentUTF8 {
 		if f.isRTL {
-			txtStr = reverseText(txtStr)
 			x -= f.GetStringWidth(txtStr)
 		}
-		txt2 = f.escape(utf8toutf16(txtStr, false))
-		for _, uni := range []rune(txtStr) {
+		visualStr = f.bidiVisual(txtStr)
+		txt2 = f.escape(utf8toutf16(visualStr, false))
+		for _, uni := range []rune(visualStr) {
 			f.currentFont.usedRunes[int(uni)] = int(uni)
 		}
 	} else {
 		txt2 = f.escape(txtStr)
 	}
 	s := sprintf("BT %.2f %.2f Td (%s) Tj ET", x*f.k, (f.h-y)*f.k, txt2)
+	if f.textTJActive(txtStr) {
+		s = sprintf("BT %.2f %.2f Td [%s] TJ ET", x*f.k, (f.h-y)*f.k, f.textTJ(txtStr, 0, 0))
+	}
 	if f.underline && txtStr != "" {
 		s += " " + f.dounderline(x, y, txtStr)
 	}
@@ -2238,6 +2356,104 @@ func (f *Fpdf) SetWordSpacing(space float64) {
 	f.out(sprintf("%.5f Tw", space*f.k))
 }
 
+// SetCharSpacing sets the spacing, in the unit of measure specified in New(),
+// that is added after each character of following text. A negative value
+// brings characters closer together. The spacing is taken into account by
+// GetStringWidth() and by the methods that break text into lines. The
+// method can be called before the first page is created and the value is
+// retained from page to page.
+func (f *Fpdf) SetCharSpacing(space float64) {
+	f.charSpacing = space
+	if f.page > 0 {
+		f.outf("%.5f Tc", space*f.k)
+	}
+}
+
+// GetCharSpacing returns the character spacing set with SetCharSpacing().
+func (f *Fpdf) GetCharSpacing() float64 {
+	return f.charSpacing
+}
+
+// SetHorizontalScaling stretches or compresses following text horizontally.
+// scale is a percentage of the normal width of the text, 100 by default. The
+// scaling is taken into account by GetStringWidth() and by the methods that
+// break text into lines. The method can be called before the first page is
+// created and the value is retained from page to page.
+func (f *Fpdf) SetHorizontalScaling(scale float64) {
+	if scale <= 0 {
+		f.SetErrorf("horizontal scaling must be positive: %.2f", scale)
+		return
+	}
+	f.hScaling = scale
+	if f.page > 0 {
+		f.outf("%.2f Tz", scale)
+	}
+}
+
+// GetHorizontalScaling returns the horizontal scaling of text, in percent,
+// set with SetHorizontalScaling().
+func (f *Fpdf) GetHorizontalScaling() float64 {
+	return f.hScaling
+}
+
+// SetTextRise moves following text up, or down if rise is negative, by rise
+// in the unit of measure specified in New(), without changing the current
+// position or the placement of cells. The method can be called before the
+// first page is created and the value is retained from page to page.
+func (f *Fpdf) SetTextRise(rise float64) {
+	f.textRise = rise
+	if f.page > 0 {
+		f.outf("%.2f Ts", rise*f.k)
+	}
+}
+
+// GetTextRise returns the text rise set with SetTextRise().
+func (f *Fpdf) GetTextRise() float64 {
+	return f.textRise
+}
+
+// outTextState sets the character spacing, horizontal scaling and text rise
+// at the start of a page if they differ from their initial values.
+func (f *Fpdf) outTextState() {
+	if f.charSpacing != 0 {
+		f.outf("%.5f Tc", f.charSpacing*f.k)
+	}
+	if f.hScaling != 100 {
+		f.outf("%.2f Tz", f.hScaling)
+	}
+	if f.textRise != 0 {
+		f.outf("%.2f Ts", f.textRise*f.k)
+	}
+}
+
+// charSpacingUnits returns the character spacing in thousandths of the font
+// size.
+func (f *Fpdf) charSpacingUnits() int {
+	if f.charSpacing == 0 || f.fontSize == 0 {
+		return 0
+	}
+	return int(math.Round(f.charSpacing * 1000 / f.fontSize))
+}
+
+// hScaled returns the width w, in thousandths of the font size, scaled
+// horizontally.
+func (f *Fpdf) hScaled(w int) int {
+	if f.hScaling == 100 {
+		return w
+	}
+	return int(math.Round(float64(w) * f.hScaling / 100))
+}
+
+// textWidthMax returns the width available for text in a cell of width w,
+// in thousandths of the font size before horizontal scaling.
+func (f *Fpdf) textWidthMax(w float64) float64 {
+	wmax := (w - 2*f.cMargin) * 1000 / f.fontSize
+	if f.hScaling != 100 {
+		wmax = wmax * 100 / f.hScaling
+	}
+	return wmax
+}
+
 // SetTextRenderingMode sets the rendering mode of following text.
 // The mode can be as follows:
 // 0: Fill text
@@ -2323,9 +2539,9 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 
 	borderStr = strings.ToUpper(borderStr)
 	k := f.k
-	if f.y+h > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptPageBreak() {
+	if f.y+h > f.pageBreakTrigger && !f.inHeader && !f.inFooter && !f.nextColumn() && f.acceptPageBreak() {
 		// Automatic page break
-		x := f.x
+		x, left := f.x, f.lMargin
 		ws := f.ws
 		// dbg("auto page break, x %.2f, ws %.2f", x, ws)
 		if ws > 0 {
@@ -2337,11 +2553,23 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 			return
 		}
 		f.x = x
+		if f.columns.n > 1 {
+			// Continue in the first column
+			f.x = f.lMargin + x - left
+		}
 		if ws > 0 {
 			f.ws = ws
 			f.outf("%.3f Tw", ws*k)
 		}
 	}
+	if f.tagAuto(len(txtStr) == 0) {
+		defer f.tagAutoEnd(ln)
+	}
+	if len(txtStr) > 0 && (link > 0 || len(linkStr) > 0) && f.tagActive() &&
+		f.tag.elems[f.tagTop()].tag != "Link" {
+		f.BeginTag("Link")
+		defer f.EndTag()
+	}
 	if w == 0 {
 		w = f.w - f.rMargin - f.x
 	}
@@ -2386,6 +2614,8 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 	}
 	if len(txtStr) > 0 {
 		var dx, dy float64
+		// txtStr is reversed for output in right-to-left mode
+		lineStr := txtStr
 		// Horizontal alignment
 		switch {
 		case strings.Contains(alignStr, "R"):
@@ -2420,19 +2650,21 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 		}
 		//If multibyte, Tw has no effect - do word spacing using an adjustment before each space
 		if (f.ws != 0 || alignStr == "J") && f.isCurrentUTF8 { // && f.ws != 0
-			if f.isRTL {
-				txtStr = reverseText(txtStr)
-			}
-			wmax := int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
+			txtStr = f.bidiVisual(txtStr)
+			wmax := int(math.Ceil(f.textWidthMax(w)))
 			for _, uni := range []rune(txtStr) {
 				f.currentFont.usedRunes[int(uni)] = int(uni)
 			}
 			space := f.escape(utf8toutf16(" ", false))
-			strSize := f.GetStringSymbolWidth(txtStr)
+			strSize := f.symbolWidth(lineStr)
 			s.printf("BT 0 Tw %.2f %.2f Td [", (f.x+dx)*k, (f.h-(f.y+.5*h+.3*f.fontSize))*k)
 			t := strings.Split(txtStr, " ")
-			shift := float64((wmax - strSize)) / float64(len(t)-1)
+			shift, spread := f.justifySpacing(float64(wmax-strSize), len(t)-1, len([]rune(lineStr))-1)
 			numt := len(t)
+			if f.textTJActive(lineStr) || spread != 0 {
+				s.printf("%s", f.textTJ(lineStr, shift, spread))
+				numt = 0
+			}
 			for i := 0; i < numt; i++ {
 				tx := t[i]
 				tx = "(" + f.escape(utf8toutf16(tx, false)) + ")"
@@ -2445,9 +2677,7 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 		} else {
 			var txt2 string
 			if f.isCurrentUTF8 {
-				if f.isRTL {
-					txtStr = reverseText(txtStr)
-				}
+				txtStr = f.bidiVisual(txtStr)
 				txt2 = f.escape(utf8toutf16(txtStr, false))
 				for _, uni := range []rune(txtStr) {
 					f.currentFont.usedRunes[int(uni)] = int(uni)
@@ -2460,21 +2690,25 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 			}
 			bt := (f.x + dx) * k
 			td := (f.h - (f.y + dy + .5*h + .3*f.fontSize)) * k
-			s.printf("BT %.2f %.2f Td (%s)Tj ET", bt, td, txt2)
+			if f.textTJActive(lineStr) {
+				s.printf("BT %.2f %.2f Td [%s] TJ ET", bt, td, f.textTJ(lineStr, 0, 0))
+			} else {
+				s.printf("BT %.2f %.2f Td (%s)Tj ET", bt, td, txt2)
+			}
 			//BT %.2F %.2F Td (%s) Tj ET',(f.x+dx)*k,(f.h-(f.y+.5*h+.3*f.FontSize))*k,txt2);
 		}
 
 		if f.underline {
-			s.printf(" %s", f.dounderline(f.x+dx, f.y+dy+.5*h+.3*f.fontSize, txtStr))
+			s.printf(" %s", f.dounderline(f.x+dx, f.y+dy+.5*h+.3*f.fontSize, lineStr))
 		}
 		if f.strikeout {
-			s.printf(" %s", f.dostrikeout(f.x+dx, f.y+dy+.5*h+.3*f.fontSize, txtStr))
+			s.printf(" %s", f.dostrikeout(f.x+dx, f.y+dy+.5*h+.3*f.fontSize, lineStr))
 		}
 		if f.colorFlag {
 			s.printf(" Q")
 		}
 		if link > 0 || len(linkStr) > 0 {
-			f.newLink(f.x+dx, f.y+dy+.5*h-.5*f.fontSize, f.GetStringWidth(txtStr), f.fontSize, link, linkStr)
+			f.newLink(f.x+dx, f.y+dy+.5*h-.5*f.fontSize, f.GetStringWidth(lineStr), f.fontSize, link, linkStr, lineStr)
 		}
 	}
 	str := s.String()
@@ -2494,17 +2728,6 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 	return
 }
 
-// Revert string to use in RTL languages
-func reverseText(text string) string {
-	oldText := []rune(text)
-	newText := make([]rune, len(oldText))
-	length := len(oldText) - 1
-	for i, r := range oldText {
-		newText[length-i] = r
-	}
-	return string(newText)
-}
-
 // Cell is a simpler version of CellFormat with no fill, border, links or
 // special alignment. The Cell_strikeout() example demonstrates this method.
 func (f *Fpdf) Cell(w, h float64, txtStr string) {
@@ -2523,8 +2746,9 @@ func (f *Fpdf) Cellf(w, h float64, fmtStr string, args ...interface{}) {
 // used to determine the total height of wrapped text for vertical placement
 // purposes.
 //
-// This method is useful for codepage-based fonts only. For UTF-8 encoded text,
-// use SplitText().
+// With a UTF-8 font, txt is decoded as UTF-8 and the lines are returned in
+// logical order: CellFormat() puts the right-to-left runs of each line in
+// display order. SplitText() does the same for strings.
 //
 // You can use MultiCell if you want to print a text on several lines in a
 // simple way.
@@ -2532,27 +2756,67 @@ func (f *Fpdf) SplitLines(txt []byte, w float64) [][]byte {
 	// Function contributed by Bruno Michel
 	lines := [][]byte{}
 	cw := f.currentFont.Cw
-	wmax := int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
+	wmax := int(math.Ceil(f.textWidthMax(w)))
+	cs := f.charSpacingUnits()
 	s := bytes.Replace(txt, []byte("\r"), []byte{}, -1)
 	nb := len(s)
 	for nb > 0 && s[nb-1] == '\n' {
 		nb--
 	}
 	s = s[0:nb]
+	// The advances of shaped text or text in fallback fonts are those of the
+	// whole text, found by the index of the rune that starts at each byte
+	var adv, runeIdx []int
+	if f.isCurrentUTF8 {
+		if adv = f.textAdvances([]rune(string(s))); adv != nil {
+			runeIdx = make([]int, nb)
+			k := 0
+			for b := range string(s) {
+				runeIdx[b] = k
+				k++
+			}
+		}
+	}
+	kerning := adv == nil && f.kernActive()
 	sep := -1
 	i := 0
 	j := 0
 	l := 0
+	var prev rune
 	for i < nb {
-		c := s[i]
-		l += cw[c]
+		c, size := rune(s[i]), 1
+		switch {
+		case adv != nil:
+			c, size = utf8.DecodeRune(s[i:])
+			l += adv[runeIdx[i]] + cs
+		case f.isCurrentUTF8:
+			c, size = utf8.DecodeRune(s[i:])
+			l += f.runeWidth(c) + cs
+		default:
+			l += cw[c] + cs
+		}
+		if i > j && kerning {
+			l += f.kern(prev, c)
+		}
+		prev = c
 		if c == ' ' || c == '\t' || c == '\n' {
 			sep = i
 		}
 		if c == '\n' || l > wmax {
+			if c != '\n' {
+				if hyph := f.hyphenBreakBytes(s, j, i, wmax); hyph >= 0 {
+					line := append(append([]byte{}, s[j:hyph]...), '-')
+					lines = append(lines, line)
+					sep = -1
+					i = hyph
+					j = i
+					l = 0
+					continue
+				}
+			}
 			if sep == -1 {
 				if i == j {
-					i++
+					i += size
 				}
 				sep = i
 			} else {
@@ -2563,7 +2827,7 @@ func (f *Fpdf) SplitLines(txt []byte, w float64) [][]byte {
 			j = i
 			l = 0
 		} else {
-			i++
+			i += size
 		}
 	}
 	if i != j {
@@ -2599,6 +2863,9 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 	if f.err != nil {
 		return
 	}
+	if f.tagAuto(len(txtStr) == 0) {
+		defer f.tagAutoEnd(0)
+	}
 	// dbg("MultiCell")
 	if alignStr == "" {
 		alignStr = "J"
@@ -2607,9 +2874,18 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 	if w == 0 {
 		w = f.w - f.rMargin - f.x
 	}
-	wmax := int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
+	wmax := int(math.Ceil(f.textWidthMax(w)))
+	cs := f.charSpacingUnits()
 	s := strings.Replace(txtStr, "\r", "", -1)
 	srune := []rune(s)
+	if f.widowLines > 1 || f.orphanLines > 1 {
+		defer f.restoreBreakTrigger()
+		if f.isCurrentUTF8 {
+			f.keepLines(len(f.SplitText(s, w)), h)
+		} else {
+			f.keepLines(len(f.SplitLines([]byte(s), w)), h)
+		}
+	}
 
 	// remove extra line breaks
 	var nb int
@@ -2658,6 +2934,22 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 			}
 		}
 	}
+	// Widths of the characters if text is shaped or shown in fallback fonts
+	adv := f.textAdvances(srune)
+	if f.isCurrentUTF8 {
+		defer func(para int) { f.bidiPara = para }(f.bidiPara)
+		f.bidiPara = f.bidiParagraph(srune)
+	}
+	// Lines are shortened to the space left by exclusion areas, shifting them
+	// from the left side of the cells by shift
+	lw, shift := w, 0.0
+	lineStart := func() {
+		x0 := f.x - shift
+		f.x, lw = f.lineSpace(x0, x0+w, h, false)
+		shift = f.x - x0
+		wmax = int(math.Ceil(f.textWidthMax(lw)))
+	}
+	lineStart()
 	sep := -1
 	i := 0
 	j := 0
@@ -2683,15 +2975,16 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 			if f.isCurrentUTF8 {
 				newAlignStr := alignStr
 				if newAlignStr == "J" {
-					if f.isRTL {
+					if f.bidiPara == 2 {
 						newAlignStr = "R"
 					} else {
 						newAlignStr = "L"
 					}
 				}
-				f.CellFormat(w, h, string(srune[j:i]), b, 2, newAlignStr, fill, 0, "")
+				f.CellFormat(lw, h, string(srune[j:i]), b, 2, newAlignStr, fill, 0, "")
+				f.bidiPara = f.bidiParagraph(srune[i+1:])
 			} else {
-				f.CellFormat(w, h, s[j:i], b, 2, alignStr, fill, 0, "")
+				f.CellFormat(lw, h, s[j:i], b, 2, alignStr, fill, 0, "")
 			}
 			i++
 			sep = -1
@@ -2702,6 +2995,7 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 			if len(borderStr) > 0 && nl == 2 {
 				b = b2
 			}
+			lineStart()
 			continue
 		}
 		if c == ' ' || isChinese(c) {
@@ -2713,14 +3007,47 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 			f.err = fmt.Errorf("character outside the supported range: %s", string(c))
 			return
 		}
-		if cw[int(c)] == 0 { //Marker width 0 used for missing symbols
+		l += cs
+		if adv != nil {
+			l += adv[i]
+		} else if cw[int(c)] == 0 { //Marker width 0 used for missing symbols
 			l += f.currentFont.Desc.MissingWidth
 		} else if cw[int(c)] != 65535 { //Marker width 65535 used for zero width symbols
 			l += cw[int(c)]
 		}
+		if i > j && f.isCurrentUTF8 && adv == nil {
+			l += f.kern(srune[i-1], c)
+		}
 		if l > wmax {
 			// Automatic line break
-			if sep == -1 {
+			hyph := -1
+			if f.hyphenator != nil {
+				if f.isCurrentUTF8 {
+					hyph = f.hyphenBreak(srune, j, i, wmax)
+				} else {
+					hyph = f.hyphenBreakBytes([]byte(s), j, i, wmax)
+				}
+			}
+			if hyph >= 0 {
+				// Hyphenated word
+				var line string
+				if f.isCurrentUTF8 {
+					line = string(srune[j:hyph]) + "-"
+				} else {
+					line = s[j:hyph] + "-"
+				}
+				if alignStr == "J" {
+					ns = strings.Count(line, " ")
+					if ns > 0 {
+						f.ws = float64(wmax-f.symbolWidth(line)) / 1000 * f.fontSize / float64(ns)
+					} else {
+						f.ws = 0
+					}
+					f.outf("%.3f Tw", f.ws*f.k)
+				}
+				f.CellFormat(lw, h, line, b, 2, alignStr, fill, 0, "")
+				i = hyph
+			} else if sep == -1 {
 				if i == j {
 					i++
 				}
@@ -2729,9 +3056,9 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 					f.out("0 Tw")
 				}
 				if f.isCurrentUTF8 {
-					f.CellFormat(w, h, string(srune[j:i]), b, 2, alignStr, fill, 0, "")
+					f.CellFormat(lw, h, string(srune[j:i]), b, 2, alignStr, fill, 0, "")
 				} else {
-					f.CellFormat(w, h, s[j:i], b, 2, alignStr, fill, 0, "")
+					f.CellFormat(lw, h, s[j:i], b, 2, alignStr, fill, 0, "")
 				}
 			} else {
 				if alignStr == "J" {
@@ -2743,9 +3070,9 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 					f.outf("%.3f Tw", f.ws*f.k)
 				}
 				if f.isCurrentUTF8 {
-					f.CellFormat(w, h, string(srune[j:sep]), b, 2, alignStr, fill, 0, "")
+					f.CellFormat(lw, h, string(srune[j:sep]), b, 2, alignStr, fill, 0, "")
 				} else {
-					f.CellFormat(w, h, s[j:sep], b, 2, alignStr, fill, 0, "")
+					f.CellFormat(lw, h, s[j:sep], b, 2, alignStr, fill, 0, "")
 				}
 				i = sep + 1
 			}
@@ -2757,6 +3084,7 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 			if len(borderStr) > 0 && nl == 2 {
 				b = b2
 			}
+			lineStart()
 		} else {
 			i++
 		}
@@ -2771,15 +3099,15 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 	}
 	if f.isCurrentUTF8 {
 		if alignStr == "J" {
-			if f.isRTL {
+			if f.bidiPara == 2 {
 				alignStr = "R"
 			} else {
 				alignStr = ""
 			}
 		}
-		f.CellFormat(w, h, string(srune[j:i]), b, 2, alignStr, fill, 0, "")
+		f.CellFormat(lw, h, string(srune[j:i]), b, 2, alignStr, fill, 0, "")
 	} else {
-		f.CellFormat(w, h, s[j:i], b, 2, alignStr, fill, 0, "")
+		f.CellFormat(lw, h, s[j:i], b, 2, alignStr, fill, 0, "")
 	}
 	f.x = f.lMargin
 }
@@ -2787,13 +3115,21 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 // write outputs text in flowing mode
 func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 	// dbg("Write")
+	if f.tagAuto(len(txtStr) == 0) {
+		defer f.tagAutoEnd(0)
+	}
 	cw := f.currentFont.Cw
-	w := f.w - f.rMargin - f.x
-	wmax := (w - 2*f.cMargin) * 1000 / f.fontSize
+	// Text continues in the space left by exclusion areas after the current
+	// position
+	var w float64
+	f.x, w = f.lineSpace(f.x, f.w-f.rMargin, h, f.x > f.lMargin)
+	wmax := f.textWidthMax(w)
+	cs := f.charSpacingUnits()
 	s := strings.Replace(txtStr, "\r", "", -1)
+	srune := []rune(s)
 	var nb int
 	if f.isCurrentUTF8 {
-		nb = len([]rune(s))
+		nb = len(srune)
 		if nb == 1 && s == " " {
 			f.x += f.GetStringWidth(s)
 			return
@@ -2801,6 +3137,12 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 	} else {
 		nb = len(s)
 	}
+	adv := f.textAdvances(srune)
+	kerning := adv == nil && f.kernActive()
+	if f.isCurrentUTF8 {
+		defer func(para int) { f.bidiPara = para }(f.bidiPara)
+		f.bidiPara = f.bidiParagraph(srune)
+	}
 	sep := -1
 	i := 0
 	j := 0
@@ -2810,14 +3152,15 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 		// Get next character
 		var c rune
 		if f.isCurrentUTF8 {
-			c = []rune(s)[i]
+			c = srune[i]
 		} else {
 			c = rune(byte(s[i]))
 		}
 		if c == '\n' {
 			// Explicit line break
 			if f.isCurrentUTF8 {
-				f.CellFormat(w, h, string([]rune(s)[j:i]), "", 2, "", false, link, linkStr)
+				f.CellFormat(w, h, string(srune[j:i]), "", 2, "", false, link, linkStr)
+				f.bidiPara = f.bidiParagraph(srune[i+1:])
 			} else {
 				f.CellFormat(w, h, s[j:i], "", 2, "", false, link, linkStr)
 			}
@@ -2825,10 +3168,9 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 			sep = -1
 			j = i
 			l = 0.0
-			if nl == 1 {
-				f.x = f.lMargin
-				w = f.w - f.rMargin - f.x
-				wmax = (w - 2*f.cMargin) * 1000 / f.fontSize
+			if nl == 1 || len(f.exclusions) > 0 {
+				f.x, w = f.lineSpace(f.lMargin, f.w-f.rMargin, h, false)
+				wmax = f.textWidthMax(w)
 			}
 			nl++
 			continue
@@ -2836,16 +3178,22 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 		if c == ' ' {
 			sep = i
 		}
-		l += float64(cw[int(c)])
+		if adv != nil {
+			l += float64(adv[i] + cs)
+		} else {
+			l += float64(cw[int(c)] + cs)
+		}
+		if i > j && kerning {
+			l += float64(f.kern(srune[i-1], c))
+		}
 		if l > wmax {
 			// Automatic line break
 			if sep == -1 {
 				if f.x > f.lMargin {
 					// Move to next line
-					f.x = f.lMargin
 					f.y += h
-					w = f.w - f.rMargin - f.x
-					wmax = (w - 2*f.cMargin) * 1000 / f.fontSize
+					f.x, w = f.lineSpace(f.lMargin, f.w-f.rMargin, h, false)
+					wmax = f.textWidthMax(w)
 					i++
 					nl++
 					continue
@@ -2854,13 +3202,13 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 					i++
 				}
 				if f.isCurrentUTF8 {
-					f.CellFormat(w, h, string([]rune(s)[j:i]), "", 2, "", false, link, linkStr)
+					f.CellFormat(w, h, string(srune[j:i]), "", 2, "", false, link, linkStr)
 				} else {
 					f.CellFormat(w, h, s[j:i], "", 2, "", false, link, linkStr)
 				}
 			} else {
 				if f.isCurrentUTF8 {
-					f.CellFormat(w, h, string([]rune(s)[j:sep]), "", 2, "", false, link, linkStr)
+					f.CellFormat(w, h, string(srune[j:sep]), "", 2, "", false, link, linkStr)
 				} else {
 					f.CellFormat(w, h, s[j:sep], "", 2, "", false, link, linkStr)
 				}
@@ -2869,10 +3217,9 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 			sep = -1
 			j = i
 			l = 0.0
-			if nl == 1 {
-				f.x = f.lMargin
-				w = f.w - f.rMargin - f.x
-				wmax = (w - 2*f.cMargin) * 1000 / f.fontSize
+			if nl == 1 || len(f.exclusions) > 0 {
+				f.x, w = f.lineSpace(f.lMargin, f.w-f.rMargin, h, false)
+				wmax = f.textWidthMax(w)
 			}
 			nl++
 		} else {
@@ -2882,9 +3229,9 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 	// Last chunk
 	if i != j {
 		if f.isCurrentUTF8 {
-			f.CellFormat(l/1000*f.fontSize, h, string([]rune(s)[j:]), "", 0, "", false, link, linkStr)
+			f.CellFormat(l/1000*f.fontSize*f.hScaling/100, h, string(srune[j:]), "", 0, "", false, link, linkStr)
 		} else {
-			f.CellFormat(l/1000*f.fontSize, h, s[j:], "", 0, "", false, link, linkStr)
+			f.CellFormat(l/1000*f.fontSize*f.hScaling/100, h, s[j:], "", 0, "", false, link, linkStr)
 		}
 	}
 }
@@ -2977,6 +3324,7 @@ func (f *Fpdf) WriteAligned(width, lineHeight float64, textStr, alignStr string)
 //
 // This method is demonstrated in the example for MultiCell.
 func (f *Fpdf) Ln(h float64) {
+	f.tagEndRow()
 	f.x = f.lMargin
 	if h < 0 {
 		f.y += f.lasth
@@ -3005,7 +3353,7 @@ func (f *Fpdf) ImageTypeFromMime(mimeStr string) (tp string) {
 	return
 }
 
-func (f *Fpdf) imageOut(info *ImageInfoType, x, y, w, h float64, allowNegativeX, flow bool, link int, linkStr string) {
+func (f *Fpdf) imageOut(info *ImageInfoType, x, y, w, h float64, flow bool, options ImageOptions, link int, linkStr string) {
 	// Automatic width and height calculation if needed
 	if w == 0 && h == 0 {
 		// Put image at 96 dpi
@@ -3036,28 +3384,54 @@ func (f *Fpdf) imageOut(info *ImageInfoType, x, y, w, h float64, allowNegativeX,
 	}
 	// Flowing mode
 	if flow {
-		if f.y+h > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptPageBreak() {
+		if f.y+h > f.pageBreakTrigger && !f.inHeader && !f.inFooter && !f.nextColumn() && f.acceptPageBreak() {
 			// Automatic page break
-			x2 := f.x
+			x2, left := f.x, f.lMargin
 			f.AddPageFormat(f.curOrientation, f.curPageSize)
 			if f.err != nil {
 				return
 			}
 			f.x = x2
+			if f.columns.n > 1 {
+				f.x = f.lMargin + x2 - left
+			}
 		}
 		y = f.y
-		f.y += h
+		if options.Float == "" {
+			f.y += h
+		}
 	}
-	if !allowNegativeX {
+	if !options.AllowNegativePosition {
 		if x < 0 {
 			x = f.x
 		}
 	}
+	switch options.Float {
+	case "":
+	case "L", "R":
+		left, right := f.lMargin, f.w-f.rMargin
+		if f.columns.n > 1 {
+			left, right = f.columns.lMargin, f.w-f.columns.rMargin
+		}
+		x = left
+		if options.Float == "R" {
+			x = right - w
+		}
+		m := options.FloatMargin
+		f.AddExclusion(x-m, y-m, w+2*m, h+2*m)
+	default:
+		f.SetErrorf("invalid float mode %s, expecting \"L\" or \"R\"", options.Float)
+		return
+	}
 	// dbg("h %.2f", h)
+	altStr := options.AltText
+	if f.tagFigure(altStr) {
+		defer f.EndTag()
+	}
 	// q 85.04 0 0 NaN 28.35 NaN cm /I2 Do Q
 	f.outf("q %.5f 0 0 %.5f %.5f %.5f cm /I%s Do Q", w*f.k, h*f.k, x*f.k, (f.h-(y+h))*f.k, info.i)
 	if link > 0 || len(linkStr) > 0 {
-		f.newLink(x, y, w, h, link, linkStr)
+		f.newLink(x, y, w, h, link, linkStr, altStr)
 	}
 }
 
@@ -3106,6 +3480,12 @@ func (f *Fpdf) Image(imageNameStr string, x, y, w, h float64, flow bool, tp stri
 // If flow is true, the current y value is advanced after placing the image and
 // a page break may be made if necessary.
 //
+// If options.Float is "L" or "R", the image is put against the left or right
+// margin of the page, at the current ordinate if flow is true or at y
+// otherwise, and x is ignored. The current position is left unchanged and the
+// area of the image, enlarged by options.FloatMargin on each side, is added
+// with AddExclusion(), so that text output next flows around the image.
+//
 // If link refers to an internal page anchor (that is, it is non-zero; see
 // AddLink()), the image will be a clickable internal link. Otherwise, if
 // linkStr specifies a URL, the image will be a clickable external link.
@@ -3117,7 +3497,7 @@ func (f *Fpdf) ImageOptions(imageNameStr string, x, y, w, h float64, flow bool,
 	if f.err != nil {
 		return
 	}
-	f.imageOut(info, x, y, w, h, options.AllowNegativePosition, flow, link, linkStr)
+	f.imageOut(info, x, y, w, h, flow, options, link, linkStr)
 	return
 }
 
@@ -3148,10 +3528,18 @@ func (f *Fpdf) RegisterImageReader(imgName, tp string, r io.Reader) (info *Image
 //
 // AllowNegativePosition can be set to true in order to prevent the default
 // coercion of negative x values to the current x position.
+//
+// AltText is the alternate description of the image. In a tagged document
+// (see SetTagged()) an image with an alternate description is tagged as a
+// figure; other images are marked as artifacts unless they are put within an
+// element started with BeginTag().
 type ImageOptions struct {
 	ImageType             string
 	ReadDpi               bool
 	AllowNegativePosition bool
+	AltText               string
+	Float                 string  // "L" or "R" for an image that text flows around; see ImageOptions()
+	FloatMargin           float64 // space between a floating image and the text
 }
 
 // RegisterImageOptionsReader registers an image, reading it from Reader r, adding it
@@ -3423,10 +3811,27 @@ func (f *Fpdf) SetXY(x, y float64) {
 // string for this argument will be replaced with a random value, effectively
 // prohibiting full access to the document.
 func (f *Fpdf) SetProtection(actionFlag byte, userPassStr, ownerPassStr string) {
+	f.SetProtectionExt(actionFlag, userPassStr, ownerPassStr, EncryptionRC4)
+}
+
+// SetProtectionExt is like SetProtection() but lets the encryption algorithm
+// be selected. algorithm is one of EncryptionRC4 (40-bit RC4, the algorithm
+// used by SetProtection()), EncryptionAES128 (128-bit AES, PDF 1.6) or
+// EncryptionAES256 (256-bit AES, PDF 2.0). Strings and streams, including
+// embedded fonts and attachments, are encrypted with the selected algorithm.
+//
+// The SetProtectionExt() example demonstrates this method.
+func (f *Fpdf) SetProtectionExt(actionFlag byte, userPassStr, ownerPassStr string, algorithm int) {
 	if f.err != nil {
 		return
 	}
-	f.protect.setProtection(actionFlag, userPassStr, ownerPassStr)
+	switch algorithm {
+	case EncryptionRC4, EncryptionAES128, EncryptionAES256:
+		f.protect.setProtection(actionFlag, userPassStr, ownerPassStr, algorithm)
+	default:
+		f.err = fmt.Errorf("unsupported encryption algorithm %d", algorithm)
+	}
+	f.pdfaCheckProtection()
 }
 
 // OutputAndClose sends the PDF document to the writer specified by w. This
@@ -3547,11 +3952,13 @@ func (f *Fpdf) beginpage(orientationStr string, size SizeType) {
 	if orientationStr != f.defOrientation || size.Wd != f.defPageSize.Wd || size.Ht != f.defPageSize.Ht {
 		f.pageSizes[f.page] = SizeType{f.wPt, f.hPt}
 	}
+	f.tagPageBegin()
 	return
 }
 
 func (f *Fpdf) endpage() {
 	f.EndLayer()
+	f.tagPageEnd()
 	f.state = 1
 }
 
@@ -3592,9 +3999,7 @@ func (f *Fpdf) escape(s string) string {
 // textstring formats a text string
 func (f *Fpdf) textstring(s string) string {
 	if f.protect.encrypted {
-		b := []byte(s)
-		f.protect.rc4(uint32(f.n), &b)
-		s = string(b)
+		s = string(f.protect.encrypt(uint32(f.n), []byte(s)))
 	}
 	return "(" + f.escape(s) + ")"
 }
@@ -3619,7 +4024,7 @@ func (f *Fpdf) SetUnderlineThickness(thickness float64) {
 func (f *Fpdf) dounderline(x, y float64, txt string) string {
 	up := float64(f.currentFont.Up)
 	ut := float64(f.currentFont.Ut) * f.userUnderlineThickness
-	w := f.GetStringWidth(txt) + f.ws*float64(blankCount(txt))
+	w := f.GetStringWidth(txt) + f.ws*float64(blankCount(txt))*f.hScaling/100
 	return sprintf("%.2f %.2f %.2f %.2f re f", x*f.k,
 		(f.h-(y-up/1000*f.fontSize))*f.k, w*f.k, -ut/1000*f.fontSizePt)
 }
@@ -3627,7 +4032,7 @@ func (f *Fpdf) dounderline(x, y float64, txt string) string {
 func (f *Fpdf) dostrikeout(x, y float64, txt string) string {
 	up := float64(f.currentFont.Up)
 	ut := float64(f.currentFont.Ut)
-	w := f.GetStringWidth(txt) + f.ws*float64(blankCount(txt))
+	w := f.GetStringWidth(txt) + f.ws*float64(blankCount(txt))*f.hScaling/100
 	return sprintf("%.2f %.2f %.2f %.2f re f", x*f.k,
 		(f.h-(y+4*up/1000*f.fontSize))*f.k, w*f.k, -ut/1000*f.fontSizePt)
 }
@@ -3745,7 +4150,7 @@ func (f *Fpdf) newobj() {
 func (f *Fpdf) putstream(b []byte) {
 	// dbg("putstream")
 	if f.protect.encrypted {
-		f.protect.rc4(uint32(f.n), &b)
+		b = f.protect.encrypt(uint32(f.n), b)
 	}
 	f.out("stream")
 	f.out(string(b))
@@ -3840,6 +4245,7 @@ func (f *Fpdf) SetModificationDate(tm time.Time) {
 // SetJavascript adds Adobe JavaScript to the document.
 func (f *Fpdf) SetJavascript(script string) {
 	f.javascript = &script
+	f.pdfaCheckJavascript()
 }
 
 // RegisterAlias adds an (alias, replacement) pair to the document so we can
@@ -3874,6 +4280,45 @@ func (f *Fpdf) replaceAliases() {
 	}
 }
 
+// pageObjNum returns the object number of the specified 1-based page. It is
+// valid once putpages() has started.
+func (f *Fpdf) pageObjNum(page int) int {
+	return f.nFirstPage + 2*(page-1)
+}
+
+// putLinkAnnotation appends the dictionary of the link annotation pl to out.
+func (f *Fpdf) putLinkAnnotation(out *fmtBuffer, pl linkType) {
+	out.printf("<</Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] /F 4 ",
+		pl.x, pl.y, pl.x+pl.wd, pl.y-pl.ht)
+	if pl.link == 0 {
+		out.printf("/A <</S /URI /URI %s>>", f.textstring(pl.linkStr))
+	} else {
+		l := f.links[pl.link]
+		var h float64
+		sz, ok := f.pageSizes[l.page]
+		if ok {
+			h = sz.Ht
+		} else if f.defOrientation == "P" {
+			h = f.defPageSize.Ht * f.k
+		} else {
+			h = f.defPageSize.Wd * f.k
+		}
+		// dbg("h [%.2f], l.y [%.2f] f.k [%.2f]\n", h, l.y, f.k)
+		out.printf("/Dest [%d 0 R /XYZ 0 %.2f null]", f.pageObjNum(l.page), h-l.y*f.k)
+	}
+	if pl.objNum > 0 {
+		contentsStr := pl.contentsStr
+		if contentsStr == "" {
+			contentsStr = pl.linkStr
+		}
+		out.printf(" /StructParent %d", pl.structKey)
+		if contentsStr != "" {
+			out.printf(" /Contents %s", f.textstring(utf8toutf16(contentsStr)))
+		}
+	}
+	out.printf(">>")
+}
+
 func (f *Fpdf) putpages() {
 	var wPt, hPt float64
 	var pageSize SizeType
@@ -3892,6 +4337,10 @@ func (f *Fpdf) putpages() {
 		hPt = f.defPageSize.Wd * f.k
 	}
 	pagesObjectNumbers := make([]int, nb+1) // 1-based
+	f.nFirstPage = f.n + 1
+	// Form fields and tagged link annotations are written right after the
+	// pages
+	f.tagAssignObjNums(f.formAssignObjNums(f.n + 2*nb + 1))
 	for n := 1; n <= nb; n++ {
 		// Page
 		f.newobj()
@@ -3906,32 +4355,27 @@ func (f *Fpdf) putpages() {
 			f.outf("/%s [%.2f %.2f %.2f %.2f]", t, pb.X, pb.Y, pb.Wd, pb.Ht)
 		}
 		f.out("/Resources 2 0 R")
+		if f.tag.on {
+			f.outf("/StructParents %d", n-1)
+		}
 		// Links
-		if len(f.pageLinks[n])+len(f.pageAttachments[n]) > 0 {
+		if len(f.pageLinks[n])+len(f.pageAttachments[n]) > 0 || f.formPageHasWidgets(n) {
 			var annots fmtBuffer
 			annots.printf("/Annots [")
 			for _, pl := range f.pageLinks[n] {
-				annots.printf("<</Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] ",
-					pl.x, pl.y, pl.x+pl.wd, pl.y-pl.ht)
-				if pl.link == 0 {
-					annots.printf("/A <</S /URI /URI %s>>>>", f.textstring(pl.linkStr))
+				if pl.objNum > 0 {
+					annots.printf("%d 0 R ", pl.objNum)
 				} else {
-					l := f.links[pl.link]
-					var sz SizeType
-					var h float64
-					sz, ok = f.pageSizes[l.page]
-					if ok {
-						h = sz.Ht
-					} else {
-						h = hPt
-					}
-					// dbg("h [%.2f], l.y [%.2f] f.k [%.2f]\n", h, l.y, f.k)
-					annots.printf("/Dest [%d 0 R /XYZ 0 %.2f null]>>", 1+2*l.page, h-l.y*f.k)
+					f.putLinkAnnotation(&annots, pl)
 				}
 			}
 			f.putAttachmentAnnotationLinks(&annots, n)
+			f.formPutAnnotations(&annots, n)
 			annots.printf("]")
 			f.out(annots.String())
+			if f.tag.on {
+				f.out("/Tabs /S")
+			}
 		}
 		if f.pdfVersion > "1.3" {
 			f.out("/Group <</Type /Group /S /Transparency /CS /DeviceRGB>>")
@@ -3942,10 +4386,10 @@ func (f *Fpdf) putpages() {
 		f.newobj()
 		if f.compress {
 			data := sliceCompress(f.pages[n].Bytes())
-			f.outf("<</Filter /FlateDecode /Length %d>>", len(data))
+			f.outf("<</Filter /FlateDecode /Length %d>>", f.protect.cipherLen(len(data)))
 			f.putstream(data)
 		} else {
-			f.outf("<</Length %d>>", f.pages[n].Len())
+			f.outf("<</Length %d>>", f.protect.cipherLen(f.pages[n].Len()))
 			f.putstream(f.pages[n].Bytes())
 		}
 		f.out("endobj")
@@ -4013,7 +4457,7 @@ func (f *Fpdf) putfonts() {
 					buf = append(buf, font[6+info.length1+6:info.length2]...)
 					font = buf
 				}
-				f.outf("<</Length %d", len(font))
+				f.outf("<</Length %d", f.protect.cipherLen(len(font)))
 				if compressed {
 					f.out("/Filter /FlateDecode")
 				}
@@ -4109,6 +4553,12 @@ func (f *Fpdf) putfonts() {
 				usedRunes := font.usedRunes
 				delete(usedRunes, 0)
 				utf8FontStream := font.utf8File.GenerateCutFont(usedRunes)
+				// Fonts with CFF outlines are embedded as a bare CFF font
+				// program, whose charset maps glyphs to CIDs
+				cff := font.utf8File.outTablesData["CFF "]
+				if cff != nil {
+					utf8FontStream = cff
+				}
 				utf8FontSize := len(utf8FontStream)
 				compressedFontStream := sliceCompress(utf8FontStream)
 				CodeSignDictionary := font.utf8File.CodeSymbolDictionary
@@ -4117,19 +4567,35 @@ func (f *Fpdf) putfonts() {
 				f.newobj()
 				f.out(fmt.Sprintf("<</Type /Font\n/Subtype /Type0\n/BaseFont /%s\n/Encoding /Identity-H\n/DescendantFonts [%d 0 R]\n/ToUnicode %d 0 R>>\n"+"endobj", fontName, f.n+1, f.n+2))
 
+				subtype := "CIDFontType2"
+				if cff != nil {
+					subtype = "CIDFontType0"
+				}
 				f.newobj()
-				f.out("<</Type /Font\n/Subtype /CIDFontType2\n/BaseFont /" + fontName + "\n" +
+				f.out("<</Type /Font\n/Subtype /" + subtype + "\n/BaseFont /" + fontName + "\n" +
 					"/CIDSystemInfo " + strconv.Itoa(f.n+2) + " 0 R\n/FontDescriptor " + strconv.Itoa(f.n+3) + " 0 R")
 				if font.Desc.MissingWidth != 0 {
 					f.out("/DW " + strconv.Itoa(font.Desc.MissingWidth) + "")
 				}
 				f.generateCIDFontMap(&font, font.utf8File.LastRune)
-				f.out("/CIDToGIDMap " + strconv.Itoa(f.n+4) + " 0 R>>")
+				_, vertical := f.verticalFonts[key]
+				if vertical {
+					f.putVerticalMetrics(&font)
+				}
+				if cff != nil {
+					f.out(">>")
+				} else {
+					f.out("/CIDToGIDMap " + strconv.Itoa(f.n+4) + " 0 R>>")
+				}
 				f.out("endobj")
 
+				cmap := toUnicode
+				if sh := font.utf8File.shaper; sh != nil && len(sh.cidText) > 0 {
+					cmap = sh.toUnicode()
+				}
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(toUnicode)) + ">>")
-				f.putstream([]byte(toUnicode))
+				f.out("<</Length " + strconv.Itoa(f.protect.cipherLen(len(cmap))) + ">>")
+				f.putstream([]byte(cmap))
 				f.out("endobj")
 
 				// CIDInfo
@@ -4152,33 +4618,52 @@ func (f *Fpdf) putfonts() {
 				s.printf(" /ItalicAngle %d", font.Desc.ItalicAngle)
 				s.printf(" /StemV %d", font.Desc.StemV)
 				s.printf(" /MissingWidth %d", font.Desc.MissingWidth)
-				s.printf("/FontFile2 %d 0 R", f.n+2)
+				if cff != nil {
+					s.printf("/FontFile3 %d 0 R", f.n+1)
+				} else {
+					s.printf("/FontFile2 %d 0 R", f.n+2)
+				}
 				s.printf(">>")
 				f.out(s.String())
 				f.out("endobj")
 
-				// Embed CIDToGIDMap
-				cidToGidMap := make([]byte, 256*256*2)
+				if cff == nil {
+					// Embed CIDToGIDMap
+					cidToGidMap := make([]byte, 256*256*2)
 
-				for cc, glyph := range CodeSignDictionary {
-					cidToGidMap[cc*2] = byte(glyph >> 8)
-					cidToGidMap[cc*2+1] = byte(glyph & 0xFF)
-				}
+					for cc, glyph := range CodeSignDictionary {
+						cidToGidMap[cc*2] = byte(glyph >> 8)
+						cidToGidMap[cc*2+1] = byte(glyph & 0xFF)
+					}
 
-				cidToGidMap = sliceCompress(cidToGidMap)
-				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(cidToGidMap)) + "/Filter /FlateDecode>>")
-				f.putstream(cidToGidMap)
-				f.out("endobj")
+					cidToGidMap = sliceCompress(cidToGidMap)
+					f.newobj()
+					f.out("<</Length " + strconv.Itoa(f.protect.cipherLen(len(cidToGidMap))) + "/Filter /FlateDecode>>")
+					f.putstream(cidToGidMap)
+					f.out("endobj")
+				}
 
 				//Font file
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(compressedFontStream)))
+				f.out("<</Length " + strconv.Itoa(f.protect.cipherLen(len(compressedFontStream))))
 				f.out("/Filter /FlateDecode")
-				f.out("/Length1 " + strconv.Itoa(utf8FontSize))
+				if cff != nil {
+					f.out("/Subtype /CIDFontType0C")
+				} else {
+					f.out("/Length1 " + strconv.Itoa(utf8FontSize))
+				}
 				f.out(">>")
 				f.putstream(compressedFontStream)
 				f.out("endobj")
+
+				if vertical {
+					// Identity-V font sharing the CIDFont and ToUnicode CMap
+					f.newobj()
+					f.verticalFonts[key] = f.n
+					f.outf("<</Type /Font\n/Subtype /Type0\n/BaseFont /%s\n/Encoding /Identity-V\n/DescendantFonts [%d 0 R]\n/ToUnicode %d 0 R>>",
+						fontName, font.N+1, font.N+2)
+					f.out("endobj")
+				}
 			default:
 				f.err = fmt.Errorf("unsupported font type: %s", tp)
 				return
@@ -4406,7 +4891,7 @@ func (f *Fpdf) putimage(info *ImageInfoType) {
 	if info.smask != nil {
 		f.outf("/SMask %d 0 R", f.n+1)
 	}
-	f.outf("/Length %d>>", len(info.data))
+	f.outf("/Length %d>>", f.protect.cipherLen(len(info.data)))
 	f.putstream(info.data)
 	f.out("endobj")
 	// 	Soft mask
@@ -4428,10 +4913,10 @@ func (f *Fpdf) putimage(info *ImageInfoType) {
 		f.newobj()
 		if f.compress {
 			pal := sliceCompress(info.pal)
-			f.outf("<</Filter /FlateDecode /Length %d>>", len(pal))
+			f.outf("<</Filter /FlateDecode /Length %d>>", f.protect.cipherLen(len(pal)))
 			f.putstream(pal)
 		} else {
-			f.outf("<</Length %d>>", len(info.pal))
+			f.outf("<</Length %d>>", f.protect.cipherLen(len(info.pal)))
 			f.putstream(info.pal)
 		}
 		f.out("endobj")
@@ -4492,6 +4977,9 @@ func (f *Fpdf) putresourcedict() {
 		for _, key = range keyList {
 			font = f.fonts[key]
 			f.outf("/F%s %d 0 R", font.i, font.N)
+			if n, ok := f.verticalFonts[key]; ok {
+				f.outf("/F%sV %d 0 R", font.i, n)
+			}
 		}
 	}
 	f.out(">>")
@@ -4598,13 +5086,32 @@ func (f *Fpdf) putresources() {
 	f.out(">>")
 	f.out("endobj")
 	f.putjavascript()
+	f.pdfaPutOutputIntent()
 	if f.protect.encrypted {
 		f.newobj()
 		f.protect.objNum = f.n
 		f.out("<<")
 		f.out("/Filter /Standard")
-		f.out("/V 1")
-		f.out("/R 2")
+		switch f.protect.algorithm {
+		case EncryptionAES128:
+			f.out("/V 4")
+			f.out("/R 4")
+			f.out("/Length 128")
+			f.out("/CF <</StdCF <</Type /CryptFilter /CFM /AESV2 /AuthEvent /DocOpen /Length 16>>>>")
+			f.out("/StmF /StdCF /StrF /StdCF")
+		case EncryptionAES256:
+			f.out("/V 5")
+			f.out("/R 6")
+			f.out("/Length 256")
+			f.out("/CF <</StdCF <</Type /CryptFilter /CFM /AESV3 /AuthEvent /DocOpen /Length 32>>>>")
+			f.out("/StmF /StdCF /StrF /StdCF")
+			f.outf("/OE <%x>", f.protect.oeValue)
+			f.outf("/UE <%x>", f.protect.ueValue)
+			f.outf("/Perms <%x>", f.protect.permsValue)
+		default:
+			f.out("/V 1")
+			f.out("/R 2")
+		}
 		f.outf("/O (%s)", f.escape(string(f.protect.oValue)))
 		f.outf("/U (%s)", f.escape(string(f.protect.uValue)))
 		f.outf("/P %d", f.protect.pValue)
@@ -4642,9 +5149,9 @@ func (f *Fpdf) putinfo() {
 		f.outf("/Creator %s", f.textstring(f.creator))
 	}
 	creation := timeOrNow(f.creationDate)
-	f.outf("/CreationDate %s", f.textstring("D:"+creation.Format("20060102150405")))
+	f.outf("/CreationDate %s", f.textstring("D:"+f.pdfaDateStr(creation)))
 	mod := timeOrNow(f.modDate)
-	f.outf("/ModDate %s", f.textstring("D:"+mod.Format("20060102150405")))
+	f.outf("/ModDate %s", f.textstring("D:"+f.pdfaDateStr(mod)))
 }
 
 func (f *Fpdf) putcatalog() {
@@ -4652,11 +5159,11 @@ func (f *Fpdf) putcatalog() {
 	f.out("/Pages 1 0 R")
 	switch f.zoomMode {
 	case "fullpage":
-		f.out("/OpenAction [3 0 R /Fit]")
+		f.outf("/OpenAction [%d 0 R /Fit]", f.pageObjNum(1))
 	case "fullwidth":
-		f.out("/OpenAction [3 0 R /FitH null]")
+		f.outf("/OpenAction [%d 0 R /FitH null]", f.pageObjNum(1))
 	case "real":
-		f.out("/OpenAction [3 0 R /XYZ null null 1]")
+		f.outf("/OpenAction [%d 0 R /XYZ null null 1]", f.pageObjNum(1))
 	}
 	// } 	else if !is_string($this->zoomMode))
 	// 		$this->out('/OpenAction [3 0 R /XYZ null null '.sprintf('%.2f',$this->zoomMode/100).']');
@@ -4682,6 +5189,16 @@ func (f *Fpdf) putcatalog() {
 	}
 	// Layers
 	f.layerPutCatalog()
+	// Interactive form
+	f.formPutCatalog()
+	// Language and logical structure
+	f.tagPutCatalog()
+	// Metadata
+	if f.nXmp > 0 {
+		f.outf("/Metadata %d 0 R", f.nXmp)
+	}
+	// Output intent and associated files
+	f.pdfaPutCatalog()
 	// Name dictionary :
 	//	-> Javascript
 	//	-> Embedded files
@@ -4699,7 +5216,22 @@ func (f *Fpdf) putheader() {
 	if len(f.blendMap) > 0 && f.pdfVersion < "1.4" {
 		f.pdfVersion = "1.4"
 	}
+	if (f.signature != nil || f.pdfa.part > 0) && f.pdfVersion < "1.7" {
+		f.pdfVersion = "1.7"
+	}
+	if f.protect.encrypted {
+		switch {
+		case f.protect.algorithm == EncryptionAES256 && f.pdfVersion < "2.0":
+			f.pdfVersion = "2.0"
+		case f.protect.algorithm == EncryptionAES128 && f.pdfVersion < "1.6":
+			f.pdfVersion = "1.6"
+		}
+	}
 	f.outf("%%PDF-%s", f.pdfVersion)
+	if f.pdfa.part > 0 {
+		// Binary comment required by PDF/A
+		f.out("%\xe2\xe3\xcf\xd3")
+	}
 }
 
 func (f *Fpdf) puttrailer() {
@@ -4708,7 +5240,10 @@ func (f *Fpdf) puttrailer() {
 	f.outf("/Info %d 0 R", f.n-1)
 	if f.protect.encrypted {
 		f.outf("/Encrypt %d 0 R", f.protect.objNum)
-		f.out("/ID [()()]")
+		f.outf("/ID [<%x><%x>]", f.protect.id, f.protect.id)
+	} else if f.pdfa.part > 0 {
+		id := md5.Sum(f.buffer.Bytes())
+		f.outf("/ID [<%x><%x>]", id, id)
 	}
 }
 
@@ -4717,7 +5252,8 @@ func (f *Fpdf) putxmp() {
 		return
 	}
 	f.newobj()
-	f.outf("<< /Type /Metadata /Subtype /XML /Length %d >>", len(f.xmp))
+	f.nXmp = f.n
+	f.outf("<< /Type /Metadata /Subtype /XML /Length %d >>", f.protect.cipherLen(len(f.xmp)))
 	f.putstream(f.xmp)
 	f.out("endobj")
 }
@@ -4763,7 +5299,7 @@ func (f *Fpdf) putbookmarks() {
 			if o.last != -1 {
 				f.outf("/Last %d 0 R", n+o.last)
 			}
-			f.outf("/Dest [%d 0 R /XYZ 0 %.2f null]", 1+2*o.p, (f.h-o.y)*f.k)
+			f.outf("/Dest [%d 0 R /XYZ 0 %.2f null]", f.pageObjNum(o.p), (f.h-o.y)*f.k)
 			f.out("/Count 0>>")
 			f.out("endobj")
 		}
@@ -4780,11 +5316,18 @@ func (f *Fpdf) enddoc() {
 		return
 	}
 	f.layerEndDoc()
+	f.pdfaCheck()
+	if f.err != nil {
+		return
+	}
 	f.putheader()
 	// Embedded files
 	f.putAttachments()
 	f.putAnnotationsAttachments()
 	f.putpages()
+	f.formPutFields()
+	f.tagPutAnnotations()
+	f.tagPutStructTree()
 	f.putresources()
 	if f.err != nil {
 		return
@@ -4822,6 +5365,9 @@ func (f *Fpdf) enddoc() {
 	f.outf("%d", o)
 	f.out("%%EOF")
 	f.state = 3
+	if f.signature != nil {
+		f.signatureSign()
+	}
 	return
 }
 
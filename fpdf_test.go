@@ -19,14 +19,31 @@ package gofpdf_test
 import (
 	"bufio"
 	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
+	"math/big"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -1699,6 +1716,158 @@ func ExampleFpdf_SetProtection() {
 	// Successfully generated pdf/Fpdf_SetProtection.pdf
 }
 
+// ExampleFpdf_SetProtectionExt demonstrates AES encryption of a document with
+// an embedded font and an attachment. The user password is "123" and the
+// owner password is "abc".
+func ExampleFpdf_SetProtectionExt() {
+	for _, enc := range []struct {
+		algorithm int
+		name      string
+	}{{gofpdf.EncryptionAES128, "AES128"}, {gofpdf.EncryptionAES256, "AES256"}} {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetProtectionExt(gofpdf.CnProtectPrint, "123", "abc", enc.algorithm)
+		pdf.SetTitle("Protected with "+enc.name, true)
+		pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+		pdf.SetAttachments([]gofpdf.Attachment{{Content: []byte("Secret notes"), Filename: "notes.txt"}})
+		pdf.AddPage()
+		pdf.SetFont("dejavu", "", 12)
+		pdf.Write(10, "Password-protected with "+enc.name+": Ünïcödé text.")
+		fileStr := example.Filename("Fpdf_SetProtectionExt_" + enc.name)
+		err := pdf.OutputFileAndClose(fileStr)
+		example.Summary(err, fileStr)
+	}
+	// Output:
+	// Successfully generated pdf/Fpdf_SetProtectionExt_AES128.pdf
+	// Successfully generated pdf/Fpdf_SetProtectionExt_AES256.pdf
+}
+
+// TestSetProtectionExt verifies that the content stream of a document
+// encrypted with AES-128 or AES-256 is decrypted with the file key that a
+// reader computes from the user password and the encryption dictionary.
+func TestSetProtectionExt(t *testing.T) {
+	padding := []byte{
+		0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41,
+		0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+		0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80,
+		0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+	}
+	// literal returns the unescaped literal string of a dictionary key
+	literal := func(doc []byte, key string) (s []byte) {
+		j := bytes.Index(doc, []byte(key+" ("))
+		if j < 0 {
+			t.Fatalf("%s not found", key)
+		}
+		for j += len(key) + 2; doc[j] != ')'; j++ {
+			if doc[j] == '\\' {
+				j++
+				if doc[j] == 'r' {
+					s = append(s, '\r')
+					continue
+				}
+			}
+			s = append(s, doc[j])
+		}
+		return
+	}
+	hexValue := func(doc []byte, expr string) []byte {
+		m := regexp.MustCompile(expr).FindSubmatch(doc)
+		if m == nil {
+			t.Fatalf("%s not found", expr)
+		}
+		b, _ := hex.DecodeString(string(m[1]))
+		return b
+	}
+	// hash2B is algorithm 2.B of the PDF 2.0 specification
+	hash2B := func(pass, salt, udata []byte) []byte {
+		sum := sha256.Sum256(append(append(append([]byte{}, pass...), salt...), udata...))
+		k := sum[:]
+		var e []byte
+		for round := 0; round < 64 || int(e[len(e)-1]) > round-32; round++ {
+			k1 := bytes.Repeat(append(append(append([]byte{}, pass...), k...), udata...), 64)
+			block, _ := aes.NewCipher(k[:16])
+			e = make([]byte, len(k1))
+			cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+			mod := 0
+			for _, b := range e[:16] {
+				mod += int(b)
+			}
+			switch mod % 3 {
+			case 0:
+				h := sha256.Sum256(e)
+				k = h[:]
+			case 1:
+				h := sha512.Sum384(e)
+				k = h[:]
+			default:
+				h := sha512.Sum512(e)
+				k = h[:]
+			}
+		}
+		return k[:32]
+	}
+	for _, algorithm := range []int{gofpdf.EncryptionAES128, gofpdf.EncryptionAES256} {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(false)
+		pdf.SetProtectionExt(gofpdf.CnProtectPrint, "123", "abc", algorithm)
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.Cell(40, 10, "Secret text")
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		doc := buf.Bytes()
+		o, u := literal(doc, "/O"), literal(doc, "/U")
+		var key []byte
+		if algorithm == gofpdf.EncryptionAES128 {
+			// Algorithm 2, then the key of object 4, the page content
+			p, _ := strconv.Atoi(string(regexp.MustCompile(`/P (-?\d+)`).FindSubmatch(doc)[1]))
+			b := append(append([]byte("123"), padding...)[:32], o...)
+			b = append(b, byte(p), byte(p>>8), byte(p>>16), byte(p>>24))
+			b = append(b, hexValue(doc, `/ID \[<([0-9a-f]+)>`)...)
+			sum := md5.Sum(b)
+			for j := 0; j < 50; j++ {
+				sum = md5.Sum(sum[:])
+			}
+			sum = md5.Sum(append(append(sum[:], 4, 0, 0, 0, 0), "sAlT"...))
+			key = sum[:]
+		} else {
+			// Algorithm 2.A, with the passwords validated and the
+			// permissions decrypted with the file key
+			if !bytes.Equal(hash2B([]byte("123"), u[32:40], nil), u[:32]) {
+				t.Fatal("user password not validated")
+			}
+			if !bytes.Equal(hash2B([]byte("abc"), o[32:40], u[:48]), o[:32]) {
+				t.Fatal("owner password not validated")
+			}
+			block, _ := aes.NewCipher(hash2B([]byte("123"), u[40:48], nil))
+			key = make([]byte, 32)
+			cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, hexValue(doc, `/UE <([0-9a-f]+)>`))
+			block, _ = aes.NewCipher(key)
+			perms := make([]byte, 16)
+			block.Decrypt(perms, hexValue(doc, `/Perms <([0-9a-f]+)>`))
+			if string(perms[9:12]) != "adb" {
+				t.Fatalf("permissions %x not decrypted", perms)
+			}
+		}
+		m := regexp.MustCompile(`4 0 obj\n<</Length (\d+)>>\nstream\n`).FindSubmatchIndex(doc)
+		if m == nil {
+			t.Fatal("page content not found")
+		}
+		n, _ := strconv.Atoi(string(doc[m[2]:m[3]]))
+		data := doc[m[1] : m[1]+n]
+		if !bytes.HasPrefix(doc[m[1]+n:], []byte("\nendstream")) || n%aes.BlockSize != 0 {
+			t.Fatalf("length %d does not match the encrypted page content", n)
+		}
+		block, _ := aes.NewCipher(key)
+		plain := make([]byte, n-aes.BlockSize)
+		cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(plain, data[aes.BlockSize:])
+		if pad := int(plain[len(plain)-1]); pad > aes.BlockSize || !bytes.Contains(plain[:len(plain)-pad], []byte("(Secret text)Tj")) {
+			t.Fatalf("algorithm %d: page content not decrypted: %q", algorithm, plain)
+		}
+	}
+}
+
 // ExampleFpdf_Polygon displays equilateral polygons in a demonstration of the Polygon
 // function.
 func ExampleFpdf_Polygon() {
@@ -2944,3 +3113,2467 @@ func ExampleFpdf_SetModificationDate() {
 	// Output:
 	// Successfully generated pdf/Fpdf_SetModificationDate.pdf
 }
+
+// ExampleFpdf_AddTextField demonstrates the creation of an interactive form
+// with text fields, check boxes, a radio group and choice fields.
+func ExampleFpdf_AddTextField() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 12)
+	pdf.SetDrawColor(64, 64, 128)
+	pdf.SetFillColor(235, 240, 255)
+	opts := gofpdf.FormFieldOptions{Border: true, Fill: true}
+
+	label := func(y float64, str string) {
+		pdf.SetXY(20, y)
+		pdf.Cell(40, 8, str)
+	}
+
+	label(20, "Name")
+	opts.Value = "Grüße"
+	opts.Required = true
+	pdf.AddTextField("name", 60, 20, 100, 8, opts)
+	opts.Required = false
+
+	label(32, "Postal code")
+	opts.Value = ""
+	opts.MaxLen = 5
+	pdf.AddTextField("zip", 60, 32, 30, 8, opts)
+	opts.MaxLen = 0
+
+	label(44, "Comments")
+	opts.Multiline = true
+	opts.Value = "This field accepts several lines of text. The initial value is wrapped to the width of the field."
+	pdf.AddTextField("comments", 60, 44, 100, 24, opts)
+	opts.Multiline = false
+
+	label(74, "Subscribe")
+	pdf.AddCheckBox("subscribe", 60, 75, 6, true, opts)
+
+	label(86, "Contact by")
+	opts.Value = "mail"
+	pdf.AddRadioGroup("contact", []gofpdf.RadioButtonType{
+		{Value: "mail", X: 60, Y: 87, Size: 6},
+		{Value: "phone", X: 90, Y: 87, Size: 6},
+	}, opts)
+	pdf.SetXY(67, 86)
+	pdf.Cell(20, 8, "Mail")
+	pdf.SetXY(97, 86)
+	pdf.Cell(20, 8, "Phone")
+
+	label(98, "Country")
+	countries := []string{"Deutschland", "France", "Italia", "Österreich"}
+	opts.Value = "France"
+	pdf.AddChoiceField("country", 60, 98, 60, 8, countries, true, opts)
+
+	label(110, "Language")
+	opts.Value = "Italia"
+	opts.ReadOnly = true
+	pdf.AddChoiceField("language", 60, 110, 60, 24, countries, false, opts)
+
+	fileStr := example.Filename("Fpdf_AddTextField")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddTextField.pdf
+}
+
+// signatureCertificate returns a self-signed certificate and its private key
+// for signature tests and examples.
+func signatureCertificate() (*ecdsa.PrivateKey, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gofpdf example signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(crand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return key, cert, err
+}
+
+// ExampleFpdf_Sign demonstrates a document with a visible digital signature.
+// The appearance of the signature is defined by a template.
+func ExampleFpdf_Sign() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	key, cert, err := signatureCertificate()
+	if err != nil {
+		pdf.SetError(err)
+	}
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.MultiCell(0, 6, "This contract is signed digitally. Any modification of the "+
+		"document after signing invalidates the signature.", "", "", false)
+	appearance := pdf.CreateTemplateCustom(gofpdf.PointType{X: 0, Y: 0}, gofpdf.SizeType{Wd: 60, Ht: 20},
+		func(tpl *gofpdf.Tpl) {
+			tpl.SetDrawColor(0, 0, 128)
+			tpl.Rect(0.5, 0.5, 59, 19, "D")
+			tpl.SetFont("Helvetica", "I", 10)
+			tpl.SetXY(2, 3)
+			tpl.Cell(56, 6, "Digitally signed by")
+			tpl.SetFont("Helvetica", "B", 10)
+			tpl.SetXY(2, 10)
+			tpl.Cell(56, 6, "gofpdf example signer")
+		})
+	pdf.Sign(gofpdf.SignatureOptions{
+		Signer:       key,
+		Certificates: []*x509.Certificate{cert},
+		Name:         "gofpdf example signer",
+		Reason:       "Contract approval",
+		Location:     "Berlin",
+		X:            130, Y: 40, W: 60, H: 20,
+		Appearance: appearance,
+	})
+	fileStr := example.Filename("Fpdf_Sign")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_Sign.pdf
+}
+
+// TestSignTimestamp verifies the byte range, the message digest and the
+// signature of a signed document that includes a time stamp token from a
+// local stand-in time stamping authority.
+func TestSignTimestamp(t *testing.T) {
+	type attribute struct {
+		Type   asn1.ObjectIdentifier
+		Values []asn1.RawValue `asn1:"set"`
+	}
+	type signerInfo struct {
+		Version            int
+		Sid                asn1.RawValue
+		DigestAlgorithm    asn1.RawValue
+		SignedAttrs        asn1.RawValue
+		SignatureAlgorithm asn1.RawValue
+		Signature          []byte
+		UnsignedAttrs      []attribute `asn1:"optional,tag:1,set"`
+	}
+	type signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		EncapContentInfo asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+		SignerInfos      []signerInfo  `asn1:"set"`
+	}
+	type contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     signedData `asn1:"explicit,tag:0"`
+	}
+
+	// The stand-in authority accepts any request and returns a fixed token
+	token, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2})
+	tsa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Version        int
+			MessageImprint struct {
+				HashAlgorithm asn1.RawValue
+				HashedMessage []byte
+			}
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if _, err := asn1.Unmarshal(body, &req); err != nil || len(req.MessageImprint.HashedMessage) != 32 {
+			t.Errorf("invalid time stamp request")
+		}
+		resp, _ := asn1.Marshal(struct {
+			Status struct{ Status int }
+			Token  asn1.RawValue
+		}{Token: asn1.RawValue{FullBytes: token}})
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.Write(resp)
+	}))
+	defer tsa.Close()
+
+	key, cert, err := signatureCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.Cell(40, 10, "Signed and time stamped")
+	pdf.Sign(gofpdf.SignatureOptions{Signer: key, Certificates: []*x509.Certificate{cert},
+		TimestampURL: tsa.URL})
+	var buf bytes.Buffer
+	err = pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.Bytes()
+
+	var r [4]int
+	pos := bytes.Index(doc, []byte("/ByteRange ["))
+	if pos < 0 {
+		t.Fatal("byte range not found")
+	}
+	fmt.Sscanf(string(doc[pos:]), "/ByteRange [%d %d %d %d]", &r[0], &r[1], &r[2], &r[3])
+	if r[0] != 0 || r[2]+r[3] != len(doc) || doc[r[1]] != '<' || doc[r[2]-1] != '>' {
+		t.Fatalf("invalid byte range %v", r)
+	}
+	// The zero padding that follows the signature is ignored by asn1.Unmarshal
+	cms, err := hex.DecodeString(string(doc[r[1]+1 : r[2]-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ci contentInfo
+	if _, err = asn1.Unmarshal(cms, &ci); err != nil {
+		t.Fatal(err)
+	}
+	if len(ci.Content.SignerInfos) != 1 {
+		t.Fatal("expecting one signer")
+	}
+	si := ci.Content.SignerInfos[0]
+
+	// The signed attributes are signed as an explicit SET OF
+	signedAttrs := append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+	attrDigest := sha256.Sum256(signedAttrs)
+	if !ecdsa.VerifyASN1(&key.PublicKey, attrDigest[:], si.Signature) {
+		t.Fatal("signature does not verify")
+	}
+	var attrs []attribute
+	if _, err = asn1.UnmarshalWithParams(signedAttrs, &attrs, "set"); err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.New()
+	h.Write(doc[:r[1]])
+	h.Write(doc[r[2]:])
+	found := false
+	for _, a := range attrs {
+		if a.Type.Equal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}) {
+			var md []byte
+			asn1.Unmarshal(a.Values[0].FullBytes, &md)
+			found = bytes.Equal(md, h.Sum(nil))
+		}
+	}
+	if !found {
+		t.Fatal("message digest does not match document")
+	}
+	if len(si.UnsignedAttrs) != 1 || !bytes.Equal(si.UnsignedAttrs[0].Values[0].FullBytes, token) {
+		t.Fatal("time stamp token not found")
+	}
+}
+
+// ExampleFpdf_SetConformance demonstrates the generation of a PDF/A-3b
+// document with an embedded source file.
+func ExampleFpdf_SetConformance() {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr:        "mm",
+		SizeStr:        "A4",
+		ConformanceStr: gofpdf.ConformancePDFA3B,
+	})
+	pdf.SetTitle("Archived document", true)
+	pdf.SetAuthor("Jörg Müller", true)
+	pdf.SetSubject("PDF/A-3b conformance", true)
+	pdf.SetKeywords("archive, PDF/A", true)
+	pdf.SetCreationDate(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	file, err := ioutil.ReadFile("grid.go")
+	if err != nil {
+		pdf.SetError(err)
+	}
+	pdf.SetAttachments([]gofpdf.Attachment{{Content: file, Filename: "grid.go",
+		MimeType: "text/plain", Relationship: "Source"}})
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 14)
+	pdf.Write(8, "This document conforms to PDF/A-3b. Its fonts are embedded, "+
+		"its colors refer to an sRGB output intent and its source is attached.")
+	fileStr := example.Filename("Fpdf_SetConformance")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetConformance.pdf
+}
+
+// TestConformanceViolations verifies that features not permitted by PDF/A are
+// reported as errors.
+func TestConformanceViolations(t *testing.T) {
+	newDoc := func(levelStr string) *gofpdf.Fpdf {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetConformance(levelStr)
+		pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+		pdf.AddPage()
+		pdf.SetFont("dejavu", "", 12)
+		pdf.Cell(40, 10, "PDF/A")
+		return pdf
+	}
+	for _, c := range []struct {
+		name     string
+		levelStr string
+		fnc      func(pdf *gofpdf.Fpdf)
+	}{
+		{"core font", gofpdf.ConformancePDFA2B, func(pdf *gofpdf.Fpdf) { pdf.SetFont("Helvetica", "", 12) }},
+		{"encryption", gofpdf.ConformancePDFA2B, func(pdf *gofpdf.Fpdf) { pdf.SetProtection(0, "", "owner") }},
+		{"javascript", gofpdf.ConformancePDFA3B, func(pdf *gofpdf.Fpdf) { pdf.SetJavascript("print(true);") }},
+		{"spot color", gofpdf.ConformancePDFA3B, func(pdf *gofpdf.Fpdf) { pdf.AddSpotColor("PANTONE 145 CVC", 0, 42, 100, 25) }},
+		{"attachment", gofpdf.ConformancePDFA2B, func(pdf *gofpdf.Fpdf) {
+			pdf.SetAttachments([]gofpdf.Attachment{{Content: []byte("data"), Filename: "data.txt"}})
+		}},
+	} {
+		pdf := newDoc(c.levelStr)
+		c.fnc(pdf)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err == nil {
+			t.Errorf("%s: expected error with %s", c.name, c.levelStr)
+		}
+	}
+	pdf := newDoc(gofpdf.ConformancePDFA2B)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("<pdfaid:part>2</pdfaid:part>")) ||
+		!bytes.Contains(buf.Bytes(), []byte("/OutputIntents")) {
+		t.Fatal("conformance metadata or output intent missing")
+	}
+}
+
+// TestConformanceDates verifies that the dates of a conforming document carry
+// the minutes of their time zone offset.
+func TestConformanceDates(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+30*60)
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetConformance(gofpdf.ConformancePDFA2B)
+	pdf.SetCompression(false)
+	pdf.SetCreationDate(time.Date(2026, 1, 2, 3, 4, 5, 0, loc))
+	pdf.SetModificationDate(time.Date(2026, 2, 3, 4, 5, 6, 0, loc))
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 12)
+	pdf.Cell(40, 10, "PDF/A")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{
+		"/CreationDate (D:20260102030405+05'30')",
+		"/ModDate (D:20260203040506+05'30')",
+		"<xmp:CreateDate>2026-01-02T03:04:05+05:30</xmp:CreateDate>",
+		"<xmp:ModifyDate>2026-02-03T04:05:06+05:30</xmp:ModifyDate>",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(str)) {
+			t.Errorf("%s not found", str)
+		}
+	}
+}
+
+// ExampleFpdf_SetTagged demonstrates the generation of a tagged document with
+// headings, paragraphs, a table, a figure and links.
+func ExampleFpdf_SetTagged() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTagged(true)
+	pdf.SetLang("en-US")
+	pdf.SetTitle("Tagged document", true)
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddUTF8Font("dejavu", "B", example.FontFile("DejaVuSansCondensed-Bold.ttf"))
+	pdf.SetHeaderFunc(func() {
+		// Page headers and footers are marked as artifacts
+		pdf.SetFont("dejavu", "", 8)
+		pdf.CellFormat(0, 10, "Tagged document", "B", 1, "R", false, 0, "")
+		pdf.Ln(5)
+	})
+	pdf.AddPage()
+	pdf.BeginTag("Sect")
+	pdf.BeginTag("H1")
+	pdf.SetFont("dejavu", "B", 18)
+	pdf.CellFormat(0, 12, "Accessible documents", "", 1, "", false, 0, "")
+	pdf.EndTag()
+	pdf.SetFont("dejavu", "", 11)
+	// Text output by MultiCell() is tagged as a paragraph
+	pdf.MultiCell(0, 5, "A tagged PDF describes the logical structure of its "+
+		"content. Screen readers use the structure to present the text in the "+
+		"right order and to announce headings, tables and figures.", "", "", false)
+	pdf.Ln(4)
+	pdf.ImageOptions(example.ImageFile("logo.png"), 10, pdf.GetY(), 30, 0, true,
+		gofpdf.ImageOptions{AltText: "Logo of the FPDF library"}, 0, "")
+	pdf.Ln(4)
+	// Cells within a table become table cells of automatically tagged rows
+	pdf.BeginTag("Table")
+	pdf.BeginTag("THead")
+	pdf.SetFont("dejavu", "B", 11)
+	for _, str := range []string{"Element", "Meaning"} {
+		pdf.CellFormat(50, 7, str, "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.EndTag()
+	pdf.BeginTag("TBody")
+	pdf.SetFont("dejavu", "", 11)
+	for _, row := range [][]string{{"H1", "Heading"}, {"P", "Paragraph"}, {"Figure", "Image"}} {
+		pdf.CellFormat(50, 7, row[0], "1", 0, "", false, 0, "")
+		pdf.CellFormat(50, 7, row[1], "1", 1, "", false, 0, "")
+	}
+	pdf.EndTag()
+	pdf.EndTag()
+	pdf.Ln(4)
+	pdf.Write(5, "More information is available at ")
+	pdf.WriteLinkString(5, "github.com", "https://github.com/jbuchbinder/gofpdf")
+	pdf.Ln(10)
+	pdf.BeginTagExt("Link", gofpdf.TagOptions{AltStr: "FPDF home page"})
+	pdf.CellFormat(60, 7, "www.fpdf.org", "1", 1, "C", false, 0, "")
+	pdf.LinkString(10, pdf.GetY()-7, 60, 7, "http://www.fpdf.org")
+	pdf.EndTag()
+	pdf.EndTag()
+	fileStr := example.Filename("Fpdf_SetTagged")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetTagged.pdf
+}
+
+// TestTaggedMarkedContent verifies that marked-content sequences are balanced
+// on each page of a tagged document and that unended elements are reported.
+func TestTaggedMarkedContent(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A6", "")
+	pdf.SetCompression(false)
+	pdf.SetTagged(true)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-10)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.BeginTag("P")
+	for j := 0; j < 40; j++ {
+		pdf.Write(6, "A paragraph that spans several pages. ")
+	}
+	pdf.EndTag()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	if pdf.PageCount() < 2 {
+		t.Fatal("expecting paragraph to span pages")
+	}
+	begins := strings.Count(str, " BDC") + strings.Count(str, " BMC")
+	if ends := strings.Count(str, "EMC"); begins != ends || begins == 0 {
+		t.Fatalf("%d marked-content sequences begun, %d ended", begins, ends)
+	}
+	for _, key := range []string{"/StructTreeRoot", "/ParentTree", "/MarkInfo <</Marked true>>", "/StructParents 1"} {
+		if !strings.Contains(str, key) {
+			t.Fatalf("%s not found", key)
+		}
+	}
+	pdf = gofpdf.New("P", "mm", "A6", "")
+	pdf.SetTagged(true)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.BeginTag("H1")
+	pdf.Cell(0, 10, "Unended heading")
+	if err := pdf.Output(&buf); err == nil {
+		t.Fatal("expecting error for unended element")
+	}
+}
+
+// taggedArtifactText returns true if str, the content of a tagged document,
+// has text in a marked-content sequence of an artifact.
+func taggedArtifactText(str string) bool {
+	for _, part := range strings.Split(str, "/Artifact BMC")[1:] {
+		if strings.Contains(strings.SplitN(part, "EMC", 2)[0], "Tj") {
+			return true
+		}
+	}
+	return false
+}
+
+// TestTaggedText verifies that paragraphs and text laid along a path are
+// tagged as paragraphs rather than marked as artifacts.
+func TestTaggedText(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetTagged(true)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	para := pdf.ParagraphNew()
+	para.AddSpan("A paragraph of ", gofpdf.SpanStyleType{})
+	para.AddSpan("styled", gofpdf.SpanStyleType{Style: "B"})
+	para.AddSpan(" text.", gofpdf.SpanStyleType{})
+	para.Render(20, 20, 100)
+	path := pdf.PathNew()
+	path.MoveTo(20, 60)
+	path.LineTo(120, 40)
+	pdf.TextOnPath("Text on a path", path.Segments(), gofpdf.TextPathOptions{})
+	pdf.BeginTag("Div")
+	pdf.BeginTag("P")
+	para.Render(20, 80, 100)
+	pdf.EndTag()
+	pdf.EndTag()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	if n := strings.Count(str, "/P <</MCID"); n != 3 {
+		t.Fatalf("%d paragraphs, expecting 3", n)
+	}
+	if taggedArtifactText(str) {
+		t.Fatal("text marked as an artifact")
+	}
+}
+
+// ExampleFpdf_SetKerning compares text set with and without kerning.
+func ExampleFpdf_SetKerning() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	const str = "AVATAR Tower WAVY Törö"
+	for _, kerning := range []bool{false, true} {
+		pdf.SetKerning(kerning)
+		pdf.SetFont("dejavu", "", 11)
+		pdf.CellFormat(0, 8, fmt.Sprintf("Kerning %v", kerning), "", 1, "", false, 0, "")
+		pdf.SetFont("dejavu", "", 32)
+		pdf.CellFormat(pdf.GetStringWidth(str)+4, 14, str, "1", 1, "C", false, 0, "")
+		pdf.SetFont("dejavu", "", 11)
+		pdf.MultiCell(80, 5, "To Vaclav: Your AVIATOR WAVE text is now kerned "+
+			"using the pair adjustments of the font.", "1", "J", false)
+		pdf.Ln(6)
+	}
+	fileStr := example.Filename("Fpdf_SetKerning")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetKerning.pdf
+}
+
+// TestKerningWidth verifies that kerning reduces the width of text and that
+// the lines of SplitText() fit the widths measured by GetStringWidth().
+func TestKerningWidth(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 20)
+	const str = "AVATAR To WAVE"
+	plain := pdf.GetStringWidth(str)
+	pdf.SetKerning(true)
+	kerned := pdf.GetStringWidth(str)
+	if kerned >= plain {
+		t.Fatalf("kerned width %.3f not less than %.3f", kerned, plain)
+	}
+	pdf.SetCellMargin(0)
+	lines := pdf.SplitText(str+" "+str, kerned+0.01)
+	if len(lines) != 2 || lines[0] != str {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	pdf.Cell(0, 10, str)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("] TJ ET")) {
+		t.Fatal("kerned text not shown with TJ")
+	}
+}
+
+// ExampleFpdf_SetTextShaping demonstrates OpenType shaping of Latin
+// ligatures and of Arabic text, whose letters take the contextual forms
+// that join them and whose vowel marks are placed above and below them.
+func ExampleFpdf_SetTextShaping() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	for _, shaping := range []bool{false, true} {
+		pdf.SetTextShaping(shaping)
+		pdf.SetFont("dejavu", "", 11)
+		pdf.LTR()
+		pdf.CellFormat(0, 8, fmt.Sprintf("Shaping %v", shaping), "", 1, "", false, 0, "")
+		pdf.SetFont("dejavu", "", 24)
+		pdf.CellFormat(0, 12, "office affluent fjord", "", 1, "", false, 0, "")
+		pdf.RTL()
+		pdf.CellFormat(0, 12, "بِسْمِ اللهِ الرَّحْمٰنِ الرَّحِيْمِ", "", 1, "R", false, 0, "")
+		pdf.SetFont("dejavu", "", 14)
+		pdf.MultiCell(100, 7, "مرحبا بالعالم. هذا نص عربي يلتف على عدة أسطر "+
+			"داخل خلية متعددة الأسطر.", "1", "R", false)
+		pdf.Ln(6)
+	}
+	fileStr := example.Filename("Fpdf_SetTextShaping")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetTextShaping.pdf
+}
+
+// TestTextShaping verifies that shaped glyphs without a character of their
+// own are shown with allocated CIDs, embedded in the subset font and mapped
+// back to their characters.
+func TestTextShaping(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 20)
+	const latin = "office"
+	plain := pdf.GetStringWidth(latin)
+	pdf.SetTextShaping(true)
+	if shaped := pdf.GetStringWidth(latin); shaped >= plain {
+		t.Fatalf("width %.3f with ligature not less than %.3f", shaped, plain)
+	}
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	pdf.Cell(0, 10, latin)
+	pdf.Ln(10)
+	pdf.RTL()
+	pdf.Cell(0, 10, "لا سلام")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{
+		"<D800> <006600660069>", // ffi ligature
+		"<D801> <06440627>",     // lam-alef ligature
+		"/CIDToGIDMap",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(str)) {
+			t.Fatalf("%q not found in document", str)
+		}
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("(\x00o\xd8\x00\x00c\x00e)")) {
+		t.Fatal("ligature not shown with allocated CID")
+	}
+}
+
+// ExampleFpdf_MultiCell_bidi demonstrates the layout of paragraphs that mix
+// Hebrew with numbers and Latin product codes. The direction of each
+// paragraph is that of its first letter, so no call to RTL() or LTR() is
+// needed.
+func ExampleFpdf_MultiCell_bidi() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 14)
+	pdf.AddPage()
+	pdf.CellFormat(0, 8, "לקוח: דוד כהן (מספר 4711)", "", 1, "R", false, 0, "")
+	pdf.CellFormat(0, 8, "Order 2024-17 for דוד כהן, 3 items", "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+	pdf.MultiCell(90, 7, "המוצר XK-200 נשלח ב-12/03/2024 במחיר 1,250.00 ₪ "+
+		"ללקוח שרה לוי. המוצר הבא, AB-17 (גרסה 2), יישלח בשבוע הבא.\n"+
+		"Shipment XK-200 for שרה לוי leaves on 12/03/2024.", "1", "J", false)
+	pdf.Ln(4)
+	pdf.Write(7, "Invoice 881 was issued to ")
+	pdf.Write(7, "משה פרץ, רחוב הרצל 12, תל אביב")
+	pdf.Write(7, " on Monday.")
+	fileStr := example.Filename("Fpdf_MultiCell_bidi")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_MultiCell_bidi.pdf
+}
+
+// TestBidi verifies that mixed-direction lines are shown in the order given
+// by the Unicode Bidirectional Algorithm.
+func TestBidi(t *testing.T) {
+	encode := func(s string) string {
+		var b []byte
+		for _, r := range s {
+			b = append(b, byte(r>>8), byte(r))
+		}
+		s = strings.Replace(string(b), "\\", "\\\\", -1)
+		s = strings.Replace(s, "(", "\\(", -1)
+		return strings.Replace(s, ")", "\\)", -1)
+	}
+	for _, c := range []struct {
+		rtl       bool
+		logical   string
+		displayed string
+	}{
+		{false, "abc שלום def", "abc םולש def"},
+		{false, "שלום abc 123", "abc 123 םולש"},
+		{false, "Order 12 שלום 34", "Order 12 34 םולש"},
+		{false, "שלום (abc)", "(abc) םולש"},
+		{false, "מחיר: 1,250.00 ₪", "₪ 1,250.00 :ריחמ"},
+		{false, "⁧שלום⁩ abc", "⁧םולש⁩ abc"},
+		{false, "مرحبا 123", "123 ابحرم"},
+		{true, "abc", "abc"},
+		{true, "abc def", "abc def"},
+		{true, "abc שלום", "םולש abc"},
+	} {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+		pdf.SetFont("dejavu", "", 12)
+		pdf.SetCompression(false)
+		if c.rtl {
+			pdf.RTL()
+		}
+		pdf.AddPage()
+		pdf.Cell(0, 10, c.logical)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("("+encode(c.displayed)+")Tj")) {
+			t.Errorf("%q not displayed as %q", c.logical, c.displayed)
+		}
+	}
+}
+
+// TestCFFFont verifies that an OpenType font with CFF outlines is embedded
+// as a CID-keyed CFF font program, and that UTF8CutFont() subsets it into a
+// font that can be used in turn.
+func TestCFFFont(t *testing.T) {
+	fontFileStr := example.FontFile("CFFTest.otf")
+	fontBuf, err := ioutil.ReadFile(fontFileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subFont := gofpdf.UTF8CutFont(fontBuf, "1Q")
+	if !bytes.HasPrefix(subFont, []byte("OTTO")) {
+		t.Fatal("subset is not an OpenType font with CFF outlines")
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("cff", "", fontFileStr)
+	pdf.AddUTF8FontFromBytes("cffsub", "", subFont)
+	pdf.AddPage()
+	pdf.SetFont("cff", "", 20)
+	wd := pdf.GetStringWidth("1Q")
+	pdf.Cell(0, 10, "1Q中")
+	pdf.Ln(10)
+	pdf.SetFont("cffsub", "", 20)
+	if subWd := pdf.GetStringWidth("1Q"); subWd != wd {
+		t.Fatalf("width %.3f in subset, %.3f in font", subWd, wd)
+	}
+	pdf.Cell(0, 10, "1Q")
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{"/Subtype /CIDFontType0\n", "/FontFile3 ", "/Subtype /CIDFontType0C"} {
+		if bytes.Count(buf.Bytes(), []byte(str)) != 2 {
+			t.Fatalf("%q not found for each font", str)
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("/CIDToGIDMap")) {
+		t.Fatal("CIDToGIDMap found with CFF fonts")
+	}
+}
+
+// fontTables returns the tags and contents of the tables of a TrueType font
+func fontTables(t *testing.T, fileStr string) (tags []string, tables [][]byte) {
+	data, err := ioutil.ReadFile(example.FontFile(fileStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	be := binary.BigEndian
+	for j := 0; j < int(be.Uint16(data[4:])); j++ {
+		rec := data[12+16*j:]
+		tags = append(tags, string(rec[:4]))
+		tables = append(tables, data[be.Uint32(rec[8:]):be.Uint32(rec[8:])+be.Uint32(rec[12:])])
+	}
+	return
+}
+
+// TestFontContainers builds a TrueType collection, a WOFF file and a WOFF2
+// file from the fonts in the font directory and checks that each face loads
+// with the metrics of the original font.
+func TestFontContainers(t *testing.T) {
+	be := binary.BigEndian
+	put := func(buf *bytes.Buffer, v ...uint32) {
+		for _, n := range v {
+			binary.Write(buf, be, n)
+		}
+	}
+	put16 := func(buf *bytes.Buffer, v ...uint16) {
+		for _, n := range v {
+			binary.Write(buf, be, n)
+		}
+	}
+	pad := func(buf *bytes.Buffer) {
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	faces := []string{"DejaVuSansCondensed.ttf", "calligra.ttf"}
+
+	// Collection whose faces point into a shared data area
+	var ttc, body bytes.Buffer
+	dirSize := 12 + 4*len(faces)
+	var dirs []bytes.Buffer
+	for _, fileStr := range faces {
+		tags, _ := fontTables(t, fileStr)
+		dirSize += 12 + 16*len(tags)
+		dirs = append(dirs, bytes.Buffer{})
+	}
+	for j, fileStr := range faces {
+		tags, tables := fontTables(t, fileStr)
+		put(&dirs[j], 0x00010000)
+		put16(&dirs[j], uint16(len(tags)), 0, 0, 0)
+		for k, tag := range tags {
+			dirs[j].WriteString(tag)
+			put(&dirs[j], 0, uint32(dirSize+body.Len()), uint32(len(tables[k])))
+			body.Write(tables[k])
+			pad(&body)
+		}
+	}
+	ttc.WriteString("ttcf")
+	put(&ttc, 0x00010000, uint32(len(faces)))
+	offset := 12 + 4*len(faces)
+	for j := range dirs {
+		put(&ttc, uint32(offset))
+		offset += dirs[j].Len()
+	}
+	for j := range dirs {
+		ttc.Write(dirs[j].Bytes())
+	}
+	ttc.Write(body.Bytes())
+
+	// WOFF file with zlib-compressed tables
+	var woff bytes.Buffer
+	tags, tables := fontTables(t, faces[0])
+	woff.WriteString("wOFF")
+	put(&woff, 0x00010000, 0)
+	put16(&woff, uint16(len(tags)), 0)
+	put(&woff, 0, 0, 0, 0, 0, 0, 0)
+	var data bytes.Buffer
+	for k, tag := range tags {
+		var comp bytes.Buffer
+		w := zlib.NewWriter(&comp)
+		w.Write(tables[k])
+		w.Close()
+		if comp.Len() >= len(tables[k]) {
+			comp.Reset()
+			comp.Write(tables[k])
+		}
+		woff.WriteString(tag)
+		put(&woff, uint32(44+20*len(tags)+data.Len()), uint32(comp.Len()), uint32(len(tables[k])), 0)
+		data.Write(comp.Bytes())
+		pad(&data)
+	}
+	woff.Write(data.Bytes())
+
+	// WOFF2 file with untransformed tables; the decoder set below passes the
+	// stream through, so it is not compressed
+	var woff2, stream bytes.Buffer
+	base128 := func(buf *bytes.Buffer, n int) {
+		var b []byte
+		for b = []byte{byte(n & 0x7f)}; n > 0x7f; b = append([]byte{byte(n&0x7f) | 0x80}, b...) {
+			n >>= 7
+		}
+		buf.Write(b)
+	}
+	var dir bytes.Buffer
+	for k, tag := range tags {
+		// Transform version 3 is the null transform of glyf and loca, 0
+		// that of the other tables
+		if tag == "glyf" || tag == "loca" {
+			dir.WriteByte(0xff)
+		} else {
+			dir.WriteByte(0x3f)
+		}
+		dir.WriteString(tag)
+		base128(&dir, len(tables[k]))
+	}
+	for _, table := range tables {
+		stream.Write(table)
+	}
+	woff2.WriteString("wOF2")
+	put(&woff2, 0x00010000, 0)
+	put16(&woff2, uint16(len(tags)), 0)
+	put(&woff2, 0, uint32(stream.Len()), 0, 0, 0, 0, 0, 0)
+	woff2.Write(dir.Bytes())
+	woff2.Write(stream.Bytes())
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFontLocation(example.FontDir())
+	pdf.AddUTF8Font("dejavu", "", faces[0])
+	pdf.AddUTF8Font("calligra", "", faces[1])
+	pdf.AddUTF8FontFaceFromBytes("ttc0", "", ttc.Bytes(), 0)
+	pdf.AddUTF8FontFaceFromBytes("ttc1", "", ttc.Bytes(), 1)
+	pdf.AddUTF8FontFromBytes("woff", "", woff.Bytes())
+	pdf.SetWOFF2Decoder(func(r io.Reader) io.Reader { return r })
+	pdf.AddUTF8FontFromBytes("woff2", "", woff2.Bytes())
+	pdf.AddPage()
+	str := "Grüße, ABC xyz"
+	width := func(family string) float64 {
+		pdf.SetFont(family, "", 14)
+		pdf.Cell(0, 8, family+": "+str)
+		pdf.Ln(8)
+		return pdf.GetStringWidth(str)
+	}
+	for _, c := range [][2]string{{"ttc0", "dejavu"}, {"ttc1", "calligra"}, {"woff", "dejavu"}, {"woff2", "dejavu"}} {
+		if wd, ref := width(c[0]), width(c[1]); wd != ref {
+			t.Fatalf("%s: width %.3f, expecting %.3f", c[0], wd, ref)
+		}
+	}
+	if err := pdf.Output(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFaceFromBytes("ttc2", "", ttc.Bytes(), 2)
+	if pdf.Err() == false {
+		t.Fatal("missing face of collection not reported")
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes("woff2", "", woff2.Bytes())
+	if pdf.Err() == false {
+		t.Fatal("WOFF2 font without decoder not reported")
+	}
+}
+
+// ExampleFpdf_SetFontFallback demonstrates showing the characters that a
+// font lacks in fallback fonts.
+func ExampleFpdf_SetFontFallback() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("calligra", "", example.FontFile("calligra.ttf"))
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFontFallback("calligra", "dejavu")
+	pdf.AddPage()
+	pdf.SetFont("calligra", "", 18)
+	pdf.Cell(0, 10, "Calligraphy: αβγ, Привет, ≈ ∞ → ✓")
+	pdf.Ln(12)
+	pdf.MultiCell(100, 8, "Characters missing from this font, such as the Greek "+
+		"Ωμέγα or the Cyrillic Язык, are taken from the fallback font.", "1", "J", false)
+	pdf.Ln(4)
+	pdf.Write(8, "Write() switches fonts too: שלום and 1 € ≠ 1 $.")
+	fileStr := example.Filename("Fpdf_SetFontFallback")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetFontFallback.pdf
+}
+
+// TestFontFallback verifies that characters missing from a font are measured
+// and shown in its fallback font.
+func TestFontFallback(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("calligra", "", example.FontFile("calligra.ttf"))
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 14)
+	greek := pdf.GetStringWidth("λόγος")
+	pdf.SetFont("calligra", "", 14)
+	latin := pdf.GetStringWidth("abc ")
+	pdf.SetFontFallback("calligra", "dejavu")
+	if wd := pdf.GetStringWidth("abc λόγος"); math.Abs(wd-latin-greek) > 1e-9 {
+		t.Fatalf("width %.3f, expecting %.3f", wd, latin+greek)
+	}
+	pdf.SetCellMargin(0)
+	if lines := pdf.SplitText("abc λόγος abc", latin+greek+0.01); len(lines) != 2 || lines[0] != "abc λόγος" {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+	pdf.Cell(0, 10, "abc λόγος abc")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{"(\x00a\x00b\x00c\x00 )] TJ /F", " 14.00 Tf [(\x03\xbb\x03\xcc"} {
+		if !bytes.Contains(buf.Bytes(), []byte(str)) {
+			t.Fatalf("%q not found", str)
+		}
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("calligra", "", example.FontFile("calligra.ttf"))
+	pdf.SetFont("calligra", "", 14)
+	plain := pdf.GetStringWidth("λόγος")
+	pdf.SetFontFallback("calligra", "dejavu")
+	if wd := pdf.GetStringWidth("λόγος"); wd != plain {
+		t.Fatalf("width %.3f with unregistered fallback, expecting %.3f", wd, plain)
+	}
+}
+
+// ExampleFpdf_VMultiCell demonstrates text set vertically in columns that
+// run from right to left.
+func ExampleFpdf_VMultiCell() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 14)
+	pdf.SetXY(180, 20)
+	pdf.VCellFormat(12, 60, "GOFPDF", "1", 2, "M", false, 0, "")
+	pdf.SetFont("dejavu", "", 11)
+	pdf.VMultiCell(8, 60, "VERTICAL TEXT IS BROKEN INTO COLUMNS THAT RUN FROM RIGHT TO LEFT.",
+		"LR", "", false)
+	pdf.SetTextColor(200, 0, 0)
+	pdf.VCellFormat(8, 60, "2024 — 2025", "1", 2, "B", false, 0, "")
+	fileStr := example.Filename("Fpdf_VMultiCell")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_VMultiCell.pdf
+}
+
+// sfntFont assembles the tables of a TrueType font.
+func sfntFont(tags []string, tables [][]byte) []byte {
+	var buf, data bytes.Buffer
+	be := binary.BigEndian
+	binary.Write(&buf, be, []uint32{0x00010000})
+	binary.Write(&buf, be, []uint16{uint16(len(tags)), 0, 0, 0})
+	for j, tag := range tags {
+		buf.WriteString(tag)
+		binary.Write(&buf, be, []uint32{0, uint32(12 + 16*len(tags) + data.Len()), uint32(len(tables[j]))})
+		data.Write(tables[j])
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+// TestVerticalText verifies the vertical metrics and substitutions of text
+// set vertically, using a font given vmtx and GSUB tables.
+func TestVerticalText(t *testing.T) {
+	ttf, err := gofpdf.TtfParse(example.FontFile("DejaVuSansCondensed.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, tables := fontTables(t, "DejaVuSansCondensed.ttf")
+	for j := 0; j < len(tags); j++ {
+		if tags[j] == "GSUB" || tags[j] == "GPOS" {
+			tags, tables = append(tags[:j], tags[j+1:]...), append(tables[:j], tables[j+1:]...)
+			j--
+		}
+	}
+	be := binary.BigEndian
+	// Advance height of one em for all glyphs
+	vhea := make([]byte, 36)
+	be.PutUint32(vhea, 0x00011000)
+	be.PutUint16(vhea[34:], 1)
+	vmtx := make([]byte, 2+2*len(ttf.Widths))
+	be.PutUint16(vmtx, ttf.UnitsPerEm)
+	// The vert feature substitutes the glyph of | for that of —
+	var gsub bytes.Buffer
+	binary.Write(&gsub, be, []uint16{1, 0, 10, 30, 44,
+		1, 'D'<<8 | 'F', 'L'<<8 | 'T', 8, 4, 0, 0, 0xFFFF, 1, 0,
+		1, 'v'<<8 | 'e', 'r'<<8 | 't', 8, 0, 1, 0,
+		1, 4, 1, 0, 1, 8, 2, 8, 1, ttf.Chars['|'], 1, 1, ttf.Chars[0x2014]})
+	tags = append(tags, "vhea", "vmtx", "GSUB")
+	tables = append(tables, vhea, vmtx, gsub.Bytes())
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8FontFromBytes("vert", "", sfntFont(tags, tables))
+	pdf.AddPage()
+	pdf.SetFont("vert", "", 10)
+	if hgt := pdf.GetStringHeight("ab—"); math.Abs(hgt-3*pdf.PointConvert(10)) > 1e-9 {
+		t.Fatalf("height %.3f, expecting three ems", hgt)
+	}
+	pdf.SetCellMargin(0)
+	pdf.SetXY(100, 20)
+	pdf.VCell(10, 0, "a—b")
+	pdf.SetXY(100, 20)
+	pdf.VMultiCell(10, pdf.PointConvert(10)*3.5, "abc def ghi", "", "", false)
+	if x, y := pdf.GetXY(); math.Abs(x-70) > 1e-9 || math.Abs(y-20) > 1e-9 {
+		t.Fatalf("position (%.3f, %.3f) after three columns", x, y)
+	}
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "V 10.00 Tf"); n != 4 {
+		t.Fatalf("%d vertical text objects, expecting 4", n)
+	}
+	for _, str := range []string{"(\x00a\xd8\x00\x00b) Tj", "/Encoding /Identity-V", "/DW2 [", "/W2 [", "<D800> <2014>"} {
+		if !strings.Contains(out, str) {
+			t.Fatalf("%q not found", str)
+		}
+	}
+	// Each cell, and the columns of a multi-column cell together, form a
+	// paragraph of a tagged document
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetTagged(true)
+	pdf.AddUTF8FontFromBytes("vert", "", sfntFont(tags, tables))
+	pdf.AddPage()
+	pdf.SetFont("vert", "", 10)
+	pdf.SetXY(100, 20)
+	pdf.VCell(10, 0, "a—b")
+	pdf.SetXY(100, 20)
+	pdf.VMultiCell(10, pdf.PointConvert(10)*3.5, "abc def ghi", "", "", false)
+	buf.Reset()
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	if n := strings.Count(out, "/P <</MCID"); n != 2 {
+		t.Fatalf("%d paragraphs of vertical text, expecting 2", n)
+	}
+	if taggedArtifactText(out) {
+		t.Fatal("vertical text marked as an artifact")
+	}
+	// Columns carried over to a new page start below the page header
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8FontFromBytes("vert", "", sfntFont(tags, tables))
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(0, 25, "Page header", "1", 1, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("vert", "", 10)
+	pdf.SetX(100)
+	pdf.VMultiCell(10, 50, strings.Repeat("abc def ghi ", 20), "", "", false)
+	buf.Reset()
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := pdf.PageCount(); n < 2 {
+		t.Fatalf("%d pages, expecting at least 2", n)
+	}
+	checkBelowHeader(t, buf.Bytes(), 35, 297-20)
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("helvetica", "", 10)
+	pdf.VCell(10, 40, "abc")
+	if !pdf.Err() {
+		t.Fatal("vertical text in a core font not reported")
+	}
+}
+
+// hyphenPatterns contains the patterns of Liang's thesis, enough to
+// hyphenate the word "hyphenation", and some exceptions.
+const hyphenPatterns = `% sample patterns
+\patterns{
+hy3ph he2n hena4 hen5at 1na n2at 1tio 2io o2n
+}
+\hyphenation{ta-ble pro-gram-ming}
+`
+
+// ExampleFpdf_SetHyphenation demonstrates the automatic hyphenation of
+// words that do not fit on a line.
+func ExampleFpdf_SetHyphenation() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddHyphenationPatterns("sample", strings.NewReader(hyphenPatterns))
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 14)
+	txt := "Programming the hyphenation of a table: hyphenation " +
+		"breaks words that do not fit on a line, so that programming " +
+		"a narrow table leaves less space between words."
+	for n, lang := range []string{"", "sample"} {
+		pdf.SetHyphenation(lang)
+		pdf.SetXY(20+float64(n)*60, 20)
+		pdf.MultiCell(35, 7, txt, "1", "J", false)
+	}
+	fileStr := example.Filename("Fpdf_SetHyphenation")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetHyphenation.pdf
+}
+
+type hyphenLoader map[string]string
+
+func (l hyphenLoader) Open(lang string) (io.Reader, error) {
+	if s, ok := l[lang]; ok {
+		return strings.NewReader(s), nil
+	}
+	return nil, fmt.Errorf("no patterns for %s", lang)
+}
+
+// TestHyphenation verifies the hyphenation points found in patterns and
+// exceptions and the lines broken at them.
+func TestHyphenation(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetHyphenationLoader(hyphenLoader{"sample": hyphenPatterns})
+	pdf.SetHyphenation("sample")
+	if lang := pdf.GetHyphenation(); lang != "sample" {
+		t.Fatalf("hyphenation %q, expecting sample", lang)
+	}
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.SetCellMargin(0)
+	check := func(txt, fit string, expect ...string) {
+		t.Helper()
+		w := pdf.GetStringWidth(fit) + 0.01
+		lines := pdf.SplitText(txt, w)
+		if strings.Join(lines, "|") != strings.Join(expect, "|") {
+			t.Fatalf("SplitText(%q) = %q, expecting %q", txt, lines, expect)
+		}
+		var byteLines []string
+		for _, line := range pdf.SplitLines([]byte(txt), w) {
+			byteLines = append(byteLines, string(line))
+		}
+		if strings.Join(byteLines, "|") != strings.Join(expect, "|") {
+			t.Fatalf("SplitLines(%q) = %q, expecting %q", txt, byteLines, expect)
+		}
+	}
+	check("xx hyphenation", "xx hyphen-", "xx hyphen-", "ation")
+	check("xx hyphenation", "xx hy-", "xx hy-", "phen-", "ation")
+	check("xx hyphenation", "hyphen-", "xx hy-", "phen-", "ation")
+	check("hyphenation", "hyphena", "hyphen-", "ation")
+	check("a table", "a ta-", "a ta-", "ble")
+	check("a Table", "a Ta-", "a Ta-", "ble")
+	check("a stable", "stable", "a", "stable")
+	pdf.MultiCell(pdf.GetStringWidth("xx hyphen-")+0.01, 5, "xx hyphenation", "", "L", false)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{"(xx hyphen-)Tj", "(ation)Tj"} {
+		if !bytes.Contains(buf.Bytes(), []byte(str)) {
+			t.Fatalf("%q not found", str)
+		}
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetHyphenation("en")
+	if !pdf.Err() {
+		t.Fatalf("expecting error for missing patterns")
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetHyphenationLoader(hyphenLoader{"en": "% nothing"})
+	pdf.SetHyphenation("en")
+	if !pdf.Err() {
+		t.Fatalf("expecting error for empty patterns")
+	}
+}
+
+// ExampleFpdf_SetCharSpacing demonstrates character spacing, horizontal
+// scaling and text rise.
+func ExampleFpdf_SetCharSpacing() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 14)
+	txt := "The quick brown fox jumps over the lazy dog."
+	for _, space := range []float64{-0.2, 0, 0.3, 0.6} {
+		pdf.SetCharSpacing(space)
+		pdf.CellFormat(0, 8, fmt.Sprintf("%.1f mm: %s", space, txt), "1", 1, "C", false, 0, "")
+	}
+	pdf.SetCharSpacing(0)
+	pdf.Ln(4)
+	for _, scale := range []float64{50, 100, 150} {
+		pdf.SetHorizontalScaling(scale)
+		pdf.CellFormat(0, 8, fmt.Sprintf("%.0f%%: %s", scale, txt), "1", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+	pdf.SetHorizontalScaling(80)
+	pdf.MultiCell(80, 6, strings.Repeat(txt+" ", 4), "1", "J", false)
+	pdf.SetHorizontalScaling(100)
+	pdf.Ln(4)
+	pdf.Write(8, "Text rise: E = mc")
+	pdf.SetFontSize(9)
+	pdf.SetTextRise(2)
+	pdf.Write(8, "2")
+	pdf.SetTextRise(-1)
+	pdf.Write(8, " (lowered)")
+	pdf.SetTextRise(0)
+	fileStr := example.Filename("Fpdf_SetCharSpacing")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetCharSpacing.pdf
+}
+
+// TestTextState verifies that character spacing and horizontal scaling are
+// taken into account when measuring and breaking text, and that the text
+// state is restored on new pages and by StateType.
+func TestTextState(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetCellMargin(0)
+	plain := pdf.GetStringWidth("abcd")
+	pdf.SetCharSpacing(1)
+	pdf.SetHorizontalScaling(50)
+	pdf.SetTextRise(2)
+	if wd, expect := pdf.GetStringWidth("abcd"), (plain+4)/2; math.Abs(wd-expect) > 0.01 {
+		t.Fatalf("width %.3f, expecting %.3f", wd, expect)
+	}
+	w := pdf.GetStringWidth("abcd abcd") + 0.01
+	if lines := pdf.SplitText("abcd abcd abcd", w); len(lines) != 2 || lines[0] != "abcd abcd" {
+		t.Fatalf("unexpected SplitText lines %q", lines)
+	}
+	if lines := pdf.SplitLines([]byte("abcd abcd abcd"), w); len(lines) != 2 || string(lines[0]) != "abcd abcd" {
+		t.Fatalf("unexpected SplitLines lines %q", lines)
+	}
+	pdf.AddPage()
+	st := gofpdf.StateGet(pdf)
+	pdf.SetCharSpacing(0)
+	pdf.SetHorizontalScaling(100)
+	pdf.SetTextRise(0)
+	st.Put(pdf)
+	if pdf.GetCharSpacing() != 1 || pdf.GetHorizontalScaling() != 50 || pdf.GetTextRise() != 2 {
+		t.Fatalf("text state not restored")
+	}
+	pdf.SetHeaderFunc(func() {
+		pdf.SetCharSpacing(0)
+	})
+	pdf.AddPage()
+	pdf.SetHorizontalScaling(0)
+	if !pdf.Err() {
+		t.Fatalf("expecting error for horizontal scaling 0")
+	}
+	pdf.ClearError()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{"2.83465 Tc\n50.00 Tz\n5.67 Ts", "0.00000 Tc\n2.83465 Tc 50.00 Tz 5.67 Ts"} {
+		if !bytes.Contains(buf.Bytes(), []byte(str)) {
+			t.Fatalf("%q not found", str)
+		}
+	}
+}
+
+// ExampleFpdf_SetJustification demonstrates justified text in a UTF-8 font,
+// stretched only at spaces and then also between characters.
+func ExampleFpdf_SetJustification() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 11)
+	txt := "Justified text in UTF-8 fonts is stretched with positioning " +
+		"adjustments: Überschriftenverzeichnis, Donaudampfschifffahrt and " +
+		"Ηλεκτροεγκεφαλογράφημα are long words that leave wide gaps in narrow columns."
+	for n, limit := range []float64{0, 1} {
+		pdf.SetJustification(limit, 0.4)
+		pdf.SetXY(20+float64(n)*70, 20)
+		pdf.MultiCell(55, 6, txt, "1", "J", false)
+	}
+	fileStr := example.Filename("Fpdf_SetJustification")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetJustification.pdf
+}
+
+// TestJustification verifies the positioning adjustments of justified lines
+// in a UTF-8 font.
+func TestJustification(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 12)
+	pdf.SetCellMargin(0)
+	_, fontSize := pdf.GetFontSize()
+	units := func(w float64) float64 {
+		return w * 1000 / fontSize
+	}
+	extra := func(txt string, w float64) float64 {
+		return math.Ceil(units(w)) - float64(pdf.GetStringSymbolWidth(txt))
+	}
+	// A word is spread between its characters, up to the limit
+	pdf.SetJustification(0, 1)
+	w := pdf.GetStringWidth("abc") + 1
+	pdf.CellFormat(w, 10, "abc", "", 1, "J", false, 0, "")
+	spread := extra("abc", w) / 2
+	// Spaces are widened up to 0.5, then characters are spread by up to 0.2
+	pdf.SetJustification(0.5, 0.2)
+	w = pdf.GetStringWidth("ab cd") + 3
+	pdf.CellFormat(w, 10, "ab cd", "", 1, "J", false, 0, "")
+	char := units(0.2)
+	word := extra("ab cd", w) - 4*char
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{
+		fmt.Sprintf("[(\x00a) %.3f (\x00b) %.3f (\x00c)] TJ", -spread, -spread),
+		fmt.Sprintf("[(\x00a) %.3f (\x00b) %.3f (\x00 ) %.3f (\x00c) %.3f (\x00d)] TJ", -char, -char-word, -char, -char),
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(str)) {
+			t.Fatalf("%q not found", str)
+		}
+	}
+}
+
+// ExampleFpdf_ParagraphNew demonstrates a paragraph made of spans of mixed
+// styles that wrap together.
+func ExampleFpdf_ParagraphNew() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Times", "", 12)
+	red := gofpdf.RGBType{R: 200, G: 0, B: 0}
+	blue := gofpdf.RGBType{R: 0, G: 0, B: 160}
+	y := 20.0
+	for _, alignStr := range []string{"L", "J", "C", "R"} {
+		para := pdf.ParagraphNew()
+		para.SetAlign(alignStr)
+		para.AddSpan("Aligned "+alignStr+": ", gofpdf.SpanStyleType{Style: "B"})
+		para.AddSpan("A paragraph mixes ", gofpdf.SpanStyleType{})
+		para.AddSpan("bold", gofpdf.SpanStyleType{Style: "B"})
+		para.AddSpan(", ", gofpdf.SpanStyleType{})
+		para.AddSpan("italic", gofpdf.SpanStyleType{Style: "I"})
+		para.AddSpan(", ", gofpdf.SpanStyleType{})
+		para.AddSpan("red", gofpdf.SpanStyleType{Color: &red})
+		para.AddSpan(" and ", gofpdf.SpanStyleType{})
+		para.AddSpan("larger", gofpdf.SpanStyleType{Family: "Helvetica", Size: 18})
+		para.AddSpan(" text, with half-bold wor", gofpdf.SpanStyleType{})
+		para.AddSpan("ds", gofpdf.SpanStyleType{Style: "B"})
+		para.AddSpan(" that stay whole, superscripts like x", gofpdf.SpanStyleType{})
+		para.AddSpan("2", gofpdf.SpanStyleType{Size: 8, Rise: 1.5})
+		para.AddSpan(" and a ", gofpdf.SpanStyleType{})
+		para.AddSpan("link", gofpdf.SpanStyleType{Style: "U", Color: &blue, Link: "https://github.com/jbuchbinder/gofpdf"})
+		para.AddSpan(".", gofpdf.SpanStyleType{})
+		ht := para.Render(20, y, 90)
+		pdf.Rect(20, y, 90, ht, "D")
+		y += ht + 5
+	}
+	fileStr := example.Filename("Fpdf_ParagraphNew")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_ParagraphNew.pdf
+}
+
+// TestParagraph verifies the line breaks and height of a paragraph with
+// spans of mixed styles.
+func TestParagraph(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetTextColor(0, 0, 255)
+	_, size := pdf.GetFontSize()
+	para := pdf.ParagraphNew()
+	para.AddSpan("one tw", gofpdf.SpanStyleType{})
+	para.AddSpan("o", gofpdf.SpanStyleType{Style: "B", Size: 20})
+	para.AddSpan(" three\nfour", gofpdf.SpanStyleType{})
+	pdf.SetFont("Helvetica", "B", 20)
+	wd := pdf.GetStringWidth("o")
+	pdf.SetFont("Helvetica", "", 10)
+	w := pdf.GetStringWidth("one tw") + wd + 0.01
+	// Lines: "one two" with a 20 point font, "three", "four"
+	expect := size*2*1.2 + size*1.2*2
+	if ht := para.Height(w); math.Abs(ht-expect) > 1e-9 {
+		t.Fatalf("height %.3f, expecting %.3f", ht, expect)
+	}
+	if ht := para.Render(10, 10, w); math.Abs(ht-expect) > 1e-9 {
+		t.Fatalf("rendered height %.3f, expecting %.3f", ht, expect)
+	}
+	if x, y := pdf.GetXY(); x != 10 || math.Abs(y-10-expect) > 1e-9 {
+		t.Fatalf("position %.3f, %.3f after paragraph", x, y)
+	}
+	if sz, _ := pdf.GetFontSize(); sz != 10 {
+		t.Fatalf("font size %.1f not restored", sz)
+	}
+	if r, g, b := pdf.GetTextColor(); r != 0 || g != 0 || b != 255 {
+		t.Fatalf("text color %d %d %d not restored", r, g, b)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{"(one) Tj", "(tw) Tj", "(o) Tj", "(three) Tj", "(four) Tj"} {
+		if !bytes.Contains(buf.Bytes(), []byte(str)) {
+			t.Fatalf("%q not found", str)
+		}
+	}
+
+	// Lines carried over to a new page continue below the page header
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(0, 25, "Page header", "1", 1, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 10)
+	para = pdf.ParagraphNew()
+	para.AddSpan(strings.Repeat("words of a long paragraph ", 300), gofpdf.SpanStyleType{})
+	para.Render(20, 200, 100)
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := pdf.PageCount(); n < 2 {
+		t.Fatalf("%d pages, expecting at least 2", n)
+	}
+	checkBelowHeader(t, buf.Bytes(), 35, 297-20)
+}
+
+// ExampleFpdf_TableNew demonstrates a table with a repeated header, cells
+// that span several columns or rows and rows of wrapped text.
+func ExampleFpdf_TableNew() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Arial", "", 11)
+	pdf.AddPage()
+	tbl := pdf.TableNew([]gofpdf.TableColumnType{
+		{Width: 25, Align: "C"}, {Width: 70}, {Width: 50}, {Width: 35, Align: "R"},
+	})
+	header := tbl.HeaderStyle()
+	header.Fill = true
+	header.FillColor = gofpdf.RGBType{R: 64, G: 64, B: 64}
+	header.TextColor = gofpdf.RGBType{R: 224, G: 224, B: 224}
+	header.Align = "CM"
+	header.Padding = 2
+	tbl.SetHeaderStyle(header)
+	style := tbl.Style()
+	style.Padding = 1.5
+	tbl.SetStyle(style)
+	shaded := style
+	shaded.Fill = true
+	shaded.FillColor = gofpdf.RGBType{R: 235, G: 240, B: 250}
+	shaded.Align = "M"
+	tbl.AddHeaderRow(gofpdf.TableCellType{Text: "Item"},
+		gofpdf.TableCellType{Text: "Description", ColSpan: 2},
+		gofpdf.TableCellType{Text: "Amount"})
+	strList := loremList()
+	for j := 0; j < 24; j++ {
+		if j%6 == 0 {
+			tbl.AddRow(gofpdf.TableCellType{Text: fmt.Sprintf("Group %d", j/6+1), RowSpan: 3, Style: &shaded},
+				gofpdf.TableCellType{Text: strList[j%len(strList)]},
+				gofpdf.TableCellType{Text: "Spans three rows"},
+				gofpdf.TableCellType{Text: fmt.Sprintf("%d.00", 100*(j+1))})
+			continue
+		}
+		cells := []string{fmt.Sprintf("%d", j+1), strList[j%len(strList)], "Short note", fmt.Sprintf("%d.00", 100*(j+1))}
+		if j%6 < 3 {
+			// The first column is covered by the group
+			cells = cells[1:]
+		}
+		tbl.AddRowStrings(cells...)
+	}
+	tbl.Render()
+	pdf.Ln(4)
+	pdf.Cell(0, 6, "After the table")
+	fileStr := example.Filename("Fpdf_TableNew")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_TableNew.pdf
+}
+
+// TestTable verifies the heights of table rows, cells that span rows and the
+// repetition of header rows after page breaks.
+func TestTable(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetCellMargin(0)
+	_, size := pdf.GetFontSize()
+	lineHt := size * 1.25
+	tbl := pdf.TableNew([]gofpdf.TableColumnType{{Width: 30}, {Width: 30}})
+	tbl.AddHeaderRow(gofpdf.TableCellType{Text: "Head", ColSpan: 2})
+	tbl.AddRow(gofpdf.TableCellType{Text: "a\nb\nc\nd", RowSpan: 2}, gofpdf.TableCellType{Text: "one"})
+	tbl.AddRowStrings("two")
+	tbl.AddRowStrings("three", "four\nfive")
+	// Header 1 line, rows 1 and 2 share 4 lines, row 3 has 2 lines
+	if ht, expect := tbl.Height(), 7*lineHt; math.Abs(ht-expect) > 1e-9 {
+		t.Fatalf("height %.3f, expecting %.3f", ht, expect)
+	}
+	pdf.AddPage()
+	// The first two rows do not fit below the header and move to a new page
+	pdf.SetY(297 - 20 - 4.5*lineHt)
+	tbl.Render()
+	if pdf.PageNo() != 2 {
+		t.Fatalf("table ends on page %d, expecting 2", pdf.PageNo())
+	}
+	_, top, _, _ := pdf.GetMargins()
+	if y, expect := pdf.GetY(), top+7*lineHt; math.Abs(y-expect) > 1e-9 {
+		t.Fatalf("y %.3f after table, expecting %.3f", y, expect)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("(Head)Tj")); n != 2 {
+		t.Fatalf("header output %d times, expecting 2", n)
+	}
+	for _, str := range []string{"(a)Tj", "(two)Tj", "(five)Tj"} {
+		if n := bytes.Count(buf.Bytes(), []byte(str)); n != 1 {
+			t.Fatalf("%q output %d times", str, n)
+		}
+	}
+
+	// A row that is taller than a page is split between pages
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetCellMargin(0)
+	var lines []string
+	for j := 0; j < 150; j++ {
+		lines = append(lines, fmt.Sprintf("line%03d", j))
+	}
+	tbl = pdf.TableNew([]gofpdf.TableColumnType{{Width: 30}, {Width: 30}})
+	tbl.AddHeaderRow(gofpdf.TableCellType{Text: "Head", ColSpan: 2})
+	tbl.AddRowStrings("short", "tall")
+	tbl.AddRowStrings(strings.Join(lines, "\n"), "beside")
+	tbl.AddRowStrings("after", "last")
+	pdf.AddPage()
+	pdf.SetY(150)
+	tbl.Render()
+	_, _, _, bottom := pdf.GetMargins()
+	if y := pdf.GetY(); y > 297-bottom {
+		t.Fatalf("y %.3f after table, below the bottom margin", y)
+	}
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := pdf.PageCount(); n != 4 {
+		t.Fatalf("%d pages, expecting 4", n)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("(Head)Tj")); n != 4 {
+		t.Fatalf("header output %d times, expecting 4", n)
+	}
+	for _, str := range append(lines, "beside", "after") {
+		if n := bytes.Count(buf.Bytes(), []byte("("+str+")Tj")); n != 1 {
+			t.Fatalf("%q output %d times", str, n)
+		}
+	}
+
+	// Rows joined by a cell that spans them cannot be split
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	tbl = pdf.TableNew([]gofpdf.TableColumnType{{Width: 30}, {Width: 30}})
+	tbl.AddRow(gofpdf.TableCellType{Text: strings.Join(lines, "\n"), RowSpan: 2}, gofpdf.TableCellType{Text: "one"})
+	tbl.AddRowStrings("two")
+	pdf.AddPage()
+	tbl.Render()
+	if !pdf.Err() {
+		t.Fatal("rows spanned by a cell taller than a page not reported")
+	}
+
+	// Rows and repeated header rows continue below the page header
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(0, 25, "Page header", "1", 1, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 10)
+	tbl = pdf.TableNew([]gofpdf.TableColumnType{{Width: 30}, {Width: 30}})
+	tbl.AddHeaderRow(gofpdf.TableCellType{Text: "Head", ColSpan: 2})
+	for j := 0; j < 40; j++ {
+		tbl.AddRowStrings(fmt.Sprintf("row%02d", j), "a\nb")
+	}
+	tbl.AddRowStrings(strings.Join(lines, "\n"), "tall")
+	tbl.Render()
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := pdf.PageCount(); n < 3 {
+		t.Fatalf("%d pages, expecting at least 3", n)
+	}
+	checkBelowHeader(t, buf.Bytes(), 35, 297-20)
+}
+
+// checkBelowHeader verifies that, on each page after the first, the text
+// other than the page header lies between the bottom of the header, at top,
+// and bottom, in millimeters from the top of an A4 page.
+func checkBelowHeader(t *testing.T, doc []byte, top, bottom float64) {
+	t.Helper()
+	re := regexp.MustCompile(`(?s)[-0-9.]+ ([-0-9.]+) Td \((.*?)\) ?Tj`)
+	pages := bytes.Split(doc, []byte("/Type /Page\n"))
+	for j := 2; j < len(pages); j++ {
+		for _, m := range re.FindAllSubmatch(pages[j], -1) {
+			y, _ := strconv.ParseFloat(string(m[1]), 64)
+			// Baseline in millimeters from the top of the page
+			y = 297 - y*25.4/72
+			if string(m[2]) != "Page header" && (y < top || y > bottom) {
+				t.Fatalf("%q at %.3f mm on page %d, outside the body", m[2], y, j)
+			}
+		}
+	}
+}
+
+// ExampleFpdf_SetColumns demonstrates text that flows through columns with
+// MultiCell(), Write() and HTMLBasicType.
+func ExampleFpdf_SetColumns() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Arial", "B", 14)
+		pdf.CellFormat(0, 10, "Column layout", "B", 1, "C", false, 0, "")
+		pdf.Ln(4)
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("Times", "", 11)
+	pdf.SetColumns(3, 6)
+	loremStr := lorem()
+	for j := 0; j < 8; j++ {
+		pdf.MultiCell(0, 5, loremStr, "", "J", false)
+		pdf.Ln(2)
+	}
+	pdf.SetColumns(2, 10)
+	for j := 0; j < 6; j++ {
+		pdf.Write(5, loremStr+" ")
+	}
+	pdf.Ln(8)
+	html := pdf.HTMLBasicNew()
+	for j := 0; j < 4; j++ {
+		html.Write(5, "<b>Bold</b>, <i>italic</i> and <u>underlined</u> text. "+loremStr+"<br><br>")
+	}
+	pdf.SetColumns(0, 0)
+	fileStr := example.Filename("Fpdf_SetColumns")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetColumns.pdf
+}
+
+// TestColumns verifies that text moves to the next column before a new page
+// is added, and that the header and footer use the page margins.
+func TestColumns(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	var headerX []float64
+	pdf.SetHeaderFunc(func() {
+		headerX = append(headerX, pdf.GetX())
+		pdf.Ln(10)
+	})
+	pdf.AddPage()
+	left, top, right, _ := pdf.GetMargins()
+	pdf.SetColumns(2, 10)
+	pageWd, _ := pdf.GetPageSize()
+	colWd := (pageWd - left - right - 10) / 2
+	if l, _, r, _ := pdf.GetMargins(); math.Abs(l-left) > 1e-9 || math.Abs(pageWd-r-colWd-left) > 1e-9 {
+		t.Fatalf("column margins %.3f, %.3f", l, r)
+	}
+	y0 := pdf.GetY()
+	type posType struct {
+		page, col int
+		x, y      float64
+	}
+	var list []posType
+	for pdf.PageNo() < 3 {
+		pdf.MultiCell(0, 10, "line", "", "", false)
+		list = append(list, posType{pdf.PageNo(), pdf.GetColumn(), pdf.GetX(), pdf.GetY()})
+	}
+	var sawSecond bool
+	for j := 1; j < len(list); j++ {
+		prev, cur := list[j-1], list[j]
+		switch {
+		case cur.page == prev.page && cur.col == prev.col:
+		case cur.page == prev.page && cur.col == 1 && prev.col == 0:
+			sawSecond = true
+			if math.Abs(cur.y-(y0+10)) > 1e-9 {
+				t.Fatalf("second column starts at %.3f, expecting %.3f", cur.y-10, y0)
+			}
+		case cur.page == prev.page+1 && cur.col == 0 && prev.col == 1:
+			if math.Abs(cur.x-left) > 1e-9 || math.Abs(cur.y-(top+20)) > 1e-9 {
+				t.Fatalf("new page starts at (%.3f, %.3f)", cur.x, cur.y-10)
+			}
+		default:
+			t.Fatalf("text moves from page %d column %d to page %d column %d", prev.page, prev.col, cur.page, cur.col)
+		}
+	}
+	if !sawSecond {
+		t.Fatalf("text never reaches the second column")
+	}
+	for _, x := range headerX {
+		if math.Abs(x-left) > 1e-9 {
+			t.Fatalf("header at %.3f, expecting %.3f", x, left)
+		}
+	}
+	pdf.SetColumns(0, 0)
+	if l, _, r, _ := pdf.GetMargins(); l != left || r != right {
+		t.Fatalf("page margins %.3f, %.3f not restored", l, r)
+	}
+	if err := pdf.Output(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ExampleFpdf_ReserveToc demonstrates a table of contents that is output on a
+// page reserved after the title page.
+func ExampleFpdf_ReserveToc() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("Arial", "B", 24)
+	pdf.CellFormat(0, 100, "A Document with Contents", "", 1, "C", false, 0, "")
+	pdf.SetFont("Times", "", 12)
+	pdf.ReserveToc(1, gofpdf.TocOptions{Title: "Contents", Indent: 8})
+	loremStr := lorem()
+	for chapter := 1; chapter <= 4; chapter++ {
+		pdf.AddPage()
+		title := fmt.Sprintf("Chapter %d", chapter)
+		pdf.Bookmark(title, 0, -1)
+		pdf.TocEntry(title, 0)
+		pdf.SetFont("Times", "B", 16)
+		pdf.CellFormat(0, 10, title, "", 1, "L", false, 0, "")
+		for section := 1; section <= 3; section++ {
+			title := fmt.Sprintf("Section %d.%d, with a title that is long enough to be wrapped on the next line of the table of contents", chapter, section)
+			if section < 3 {
+				title = fmt.Sprintf("Section %d.%d", chapter, section)
+			}
+			pdf.Bookmark(title, 1, -1)
+			pdf.TocEntry(title, 1)
+			pdf.SetFont("Times", "B", 12)
+			pdf.MultiCell(0, 6, title, "", "L", false)
+			pdf.SetFont("Times", "", 12)
+			for j := 0; j < chapter+section; j++ {
+				pdf.MultiCell(0, 5, loremStr, "", "J", false)
+				pdf.Ln(2)
+			}
+		}
+	}
+	fileStr := example.Filename("Fpdf_ReserveToc")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_ReserveToc.pdf
+}
+
+// TestToc verifies the page numbers of entries when the table of contents is
+// inserted before existing pages, and the error reported when the reserved
+// pages are too few.
+func TestToc(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	pdf.InsertToc(2, gofpdf.TocOptions{Title: "Contents", LineHt: 100})
+	for j := 1; j <= 4; j++ {
+		pdf.AddPage()
+		pdf.TocEntry(fmt.Sprintf("Entry %d", j), 0)
+		pdf.Cell(0, 10, fmt.Sprintf("Body %d", j))
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// Two entries of 100 mm fit on each page of the table, which has two pages
+	if n := pdf.PageCount(); n != 7 {
+		t.Fatalf("%d pages, expecting 7", n)
+	}
+	for j, e := range pdf.TocEntries() {
+		if expect := j + 4; e.Page != expect {
+			t.Fatalf("entry %q on page %d, expecting %d", e.Text, e.Page, expect)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte(fmt.Sprintf(" %d)Tj", j+4))) {
+			t.Fatalf("page number of entry %q not found", e.Text)
+		}
+	}
+	// The body of the first chapter follows the table
+	pages := bytes.Split(buf.Bytes(), []byte("/Type /Page\n"))
+	if len(pages) < 5 || !bytes.Contains(pages[4], []byte("(Body 1)Tj")) {
+		t.Fatalf("page 4 does not contain the first chapter")
+	}
+
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	pdf.ReserveToc(1, gofpdf.TocOptions{LineHt: 100})
+	for j := 1; j <= 4; j++ {
+		pdf.AddPage()
+		pdf.TocEntry(fmt.Sprintf("Entry %d", j), 0)
+	}
+	if err := pdf.Output(ioutil.Discard); err == nil || !strings.Contains(err.Error(), "reserved pages") {
+		t.Fatalf("expecting an error about reserved pages, got %v", err)
+	}
+}
+
+// ExampleFpdf_Footnote demonstrates footnotes that are added to text output
+// with Write() and MultiCell().
+func ExampleFpdf_Footnote() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.SetFootnoteOptions(gofpdf.FootnoteOptions{FontFamily: "Times", FontSize: 9})
+	pdf.AddPage()
+	pdf.SetFont("Times", "", 12)
+	loremStr := lorem()
+	noteStr := "Duis aute irure dolor in reprehenderit in voluptate velit esse cillum " +
+		"dolore eu fugiat nulla pariatur. Excepteur sint occaecat cupidatat non proident."
+	for section := 1; section <= 2; section++ {
+		pdf.ResetFootnoteNumber()
+		pdf.SetFont("Times", "B", 14)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Section %d", section), "", 1, "L", false, 0, "")
+		pdf.SetFont("Times", "", 12)
+		for j := 0; j < 6; j++ {
+			pdf.Write(5, loremStr[:200])
+			pdf.Footnote(5, fmt.Sprintf("Note written with Write(). %s", noteStr))
+			pdf.Write(5, " "+loremStr[200:])
+			pdf.Ln(7)
+			num := pdf.AddFootnote("Note added for text output with MultiCell().")
+			pdf.MultiCell(0, 5, fmt.Sprintf("%s [%d]", loremStr[:300], num), "", "J", false)
+			pdf.Ln(2)
+		}
+	}
+	fileStr := example.Filename("Fpdf_Footnote")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_Footnote.pdf
+}
+
+// TestFootnote verifies that footnotes shrink the body of the page, that
+// notes that do not fit are carried over to the next page, and the numbering
+// of notes on each page.
+func TestFootnote(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetFootnoteOptions(gofpdf.FootnoteOptions{LineHt: 5, Numbering: gofpdf.FootnoteNumberPage})
+	pdf.AddPage()
+	_, pageHt := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	// A note of one line reserves two lines, with the separating line
+	if num := pdf.AddFootnote("first"); num != 1 {
+		t.Fatalf("first note is number %d", num)
+	}
+	for pdf.PageNo() == 1 {
+		y := pdf.GetY()
+		pdf.CellFormat(0, 10, "body", "", 1, "L", false, 0, "")
+		if pdf.PageNo() == 1 && pdf.GetY() > pageHt-bottom-10+1e-9 {
+			t.Fatalf("body reaches %.3f, into the notes", pdf.GetY())
+		}
+		if pdf.PageNo() == 2 && y+10 <= pageHt-bottom-10+1e-9 {
+			t.Fatalf("page break at %.3f, before the notes", y)
+		}
+	}
+	if num := pdf.AddFootnote("second"); num != 1 {
+		t.Fatalf("numbering does not restart on page 2, note is number %d", num)
+	}
+	// Near the bottom of the page, only part of a long note fits
+	pdf.SetY(pageHt - bottom - 10 - 22)
+	pdf.AddFootnote("carried " + strings.Repeat("word ", 400))
+	pdf.AddPage()
+	pdf.CellFormat(0, 10, "page 3", "", 1, "L", false, 0, "")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := pdf.PageCount(); n != 3 {
+		t.Fatalf("%d pages, expecting 3", n)
+	}
+	pages := bytes.Split(buf.Bytes(), []byte("/Type /Page\n"))
+	for j, str := range []string{"(first)Tj", "(second)Tj", "(page 3)Tj"} {
+		if !bytes.Contains(pages[j+1], []byte(str)) {
+			t.Fatalf("%s not found on page %d", str, j+1)
+		}
+	}
+	if !bytes.Contains(pages[2], []byte("(carried")) || !bytes.Contains(pages[3], []byte("(word")) {
+		t.Fatalf("long note is not carried over to page 3")
+	}
+}
+
+// ExampleFpdf_PutEndnotes demonstrates endnotes that are collected in each
+// chapter and output at its end.
+func ExampleFpdf_PutEndnotes() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFootnoteOptions(gofpdf.FootnoteOptions{FontFamily: "Times", FontSize: 10})
+	loremStr := lorem()
+	for chapter := 1; chapter <= 2; chapter++ {
+		pdf.AddPage()
+		pdf.SetFont("Times", "B", 16)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Chapter %d", chapter), "", 1, "L", false, 0, "")
+		pdf.SetFont("Times", "", 12)
+		for j := 0; j < 4; j++ {
+			pdf.Write(5, loremStr[:200])
+			pdf.Endnote(5, fmt.Sprintf("Note written with Write() in chapter %d. %s", chapter, loremStr[:150]))
+			pdf.Write(5, " "+loremStr[200:])
+			pdf.Ln(7)
+			num := pdf.AddEndnote("Note added for text output with MultiCell().")
+			pdf.MultiCell(0, 5, fmt.Sprintf("%s [%d]", loremStr[:300], num), "", "J", false)
+			pdf.Ln(2)
+		}
+		pdf.SetFont("Times", "B", 12)
+		pdf.CellFormat(0, 10, "Notes", "", 1, "L", false, 0, "")
+		pdf.PutEndnotes()
+	}
+	fileStr := example.Filename("Fpdf_PutEndnotes")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_PutEndnotes.pdf
+}
+
+// TestEndnote verifies the numbering of endnotes, their page breaks and the
+// position after they are output.
+func TestEndnote(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetFootnoteOptions(gofpdf.FootnoteOptions{LineHt: 5})
+	pdf.AddPage()
+	for j := 1; j <= 60; j++ {
+		if num := pdf.AddEndnote(fmt.Sprintf("note%d", j)); num != j {
+			t.Fatalf("note %d is number %d", j, num)
+		}
+	}
+	_, pageHt := pdf.GetPageSize()
+	pdf.SetY(pageHt / 2)
+	pdf.PutEndnotes()
+	if n := pdf.PageCount(); n != 2 {
+		t.Fatalf("%d pages, expecting 2", n)
+	}
+	// The lines that do not fit above the bottom margin of the first page
+	// continue at the top of the second
+	left, top, _, bottom := pdf.GetMargins()
+	rest := 60 - int((pageHt/2-bottom)/5)
+	if x, y := pdf.GetXY(); math.Abs(x-left) > 1e-9 || math.Abs(y-(top+5*float64(rest))) > 1e-9 {
+		t.Fatalf("position (%.3f, %.3f) after notes", x, y)
+	}
+	if num := pdf.AddEndnote("restarted"); num != 1 {
+		t.Fatalf("numbering does not restart, note is number %d", num)
+	}
+	pdf.PutEndnotes()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	pages := bytes.Split(buf.Bytes(), []byte("/Type /Page\n"))
+	if !bytes.Contains(pages[1], []byte("(note1)Tj")) || !bytes.Contains(pages[2], []byte("(note60)Tj")) ||
+		!bytes.Contains(pages[2], []byte("(restarted)Tj")) {
+		t.Fatal("notes not found on their pages")
+	}
+}
+
+// ExampleFpdf_KeepTogether demonstrates headings that are kept with the first
+// lines of the paragraphs that follow them, and paragraphs that are broken
+// with widow and orphan control.
+func ExampleFpdf_KeepTogether() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetWidowOrphanLines(2, 2)
+	pdf.AddPage()
+	loremStr := lorem()
+	for j := 1; j <= 12; j++ {
+		pdf.KeepTogether(func() {
+			pdf.SetFont("Arial", "B", 14)
+			pdf.CellFormat(0, 10, fmt.Sprintf("Heading %d", j), "", 1, "L", false, 0, "")
+			pdf.SetFont("Times", "", 12)
+			pdf.MultiCell(0, 5, loremStr[:150], "", "J", false)
+		})
+		pdf.MultiCell(0, 5, loremStr[150:]+" "+loremStr, "", "J", false)
+		pdf.Ln(4)
+	}
+	fileStr := example.Filename("Fpdf_KeepTogether")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_KeepTogether.pdf
+}
+
+// TestKeepTogether verifies that measuring content leaves the document
+// unchanged and that a block that does not fit moves to the next page.
+func TestKeepTogether(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	_, pageHt := pdf.GetPageSize()
+	_, top, _, bottom := pdf.GetMargins()
+	block := func() {
+		for j := 0; j < 3; j++ {
+			pdf.CellFormat(0, 10, fmt.Sprintf("line %d", j), "1", 1, "L", false, 0, "https://example.com")
+		}
+	}
+	pdf.SetY(pageHt - bottom - 25)
+	if ht := pdf.MeasureHeight(block); math.Abs(ht-30) > 1e-9 {
+		t.Fatalf("height %.3f, expecting 30", ht)
+	}
+	if pdf.PageNo() != 1 || math.Abs(pdf.GetY()-(pageHt-bottom-25)) > 1e-9 {
+		t.Fatalf("position changed by measuring")
+	}
+	pdf.KeepTogether(block)
+	if pdf.PageNo() != 2 || math.Abs(pdf.GetY()-(top+30)) > 1e-9 {
+		t.Fatalf("block ends at %.3f on page %d", pdf.GetY(), pdf.PageNo())
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for j := 0; j < 3; j++ {
+		if n := bytes.Count(buf.Bytes(), []byte(fmt.Sprintf("(line %d)Tj", j))); n != 1 {
+			t.Fatalf("line %d output %d times", j, n)
+		}
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("/Subtype /Link")); n != 3 {
+		t.Fatalf("%d links, expecting 3", n)
+	}
+}
+
+// TestWidowOrphan verifies where MultiCell() breaks text with widow and
+// orphan control.
+func TestWidowOrphan(t *testing.T) {
+	for _, tc := range []struct {
+		fit, widows, orphans int
+		page2                int // lines expected on the second page
+	}{
+		{5, 0, 0, 1},
+		{5, 2, 2, 2},
+		{5, 3, 2, 3},
+		{1, 2, 2, 6},
+		{2, 2, 3, 6},
+		{2, 2, 2, 4},
+	} {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.SetWidowOrphanLines(tc.widows, tc.orphans)
+		pdf.AddPage()
+		_, pageHt := pdf.GetPageSize()
+		_, top, _, bottom := pdf.GetMargins()
+		pdf.SetY(pageHt - bottom - float64(tc.fit)*5 - 1)
+		pdf.MultiCell(0, 5, "one\ntwo\nthree\nfour\nfive\nsix", "", "L", false)
+		if pdf.PageNo() != 2 {
+			t.Fatalf("%+v: text ends on page %d", tc, pdf.PageNo())
+		}
+		if y, expect := pdf.GetY(), top+float64(tc.page2)*5; math.Abs(y-expect) > 1e-9 {
+			t.Fatalf("%+v: %.0f lines on page 2", tc, (y-top)/5)
+		}
+		// The page break trigger is restored for the text that follows
+		pdf.SetY(pageHt - bottom - 5)
+		pdf.CellFormat(0, 5, "last", "", 1, "L", false, 0, "")
+		if pdf.PageNo() != 2 {
+			t.Fatalf("%+v: page break trigger not restored", tc)
+		}
+	}
+}
+
+// ExampleFpdf_AddExclusion demonstrates text that flows around floating
+// images and an exclusion area.
+func ExampleFpdf_AddExclusion() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Times", "", 12)
+	loremStr := lorem()
+	opts := gofpdf.ImageOptions{Float: "R", FloatMargin: 3}
+	pdf.ImageOptions(example.ImageFile("logo.png"), 0, 0, 50, 0, true, opts, 0, "")
+	pdf.MultiCell(0, 5, loremStr+" "+loremStr, "", "J", false)
+	pdf.Ln(5)
+	opts.Float = "L"
+	pdf.ImageOptions(example.ImageFile("logo.gif"), 0, 0, 40, 0, true, opts, 0, "")
+	pdf.Write(5, loremStr+" "+loremStr)
+	pdf.Ln(10)
+	// A framed box in the middle of the page
+	y := pdf.GetY()
+	pdf.SetFillColor(220, 230, 245)
+	pdf.Rect(80, y+5, 50, 30, "F")
+	pdf.AddExclusion(77, y+2, 56, 36)
+	html := pdf.HTMLBasicNew()
+	html.Write(5, "<b>HTML text</b> flows on the widest side of the box. <i>"+loremStr+"</i> "+loremStr)
+	fileStr := example.Filename("Fpdf_AddExclusion")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddExclusion.pdf
+}
+
+// TestExclusion verifies that lines are shortened by exclusion areas and
+// move below areas that leave too little room.
+func TestExclusion(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Courier", "", 10)
+	pdf.SetCellMargin(0)
+	pdf.SetMargins(10, 10, 10)
+	pdf.AddPage()
+	pageWd, _ := pdf.GetPageSize()
+	// Area on the right of the first two lines
+	pdf.AddExclusion(pageWd-60, 10, 50, 10)
+	words := strings.TrimSpace(strings.Repeat("abcd ", 80))
+	pdf.MultiCell(0, 5, words, "", "L", false)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(buf.Bytes(), []byte("\n")) {
+		if bytes.HasSuffix(line, []byte(")Tj ET")) {
+			lines = append(lines, line)
+		}
+	}
+	// Courier characters are 0.6 times the font size wide
+	charWd := 0.6 * 10 / 72 * 25.4
+	for j, line := range lines[:4] {
+		text := line[bytes.IndexByte(line, '(')+1 : len(line)-len(")Tj ET")]
+		wd := float64(len(text)) * charWd
+		limit := pageWd - 20
+		if j < 2 {
+			limit -= 50
+		}
+		if wd > limit+1e-6 || wd < limit-5*charWd {
+			t.Fatalf("line %d is %.1f wide, expecting up to %.1f", j, wd, limit)
+		}
+	}
+
+	// An area across the page moves the text below it
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	pdf.AddExclusion(0, 10, pageWd, 20)
+	pdf.SetY(10)
+	pdf.Write(5, "below")
+	left, _, _, _ := pdf.GetMargins()
+	if x, y := pdf.GetXY(); math.Abs(y-30) > 1e-9 || math.Abs(x-left-pdf.GetStringWidth("below")) > 1e-9 {
+		t.Fatalf("text ends at (%.3f, %.3f), expecting it on a single line at 30", x, y)
+	}
+	pdf.AddPage()
+	pdf.SetY(10)
+	pdf.Write(5, "new page")
+	if y := pdf.GetY(); math.Abs(y-10) > 1e-9 {
+		t.Fatalf("exclusion area not removed on new page")
+	}
+}
+
+// ExampleFpdf_MultiCellFit demonstrates badges whose names are fitted into
+// boxes of the same size.
+func ExampleFpdf_MultiCellFit() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.SetFillColor(230, 240, 250)
+	names := []string{
+		"Ann Lee",
+		"Maximilian Alexander von Hohenberg-Wittelsbach",
+		"Dr. Bartholomew Fitzgerald-Montgomery III, Chair of the Program Committee of the Conference",
+	}
+	opts := gofpdf.FitOptions{MinFontSize: 12, MaxLines: 2}
+	for _, name := range names {
+		pdf.SetX(30)
+		sizePt, strategy := pdf.MultiCellFit(80, 20, name, "1", "CM", true, opts)
+		pdf.SetXY(115, pdf.GetY()-20)
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(60, 20, fmt.Sprintf("%.1f pt, strategy %d", sizePt, strategy), "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "B", 20)
+		pdf.Ln(5)
+	}
+	fileStr := example.Filename("Fpdf_MultiCellFit")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_MultiCellFit.pdf
+}
+
+// TestFitText verifies the strategy applied to fit text into a box.
+func TestFitText(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Courier", "", 10)
+	pdf.SetCellMargin(0)
+	pdf.AddPage()
+	// Courier characters are 0.6 times the font size wide, so 10 characters
+	// of 10 points fill 21.17 mm
+	charWd := 0.6 * 10 / 72 * 25.4
+	lineHt := 10 * 1.25 / 72 * 25.4
+	w := 10*charWd + 1e-6
+	lines, sizePt, strategy := pdf.FitText(w, 2*lineHt, "abcd efgh", gofpdf.FitOptions{})
+	if strategy != gofpdf.FitNone || sizePt != 10 || len(lines) != 1 {
+		t.Fatalf("got %q at %.1f pt with strategy %d, expecting a single line", lines, sizePt, strategy)
+	}
+	// Twenty characters fit on one line at 5 points
+	lines, sizePt, strategy = pdf.FitText(w, lineHt, "abcd efgh abcd efgh", gofpdf.FitOptions{MinFontSize: 4})
+	if strategy != gofpdf.FitShrink || sizePt != 5 || len(lines) != 1 {
+		t.Fatalf("got %q at %.1f pt with strategy %d, expecting a single line at 5 pt", lines, sizePt, strategy)
+	}
+	lines, sizePt, strategy = pdf.FitText(w, 5*lineHt, "abcd efgh abcd efgh abcd efgh", gofpdf.FitOptions{MaxLines: 2})
+	if strategy != gofpdf.FitTruncate || sizePt != 10 || len(lines) != 2 || lines[1] != "abcd ef..." {
+		t.Fatalf("got %q at %.1f pt with strategy %d, expecting two truncated lines", lines, sizePt, strategy)
+	}
+	if ptSize, _ := pdf.GetFontSize(); ptSize != 10 {
+		t.Fatalf("font size changed to %.1f", ptSize)
+	}
+	pdf.SetXY(20, 30)
+	pdf.MultiCellFit(w, lineHt, "abcd efgh abcd efgh", "1", "", false, gofpdf.FitOptions{MinFontSize: 4})
+	left, _, _, _ := pdf.GetMargins()
+	if x, y := pdf.GetXY(); math.Abs(y-30-lineHt) > 1e-9 || x != left {
+		t.Fatalf("current position is (%.3f, %.3f), expecting the left margin below the box", x, y)
+	}
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ExampleFpdf_TextOnPath demonstrates a seal with text around a circle and a
+// caption along a curve.
+func ExampleFpdf_TextOnPath() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.SetDrawColor(160, 30, 30)
+	pdf.SetTextColor(160, 30, 30)
+	pdf.SetLineWidth(0.8)
+	x, y, r := 105.0, 80.0, 40.0
+	pdf.Circle(x, y, r+8, "D")
+	pdf.Circle(x, y, r-8, "D")
+	// Upper half of the circle, clockwise from the left
+	top := pdf.PathNew()
+	top.ArcTo(x, y, r, r, 0, 180, 0)
+	pdf.TextOnPath("CERTIFICATE OF EXCELLENCE", top.Segments(), gofpdf.TextPathOptions{Align: "C", Rise: -2})
+	// Lower half, counter-clockwise from the left, with the text below the
+	// path so that it reads upright
+	bottom := pdf.PathNew()
+	bottom.ArcTo(x, y, r, r, 0, 180, 360)
+	pdf.TextOnPath("* GOFPDF * 2024 *", bottom.Segments(), gofpdf.TextPathOptions{Align: "C", Rise: -3})
+	pdf.SetFont("Times", "I", 16)
+	pdf.SetTextColor(30, 30, 120)
+	pdf.SetDrawColor(200, 200, 200)
+	pdf.SetLineWidth(0.2)
+	wave := pdf.PathNew()
+	wave.MoveTo(30, 180)
+	wave.CurveBezierCubicTo(70, 140, 110, 220, 180, 170)
+	wave.DrawPath("D")
+	pdf.TextOnPath("Text follows the curve of any path", wave.Segments(), gofpdf.TextPathOptions{Offset: 5, Rise: 1})
+	fileStr := example.Filename("Fpdf_TextOnPath")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_TextOnPath.pdf
+}
+
+// TestTextOnPath verifies the position and the rotation of characters laid
+// along a path.
+func TestTextOnPath(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Courier", "", 10)
+	pdf.AddPage()
+	_, pageHt := pdf.GetPageSize()
+	// Courier characters are 6 points wide at 10 points
+	segs := []gofpdf.SVGBasicSegmentType{
+		{Cmd: 'M', Arg: [6]float64{100, 100}},
+		{Cmd: 'H', Arg: [6]float64{200}},
+		{Cmd: 'V', Arg: [6]float64{300}},
+	}
+	pdf.TextOnPath("ab", segs, gofpdf.TextPathOptions{Offset: 94})
+	pdf.TextOnPath("c", segs, gofpdf.TextPathOptions{Align: "R", Rise: 2})
+	// A path made with an arc ends where the arc ends
+	p := pdf.PathNew()
+	p.MoveTo(0, 0)
+	p.ArcTo(50, 50, 20, 10, 90, 0, 90)
+	segs2 := p.Segments()
+	if last := segs2[len(segs2)-1].Arg; math.Abs(last[4]-40) > 1e-9 || math.Abs(last[5]-50) > 1e-9 {
+		t.Fatalf("arc ends at (%.3f, %.3f), expecting (40, 50)", last[4], last[5])
+	}
+	if segs2[1].Cmd != 'L' || math.Abs(segs2[1].Arg[0]-50) > 1e-9 || math.Abs(segs2[1].Arg[1]-30) > 1e-9 {
+		t.Fatalf("got %c segment to (%.3f, %.3f), expecting a line to the start of the arc", segs2[1].Cmd, segs2[1].Arg[0], segs2[1].Arg[1])
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		// a before the corner, b after it turned down the page
+		fmt.Sprintf("1.00000 0.00000 0.00000 1.00000 194.00 %.2f Tm (a) Tj", pageHt-100),
+		fmt.Sprintf("0.00000 -1.00000 1.00000 0.00000 200.00 %.2f Tm (b) Tj", pageHt-100),
+		// c at the end of the path, 2 points to the right of it
+		fmt.Sprintf("0.00000 -1.00000 1.00000 0.00000 202.00 %.2f Tm (c) Tj", pageHt-294),
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("output does not contain %q", s)
+		}
+	}
+	pdf.TextOnPath("x", []gofpdf.SVGBasicSegmentType{{Cmd: 'A'}}, gofpdf.TextPathOptions{})
+	if pdf.Error() == nil {
+		t.Fatal("expecting an error for an unsupported path command")
+	}
+	// Characters missing from the current font are shown in its fallback
+	// font, which is replaced by the current font again at the end
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("calligra", "", example.FontFile("calligra.ttf"))
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFontFallback("calligra", "dejavu")
+	pdf.AddPage()
+	pdf.SetFont("calligra", "", 10)
+	pdf.TextOnPath("aλ", segs, gofpdf.TextPathOptions{})
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`\(\x00a\) Tj /F(\w+) 10.00 Tf [-. 0-9]+ Tm \(\x03.\) Tj /F(\w+) 10.00 Tf ET`)
+	if m := re.FindSubmatch(buf.Bytes()); m == nil || bytes.Equal(m[1], m[2]) {
+		t.Fatal("fallback character not shown in the fallback font")
+	}
+}
+
+// ExampleFpdf_TextOutline demonstrates text converted to outlines that are
+// filled, stroked and used as a clipping path.
+func ExampleFpdf_TextOutline() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 48)
+	pdf.SetFillColor(40, 90, 160)
+	pdf.SetDrawColor(20, 20, 60)
+	pdf.SetLineWidth(0.4)
+	pdf.TextOutline(20, 40, "Outlined text").DrawPath("FD")
+	pdf.TextOutline(20, 70, "Stroked only").DrawPath("D")
+	// Text as a clipping path for a gradient
+	pdf.SetFont("dejavu", "", 72)
+	pdf.TextOutline(20, 120, "Clipped").ClipPath(false)
+	pdf.LinearGradient(20, 90, 170, 40, 250, 120, 0, 120, 0, 160, 0, 0, 1, 0)
+	pdf.ClipEnd()
+	fileStr := example.Filename("Fpdf_TextOutline")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_TextOutline.pdf
+}
+
+// TestTextOutline verifies the contours of glyphs with TrueType and CFF
+// outlines.
+func TestTextOutline(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddUTF8Font("cff", "", example.FontFile("CFFTest.otf"))
+	pdf.AddPage()
+	for _, c := range []struct {
+		family, txt string
+		contours    int
+		curves      bool
+	}{
+		{"dejavu", "I", 1, false},
+		{"dejavu", "O", 2, true},
+		// A composite glyph made of A and a dieresis
+		{"dejavu", "Ä", 4, false},
+		{"cff", "1", 1, false},
+		{"cff", "Q", 2, true},
+	} {
+		pdf.SetFont(c.family, "", 100)
+		_, size := pdf.GetFontSize()
+		wd := pdf.GetStringWidth(c.txt)
+		count := map[byte]int{}
+		for _, seg := range pdf.TextOutline(10, 100, c.txt).Segments() {
+			count[seg.Cmd]++
+			n := map[byte]int{'M': 1, 'L': 1, 'C': 3}[seg.Cmd]
+			for j := 0; j < n; j++ {
+				x, y := seg.Arg[2*j], seg.Arg[2*j+1]
+				if x < 10 || x > 10+wd || y < 100-size || y > 100+size/3 {
+					t.Fatalf("%s %q: point (%.3f, %.3f) outside the glyph box", c.family, c.txt, x, y)
+				}
+			}
+		}
+		if count['M'] != c.contours || count['Z'] != c.contours || (count['C'] > 0) != c.curves {
+			t.Fatalf("%s %q: got %v segments, expecting %d contours", c.family, c.txt, count, c.contours)
+		}
+	}
+	// The outline of "I" rises to the cap height of the font
+	pdf.SetFont("dejavu", "", 100)
+	top := 100.0
+	for _, seg := range pdf.TextOutline(10, 100, "I").Segments() {
+		if seg.Cmd == 'M' || seg.Cmd == 'L' {
+			top = math.Min(top, seg.Arg[1])
+		}
+	}
+	if _, size := pdf.GetFontSize(); math.Abs(100-top-0.729*size) > 0.01 {
+		t.Fatalf("top of I at %.3f", top)
+	}
+	// Shaped text has the same outline
+	plain := pdf.TextOutline(10, 100, "AV").Segments()
+	pdf.SetTextShaping(true)
+	shaped := pdf.TextOutline(10, 100, "AV").Segments()
+	pdf.SetTextShaping(false)
+	if len(plain) == 0 || len(plain) != len(shaped) {
+		t.Fatalf("%d segments shaped, %d not shaped", len(shaped), len(plain))
+	}
+	pdf.TextOutline(10, 150, "clip").ClipPath(true)
+	pdf.ClipEnd()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(" h W S\n")) {
+		t.Fatal("clipping path not found")
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.TextOutline(10, 10, "core")
+	if pdf.Error() == nil {
+		t.Fatal("expecting an error for a core font")
+	}
+}
@@ -8,6 +8,7 @@ replace (
 )
 
 require (
+	github.com/andybalholm/brotli v1.0.6
 	github.com/boombuler/barcode v1.0.0
 	github.com/foobaz/lossypng v0.0.0-20200814224715-48fa8819852a
 	github.com/phpdave11/gofpdi v1.0.13
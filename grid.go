@@ -29,6 +29,9 @@ type StateType struct {
 	alpha                     float64
 	blendStr                  string
 	cellMargin                float64
+	charSpacing               float64
+	hScaling                  float64
+	textRise                  float64
 }
 
 // StateGet returns a variable that contains common state values.
@@ -40,6 +43,9 @@ func StateGet(pdf *Fpdf) (st StateType) {
 	_, st.fontSize = pdf.GetFontSize()
 	st.alpha, st.blendStr = pdf.GetAlpha()
 	st.cellMargin = pdf.GetCellMargin()
+	st.charSpacing = pdf.GetCharSpacing()
+	st.hScaling = pdf.GetHorizontalScaling()
+	st.textRise = pdf.GetTextRise()
 	return
 }
 
@@ -53,6 +59,9 @@ func (st StateType) Put(pdf *Fpdf) {
 	pdf.SetFontUnitSize(st.fontSize)
 	pdf.SetAlpha(st.alpha, st.blendStr)
 	pdf.SetCellMargin(st.cellMargin)
+	pdf.SetCharSpacing(st.charSpacing)
+	pdf.SetHorizontalScaling(st.hScaling)
+	pdf.SetTextRise(st.textRise)
 }
 
 // TickFormatFncType defines a callback for label drawing.
@@ -0,0 +1,305 @@
+package gofpdf
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// hyphenatorType finds the points at which the words of a language can be
+// hyphenated with Frank Liang's algorithm, as used by TeX.
+type hyphenatorType struct {
+	patterns   map[string][]int // inter-letter values by letter sequence
+	maxLen     int              // length of the longest pattern
+	exceptions map[string][]int // hyphenation points of exceptional words
+	leftMin    int              // minimum number of letters before a hyphen
+	rightMin   int              // minimum number of letters after a hyphen
+}
+
+// parseHyphenation reads hyphenation patterns. The patterns are separated by
+// spaces or line breaks, and may be enclosed in \patterns{...} as in TeX
+// pattern files. Words hyphenated explicitly, such as "ta-ble", may follow in
+// \hyphenation{...}. Comments start with %.
+func parseHyphenation(r io.Reader) (*hyphenatorType, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	h := &hyphenatorType{
+		patterns:   make(map[string][]int),
+		exceptions: make(map[string][]int),
+		leftMin:    2,
+		rightMin:   3,
+	}
+	exceptions := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if k := strings.IndexByte(line, '%'); k >= 0 {
+			line = line[:k]
+		}
+		for _, tok := range strings.Fields(line) {
+			switch {
+			case strings.HasPrefix(tok, `\patterns{`):
+				exceptions = false
+				tok = tok[len(`\patterns{`):]
+			case strings.HasPrefix(tok, `\hyphenation{`):
+				exceptions = true
+				tok = tok[len(`\hyphenation{`):]
+			case strings.HasPrefix(tok, `\`):
+				continue
+			}
+			tok = strings.TrimSuffix(tok, "}")
+			if tok == "" {
+				continue
+			}
+			if exceptions {
+				h.addException(tok)
+			} else {
+				h.addPattern(tok)
+			}
+		}
+	}
+	if len(h.patterns) == 0 && len(h.exceptions) == 0 {
+		return nil, fmt.Errorf("no hyphenation patterns found")
+	}
+	return h, nil
+}
+
+// addPattern adds a pattern such as "hy3ph", in which digits give the
+// values of the points between letters.
+func (h *hyphenatorType) addPattern(pattern string) {
+	var letters []rune
+	values := []int{0}
+	for _, r := range pattern {
+		if r >= '0' && r <= '9' {
+			values[len(values)-1] = int(r - '0')
+		} else {
+			letters = append(letters, unicode.ToLower(r))
+			values = append(values, 0)
+		}
+	}
+	h.patterns[string(letters)] = values
+	if len(letters) > h.maxLen {
+		h.maxLen = len(letters)
+	}
+}
+
+// addException adds a word hyphenated explicitly, such as "ta-ble".
+func (h *hyphenatorType) addException(word string) {
+	var letters []rune
+	var points []int
+	for _, r := range word {
+		if r == '-' {
+			points = append(points, len(letters))
+		} else {
+			letters = append(letters, unicode.ToLower(r))
+		}
+	}
+	h.exceptions[string(letters)] = points
+}
+
+// points returns the positions in word, in increasing order, before which a
+// hyphen may be inserted.
+func (h *hyphenatorType) points(word []rune) (list []int) {
+	n := len(word)
+	lower := make([]rune, n+2)
+	lower[0], lower[n+1] = '.', '.'
+	for j, r := range word {
+		lower[j+1] = unicode.ToLower(r)
+	}
+	if points, ok := h.exceptions[string(lower[1:n+1])]; ok {
+		return points
+	}
+	if n < h.leftMin+h.rightMin {
+		return nil
+	}
+	// values[k] is the value of the point before lower[k]
+	values := make([]int, n+3)
+	for start := range lower {
+		for end := start + 1; end <= len(lower) && end-start <= h.maxLen; end++ {
+			if v, ok := h.patterns[string(lower[start:end])]; ok {
+				for k, x := range v {
+					if x > values[start+k] {
+						values[start+k] = x
+					}
+				}
+			}
+		}
+	}
+	for p := h.leftMin; p <= n-h.rightMin; p++ {
+		if values[p+1]%2 == 1 {
+			list = append(list, p)
+		}
+	}
+	return
+}
+
+// AddHyphenationPatterns reads the hyphenation patterns of a language from
+// r and makes them available to SetHyphenation() under the name lang. The
+// patterns are those of Liang's algorithm used by TeX, for example from the
+// hyph-*.pat.txt or hyph-*.tex files of the hyph-utf8 project; exceptions
+// given with \hyphenation{...} are used as well.
+func (f *Fpdf) AddHyphenationPatterns(lang string, r io.Reader) {
+	if f.err != nil {
+		return
+	}
+	h, err := parseHyphenation(r)
+	if err != nil {
+		f.err = fmt.Errorf("hyphenation patterns for %s: %s", lang, err)
+		return
+	}
+	if f.hyphenators == nil {
+		f.hyphenators = make(map[string]*hyphenatorType)
+	}
+	f.hyphenators[lang] = h
+}
+
+// SetHyphenationLoader sets a loader used to read hyphenation patterns from
+// an arbitrary source. When SetHyphenation() is called with a language whose
+// patterns have not been added with AddHyphenationPatterns(), the loader is
+// asked to open the patterns of the language.
+func (f *Fpdf) SetHyphenationLoader(loader HyphenationLoader) {
+	f.hyphenLoader = loader
+}
+
+// SetHyphenation turns on the automatic hyphenation of words in the language
+// lang, or turns hyphenation off if lang is empty. The patterns of the
+// language must have been added with AddHyphenationPatterns() or be available
+// from the loader set with SetHyphenationLoader().
+//
+// When a word does not fit on a line, MultiCell(), SplitLines(), SplitText()
+// and WriteAligned() break it at the last of its hyphenation points that
+// lets the first part fit, followed by a hyphen. At least two letters are
+// kept before a hyphen and three after it.
+func (f *Fpdf) SetHyphenation(lang string) {
+	if f.err != nil {
+		return
+	}
+	if lang == "" {
+		f.hyphenator, f.hyphenLang = nil, ""
+		return
+	}
+	h, ok := f.hyphenators[lang]
+	if !ok {
+		if f.hyphenLoader == nil {
+			f.err = fmt.Errorf("hyphenation patterns for %s not found", lang)
+			return
+		}
+		r, err := f.hyphenLoader.Open(lang)
+		if err != nil {
+			f.err = err
+			return
+		}
+		f.AddHyphenationPatterns(lang, r)
+		if closer, ok := r.(io.Closer); ok {
+			closer.Close()
+		}
+		if f.err != nil {
+			return
+		}
+		h = f.hyphenators[lang]
+	}
+	f.hyphenator, f.hyphenLang = h, lang
+}
+
+// GetHyphenation returns the language of automatic hyphenation, or an empty
+// string if hyphenation is off. See SetHyphenation().
+func (f *Fpdf) GetHyphenation() string {
+	return f.hyphenLang
+}
+
+// hyphenLetter returns true if r is part of a word that can be hyphenated.
+func hyphenLetter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Mn, r)
+}
+
+// hyphenBreak returns the position in txt at which a line that starts at j
+// and exceeds the width wmax, in thousandths of the font size, at position i
+// is broken with a hyphen. The word at i may begin before j if the previous
+// line broke it. It returns -1 if hyphenation is off or no
+// hyphenation point of the word at i lets the line fit. For fonts that are
+// not UTF-8 fonts, the characters of txt are the bytes of the text.
+func (f *Fpdf) hyphenBreak(txt []rune, j, i, wmax int) int {
+	if f.hyphenator == nil || i >= len(txt) || !hyphenLetter(txt[i]) {
+		return -1
+	}
+	start, end := i, i
+	for start > 0 && hyphenLetter(txt[start-1]) {
+		start--
+	}
+	for end < len(txt) && hyphenLetter(txt[end]) {
+		end++
+	}
+	points := f.hyphenator.points(txt[start:end])
+	for k := len(points) - 1; k >= 0; k-- {
+		pos := start + points[k]
+		if pos <= j || pos > i {
+			continue
+		}
+		var line string
+		if f.isCurrentUTF8 {
+			line = string(txt[j:pos])
+		} else {
+			b := make([]byte, pos-j)
+			for n, r := range txt[j:pos] {
+				b[n] = byte(r)
+			}
+			line = string(b)
+		}
+		if f.symbolWidth(line+"-") <= wmax {
+			return pos
+		}
+	}
+	return -1
+}
+
+// hyphenBreakBytes is hyphenBreak for text given as bytes, which are UTF-8
+// encoded for UTF-8 fonts. Positions are byte offsets.
+func (f *Fpdf) hyphenBreakBytes(s []byte, j, i, wmax int) int {
+	if f.hyphenator == nil {
+		return -1
+	}
+	// Start at the beginning of the word if a line broke it
+	start := j
+	for start > 0 {
+		r, size := rune(s[start-1]), 1
+		if f.isCurrentUTF8 {
+			r, size = utf8.DecodeLastRune(s[:start])
+		}
+		if !hyphenLetter(r) {
+			break
+		}
+		start -= size
+	}
+	var txt []rune
+	var offsets []int
+	rj, ri := -1, -1
+	for k := start; k < len(s); {
+		r, size := rune(s[k]), 1
+		if f.isCurrentUTF8 {
+			r, size = utf8.DecodeRune(s[k:])
+		}
+		if k > i && !hyphenLetter(r) {
+			break
+		}
+		if k == j {
+			rj = len(txt)
+		}
+		if k == i {
+			ri = len(txt)
+		}
+		txt = append(txt, r)
+		offsets = append(offsets, k)
+		k += size
+	}
+	if ri < 0 {
+		return -1
+	}
+	pos := f.hyphenBreak(txt, rj, ri, wmax)
+	if pos < 0 {
+		return -1
+	}
+	return offsets[pos]
+}
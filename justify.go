@@ -0,0 +1,52 @@
+package gofpdf
+
+// SetJustification sets how lines of text in UTF-8 fonts are stretched when
+// they are justified, as MultiCell() does with the "J" alignment. Such lines
+// are justified with positioning adjustments in TJ arrays, because the Tw
+// word spacing operator has no effect on the two-byte codes of UTF-8 fonts.
+//
+// The spaces of a line are first widened by up to maxWordSpacing, in the unit
+// of measure specified in New(). The remaining space is then distributed
+// between the characters of the line, adding up to maxCharSpacing between
+// two characters, and what still remains is added to the spaces. Lines
+// without spaces are stretched between their characters only, up to
+// maxCharSpacing.
+//
+// By default, both values are 0: the spaces are widened without limit and
+// characters are never spread apart. A maxWordSpacing of 0 with a positive
+// maxCharSpacing only spreads the characters of lines without spaces.
+func (f *Fpdf) SetJustification(maxWordSpacing, maxCharSpacing float64) {
+	f.justifyWord = maxWordSpacing
+	f.justifyChar = maxCharSpacing
+}
+
+// justifySpacing returns the spacing, in thousandths of the font size, that
+// is added at each of the spaces and between each two characters of a line to
+// stretch it by extra thousandths of the font size. The line has the given
+// number of spaces and gaps between characters.
+func (f *Fpdf) justifySpacing(extra float64, spaces, gaps int) (word, char float64) {
+	if extra <= 0 || f.justifyChar <= 0 || gaps <= 0 {
+		if spaces > 0 {
+			word = extra / float64(spaces)
+		}
+		return
+	}
+	maxWord := f.justifyWord * 1000 / f.fontSize
+	maxChar := f.justifyChar * 1000 / f.fontSize
+	if spaces > 0 {
+		if maxWord <= 0 || extra <= maxWord*float64(spaces) {
+			word = extra / float64(spaces)
+			return
+		}
+		word = maxWord
+		extra -= maxWord * float64(spaces)
+	}
+	char = extra / float64(gaps)
+	if char > maxChar {
+		char = maxChar
+	}
+	if spaces > 0 {
+		word += (extra - char*float64(gaps)) / float64(spaces)
+	}
+	return
+}
@@ -0,0 +1,126 @@
+package gofpdf
+
+import (
+	"bytes"
+)
+
+// MeasureHeight calls fnc without producing any output and returns the
+// distance by which fnc moves the current ordinate. Automatic page breaks are
+// disabled while fnc is called, so the height of content that would not fit
+// on the current page is measured as if the page were long enough. The state
+// of the document, including the current position, font and colors, is
+// restored after fnc returns; links, bookmarks, footnotes and table of
+// contents entries added by fnc are discarded. fnc must not add pages.
+func (f *Fpdf) MeasureHeight(fnc func()) (ht float64) {
+	if f.err != nil {
+		return
+	}
+	if f.page <= 0 {
+		f.SetErrorf("cannot measure content without first adding a page")
+		return
+	}
+	saved := *f
+	page := f.page
+	content, links, attachments := f.pages[page], f.pageLinks[page], f.pageAttachments[page]
+	elems := append([]tagElemType(nil), f.tag.elems...)
+	parentTree := append([][]int(nil), f.tag.parentTree...)
+	f.pages[page] = new(bytes.Buffer)
+	f.autoPageBreak = false
+	y := f.y
+	fnc()
+	ht = f.y - y
+	err, added := f.err, f.page != page || len(f.pages) != len(saved.pages)
+	*f = saved
+	f.pages[page], f.pageLinks[page], f.pageAttachments[page] = content, links, attachments
+	copy(f.tag.elems, elems)
+	copy(f.tag.parentTree, parentTree)
+	f.err = err
+	if added {
+		for p := range f.pageSizes {
+			if p >= len(f.pages) {
+				delete(f.pageSizes, p)
+			}
+		}
+		for p := range f.pageBoxes {
+			if p >= len(f.pages) {
+				delete(f.pageBoxes, p)
+			}
+		}
+		if f.err == nil {
+			f.SetErrorf("measured content must not add pages")
+		}
+	}
+	return
+}
+
+// KeepTogether outputs the content produced by fnc on a single page. The
+// height of the content is first measured with MeasureHeight(). If it does
+// not fit between the current position and the page break trigger (see
+// SetAutoPageBreak()), but fits on an empty page, a page break occurs before
+// the content is output, provided that AcceptPageBreak() allows it. When text
+// is laid out in columns (see SetColumns()), the content moves to the next
+// column instead. Content that is taller than a page is output at the current
+// position and broken as usual.
+//
+// Use this method to keep a heading with the first lines of the paragraph
+// that follows it, or a small table or figure with its caption. The
+// KeepTogether() example demonstrates this method.
+func (f *Fpdf) KeepTogether(fnc func()) {
+	ht := f.MeasureHeight(fnc)
+	if f.err != nil {
+		return
+	}
+	if ht > 0 && f.y+ht > f.pageBreakTrigger && ht <= f.pageBreakTrigger-f.tMargin &&
+		!f.inHeader && !f.inFooter && !f.nextColumn() && f.acceptPageBreak() {
+		f.AddPageFormat(f.curOrientation, f.curPageSize)
+		if f.err != nil {
+			return
+		}
+	}
+	fnc()
+}
+
+// SetWidowOrphanLines sets the minimum number of lines of text output with
+// MultiCell() that are left at the bottom of a page (orphans) and that are
+// carried over to the top of the next page (widows) when an automatic page
+// break occurs within the text. If fewer lines would be left at the bottom
+// of the page, the whole text moves to the next page; if fewer lines would be
+// carried over, lines are moved from the bottom of the page to the next one.
+// The text of a call to MultiCell() is treated as a single paragraph. Values
+// less than 2 disable the control, which is the default.
+func (f *Fpdf) SetWidowOrphanLines(widows, orphans int) {
+	f.widowLines, f.orphanLines = widows, orphans
+}
+
+// keepLines moves the page break trigger so that the page break that occurs
+// within the n lines of height h that start at the current ordinate respects
+// the widow and orphan limits.
+func (f *Fpdf) keepLines(n int, h float64) {
+	if (f.widowLines < 2 && f.orphanLines < 2) || !f.autoPageBreak || f.inHeader || f.inFooter || h <= 0 {
+		return
+	}
+	fit := int((f.pageBreakTrigger-f.y)/h + 1e-9)
+	if fit <= 0 || fit >= n {
+		return
+	}
+	k := fit
+	if n-k < f.widowLines {
+		k = n - f.widowLines
+	}
+	if k < f.orphanLines {
+		k = 0
+	}
+	if k == fit {
+		return
+	}
+	// Line k is the first one that no longer fits
+	f.breakDelta = f.pageBreakTrigger - (f.y + float64(k)*h + h/2)
+	f.pageBreakTrigger -= f.breakDelta
+}
+
+// restoreBreakTrigger restores the page break trigger moved by keepLines()
+// once the page break has occurred.
+func (f *Fpdf) restoreBreakTrigger() {
+	f.pageBreakTrigger += f.breakDelta
+	f.breakDelta = 0
+}
@@ -0,0 +1,215 @@
+package gofpdf
+
+import (
+	"math"
+	"math/bits"
+)
+
+// kernClassType holds the class-based pair adjustments of a GPOS pair
+// positioning subtable in format 2.
+type kernClassType struct {
+	coverage       map[int]int
+	class1, class2 map[int]int
+	class2Count    int
+	values         []int // indexed by class1*class2Count+class2
+}
+
+// kernTableType holds the kerning pairs of a font in thousandths of a text
+// space unit, keyed by glyph ID.
+type kernTableType struct {
+	pairs   map[[2]int]int
+	classes []kernClassType
+}
+
+// pair returns the kerning adjustment of the glyphs a and b. Individual pairs
+// take precedence over class-based adjustments.
+func (kt *kernTableType) pair(a, b int) int {
+	if v, ok := kt.pairs[[2]int{a, b}]; ok {
+		return v
+	}
+	for _, kc := range kt.classes {
+		if _, ok := kc.coverage[a]; ok {
+			idx := kc.class1[a]*kc.class2Count + kc.class2[b]
+			if idx < len(kc.values) && kc.values[idx] != 0 {
+				return kc.values[idx]
+			}
+		}
+	}
+	return 0
+}
+
+// parseKerning reads the kerning pairs of the font. Pair adjustments of the
+// GPOS kern feature are used if present; otherwise the kern table is used.
+func (utf *utf8FontFile) parseKerning() {
+	scale := 1000.0 / float64(utf.fontElementSize)
+	kt := kernTableType{pairs: make(map[[2]int]int)}
+	if gpos := otTable(utf.getTableData("GPOS")); gpos != nil {
+		for _, idx := range otFeatureLookups(gpos, "kern") {
+			tp, subtables := otLookupSubtables(gpos, idx, 9)
+			if tp != 2 {
+				continue
+			}
+			for _, st := range subtables {
+				kt.parsePairPos(st, scale)
+			}
+		}
+	}
+	if len(kt.pairs) == 0 && len(kt.classes) == 0 {
+		kt.parseKernTable(otTable(utf.getTableData("kern")), scale)
+	}
+	if len(kt.pairs) > 0 || len(kt.classes) > 0 {
+		utf.kern = &kt
+	}
+}
+
+// kernXAdvance returns the offset of the XAdvance field within a value record
+// of the specified format, or -1 if the record has no such field.
+func kernXAdvance(valueFormat int) int {
+	if valueFormat&4 == 0 {
+		return -1
+	}
+	return 2 * bits.OnesCount(uint(valueFormat&3))
+}
+
+func kernScale(v int, scale float64) int {
+	return int(math.Round(float64(v) * scale))
+}
+
+// parsePairPos reads a GPOS pair positioning subtable.
+func (kt *kernTableType) parsePairPos(st otTable, scale float64) {
+	vf1, vf2 := st.u16(4), st.u16(6)
+	size1 := 2 * bits.OnesCount(uint(vf1))
+	size2 := 2 * bits.OnesCount(uint(vf2))
+	xa := kernXAdvance(vf1)
+	if xa < 0 {
+		return
+	}
+	coverage := otCoverage(st.sub(st.u16(2)))
+	switch st.u16(0) {
+	case 1:
+		pairSets := st.u16(8)
+		for first, idx := range coverage {
+			if idx >= pairSets {
+				continue
+			}
+			ps := st.sub(st.u16(10 + 2*idx))
+			count := ps.u16(0)
+			for j := 0; j < count; j++ {
+				rec := 2 + j*(2+size1+size2)
+				key := [2]int{first, ps.u16(rec)}
+				if _, ok := kt.pairs[key]; !ok {
+					if v := ps.i16(rec + 2 + xa); v != 0 {
+						kt.pairs[key] = kernScale(v, scale)
+					}
+				}
+			}
+		}
+	case 2:
+		kc := kernClassType{
+			coverage:    coverage,
+			class1:      otClassDef(st.sub(st.u16(8))),
+			class2:      otClassDef(st.sub(st.u16(10))),
+			class2Count: st.u16(14),
+		}
+		class1Count := st.u16(12)
+		kc.values = make([]int, class1Count*kc.class2Count)
+		for j := range kc.values {
+			kc.values[j] = kernScale(st.i16(16+j*(size1+size2)+xa), scale)
+		}
+		kt.classes = append(kt.classes, kc)
+	}
+}
+
+// parseKernTable reads the horizontal format 0 subtables of a kern table.
+func (kt *kernTableType) parseKernTable(t otTable, scale float64) {
+	if t.u16(0) != 0 {
+		// Only the version of the table used by OpenType is supported
+		return
+	}
+	count := t.u16(2)
+	off := 4
+	for j := 0; j < count && off < len(t); j++ {
+		length, coverage := t.u16(off+2), t.u16(off+4)
+		// Format 0, horizontal, not minimum values and not cross-stream
+		if coverage>>8 == 0 && coverage&7 == 1 {
+			pairs := t.u16(off + 6)
+			for k := 0; k < pairs; k++ {
+				rec := off + 14 + 6*k
+				key := [2]int{t.u16(rec), t.u16(rec + 2)}
+				if _, ok := kt.pairs[key]; !ok {
+					kt.pairs[key] = kernScale(t.i16(rec+4), scale)
+				}
+			}
+		}
+		if length == 0 {
+			break
+		}
+		off += length
+	}
+}
+
+// SetKerning turns kerning on or off for fonts added with AddUTF8Font() and
+// related methods. With kerning on, the spacing of pairs of characters such
+// as "AV" or "To" is adjusted as specified by the pair adjustments of the
+// font's GPOS table or, if it has none, its kern table. Kerning is taken into
+// account by GetStringWidth(), SplitLines(), SplitText() and the methods
+// that output text. It is off by default.
+//
+// The SetKerning() example demonstrates this method.
+func (f *Fpdf) SetKerning(kerning bool) {
+	f.kerning = kerning
+}
+
+// GetKerning returns true if kerning is turned on. See SetKerning().
+func (f *Fpdf) GetKerning() bool {
+	return f.kerning
+}
+
+// kern returns the kerning adjustment of the runes a and b in the current
+// font, in thousandths of the font size. It returns zero if kerning is off.
+func (f *Fpdf) kern(a, b rune) int {
+	if !f.kerning || !f.isCurrentUTF8 || f.currentFont.utf8File == nil {
+		return 0
+	}
+	utf := f.currentFont.utf8File
+	if utf.kern == nil {
+		return 0
+	}
+	ga, oka := utf.charSymbolDictionary[int(a)]
+	gb, okb := utf.charSymbolDictionary[int(b)]
+	if !oka || !okb {
+		return 0
+	}
+	return utf.kern.pair(ga, gb)
+}
+
+// kernActive returns true if text in the current font is kerned.
+func (f *Fpdf) kernActive() bool {
+	return f.kerning && f.isCurrentUTF8 && f.currentFont.utf8File != nil && f.currentFont.utf8File.kern != nil
+}
+
+// kernTJ returns the elements of a TJ array that shows the runes of txt
+// with kerning applied. wordSpacing and charSpacing, in thousandths of the
+// font size, are added before each space and between characters to justify
+// the text.
+func (f *Fpdf) kernTJ(txt []rune, wordSpacing, charSpacing float64) string {
+	var s fmtBuffer
+	j := 0
+	for i := 1; i <= len(txt); i++ {
+		var adj float64
+		if i < len(txt) {
+			adj = float64(f.kern(txt[i-1], txt[i])) + charSpacing
+			if txt[i] == ' ' {
+				adj += wordSpacing
+			}
+		}
+		if adj != 0 || i == len(txt) {
+			s.printf("(%s)", f.escape(utf8toutf16(string(txt[j:i]), false)))
+			if adj != 0 {
+				s.printf(" %.3f ", -adj)
+			}
+			j = i
+		}
+	}
+	return s.String()
+}
@@ -0,0 +1,216 @@
+package gofpdf
+
+import (
+	"sort"
+)
+
+// otTable provides bounds-checked access to the big-endian data of an
+// OpenType table. Reads beyond the end of the table return zero so that
+// damaged fonts degrade to missing layout features rather than panics.
+type otTable []byte
+
+func (t otTable) u16(off int) int {
+	if off < 0 || off+2 > len(t) {
+		return 0
+	}
+	return int(t[off])<<8 | int(t[off+1])
+}
+
+func (t otTable) i16(off int) int {
+	return int(int16(t.u16(off)))
+}
+
+func (t otTable) u32(off int) int {
+	return t.u16(off)<<16 | t.u16(off+2)
+}
+
+func (t otTable) tag(off int) string {
+	if off < 0 || off+4 > len(t) {
+		return ""
+	}
+	return string(t[off : off+4])
+}
+
+func (t otTable) sub(off int) otTable {
+	if off <= 0 || off >= len(t) {
+		return nil
+	}
+	return t[off:]
+}
+
+// otCoverage maps the glyphs of a coverage table to their coverage index.
+// The ranges of glyphs of format 2 must be sorted and must not overlap, as
+// required by the specification, so that a malformed table cannot list more
+// than the 65536 possible glyphs.
+func otCoverage(t otTable) map[int]int {
+	cov := make(map[int]int)
+	switch t.u16(0) {
+	case 1:
+		count := t.u16(2)
+		for j := 0; j < count; j++ {
+			cov[t.u16(4+2*j)] = j
+		}
+	case 2:
+		count, next := t.u16(2), 0
+		for j := 0; j < count; j++ {
+			rec := 4 + 6*j
+			start, end, idx := t.u16(rec), t.u16(rec+2), t.u16(rec+4)
+			if start < next {
+				continue
+			}
+			for g := start; g <= end; g++ {
+				cov[g] = idx + g - start
+			}
+			next = end + 1
+		}
+	}
+	return cov
+}
+
+// otClassDef maps glyphs to the classes of a class definition table. Glyphs
+// that are not listed belong to class 0. As in otCoverage(), the ranges of
+// format 2 must be sorted and must not overlap.
+func otClassDef(t otTable) map[int]int {
+	classes := make(map[int]int)
+	switch t.u16(0) {
+	case 1:
+		start, count := t.u16(2), t.u16(4)
+		for j := 0; j < count; j++ {
+			if c := t.u16(6 + 2*j); c != 0 {
+				classes[start+j] = c
+			}
+		}
+	case 2:
+		count, next := t.u16(2), 0
+		for j := 0; j < count; j++ {
+			rec := 4 + 6*j
+			start, end, c := t.u16(rec), t.u16(rec+2), t.u16(rec+4)
+			if start < next {
+				continue
+			}
+			for g := start; g <= end && c != 0; g++ {
+				classes[g] = c
+			}
+			next = end + 1
+		}
+	}
+	return classes
+}
+
+// otFeatureLookups returns the indexes of the lookups of the features with
+// the specified tag in the GSUB or GPOS table t, in lookup list order.
+func otFeatureLookups(t otTable, tagStr string) (list []int) {
+	features := t.sub(t.u16(6))
+	seen := make(map[int]bool)
+	count := features.u16(0)
+	for j := 0; j < count; j++ {
+		rec := 2 + 6*j
+		if features.tag(rec) != tagStr {
+			continue
+		}
+		feature := features.sub(features.u16(rec + 4))
+		lookupCount := feature.u16(2)
+		for k := 0; k < lookupCount; k++ {
+			idx := feature.u16(4 + 2*k)
+			if !seen[idx] {
+				seen[idx] = true
+				list = append(list, idx)
+			}
+		}
+	}
+	// Lookups are applied in the order of the lookup list
+	sort.Ints(list)
+	return
+}
+
+// otLookupSubtables returns the type and the subtables of the lookup with
+// the specified index. Subtables of the extension lookup type extType (7 in
+// GSUB, 9 in GPOS) are resolved to the subtables they refer to.
+func otLookupSubtables(t otTable, idx, extType int) (lookupType int, subtables []otTable) {
+	lookups := t.sub(t.u16(8))
+	if idx >= lookups.u16(0) {
+		return
+	}
+	lookup := lookups.sub(lookups.u16(2 + 2*idx))
+	lookupType = lookup.u16(0)
+	ext := lookupType == extType
+	count := lookup.u16(4)
+	for j := 0; j < count; j++ {
+		st := lookup.sub(lookup.u16(6 + 2*j))
+		if ext {
+			// All subtables of an extension lookup have the same type
+			lookupType = st.u16(2)
+			st = st.sub(st.u32(4))
+		}
+		if st != nil {
+			subtables = append(subtables, st)
+		}
+	}
+	return
+}
+
+// otScriptFeatures returns the lookup indexes of the features of the default
+// language system of a script in the GSUB or GPOS table t, keyed by feature
+// tag and in lookup list order. The first of scriptTags present in the table
+// is used, falling back to the DFLT and latn scripts.
+func otScriptFeatures(t otTable, scriptTags ...string) map[string][]int {
+	scripts := t.sub(t.u16(4))
+	var langSys otTable
+	for _, tagStr := range append(scriptTags, "DFLT", "latn") {
+		count := scripts.u16(0)
+		for j := 0; j < count && langSys == nil; j++ {
+			if scripts.tag(2+6*j) == tagStr {
+				script := scripts.sub(scripts.u16(2 + 6*j + 4))
+				langSys = script.sub(script.u16(0))
+			}
+		}
+		if langSys != nil {
+			break
+		}
+	}
+	features := t.sub(t.u16(6))
+	lookups := make(map[string][]int)
+	if langSys == nil {
+		return lookups
+	}
+	add := func(idx int) {
+		rec := 2 + 6*idx
+		tagStr := features.tag(rec)
+		feature := features.sub(features.u16(rec + 4))
+		count := feature.u16(2)
+		for k := 0; k < count; k++ {
+			lookups[tagStr] = append(lookups[tagStr], feature.u16(4+2*k))
+		}
+	}
+	if req := langSys.u16(2); req != 0xFFFF {
+		add(req)
+	}
+	count := langSys.u16(4)
+	for j := 0; j < count; j++ {
+		add(langSys.u16(6 + 2*j))
+	}
+	for tagStr, list := range lookups {
+		sort.Ints(list)
+		n := 0
+		for j, idx := range list {
+			if j == 0 || idx != list[j-1] {
+				list[n] = idx
+				n++
+			}
+		}
+		lookups[tagStr] = list[:n]
+	}
+	return lookups
+}
+
+// otLookupFlag returns the lookup flag of the lookup with the specified index
+// and, if the flag says so, the index of its mark filtering set.
+func otLookupFlag(t otTable, idx int) (flag, markSet int) {
+	lookups := t.sub(t.u16(8))
+	lookup := lookups.sub(lookups.u16(2 + 2*idx))
+	flag = lookup.u16(2)
+	if flag&0x10 != 0 {
+		markSet = lookup.u16(6 + 2*lookup.u16(4))
+	}
+	return
+}
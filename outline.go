@@ -0,0 +1,647 @@
+package gofpdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// The outlines of glyphs are read from the glyf table of fonts with
+// TrueType outlines, whose contours are made of quadratic curves, and from
+// the Type 2 charstrings of the CFF table of fonts with PostScript outlines,
+// which are made of cubic curves. Both are converted to paths of lines and
+// cubic Bézier curves.
+
+// symbolXY is the flag of a glyph component whose arguments are offsets
+// rather than point numbers
+const symbolXY = 1 << 1
+
+// outlineFontType holds the tables of a font needed for the outlines of its
+// glyphs.
+type outlineFontType struct {
+	glyf, loca otTable
+	longLoca   bool
+	cff        *cffFontType
+	gsubrs     [][]byte   // global subroutines of the CFF table
+	subrs      [][][]byte // local subroutines of each Font DICT
+}
+
+// outlineFont returns the outline tables of the font.
+func (utf *utf8FontFile) outlineFont() (*outlineFontType, error) {
+	if utf.outline != nil {
+		return utf.outline, nil
+	}
+	of := new(outlineFontType)
+	if data := utf.getTableData("CFF "); data != nil {
+		cff, err := parseCFF(data)
+		if err != nil {
+			return nil, err
+		}
+		of.cff = cff
+		if of.gsubrs, _, err = cffIndex(cff.gsubrs, 0); err != nil {
+			return nil, err
+		}
+		for _, fd := range cff.fonts {
+			var subrs [][]byte
+			if fd.subrs != nil {
+				if subrs, _, err = cffIndex(fd.subrs, 0); err != nil {
+					return nil, err
+				}
+			}
+			of.subrs = append(of.subrs, subrs)
+		}
+	} else {
+		of.glyf = otTable(utf.getTableData("glyf"))
+		of.loca = otTable(utf.getTableData("loca"))
+		of.longLoca = otTable(utf.getTableData("head")).u16(50) == 1
+		if of.glyf == nil || of.loca == nil {
+			return nil, fmt.Errorf("font has no glyph outlines")
+		}
+	}
+	utf.outline = of
+	return of, nil
+}
+
+// outlinePenType draws the outline of a glyph into a path, with the
+// coordinates of the glyph in font units transformed by tr.
+type outlinePenType struct {
+	path *PathType
+	tr   func(x, y float64) (float64, float64)
+	x, y float64 // current point in font units
+	open bool    // a contour is open
+}
+
+func (pn *outlinePenType) moveTo(x, y float64) {
+	pn.closePath()
+	pn.path.MoveTo(pn.tr(x, y))
+	pn.x, pn.y, pn.open = x, y, true
+}
+
+func (pn *outlinePenType) lineTo(x, y float64) {
+	pn.path.LineTo(pn.tr(x, y))
+	pn.x, pn.y = x, y
+}
+
+func (pn *outlinePenType) curveTo(cx0, cy0, cx1, cy1, x, y float64) {
+	x0, y0 := pn.tr(cx0, cy0)
+	x1, y1 := pn.tr(cx1, cy1)
+	x2, y2 := pn.tr(x, y)
+	pn.path.CurveBezierCubicTo(x0, y0, x1, y1, x2, y2)
+	pn.x, pn.y = x, y
+}
+
+// quadTo draws a quadratic curve raised to a cubic one.
+func (pn *outlinePenType) quadTo(cx, cy, x, y float64) {
+	pn.curveTo(pn.x+(cx-pn.x)*2/3, pn.y+(cy-pn.y)*2/3, x+(cx-x)*2/3, y+(cy-y)*2/3, x, y)
+}
+
+func (pn *outlinePenType) closePath() {
+	if pn.open {
+		pn.path.ClosePath()
+		pn.open = false
+	}
+}
+
+// glyfPointType is a point of a TrueType contour.
+type glyfPointType struct {
+	x, y float64
+	on   bool // on the curve, rather than a control point
+}
+
+// glyfContours returns the contours of glyph gid of the glyf table, in font
+// units. Components of composite glyphs are nested at most depth levels.
+func (of *outlineFontType) glyfContours(gid, depth int) (contours [][]glyfPointType, err error) {
+	var start, end int
+	if of.longLoca {
+		start, end = of.loca.u32(4*gid), of.loca.u32(4*gid+4)
+	} else {
+		start, end = 2*of.loca.u16(2*gid), 2*of.loca.u16(2*gid+2)
+	}
+	if end <= start {
+		// Glyph without outline, such as a space
+		return nil, nil
+	}
+	if end > len(of.glyf) || end-start < 10 {
+		return nil, fmt.Errorf("invalid outline of glyph %d", gid)
+	}
+	g := of.glyf[start:end]
+	count := g.i16(0)
+	if count < 0 {
+		return of.glyfComposite(g, depth)
+	}
+	endPts := make([]int, count)
+	for j := range endPts {
+		endPts[j] = g.u16(10 + 2*j)
+	}
+	n := 0
+	if count > 0 {
+		n = endPts[count-1] + 1
+	}
+	pos := 10 + 2*count
+	pos += 2 + g.u16(pos)
+	flags := make([]byte, 0, n)
+	for len(flags) < n && pos < len(g) {
+		fl := g[pos]
+		pos++
+		flags = append(flags, fl)
+		if fl&8 != 0 && pos < len(g) {
+			for rep := int(g[pos]); rep > 0 && len(flags) < n; rep-- {
+				flags = append(flags, fl)
+			}
+			pos++
+		}
+	}
+	if len(flags) < n {
+		return nil, fmt.Errorf("invalid outline of glyph %d", gid)
+	}
+	// coords reads the x or y coordinates, whose flags for a short value and
+	// for a positive short or repeated value are short and same
+	coords := func(short, same byte) []float64 {
+		list := make([]float64, n)
+		v := 0
+		for j, fl := range flags {
+			switch {
+			case fl&short != 0:
+				d := 0
+				if pos < len(g) {
+					d = int(g[pos])
+				}
+				pos++
+				if fl&same == 0 {
+					d = -d
+				}
+				v += d
+			case fl&same == 0:
+				v += g.i16(pos)
+				pos += 2
+			}
+			list[j] = float64(v)
+		}
+		return list
+	}
+	xs := coords(2, 16)
+	ys := coords(4, 32)
+	first := 0
+	for _, last := range endPts {
+		if last < first || last >= n {
+			return nil, fmt.Errorf("invalid outline of glyph %d", gid)
+		}
+		var contour []glyfPointType
+		for j := first; j <= last; j++ {
+			contour = append(contour, glyfPointType{xs[j], ys[j], flags[j]&1 != 0})
+		}
+		contours = append(contours, contour)
+		first = last + 1
+	}
+	return
+}
+
+// glyfComposite returns the contours of the components of the composite
+// glyph g.
+func (of *outlineFontType) glyfComposite(g otTable, depth int) (contours [][]glyfPointType, err error) {
+	if depth <= 0 {
+		return nil, fmt.Errorf("glyph components are nested too deeply")
+	}
+	pos := 10
+	for {
+		fl, gid := g.u16(pos), g.u16(pos+2)
+		pos += 4
+		var dx, dy float64
+		if fl&symbolWords != 0 {
+			dx, dy = float64(g.i16(pos)), float64(g.i16(pos+2))
+			pos += 4
+		} else {
+			dx, dy = float64(int8(g.u16(pos)>>8)), float64(int8(g.u16(pos)))
+			pos += 2
+		}
+		if fl&symbolXY == 0 {
+			// Components aligned by matching points are not moved
+			dx, dy = 0, 0
+		}
+		// f2dot14 reads a 2.14 fixed-point number
+		f2dot14 := func(off int) float64 {
+			return float64(g.i16(off)) / 16384
+		}
+		a, b, c, d := 1.0, 0.0, 0.0, 1.0
+		switch {
+		case fl&symbolScale != 0:
+			a = f2dot14(pos)
+			d = a
+			pos += 2
+		case fl&symbolAllScale != 0:
+			a, d = f2dot14(pos), f2dot14(pos+2)
+			pos += 4
+		case fl&symbol2x2 != 0:
+			a, b, c, d = f2dot14(pos), f2dot14(pos+2), f2dot14(pos+4), f2dot14(pos+6)
+			pos += 8
+		}
+		var list [][]glyfPointType
+		list, err = of.glyfContours(gid, depth-1)
+		if err != nil {
+			return nil, err
+		}
+		for _, contour := range list {
+			for j, pt := range contour {
+				contour[j].x, contour[j].y = a*pt.x+c*pt.y+dx, b*pt.x+d*pt.y+dy
+			}
+			contours = append(contours, contour)
+		}
+		if fl&symbolContinue == 0 || pos >= len(g) {
+			return
+		}
+	}
+}
+
+// glyfDraw draws TrueType contours with pn. Consecutive control points imply
+// an on-curve point halfway between them.
+func glyfDraw(contours [][]glyfPointType, pn *outlinePenType) {
+	mid := func(a, b glyfPointType) glyfPointType {
+		return glyfPointType{(a.x + b.x) / 2, (a.y + b.y) / 2, true}
+	}
+	for _, contour := range contours {
+		n := len(contour)
+		if n == 0 {
+			continue
+		}
+		// The contour starts on the curve
+		var start glyfPointType
+		var seq []glyfPointType
+		k := -1
+		for j, pt := range contour {
+			if pt.on {
+				k = j
+				break
+			}
+		}
+		if k < 0 {
+			start = mid(contour[n-1], contour[0])
+			seq = append(seq, contour...)
+		} else {
+			start = contour[k]
+			seq = append(append(seq, contour[k+1:]...), contour[:k]...)
+		}
+		seq = append(seq, start)
+		pn.moveTo(start.x, start.y)
+		var ctrl *glyfPointType
+		for j := range seq {
+			pt := seq[j]
+			switch {
+			case pt.on && ctrl != nil:
+				pn.quadTo(ctrl.x, ctrl.y, pt.x, pt.y)
+				ctrl = nil
+			case pt.on:
+				pn.lineTo(pt.x, pt.y)
+			default:
+				if ctrl != nil {
+					m := mid(*ctrl, pt)
+					pn.quadTo(ctrl.x, ctrl.y, m.x, m.y)
+				}
+				ctrl = &seq[j]
+			}
+		}
+		pn.closePath()
+	}
+}
+
+// cffBias returns the bias of the numbers of the subroutines of an INDEX of
+// count subroutines.
+func cffBias(count int) int {
+	switch {
+	case count < 1240:
+		return 107
+	case count < 33900:
+		return 1131
+	}
+	return 32768
+}
+
+// cffDraw interprets the Type 2 charstring of glyph gid of the CFF table
+// and draws its outline with pn. Hints are skipped.
+func (of *outlineFontType) cffDraw(gid int, pn *outlinePenType) error {
+	cff := of.cff
+	if gid < 0 || gid >= len(cff.charStrings) {
+		return fmt.Errorf("invalid glyph %d", gid)
+	}
+	var local [][]byte
+	if fd := 0; len(of.subrs) > 0 {
+		if cff.fdSelect != nil && gid < len(cff.fdSelect) {
+			fd = cff.fdSelect[gid]
+		}
+		if fd < len(of.subrs) {
+			local = of.subrs[fd]
+		}
+	}
+	var stack []float64
+	var x, y float64
+	stems := 0
+	widthDone := false
+	// width removes the advance width that may precede the arguments of the
+	// first operator that clears the stack, which take an even number of
+	// arguments if even is true
+	width := func(even bool) {
+		if !widthDone && len(stack) > 0 && (len(stack)%2 == 1) == even {
+			stack = stack[1:]
+		}
+		widthDone = true
+	}
+	line := func(dx, dy float64) {
+		x, y = x+dx, y+dy
+		pn.lineTo(x, y)
+	}
+	curve := func(dxa, dya, dxb, dyb, dxc, dyc float64) {
+		x0, y0 := x+dxa, y+dya
+		x1, y1 := x0+dxb, y0+dyb
+		x, y = x1+dxc, y1+dyc
+		pn.curveTo(x0, y0, x1, y1, x, y)
+	}
+	// alternate draws the curves of hvcurveto and vhcurveto
+	alternate := func(horizontal bool) {
+		args := stack
+		for len(args) >= 4 {
+			last := 0.0
+			if len(args) == 5 {
+				last = args[4]
+			}
+			if horizontal {
+				curve(args[0], 0, args[1], args[2], last, args[3])
+			} else {
+				curve(0, args[0], args[1], args[2], args[3], last)
+			}
+			args = args[4:]
+			if len(args) == 1 {
+				args = nil
+			}
+			horizontal = !horizontal
+		}
+	}
+	var run func(code []byte, depth int) (bool, error)
+	run = func(code []byte, depth int) (bool, error) {
+		if depth > 10 {
+			return false, errCFF
+		}
+		for i := 0; i < len(code); {
+			b := code[i]
+			// Operands
+			switch {
+			case b == 28 && i+3 <= len(code):
+				stack = append(stack, float64(int16(binary.BigEndian.Uint16(code[i+1:]))))
+				i += 3
+				continue
+			case b >= 32 && b <= 246:
+				stack = append(stack, float64(int(b)-139))
+				i++
+				continue
+			case b >= 247 && b <= 250 && i+2 <= len(code):
+				stack = append(stack, float64((int(b)-247)*256+int(code[i+1])+108))
+				i += 2
+				continue
+			case b >= 251 && b <= 254 && i+2 <= len(code):
+				stack = append(stack, float64(-(int(b)-251)*256-int(code[i+1])-108))
+				i += 2
+				continue
+			case b == 255 && i+5 <= len(code):
+				stack = append(stack, float64(int32(binary.BigEndian.Uint32(code[i+1:])))/65536)
+				i += 5
+				continue
+			case b >= 28:
+				return false, errCFF
+			}
+			op := int(b)
+			i++
+			if b == 12 {
+				if i >= len(code) {
+					return false, errCFF
+				}
+				op = 1200 + int(code[i])
+				i++
+			}
+			switch op {
+			case 1, 3, 18, 23: // hstem, vstem, hstemhm, vstemhm
+				width(true)
+				stems += len(stack) / 2
+			case 19, 20: // hintmask, cntrmask
+				width(true)
+				stems += len(stack) / 2
+				i += (stems + 7) / 8
+			case 21: // rmoveto
+				width(true)
+				if len(stack) >= 2 {
+					x, y = x+stack[0], y+stack[1]
+					pn.moveTo(x, y)
+				}
+			case 22: // hmoveto
+				width(false)
+				if len(stack) >= 1 {
+					x += stack[0]
+					pn.moveTo(x, y)
+				}
+			case 4: // vmoveto
+				width(false)
+				if len(stack) >= 1 {
+					y += stack[0]
+					pn.moveTo(x, y)
+				}
+			case 5: // rlineto
+				for j := 0; j+2 <= len(stack); j += 2 {
+					line(stack[j], stack[j+1])
+				}
+			case 6, 7: // hlineto, vlineto
+				horizontal := op == 6
+				for _, d := range stack {
+					if horizontal {
+						line(d, 0)
+					} else {
+						line(0, d)
+					}
+					horizontal = !horizontal
+				}
+			case 8: // rrcurveto
+				for j := 0; j+6 <= len(stack); j += 6 {
+					curve(stack[j], stack[j+1], stack[j+2], stack[j+3], stack[j+4], stack[j+5])
+				}
+			case 24: // rcurveline
+				j := 0
+				for ; j+8 <= len(stack); j += 6 {
+					curve(stack[j], stack[j+1], stack[j+2], stack[j+3], stack[j+4], stack[j+5])
+				}
+				if j+2 <= len(stack) {
+					line(stack[j], stack[j+1])
+				}
+			case 25: // rlinecurve
+				j := 0
+				for ; j+8 <= len(stack); j += 2 {
+					line(stack[j], stack[j+1])
+				}
+				if j+6 <= len(stack) {
+					curve(stack[j], stack[j+1], stack[j+2], stack[j+3], stack[j+4], stack[j+5])
+				}
+			case 26, 27: // vvcurveto, hhcurveto
+				args := stack
+				d1 := 0.0
+				if len(args)%2 == 1 {
+					d1, args = args[0], args[1:]
+				}
+				for ; len(args) >= 4; args = args[4:] {
+					if op == 26 {
+						curve(d1, args[0], args[1], args[2], 0, args[3])
+					} else {
+						curve(args[0], d1, args[1], args[2], args[3], 0)
+					}
+					d1 = 0
+				}
+			case 30, 31: // vhcurveto, hvcurveto
+				alternate(op == 31)
+			case 10, 29: // callsubr, callgsubr
+				subrs := local
+				if op == 29 {
+					subrs = of.gsubrs
+				}
+				if len(stack) == 0 {
+					return false, errCFF
+				}
+				idx := int(stack[len(stack)-1]) + cffBias(len(subrs))
+				stack = stack[:len(stack)-1]
+				if idx < 0 || idx >= len(subrs) {
+					return false, errCFF
+				}
+				end, err := run(subrs[idx], depth+1)
+				if end || err != nil {
+					return end, err
+				}
+				continue
+			case 11: // return
+				return false, nil
+			case 14: // endchar
+				width(true)
+				pn.closePath()
+				return true, nil
+			case 1234: // hflex
+				if len(stack) >= 7 {
+					s := stack
+					curve(s[0], 0, s[1], s[2], s[3], 0)
+					curve(s[4], 0, s[5], -s[2], s[6], 0)
+				}
+			case 1235: // flex
+				if len(stack) >= 12 {
+					s := stack
+					curve(s[0], s[1], s[2], s[3], s[4], s[5])
+					curve(s[6], s[7], s[8], s[9], s[10], s[11])
+				}
+			case 1236: // hflex1
+				if len(stack) >= 9 {
+					s := stack
+					curve(s[0], s[1], s[2], s[3], s[4], 0)
+					curve(s[5], 0, s[6], s[7], s[8], -(s[1] + s[3] + s[7]))
+				}
+			case 1237: // flex1
+				if len(stack) >= 11 {
+					s := stack
+					dx := s[0] + s[2] + s[4] + s[6] + s[8]
+					dy := s[1] + s[3] + s[5] + s[7] + s[9]
+					dx6, dy6 := s[10], -dy
+					if math.Abs(dy) > math.Abs(dx) {
+						dx6, dy6 = -dx, s[10]
+					}
+					curve(s[0], s[1], s[2], s[3], s[4], s[5])
+					curve(s[6], s[7], s[8], s[9], dx6, dy6)
+				}
+			}
+			// Operators clear the stack; the arithmetic operators, which are
+			// rare in fonts, are not supported
+			stack = stack[:0]
+		}
+		return false, nil
+	}
+	_, err := run(cff.charStrings[gid], 0)
+	pn.closePath()
+	return err
+}
+
+// TextOutline returns the outlines of the glyphs of txtStr as a path, so that
+// the text can be filled or stroked with PathType.DrawPath() or used as a
+// clipping path with PathType.ClipPath(), without the font being embedded
+// for it. The origin (x, y) is on the left of the first character at the
+// baseline, as for Text(). The current font must be a UTF-8 font added with
+// AddUTF8Font() or AddUTF8FontFromBytes(); both TrueType and CFF outlines
+// are supported. The text is laid out as by Text(), with the current font
+// size, kerning, shaping, character spacing, horizontal scaling and text
+// rise. Characters shown in fallback fonts (see SetFontFallback()) are
+// outlined with the current font.
+//
+// The glyph contours follow the nonzero winding number rule, which is the
+// rule used by DrawPath() with "F", "D" or "FD" and by ClipPath().
+//
+// The TextOutline() example demonstrates this method.
+func (f *Fpdf) TextOutline(x, y float64, txtStr string) (p *PathType) {
+	p = f.PathNew()
+	if f.err != nil {
+		return
+	}
+	if !f.isCurrentUTF8 || f.currentFont.utf8File == nil {
+		f.SetErrorf("text outlines require a UTF-8 font")
+		return
+	}
+	utf := f.currentFont.utf8File
+	of, err := utf.outlineFont()
+	if err != nil {
+		f.SetErrorf("cannot read outlines of font %s: %s", f.currentFont.Name, err)
+		return
+	}
+	if f.isRTL {
+		x -= f.GetStringWidth(txtStr)
+	}
+	cs := float64(f.charSpacingUnits())
+	// Glyph IDs with their origins in thousandths of the font size
+	var gids []int
+	var xs, ys []float64
+	if f.shapeActive() {
+		glyphs, gx, gy := f.shapeLine(f.bidiRuns(txtStr), 0, cs)
+		for _, g := range glyphs {
+			gids = append(gids, g.gid)
+		}
+		xs, ys = gx, gy
+	} else {
+		txt := []rune(f.bidiVisual(txtStr))
+		cw := f.currentFont.Cw
+		var pen float64
+		for j, r := range txt {
+			if j > 0 {
+				pen += float64(f.kern(txt[j-1], r)) + cs
+			}
+			gids = append(gids, utf.charSymbolDictionary[int(r)])
+			xs, ys = append(xs, pen), append(ys, 0)
+			switch {
+			case fontHasRune(f.currentFont, r):
+				if cw[r] != 65535 {
+					pen += float64(cw[r])
+				}
+			case f.currentFont.Desc.MissingWidth != 0:
+				pen += float64(f.currentFont.Desc.MissingWidth)
+			default:
+				pen += 500
+			}
+		}
+	}
+	// Font units and thousandths of the font size to user units
+	unit := f.fontSize / float64(utf.fontElementSize)
+	hs := f.hScaling / 100
+	for j, gid := range gids {
+		ox := x + xs[j]*f.fontSize/1000*hs
+		oy := y - f.textRise - ys[j]*f.fontSize/1000
+		pn := &outlinePenType{path: p, tr: func(gx, gy float64) (float64, float64) {
+			return ox + gx*unit*hs, oy - gy*unit
+		}}
+		if of.cff != nil {
+			err = of.cffDraw(gid, pn)
+		} else {
+			var contours [][]glyfPointType
+			contours, err = of.glyfContours(gid, 8)
+			glyfDraw(contours, pn)
+		}
+		if err != nil {
+			f.SetErrorf("cannot read outline of glyph %d of font %s: %s", gid, f.currentFont.Name, err)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,365 @@
+package gofpdf
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SpanStyleType describes the appearance of a span of text in a paragraph.
+// See ParagraphNew().
+type SpanStyleType struct {
+	Family string   // font family, or empty for the font family that is current when the paragraph is output
+	Style  string   // font style, as for SetFont(): a combination of "B", "I", "U" and "S"
+	Size   float64  // font size in points, or 0 for the font size that is current when the paragraph is output
+	Color  *RGBType // text color, or nil for the text color that is current when the paragraph is output
+	Rise   float64  // distance by which the text is raised, in the unit of measure specified in New(); see SetTextRise()
+	Link   string   // URL that the span links to, or empty
+}
+
+type paraSpanType struct {
+	text  string
+	style SpanStyleType
+}
+
+// ParagraphType is used to lay out a paragraph made of spans of text in
+// different fonts, sizes and colors. Lines are broken at spaces regardless
+// of the spans, so a word can change style without being split.
+type ParagraphType struct {
+	pdf      *Fpdf
+	spans    []paraSpanType
+	alignStr string
+	lineHt   float64
+}
+
+// ParagraphNew returns an empty paragraph that is output in the specified
+// PDF file. Spans are added with AddSpan(). The paragraph is left aligned and
+// its lines are 1.2 times as high as their largest font.
+func (f *Fpdf) ParagraphNew() *ParagraphType {
+	return &ParagraphType{pdf: f, alignStr: "L", lineHt: 1.2}
+}
+
+// AddSpan appends text in the specified style to the paragraph. A newline
+// character in text breaks the line.
+func (p *ParagraphType) AddSpan(text string, style SpanStyleType) {
+	p.spans = append(p.spans, paraSpanType{text: strings.Replace(text, "\r", "", -1), style: style})
+}
+
+// SetAlign sets the alignment of the lines of the paragraph: "L" for left,
+// "C" for center, "R" for right, and "J" to justify all lines but the last
+// line and lines that end with a newline.
+func (p *ParagraphType) SetAlign(alignStr string) {
+	p.alignStr = alignStr
+}
+
+// SetLineHeight sets the height of the lines of the paragraph as a multiple
+// of the largest font size used on each line. The default is 1.2.
+func (p *ParagraphType) SetLineHeight(factor float64) {
+	p.lineHt = factor
+}
+
+// paraPieceType is a word, a run of spaces or a line break in a single span.
+type paraPieceType struct {
+	text  string
+	span  int
+	w     float64 // width in user units
+	space bool
+	size  float64 // font size in user units
+	asc   float64 // ascent in user units
+	desc  float64 // descent in user units
+}
+
+type paraLineType struct {
+	pieces    []paraPieceType
+	w         float64 // width of the pieces
+	spaces    int     // number of spaces
+	size      float64 // largest font size
+	asc, desc float64 // largest ascent and descent
+	last      bool    // last line of the paragraph or line ended by a newline
+}
+
+// height returns the height of the line.
+func (l paraLineType) height(factor float64) float64 {
+	return l.size * factor
+}
+
+// paraStateType holds the values changed while a paragraph is laid out.
+type paraStateType struct {
+	family, style string
+	sizePt        float64
+	textColor     colorType
+	colorFlag     bool
+	rise          float64
+}
+
+// saveParaState returns the values that are changed while a paragraph or a
+// table is laid out.
+func (f *Fpdf) saveParaState() (st paraStateType) {
+	st.family, st.style, st.sizePt = f.fontFamily, f.fontStyle, f.fontSizePt
+	if f.underline {
+		st.style += "U"
+	}
+	if f.strikeout {
+		st.style += "S"
+	}
+	st.textColor, st.colorFlag = f.color.text, f.colorFlag
+	st.rise = f.textRise
+	return
+}
+
+// restoreParaState restores the values saved by saveParaState().
+func (f *Fpdf) restoreParaState(st paraStateType) {
+	if st.family != "" {
+		f.SetFont(st.family, st.style, st.sizePt)
+	}
+	f.color.text, f.colorFlag = st.textColor, st.colorFlag
+	if f.textRise != st.rise {
+		f.SetTextRise(st.rise)
+	}
+}
+
+// setFont selects the font of span n. Fonts default to those of st.
+func (p *ParagraphType) setFont(n int, st paraStateType) {
+	style := p.spans[n].style
+	family, size := style.Family, style.Size
+	if family == "" {
+		family = st.family
+	}
+	if size == 0 {
+		size = st.sizePt
+	}
+	p.pdf.SetFont(family, style.Style, size)
+}
+
+// pieces splits the spans into words, spaces and line breaks and measures
+// them.
+func (p *ParagraphType) pieces(st paraStateType) (list []paraPieceType) {
+	f := p.pdf
+	for n, span := range p.spans {
+		p.setFont(n, st)
+		if f.err != nil {
+			return nil
+		}
+		size := f.fontSize
+		asc, desc := 0.81*size, 0.19*size
+		if d := f.currentFont.Desc; d.Descent != 0 && d.Ascent != d.Descent {
+			asc = float64(d.Ascent) * size / float64(d.Ascent-d.Descent)
+			desc = size - asc
+		}
+		txt := span.text
+		for len(txt) > 0 {
+			piece := paraPieceType{span: n, size: size, asc: asc, desc: desc}
+			switch txt[0] {
+			case '\n':
+				piece.text = "\n"
+			case ' ':
+				piece.text = txt[:len(txt)-len(strings.TrimLeft(txt, " "))]
+				piece.space = true
+			default:
+				end := strings.IndexAny(txt, " \n")
+				if end < 0 {
+					end = len(txt)
+				}
+				piece.text = txt[:end]
+			}
+			txt = txt[len(piece.text):]
+			if piece.text != "\n" {
+				piece.w = f.GetStringWidth(piece.text)
+			}
+			list = append(list, piece)
+		}
+	}
+	return
+}
+
+// layout breaks the paragraph into lines of width w.
+func (p *ParagraphType) layout(w float64, st paraStateType) (lines []paraLineType) {
+	f := p.pdf
+	pieces := p.pieces(st)
+	var line paraLineType
+	var pending []paraPieceType // spaces that precede the next word
+	add := func(piece paraPieceType) {
+		line.pieces = append(line.pieces, piece)
+		line.w += piece.w
+		if piece.space {
+			line.spaces += utf8.RuneCountInString(piece.text)
+		}
+		if piece.size > line.size {
+			line.size = piece.size
+		}
+		if piece.asc > line.asc {
+			line.asc = piece.asc
+		}
+		if piece.desc > line.desc {
+			line.desc = piece.desc
+		}
+	}
+	flush := func(last bool, end paraPieceType) {
+		if len(line.pieces) == 0 {
+			line.size, line.asc, line.desc = end.size, end.asc, end.desc
+		}
+		line.last = last
+		lines = append(lines, line)
+		line = paraLineType{}
+		pending = nil
+	}
+	for j := 0; j < len(pieces); {
+		piece := pieces[j]
+		if piece.text == "\n" {
+			flush(true, piece)
+			j++
+			continue
+		}
+		if piece.space {
+			if len(line.pieces) > 0 {
+				pending = append(pending, piece)
+			}
+			j++
+			continue
+		}
+		// A word is made of the pieces up to the next space or line break
+		k := j
+		var wordW float64
+		for k < len(pieces) && !pieces[k].space && pieces[k].text != "\n" {
+			wordW += pieces[k].w
+			k++
+		}
+		var spaceW float64
+		for _, sp := range pending {
+			spaceW += sp.w
+		}
+		if len(line.pieces) > 0 && line.w+spaceW+wordW > w {
+			flush(false, piece)
+		}
+		for _, sp := range pending {
+			add(sp)
+		}
+		pending = nil
+		if wordW <= w || len(line.pieces) > 0 {
+			for _, wp := range pieces[j:k] {
+				add(wp)
+			}
+			j = k
+			continue
+		}
+		// The word is wider than a line: break it between characters
+		for _, wp := range pieces[j:k] {
+			p.setFont(wp.span, st)
+			part := func(from, to int) paraPieceType {
+				pc := wp
+				pc.text = wp.text[from:to]
+				pc.w = f.GetStringWidth(pc.text)
+				return pc
+			}
+			from := 0
+			for pos, r := range wp.text {
+				next := pos + utf8.RuneLen(r)
+				if line.w+f.GetStringWidth(wp.text[from:next]) > w && (len(line.pieces) > 0 || pos > from) {
+					if pos > from {
+						add(part(from, pos))
+					}
+					flush(false, wp)
+					from = pos
+				}
+			}
+			add(part(from, len(wp.text)))
+		}
+		j = k
+	}
+	if len(line.pieces) > 0 {
+		flush(true, pieces[len(pieces)-1])
+	}
+	return
+}
+
+// Height returns the height of the paragraph laid out in width w, without
+// outputting it.
+func (p *ParagraphType) Height(w float64) (h float64) {
+	f := p.pdf
+	if f.err != nil {
+		return
+	}
+	st := f.saveParaState()
+	defer f.restoreParaState(st)
+	for _, line := range p.layout(w, st) {
+		h += line.height(p.lineHt)
+	}
+	return
+}
+
+// Render outputs the paragraph in a box of width w whose upper left corner is
+// at (x, y). If w is 0, the box reaches to the right margin. A page break
+// occurs when a line does not fit on the page and AcceptPageBreak() allows
+// it; the paragraph then continues below the page header of the new page.
+//
+// The height of the paragraph is returned; if it is continued on new pages,
+// the heights of its parts on all pages are summed. The current position is
+// moved to the start of the line that follows the paragraph. The font, text
+// color and text rise that are current before Render() is called are
+// restored.
+func (p *ParagraphType) Render(x, y, w float64) (h float64) {
+	f := p.pdf
+	if f.err != nil {
+		return
+	}
+	if w == 0 {
+		w = f.w - f.rMargin - x
+	}
+	if f.tagAuto(len(p.spans) == 0) {
+		defer f.tagAutoEnd(0)
+	}
+	st := f.saveParaState()
+	defer f.restoreParaState(st)
+	lines := p.layout(w, st)
+	for _, line := range lines {
+		lineHt := line.height(p.lineHt)
+		if y+lineHt > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptPageBreak() {
+			f.AddPageFormat(f.curOrientation, f.curPageSize)
+			if f.err != nil {
+				return
+			}
+			y = f.y
+		}
+		extra := w - line.w
+		var spaceAdd float64
+		dx := 0.0
+		switch {
+		case strings.Contains(p.alignStr, "R"):
+			dx = extra
+		case strings.Contains(p.alignStr, "C"):
+			dx = extra / 2
+		case strings.Contains(p.alignStr, "J"):
+			if !line.last && line.spaces > 0 && extra > 0 {
+				spaceAdd = extra / float64(line.spaces)
+			}
+		}
+		baseline := y + (lineHt-line.asc-line.desc)/2 + line.asc
+		px := x + dx
+		for _, piece := range line.pieces {
+			style := p.spans[piece.span].style
+			pw := piece.w
+			if piece.space {
+				pw += spaceAdd * float64(utf8.RuneCountInString(piece.text))
+			}
+			if !piece.space || strings.ContainsAny(style.Style, "USus") {
+				p.setFont(piece.span, st)
+				if style.Color != nil {
+					f.SetTextColor(style.Color.R, style.Color.G, style.Color.B)
+				} else {
+					f.color.text, f.colorFlag = st.textColor, st.colorFlag
+				}
+				if rise := st.rise + style.Rise; f.textRise != rise {
+					f.SetTextRise(rise)
+				}
+				f.Text(px, baseline, piece.text)
+			}
+			if style.Link != "" {
+				f.LinkString(px, y, pw, lineHt, style.Link)
+			}
+			px += pw
+		}
+		y += lineHt
+		h += lineHt
+	}
+	f.x, f.y = x, y
+	return
+}
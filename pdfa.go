@@ -0,0 +1,330 @@
+package gofpdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"unicode/utf16"
+)
+
+// Conformance levels for SetConformance() and InitType.ConformanceStr
+const (
+	// ConformancePDFA2B is PDF/A-2b (ISO 19005-2, level B)
+	ConformancePDFA2B = "PDF/A-2b"
+	// ConformancePDFA3B is PDF/A-3b (ISO 19005-3, level B)
+	ConformancePDFA3B = "PDF/A-3b"
+)
+
+type pdfaRecType struct {
+	part     int    // 2 or 3; 0 if no conformance is required
+	levelStr string // conformance level, for example "PDF/A-2b"
+	nICC     int    // object number of the output intent ICC profile
+}
+
+// SetConformance requests that the document conforms to the specified
+// archival standard. conformanceStr is ConformancePDFA2B, ConformancePDFA3B or
+// an empty string to turn conformance off. It is best to call this method
+// immediately after the Fpdf instance is created. InitType.ConformanceStr
+// can be used to the same effect with NewCustom().
+//
+// In conformance mode all fonts must be embedded, so core fonts cannot be
+// used; an sRGB output intent is included; XMP metadata that matches the
+// values set with SetTitle(), SetAuthor() and related methods is generated
+// automatically, replacing any metadata set with SetXmpMetadata(); and
+// encryption, JavaScript, spot colors and CMYK images are not permitted.
+// Attachments are only permitted by PDF/A-3; their relationship to the
+// document is recorded from Attachment.Relationship. Violations are reported
+// with the error state of the instance, see Error().
+//
+// The SetConformance() example demonstrates this method.
+func (f *Fpdf) SetConformance(conformanceStr string) {
+	if f.err != nil {
+		return
+	}
+	switch conformanceStr {
+	case "":
+		f.pdfa = pdfaRecType{}
+	case ConformancePDFA2B:
+		f.pdfa = pdfaRecType{part: 2, levelStr: conformanceStr}
+	case ConformancePDFA3B:
+		f.pdfa = pdfaRecType{part: 3, levelStr: conformanceStr}
+	default:
+		f.err = fmt.Errorf("unsupported conformance level %s", conformanceStr)
+		return
+	}
+	f.pdfaCheckProtection()
+	f.pdfaCheckJavascript()
+}
+
+func (f *Fpdf) pdfaCheckProtection() {
+	if f.pdfa.part > 0 && f.protect.encrypted && f.err == nil {
+		f.err = fmt.Errorf("%s does not permit encryption", f.pdfa.levelStr)
+	}
+}
+
+func (f *Fpdf) pdfaCheckJavascript() {
+	if f.pdfa.part > 0 && f.javascript != nil && f.err == nil {
+		f.err = fmt.Errorf("%s does not permit JavaScript", f.pdfa.levelStr)
+	}
+}
+
+// pdfaCheck verifies the parts of the document that are not checked when
+// they are added.
+func (f *Fpdf) pdfaCheck() {
+	if f.pdfa.part == 0 {
+		return
+	}
+	f.pdfaCheckProtection()
+	f.pdfaCheckJavascript()
+	if f.err != nil {
+		return
+	}
+	var keyList []string
+	for key := range f.fonts {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+	for _, key := range keyList {
+		font := f.fonts[key]
+		if font.Tp == "Core" || (font.Tp != "UTF8" && font.File == "") {
+			f.err = fmt.Errorf("%s requires embedded fonts; font %s is not embedded", f.pdfa.levelStr, font.Name)
+			return
+		}
+	}
+	for _, img := range f.images {
+		if img.cs == "DeviceCMYK" {
+			f.err = fmt.Errorf("%s does not permit CMYK images with an sRGB output intent", f.pdfa.levelStr)
+			return
+		}
+	}
+	if len(f.spotColorMap) > 0 {
+		f.err = fmt.Errorf("%s does not permit spot colors with an sRGB output intent", f.pdfa.levelStr)
+		return
+	}
+	for _, l := range f.pageAttachments {
+		if len(l) > 0 {
+			f.err = fmt.Errorf("attachment annotations are not supported with %s", f.pdfa.levelStr)
+			return
+		}
+	}
+	if len(f.attachments) > 0 && f.pdfa.part < 3 {
+		f.err = fmt.Errorf("%s does not permit attachments; use %s", f.pdfa.levelStr, ConformancePDFA3B)
+		return
+	}
+	// Creation and modification dates must be identical in the document
+	// information dictionary and the metadata
+	f.creationDate = timeOrNow(f.creationDate)
+	if f.modDate.IsZero() {
+		f.modDate = f.creationDate
+	}
+	f.xmp = f.pdfaXmp()
+}
+
+// pdfaText returns the UTF-8 representation of a document information
+// string, which is either UTF-16 with byte order mark or ISO-8859-1.
+func pdfaText(s string) string {
+	b := []byte(s)
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		u := make([]uint16, 0, len(b)/2)
+		for j := 2; j+1 < len(b); j += 2 {
+			u = append(u, uint16(b[j])<<8|uint16(b[j+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	r := make([]rune, len(b))
+	for j, c := range b {
+		r[j] = rune(c)
+	}
+	return string(r)
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// pdfaXmp returns XMP metadata that identifies the conformance level and
+// mirrors the document information dictionary.
+func (f *Fpdf) pdfaXmp() []byte {
+	var s fmtBuffer
+	dateFmtStr := "2006-01-02T15:04:05-07:00"
+	s.printf("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	s.printf("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	s.printf("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	s.printf("<rdf:Description rdf:about=\"\"\n")
+	s.printf(" xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n")
+	s.printf(" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\"\n")
+	s.printf(" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"\n")
+	s.printf(" xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\">\n")
+	s.printf("<pdfaid:part>%d</pdfaid:part>\n", f.pdfa.part)
+	s.printf("<pdfaid:conformance>B</pdfaid:conformance>\n")
+	if len(f.title) > 0 {
+		s.printf("<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n",
+			xmlEscape(pdfaText(f.title)))
+	}
+	if len(f.author) > 0 {
+		s.printf("<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n",
+			xmlEscape(pdfaText(f.author)))
+	}
+	if len(f.subject) > 0 {
+		s.printf("<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n",
+			xmlEscape(pdfaText(f.subject)))
+	}
+	if len(f.keywords) > 0 {
+		s.printf("<pdf:Keywords>%s</pdf:Keywords>\n", xmlEscape(pdfaText(f.keywords)))
+	}
+	if len(f.producer) > 0 {
+		s.printf("<pdf:Producer>%s</pdf:Producer>\n", xmlEscape(pdfaText(f.producer)))
+	}
+	if len(f.creator) > 0 {
+		s.printf("<xmp:CreatorTool>%s</xmp:CreatorTool>\n", xmlEscape(pdfaText(f.creator)))
+	}
+	s.printf("<xmp:CreateDate>%s</xmp:CreateDate>\n", f.creationDate.Format(dateFmtStr))
+	s.printf("<xmp:ModifyDate>%s</xmp:ModifyDate>\n", f.modDate.Format(dateFmtStr))
+	s.printf("</rdf:Description>\n")
+	s.printf("</rdf:RDF>\n")
+	s.printf("</x:xmpmeta>\n")
+	s.printf("<?xpacket end=\"w\"?>")
+	return s.Bytes()
+}
+
+// pdfaPutOutputIntent writes the ICC profile of the output intent.
+func (f *Fpdf) pdfaPutOutputIntent() {
+	if f.pdfa.part == 0 {
+		return
+	}
+	profile := sliceCompress(iccSRGB())
+	f.newobj()
+	f.pdfa.nICC = f.n
+	f.outf("<</N 3 /Filter /FlateDecode /Length %d>>", f.protect.cipherLen(len(profile)))
+	f.putstream(profile)
+	f.out("endobj")
+}
+
+func (f *Fpdf) pdfaPutCatalog() {
+	if f.pdfa.part == 0 {
+		return
+	}
+	f.outf("/OutputIntents [<</Type /OutputIntent /S /GTS_PDFA1 /OutputConditionIdentifier %s "+
+		"/RegistryName %s /Info %s /DestOutputProfile %d 0 R>>]",
+		f.textstring("sRGB IEC61966-2.1"), f.textstring("http://www.color.org"),
+		f.textstring("sRGB IEC61966-2.1"), f.pdfa.nICC)
+	if f.pdfa.part == 3 && len(f.attachments) > 0 {
+		var af fmtBuffer
+		for _, a := range f.attachments {
+			af.printf("%d 0 R ", a.objectNumber)
+		}
+		f.outf("/AF [%s]", af.String())
+	}
+}
+
+// iccSRGB returns an ICC version 2 display profile of the sRGB color space.
+func iccSRGB() []byte {
+	fixed := func(v float64) uint32 {
+		return uint32(int32(math.Round(v * 65536)))
+	}
+	xyz := func(x, y, z float64) []byte {
+		b := make([]byte, 20)
+		copy(b, "XYZ ")
+		binary.BigEndian.PutUint32(b[8:], fixed(x))
+		binary.BigEndian.PutUint32(b[12:], fixed(y))
+		binary.BigEndian.PutUint32(b[16:], fixed(z))
+		return b
+	}
+	const desc = "sRGB IEC61966-2.1"
+	descTag := make([]byte, 12+len(desc)+1+12+67)
+	copy(descTag, "desc")
+	binary.BigEndian.PutUint32(descTag[8:], uint32(len(desc)+1))
+	copy(descTag[12:], desc)
+	cprtTag := append([]byte("text\x00\x00\x00\x00"), "No copyright, use freely\x00"...)
+	const curveSize = 1024
+	curv := make([]byte, 12+2*curveSize)
+	copy(curv, "curv")
+	binary.BigEndian.PutUint32(curv[8:], curveSize)
+	for j := 0; j < curveSize; j++ {
+		v := float64(j) / (curveSize - 1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		binary.BigEndian.PutUint16(curv[12+2*j:], uint16(math.Round(v*65535)))
+	}
+	// Colorants and white point are adapted to the D50 illuminant of the
+	// profile connection space
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", descTag},
+		{"cprt", cprtTag},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4360657, 0.2224884, 0.0139160)},
+		{"gXYZ", xyz(0.3851471, 0.7168732, 0.0970764)},
+		{"bXYZ", xyz(0.1430664, 0.0606079, 0.7140961)},
+		{"rTRC", curv},
+		{"gTRC", curv},
+		{"bTRC", curv},
+	}
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntrRGB XYZ ")
+	for j, v := range []uint16{2000, 1, 1} {
+		binary.BigEndian.PutUint16(header[24+2*j:], v)
+	}
+	copy(header[36:], "acsp")
+	binary.BigEndian.PutUint32(header[68:], fixed(0.9642))
+	binary.BigEndian.PutUint32(header[72:], fixed(1.0))
+	binary.BigEndian.PutUint32(header[76:], fixed(0.8249))
+	table := make([]byte, 4+12*len(tags))
+	binary.BigEndian.PutUint32(table, uint32(len(tags)))
+	var data []byte
+	offset := len(header) + len(table)
+	dataOffsets := make(map[string]int)
+	for j, tag := range tags {
+		key := string(tag.data)
+		pos, ok := dataOffsets[key]
+		if !ok {
+			pos = offset + len(data)
+			dataOffsets[key] = pos
+			data = append(data, tag.data...)
+			for len(data)%4 != 0 {
+				data = append(data, 0)
+			}
+		}
+		copy(table[4+12*j:], tag.sig)
+		binary.BigEndian.PutUint32(table[8+12*j:], uint32(pos))
+		binary.BigEndian.PutUint32(table[12+12*j:], uint32(len(tag.data)))
+	}
+	profile := append(append(header, table...), data...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+// pdfaDateStr formats t as a date of the document information dictionary.
+// Conforming documents include the time zone so that the dates are identical
+// to those of the metadata.
+func (f *Fpdf) pdfaDateStr(t time.Time) string {
+	if f.pdfa.part > 0 {
+		return pdfDateStr(t)
+	}
+	return t.Format("20060102150405")
+}
+
+// pdfDateStr formats t as a PDF date with the offset of its time zone, such
+// as 20060102150405+05'30'. The layouts of package time have no zone offset
+// with a quote between hours and minutes, so the colon of -07:00 is replaced.
+func pdfDateStr(t time.Time) string {
+	s := t.Format("20060102150405-07:00")
+	return s[:len(s)-3] + "'" + s[len(s)-2:] + "'"
+}
+
+// pdfaModTime returns the modification date of embedded files.
+func (f *Fpdf) pdfaModTime() time.Time {
+	return timeOrNow(f.modDate)
+}
@@ -20,9 +20,15 @@
 package gofpdf
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/md5"
+	crand "crypto/rand"
 	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/binary"
+	"hash"
 	"math/rand"
 )
 
@@ -34,24 +40,65 @@ const (
 	CnProtectAnnotForms = 32
 )
 
+// Encryption algorithms used by SetProtectionExt()
+const (
+	// EncryptionRC4 is 40-bit RC4 encryption (revision 2), the algorithm
+	// used by SetProtection()
+	EncryptionRC4 = iota
+	// EncryptionAES128 is 128-bit AES encryption (AESV2, revision 4)
+	EncryptionAES128
+	// EncryptionAES256 is 256-bit AES encryption (AESV3, revision 6) as
+	// defined by PDF 2.0
+	EncryptionAES256
+)
+
 type protectType struct {
 	encrypted     bool
+	algorithm     int // EncryptionRC4, EncryptionAES128 or EncryptionAES256
 	uValue        []byte
 	oValue        []byte
+	ueValue       []byte // revision 6 only
+	oeValue       []byte // revision 6 only
+	permsValue    []byte // revision 6 only
 	pValue        int
 	padding       []byte
 	encryptionKey []byte
+	id            []byte // first element of the document identifier
 	objNum        int
-	rc4cipher     *rc4.Cipher
-	rc4n          uint32 // Object number associated with rc4 cipher
 }
 
-func (p *protectType) rc4(n uint32, buf *[]byte) {
-	if p.rc4cipher == nil || p.rc4n != n {
-		p.rc4cipher, _ = rc4.NewCipher(p.objectKey(n))
-		p.rc4n = n
+// encrypt returns buf encrypted for the object with number n. buf itself may
+// be modified.
+func (p *protectType) encrypt(n uint32, buf []byte) []byte {
+	if p.algorithm == EncryptionRC4 {
+		c, _ := rc4.NewCipher(p.objectKey(n))
+		c.XORKeyStream(buf, buf)
+		return buf
+	}
+	key := p.encryptionKey
+	if p.algorithm == EncryptionAES128 {
+		key = p.objectKey(n)
+	}
+	block, _ := aes.NewCipher(key)
+	// Random initialization vector followed by the data padded as described
+	// in RFC 8018
+	pad := aes.BlockSize - len(buf)%aes.BlockSize
+	res := make([]byte, aes.BlockSize+len(buf)+pad)
+	crand.Read(res[:aes.BlockSize])
+	copy(res[aes.BlockSize:], buf)
+	for j := len(res) - pad; j < len(res); j++ {
+		res[j] = byte(pad)
 	}
-	p.rc4cipher.XORKeyStream(*buf, *buf)
+	cipher.NewCBCEncrypter(block, res[:aes.BlockSize]).CryptBlocks(res[aes.BlockSize:], res[aes.BlockSize:])
+	return res
+}
+
+// cipherLen returns the length of data of length n after encryption.
+func (p *protectType) cipherLen(n int) int {
+	if !p.encrypted || p.algorithm == EncryptionRC4 {
+		return n
+	}
+	return aes.BlockSize + (n/aes.BlockSize+1)*aes.BlockSize
 }
 
 func (p *protectType) objectKey(n uint32) []byte {
@@ -60,8 +107,15 @@ func (p *protectType) objectKey(n uint32) []byte {
 	binary.LittleEndian.PutUint32(nbuf, n)
 	b = append(b, p.encryptionKey...)
 	b = append(b, nbuf[0], nbuf[1], nbuf[2], 0, 0)
+	if p.algorithm == EncryptionAES128 {
+		b = append(b, "sAlT"...)
+	}
 	s := md5.Sum(b)
-	return s[0:10]
+	size := len(p.encryptionKey) + 5
+	if size > 16 {
+		size = 16
+	}
+	return s[0:size]
 }
 
 func oValueGen(userPass, ownerPass []byte) (v []byte) {
@@ -83,7 +137,7 @@ func (p *protectType) uValueGen() (v []byte) {
 	return
 }
 
-func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr string) {
+func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr string, algorithm int) {
 	privFlag = 192 | (privFlag & (CnProtectCopy | CnProtectModify | CnProtectPrint | CnProtectAnnotForms))
 	p.padding = []byte{
 		0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41,
@@ -99,9 +153,21 @@ func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr str
 	} else {
 		ownerPass = []byte(ownerPassStr)
 	}
+	p.encrypted = true
+	p.algorithm = algorithm
+	p.pValue = -(int(privFlag^255) + 1)
+	p.id = make([]byte, 16)
+	crand.Read(p.id)
+	switch algorithm {
+	case EncryptionAES128:
+		p.setProtectionR4(userPass, ownerPass)
+		return
+	case EncryptionAES256:
+		p.setProtectionR6(userPass, ownerPass)
+		return
+	}
 	userPass = append(userPass, p.padding...)[0:32]
 	ownerPass = append(ownerPass, p.padding...)[0:32]
-	p.encrypted = true
 	p.oValue = oValueGen(userPass, ownerPass)
 	var buf []byte
 	buf = append(buf, userPass...)
@@ -110,5 +176,117 @@ func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr str
 	sum := md5.Sum(buf)
 	p.encryptionKey = sum[0:5]
 	p.uValue = p.uValueGen()
-	p.pValue = -(int(privFlag^255) + 1)
+}
+
+// rc4Iterate encrypts buf with RC4 twenty times, each time using key with
+// every byte xor-ed with the iteration number
+func rc4Iterate(key, buf []byte) []byte {
+	res := append([]byte{}, buf...)
+	k := make([]byte, len(key))
+	for i := 0; i < 20; i++ {
+		for j := range key {
+			k[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(k)
+		c.XORKeyStream(res, res)
+	}
+	return res
+}
+
+// setProtectionR4 computes the values of the standard security handler,
+// revision 4, following algorithms 2, 3 and 5 of the PDF specification
+func (p *protectType) setProtectionR4(userPass, ownerPass []byte) {
+	userPass = append(userPass, p.padding...)[0:32]
+	ownerPass = append(ownerPass, p.padding...)[0:32]
+	sum := md5.Sum(ownerPass)
+	for j := 0; j < 50; j++ {
+		sum = md5.Sum(sum[:])
+	}
+	p.oValue = rc4Iterate(sum[:], userPass)
+	var buf []byte
+	buf = append(buf, userPass...)
+	buf = append(buf, p.oValue...)
+	pbuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(pbuf, uint32(int32(p.pValue)))
+	buf = append(buf, pbuf...)
+	buf = append(buf, p.id...)
+	sum = md5.Sum(buf)
+	for j := 0; j < 50; j++ {
+		sum = md5.Sum(sum[:])
+	}
+	p.encryptionKey = append([]byte{}, sum[:]...)
+	sum = md5.Sum(append(append([]byte{}, p.padding...), p.id...))
+	p.uValue = append(rc4Iterate(p.encryptionKey, sum[:]), make([]byte, 16)...)
+}
+
+// hashR6 is algorithm 2.B of the PDF 2.0 specification, the hash used to
+// compute and validate the passwords of revision 6
+func hashR6(pass, salt, udata []byte) []byte {
+	sum := sha256.Sum256(append(append(append([]byte{}, pass...), salt...), udata...))
+	k := sum[:]
+	for i := 0; ; i++ {
+		var k1 []byte
+		for j := 0; j < 64; j++ {
+			k1 = append(k1, pass...)
+			k1 = append(k1, k...)
+			k1 = append(k1, udata...)
+		}
+		block, _ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+		mod := 0
+		for _, b := range e[:16] {
+			mod += int(b)
+		}
+		var h hash.Hash
+		switch mod % 3 {
+		case 0:
+			h = sha256.New()
+		case 1:
+			h = sha512.New384()
+		default:
+			h = sha512.New()
+		}
+		h.Write(e)
+		k = h.Sum(nil)
+		if i >= 63 && int(e[len(e)-1]) <= i+1-32 {
+			break
+		}
+	}
+	return k[:32]
+}
+
+// setProtectionR6 computes the values of the standard security handler,
+// revision 6, following algorithms 8, 9 and 10 of the PDF 2.0 specification
+func (p *protectType) setProtectionR6(userPass, ownerPass []byte) {
+	if len(userPass) > 127 {
+		userPass = userPass[:127]
+	}
+	if len(ownerPass) > 127 {
+		ownerPass = ownerPass[:127]
+	}
+	p.encryptionKey = make([]byte, 32)
+	crand.Read(p.encryptionKey)
+	salts := make([]byte, 32)
+	crand.Read(salts)
+	zeroIV := make([]byte, aes.BlockSize)
+	keyCrypt := func(key []byte) []byte {
+		block, _ := aes.NewCipher(key)
+		res := make([]byte, 32)
+		cipher.NewCBCEncrypter(block, zeroIV).CryptBlocks(res, p.encryptionKey)
+		return res
+	}
+	// User and owner validation and key salts
+	uvs, uks, ovs, oks := salts[0:8], salts[8:16], salts[16:24], salts[24:32]
+	p.uValue = append(append(hashR6(userPass, uvs, nil), uvs...), uks...)
+	p.ueValue = keyCrypt(hashR6(userPass, uks, nil))
+	p.oValue = append(append(hashR6(ownerPass, ovs, p.uValue), ovs...), oks...)
+	p.oeValue = keyCrypt(hashR6(ownerPass, oks, p.uValue))
+	perms := make([]byte, 16)
+	binary.LittleEndian.PutUint32(perms, uint32(int32(p.pValue)))
+	copy(perms[4:], []byte{0xff, 0xff, 0xff, 0xff, 'T', 'a', 'd', 'b'})
+	crand.Read(perms[12:])
+	block, _ := aes.NewCipher(p.encryptionKey)
+	p.permsValue = make([]byte, 16)
+	block.Encrypt(p.permsValue, perms)
 }
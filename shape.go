@@ -0,0 +1,1310 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Text shaping turns the characters of a string into positioned glyphs as
+// specified by the GSUB and GPOS tables of an OpenType font: characters are
+// mapped to glyphs, which are then replaced by ligatures, contextual forms
+// and the conjuncts of Indic scripts, and finally positioned, for example to
+// place marks above or below their base. Glyphs that do not represent a
+// single character of the cmap table are shown with CIDs allocated from the
+// range 0xD800 to 0xDFFF, which no character uses, so that all other CIDs
+// remain equal to the code points of the characters.
+
+const (
+	shapeFirstCID = 0xD800
+	shapeLastCID  = 0xDFFF
+)
+
+// shapeMasks maps features that only apply to some glyphs to the bit that
+// marks these glyphs. All other features apply to every glyph.
+var shapeMasks = map[string]uint32{
+	"isol": 1 << 1, "fina": 1 << 2, "medi": 1 << 3, "init": 1 << 4,
+	"rphf": 1 << 5, "half": 1 << 6, "blwf": 1 << 7, "pstf": 1 << 8,
+	"abvf": 1 << 9, "pref": 1 << 10,
+}
+
+const shapeGlobalMask = 1
+
+// otCacheType holds the parsed coverage and class definition tables of a
+// GSUB or GPOS table. Every table passed to it is a suffix of the same
+// layout table, so its length identifies its offset.
+type otCacheType struct {
+	coverage, classDef map[int]map[int]int
+}
+
+func (c *otCacheType) cov(t otTable) map[int]int {
+	m, ok := c.coverage[len(t)]
+	if !ok {
+		m = otCoverage(t)
+		c.coverage[len(t)] = m
+	}
+	return m
+}
+
+func (c *otCacheType) class(t otTable) map[int]int {
+	m, ok := c.classDef[len(t)]
+	if !ok {
+		m = otClassDef(t)
+		c.classDef[len(t)] = m
+	}
+	return m
+}
+
+// otShaperType holds the layout tables and metrics of a font needed for
+// shaping, and the CIDs allocated to shaped glyphs.
+type otShaperType struct {
+	gsub, gpos           otTable
+	gsubCache, gposCache otCacheType
+	cmap                 map[int]int
+	advances             []int       // advance widths in font units by glyph ID
+	glyphClass           map[int]int // 1 base, 2 ligature, 3 mark, 4 component
+	markClass            map[int]int // mark attachment classes
+	markSets             []map[int]int
+	features             map[string]map[string][]int
+	kern                 *kernTableType
+	scale                float64 // thousandths of the font size per font unit
+	glyphCID             map[int]int
+	cidText              map[int][]rune
+	nextCID              int
+}
+
+// shapeGlyph is a glyph of shaped text. Positions are in font units.
+type shapeGlyph struct {
+	gid      int
+	cluster  int    // index of the first character represented by the glyph
+	text     []rune // characters represented by the glyph
+	mask     uint32 // features that apply to the glyph
+	syllable int    // Indic syllable, starting with 1
+	reph     bool   // Indic reph
+	adv      int    // advance width
+	dx, dy   int    // placement, relative to the base for attached marks
+	attach   int    // index of the glyph a mark is attached to, or -1
+}
+
+// newShaper reads the tables of utf that are needed for shaping.
+func newShaper(utf *utf8FontFile) *otShaperType {
+	sh := &otShaperType{
+		gsub:       otTable(utf.getTableData("GSUB")),
+		gpos:       otTable(utf.getTableData("GPOS")),
+		gsubCache:  otCacheType{make(map[int]map[int]int), make(map[int]map[int]int)},
+		gposCache:  otCacheType{make(map[int]map[int]int), make(map[int]map[int]int)},
+		cmap:       utf.charSymbolDictionary,
+		features:   make(map[string]map[string][]int),
+		kern:       utf.kern,
+		scale:      1000.0 / float64(utf.fontElementSize),
+		glyphCID:   make(map[int]int),
+		cidText:    make(map[int][]rune),
+		nextCID:    shapeFirstCID,
+		markClass:  make(map[int]int),
+		glyphClass: make(map[int]int),
+	}
+	numSymbols := otTable(utf.getTableData("maxp")).u16(4)
+	numMetrics := otTable(utf.getTableData("hhea")).u16(34)
+	hmtx := otTable(utf.getTableData("hmtx"))
+	sh.advances = make([]int, numSymbols)
+	for gid := range sh.advances {
+		if gid < numMetrics {
+			sh.advances[gid] = hmtx.u16(4 * gid)
+		} else if numMetrics > 0 {
+			sh.advances[gid] = sh.advances[numMetrics-1]
+		}
+	}
+	if gdef := otTable(utf.getTableData("GDEF")); gdef != nil {
+		sh.glyphClass = otClassDef(gdef.sub(gdef.u16(4)))
+		sh.markClass = otClassDef(gdef.sub(gdef.u16(10)))
+		if gdef.u16(2) >= 2 {
+			sets := gdef.sub(gdef.u16(12))
+			count := sets.u16(2)
+			for j := 0; j < count; j++ {
+				sh.markSets = append(sh.markSets, otCoverage(sets.sub(sets.u32(4+4*j))))
+			}
+		}
+	} else {
+		// Without glyph classes, the glyphs of combining marks are marks
+		for r, gid := range sh.cmap {
+			if gid != 0 && unicode.Is(unicode.Mn, rune(r)) {
+				sh.glyphClass[gid] = 3
+			}
+		}
+	}
+	return sh
+}
+
+// scriptFeatures returns the lookups of the features of the specified
+// script in the GSUB or GPOS table.
+func (sh *otShaperType) scriptFeatures(gpos bool, scriptStr string) map[string][]int {
+	key := "GSUB " + scriptStr
+	t := sh.gsub
+	if gpos {
+		key = "GPOS " + scriptStr
+		t = sh.gpos
+	}
+	features, ok := sh.features[key]
+	if !ok {
+		var tags []string
+		switch scriptStr {
+		case "dev2":
+			tags = []string{"dev2", "deva"}
+		case "":
+		default:
+			tags = []string{scriptStr}
+		}
+		features = otScriptFeatures(t, tags...)
+		sh.features[key] = features
+	}
+	return features
+}
+
+// shapeScript returns the OpenType tag of the script of r, or an empty string
+// for characters such as spaces and marks that take the script of the text
+// around them.
+func shapeScript(r rune) string {
+	switch {
+	case unicode.In(r, unicode.Common, unicode.Inherited):
+		return ""
+	case unicode.Is(unicode.Arabic, r):
+		return "arab"
+	case unicode.Is(unicode.Devanagari, r):
+		return "dev2"
+	case unicode.Is(unicode.Latin, r):
+		return "latn"
+	case unicode.Is(unicode.Cyrillic, r):
+		return "cyrl"
+	case unicode.Is(unicode.Greek, r):
+		return "grek"
+	}
+	return "DFLT"
+}
+
+// shape returns the glyphs of the characters of txt, in logical order. The
+// kern feature is applied if kerning is true.
+func (sh *otShaperType) shape(txt []rune, kerning bool) (glyphs []shapeGlyph) {
+	for start := 0; start < len(txt); {
+		scriptStr := ""
+		end := start
+		for ; end < len(txt); end++ {
+			s := shapeScript(txt[end])
+			if s != "" {
+				if scriptStr != "" && s != scriptStr {
+					break
+				}
+				scriptStr = s
+			}
+		}
+		run := make([]shapeGlyph, end-start)
+		for j := range run {
+			r := txt[start+j]
+			run[j] = shapeGlyph{
+				gid:     sh.cmap[int(r)],
+				cluster: start + j,
+				text:    []rune{r},
+				mask:    shapeGlobalMask,
+				attach:  -1,
+			}
+		}
+		run = sh.shapeRun(run, scriptStr, kerning)
+		for j := range run {
+			if run[j].attach >= 0 {
+				run[j].attach += len(glyphs)
+			}
+		}
+		glyphs = append(glyphs, run...)
+		start = end
+	}
+	return
+}
+
+// shapeRun shapes glyphs of text in a single script.
+func (sh *otShaperType) shapeRun(glyphs []shapeGlyph, scriptStr string, kerning bool) []shapeGlyph {
+	b := shapeBufType{sh: sh, glyphs: glyphs}
+	gsub := sh.scriptFeatures(false, scriptStr)
+	switch scriptStr {
+	case "arab":
+		b.stages(gsub, []string{"ccmp", "locl"})
+		b.arabicJoining()
+		b.stages(gsub, []string{"isol"}, []string{"fina"}, []string{"medi"}, []string{"init"},
+			[]string{"rlig"}, []string{"calt"}, []string{"liga", "clig", "mset", "rclt"})
+	case "dev2":
+		b.stages(gsub, []string{"locl", "ccmp"})
+		b.indicInitialReordering(len(gsub["rphf"]) > 0)
+		b.stages(gsub, []string{"nukt"}, []string{"akhn"}, []string{"rphf"}, []string{"rkrf"},
+			[]string{"pref"}, []string{"blwf"}, []string{"abvf"}, []string{"half"},
+			[]string{"pstf"}, []string{"vatu"}, []string{"cjct"})
+		b.indicFinalReordering()
+		b.stages(gsub, []string{"pres", "abvs", "blws", "psts", "haln", "calt", "clig", "liga", "rclt"})
+	default:
+		b.stages(gsub, []string{"ccmp", "locl", "rlig", "calt", "clig", "liga", "rclt"})
+	}
+	for j := range b.glyphs {
+		g := &b.glyphs[j]
+		if g.gid < len(sh.advances) {
+			g.adv = sh.advances[g.gid]
+		}
+	}
+	b.gpos = true
+	gpos := sh.scriptFeatures(true, scriptStr)
+	features := []string{"abvm", "blwm", "dist", "mark", "mkmk"}
+	if kerning {
+		features = append(features, "kern")
+	}
+	b.stages(gpos, features)
+	if kerning && len(gpos["kern"]) == 0 && sh.kern != nil {
+		b.kernTable(scriptStr == "arab")
+	}
+	if scriptStr != "dev2" {
+		// Marks do not advance
+		for j := range b.glyphs {
+			if sh.glyphClass[b.glyphs[j].gid] == 3 {
+				b.glyphs[j].adv = 0
+			}
+		}
+	}
+	return b.glyphs
+}
+
+// shapeBufType holds glyphs while lookups are applied to them.
+type shapeBufType struct {
+	sh      *otShaperType
+	glyphs  []shapeGlyph
+	gpos    bool
+	flag    int
+	markSet map[int]int
+}
+
+func (b *shapeBufType) table() (otTable, *otCacheType, int) {
+	if b.gpos {
+		return b.sh.gpos, &b.sh.gposCache, 9
+	}
+	return b.sh.gsub, &b.sh.gsubCache, 7
+}
+
+// stages applies the lookups of the features of each stage in turn. The
+// lookups of the features of a stage are applied in lookup list order.
+func (b *shapeBufType) stages(features map[string][]int, stages ...[]string) {
+	for _, stage := range stages {
+		masks := make(map[int]uint32)
+		for _, tagStr := range stage {
+			mask, ok := shapeMasks[tagStr]
+			if !ok {
+				mask = shapeGlobalMask
+			}
+			for _, idx := range features[tagStr] {
+				masks[idx] |= mask
+			}
+		}
+		lookups := make([]int, 0, len(masks))
+		for idx := range masks {
+			lookups = append(lookups, idx)
+		}
+		sort.Ints(lookups)
+		for _, idx := range lookups {
+			b.applyLookup(idx, masks[idx])
+		}
+	}
+}
+
+// applyLookup applies a lookup to each glyph that has one of the features of
+// mask.
+func (b *shapeBufType) applyLookup(idx int, mask uint32) {
+	t, _, extType := b.table()
+	b.setFlag(t, idx)
+	lookupType, subtables := otLookupSubtables(t, idx, extType)
+	if !b.gpos && lookupType == 8 {
+		// Reverse chaining substitution is not supported
+		return
+	}
+	for i := 0; i < len(b.glyphs); {
+		next := i + 1
+		if b.glyphs[i].mask&mask != 0 && !b.ignored(i) {
+			for _, st := range subtables {
+				if n, ok := b.applySubtable(lookupType, st, i); ok {
+					next = n
+					break
+				}
+			}
+		}
+		i = next
+	}
+}
+
+func (b *shapeBufType) setFlag(t otTable, idx int) {
+	var set int
+	b.flag, set = otLookupFlag(t, idx)
+	b.markSet = nil
+	if b.flag&0x10 != 0 && set < len(b.sh.markSets) {
+		b.markSet = b.sh.markSets[set]
+	}
+}
+
+// ignored returns true if glyph i is skipped by the current lookup flag.
+func (b *shapeBufType) ignored(i int) bool {
+	gid := b.glyphs[i].gid
+	switch b.sh.glyphClass[gid] {
+	case 1:
+		return b.flag&2 != 0
+	case 2:
+		return b.flag&4 != 0
+	case 3:
+		if b.flag&8 != 0 {
+			return true
+		}
+		if b.flag&0x10 != 0 {
+			_, ok := b.markSet[gid]
+			return !ok
+		}
+		if cls := b.flag >> 8; cls != 0 {
+			return b.sh.markClass[gid] != cls
+		}
+	}
+	return false
+}
+
+// next returns the index of the first glyph after i that is not ignored, or
+// -1 if there is none.
+func (b *shapeBufType) next(i int) int {
+	for i++; i < len(b.glyphs); i++ {
+		if !b.ignored(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// prev returns the index of the last glyph before i that is not ignored, or
+// -1 if there is none.
+func (b *shapeBufType) prev(i int) int {
+	for i--; i >= 0; i-- {
+		if !b.ignored(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applySubtable applies a lookup subtable at glyph i. It returns the index of
+// the glyph to continue with and true if the subtable applied.
+func (b *shapeBufType) applySubtable(lookupType int, st otTable, i int) (int, bool) {
+	_, cache, _ := b.table()
+	g := &b.glyphs[i]
+	if b.gpos {
+		switch lookupType {
+		case 1:
+			return b.singlePos(st, cache, i)
+		case 2:
+			return b.pairPos(st, cache, i)
+		case 4, 5, 6:
+			return b.markPos(lookupType, st, cache, i)
+		case 7:
+			return b.context(st, cache, i, false)
+		case 8:
+			return b.context(st, cache, i, true)
+		}
+		return 0, false
+	}
+	switch lookupType {
+	case 5:
+		return b.context(st, cache, i, false)
+	case 6:
+		return b.context(st, cache, i, true)
+	}
+	idx, ok := cache.cov(st.sub(st.u16(2)))[g.gid]
+	if !ok {
+		return 0, false
+	}
+	switch lookupType {
+	case 1:
+		if st.u16(0) == 1 {
+			g.gid = (g.gid + st.i16(4)) & 0xFFFF
+		} else if idx < st.u16(4) {
+			g.gid = st.u16(6 + 2*idx)
+		} else {
+			return 0, false
+		}
+		return i + 1, true
+	case 2:
+		seq := st.sub(st.u16(6 + 2*idx))
+		count := seq.u16(0)
+		if seq == nil || count == 0 {
+			return 0, false
+		}
+		out := make([]shapeGlyph, count)
+		for j := range out {
+			out[j] = *g
+			out[j].gid = seq.u16(2 + 2*j)
+			if j > 0 {
+				out[j].text = nil
+			}
+		}
+		b.glyphs = append(b.glyphs[:i], append(out, b.glyphs[i+1:]...)...)
+		return i + count, true
+	case 3:
+		set := st.sub(st.u16(6 + 2*idx))
+		if set.u16(0) == 0 {
+			return 0, false
+		}
+		g.gid = set.u16(2)
+		return i + 1, true
+	case 4:
+		set := st.sub(st.u16(6 + 2*idx))
+		count := set.u16(0)
+		for j := 0; j < count; j++ {
+			lig := set.sub(set.u16(2 + 2*j))
+			pos, ok := b.matchForward(i, lig.u16(2)-1, func(k, gid int) bool {
+				return gid == lig.u16(4+2*k)
+			})
+			if !ok {
+				continue
+			}
+			g.gid = lig.u16(0)
+			for _, p := range pos {
+				g.text = append(append([]rune{}, g.text...), b.glyphs[p].text...)
+				if b.glyphs[p].cluster < g.cluster {
+					g.cluster = b.glyphs[p].cluster
+				}
+			}
+			for k := len(pos) - 1; k >= 0; k-- {
+				b.glyphs = append(b.glyphs[:pos[k]], b.glyphs[pos[k]+1:]...)
+			}
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// matchForward matches the count glyphs that follow glyph i, skipping
+// ignored glyphs, and returns their indexes.
+func (b *shapeBufType) matchForward(i, count int, match func(k, gid int) bool) ([]int, bool) {
+	pos := make([]int, 0, count)
+	for k := 0; k < count; k++ {
+		if i = b.next(i); i < 0 || !match(k, b.glyphs[i].gid) {
+			return nil, false
+		}
+		pos = append(pos, i)
+	}
+	return pos, true
+}
+
+// matchBackward matches the count glyphs that precede glyph i in reverse
+// order, skipping ignored glyphs.
+func (b *shapeBufType) matchBackward(i, count int, match func(k, gid int) bool) bool {
+	for k := 0; k < count; k++ {
+		if i = b.prev(i); i < 0 || !match(k, b.glyphs[i].gid) {
+			return false
+		}
+	}
+	return true
+}
+
+// context applies a contextual (GSUB 5, GPOS 7) or, if chained is true, a
+// chaining contextual (GSUB 6, GPOS 8) lookup subtable at glyph i.
+func (b *shapeBufType) context(st otTable, cache *otCacheType, i int, chained bool) (int, bool) {
+	gid := b.glyphs[i].gid
+	glyphMatch := func(t otTable, off int) func(k, gid int) bool {
+		return func(k, gid int) bool { return gid == t.u16(off+2*k) }
+	}
+	classMatch := func(classes map[int]int, t otTable, off int) func(k, gid int) bool {
+		return func(k, gid int) bool { return classes[gid] == t.u16(off+2*k) }
+	}
+	coverageMatch := func(off int) func(k, gid int) bool {
+		return func(k, gid int) bool {
+			_, ok := cache.cov(st.sub(st.u16(off + 2*k)))[gid]
+			return ok
+		}
+	}
+	switch st.u16(0) {
+	case 1, 2:
+		idx, ok := cache.cov(st.sub(st.u16(2)))[gid]
+		if !ok {
+			return 0, false
+		}
+		var backClasses, inputClasses, aheadClasses map[int]int
+		var set otTable
+		format2 := st.u16(0) == 2
+		switch {
+		case !format2:
+			if idx < st.u16(4) {
+				set = st.sub(st.u16(6 + 2*idx))
+			}
+		case !chained:
+			inputClasses = cache.class(st.sub(st.u16(4)))
+			if cls := inputClasses[gid]; cls < st.u16(6) {
+				set = st.sub(st.u16(8 + 2*cls))
+			}
+		default:
+			backClasses = cache.class(st.sub(st.u16(4)))
+			inputClasses = cache.class(st.sub(st.u16(6)))
+			aheadClasses = cache.class(st.sub(st.u16(8)))
+			if cls := inputClasses[gid]; cls < st.u16(10) {
+				set = st.sub(st.u16(12 + 2*cls))
+			}
+		}
+		match := func(classes map[int]int, t otTable, off int) func(k, gid int) bool {
+			if format2 {
+				return classMatch(classes, t, off)
+			}
+			return glyphMatch(t, off)
+		}
+		count := set.u16(0)
+		for j := 0; j < count; j++ {
+			rule := set.sub(set.u16(2 + 2*j))
+			var next int
+			var ok bool
+			if chained {
+				// Backtrack, input and lookahead sequences, each preceded
+				// by its length, followed by the lookup records
+				backCount := rule.u16(0)
+				off := 2 + 2*backCount
+				inputCount := rule.u16(off)
+				inputOff := off + 2
+				off += 2 * inputCount
+				aheadCount := rule.u16(off)
+				aheadOff := off + 2
+				off += 2 + 2*aheadCount
+				next, ok = b.applyRule(i, backCount, match(backClasses, rule, 2), inputCount,
+					match(inputClasses, rule, inputOff), aheadCount, match(aheadClasses, rule, aheadOff),
+					rule.sub(off+2), rule.u16(off))
+			} else {
+				// Input length, record count, input sequence and records
+				inputCount := rule.u16(0)
+				next, ok = b.applyRule(i, 0, nil, inputCount, match(inputClasses, rule, 4),
+					0, nil, rule.sub(4+2*(inputCount-1)), rule.u16(2))
+			}
+			if ok {
+				return next, true
+			}
+		}
+	case 3:
+		if !chained {
+			inputCount := st.u16(2)
+			if _, ok := cache.cov(st.sub(st.u16(6)))[gid]; !ok {
+				return 0, false
+			}
+			input := coverageMatch(8)
+			return b.applyRule(i, 0, nil, inputCount, input, 0, nil, st.sub(6+2*inputCount), st.u16(4))
+		}
+		backCount := st.u16(2)
+		back := coverageMatch(4)
+		off := 4 + 2*backCount
+		inputCount := st.u16(off)
+		if _, ok := cache.cov(st.sub(st.u16(off + 2)))[gid]; !ok {
+			return 0, false
+		}
+		input := coverageMatch(off + 4)
+		off += 2 + 2*inputCount
+		aheadCount := st.u16(off)
+		ahead := coverageMatch(off + 2)
+		off += 2 + 2*aheadCount
+		return b.applyRule(i, backCount, back, inputCount, input, aheadCount, ahead, st.sub(off+2), st.u16(off))
+	}
+	return 0, false
+}
+
+// applyRule matches a contextual rule at glyph i, which has already been
+// matched as the first glyph of the input sequence, and applies the lookups
+// of its records to the input sequence.
+func (b *shapeBufType) applyRule(i, backCount int, back func(k, gid int) bool, inputCount int,
+	input func(k, gid int) bool, aheadCount int, ahead func(k, gid int) bool, records otTable, recordCount int) (int, bool) {
+	if inputCount < 1 {
+		return 0, false
+	}
+	pos, ok := b.matchForward(i, inputCount-1, input)
+	if !ok {
+		return 0, false
+	}
+	pos = append([]int{i}, pos...)
+	last := pos[len(pos)-1]
+	if _, ok = b.matchForward(last, aheadCount, ahead); !ok {
+		return 0, false
+	}
+	if !b.matchBackward(i, backCount, back) {
+		return 0, false
+	}
+	if recordCount > 0 && records == nil {
+		return 0, false
+	}
+	t, _, extType := b.table()
+	flag, markSet := b.flag, b.markSet
+	for j := 0; j < recordCount; j++ {
+		seqIdx, lookupIdx := records.u16(4*j), records.u16(4*j+2)
+		if seqIdx >= len(pos) || pos[seqIdx] >= len(b.glyphs) {
+			continue
+		}
+		p := pos[seqIdx]
+		count := len(b.glyphs)
+		b.setFlag(t, lookupIdx)
+		lookupType, subtables := otLookupSubtables(t, lookupIdx, extType)
+		for _, st := range subtables {
+			if _, ok := b.applySubtable(lookupType, st, p); ok {
+				break
+			}
+		}
+		// Glyphs after p move if the lookup changed the number of glyphs
+		delta := len(b.glyphs) - count
+		for k := range pos {
+			if pos[k] > p {
+				pos[k] += delta
+			}
+		}
+		last = pos[len(pos)-1]
+	}
+	b.flag, b.markSet = flag, markSet
+	return last + 1, true
+}
+
+// valueRecord adds the placement and advance of a GPOS value record to g.
+func valueRecord(t otTable, off, format int, g *shapeGlyph) {
+	if format&1 != 0 {
+		g.dx += t.i16(off)
+		off += 2
+	}
+	if format&2 != 0 {
+		g.dy += t.i16(off)
+		off += 2
+	}
+	if format&4 != 0 {
+		g.adv += t.i16(off)
+	}
+}
+
+func valueRecordSize(format int) int {
+	size := 0
+	for ; format != 0; format >>= 1 {
+		size += 2 * (format & 1)
+	}
+	return size
+}
+
+// singlePos applies a single adjustment positioning subtable at glyph i.
+func (b *shapeBufType) singlePos(st otTable, cache *otCacheType, i int) (int, bool) {
+	g := &b.glyphs[i]
+	idx, ok := cache.cov(st.sub(st.u16(2)))[g.gid]
+	if !ok {
+		return 0, false
+	}
+	format := st.u16(4)
+	switch st.u16(0) {
+	case 1:
+		valueRecord(st, 6, format, g)
+	case 2:
+		if idx >= st.u16(6) {
+			return 0, false
+		}
+		valueRecord(st, 8+idx*valueRecordSize(format), format, g)
+	default:
+		return 0, false
+	}
+	return i + 1, true
+}
+
+// pairPos applies a pair adjustment positioning subtable at glyph i.
+func (b *shapeBufType) pairPos(st otTable, cache *otCacheType, i int) (int, bool) {
+	idx, ok := cache.cov(st.sub(st.u16(2)))[b.glyphs[i].gid]
+	j := b.next(i)
+	if !ok || j < 0 {
+		return 0, false
+	}
+	vf1, vf2 := st.u16(4), st.u16(6)
+	size1, size2 := valueRecordSize(vf1), valueRecordSize(vf2)
+	second := b.glyphs[j].gid
+	off := -1
+	var t otTable
+	switch st.u16(0) {
+	case 1:
+		if idx >= st.u16(8) {
+			return 0, false
+		}
+		t = st.sub(st.u16(10 + 2*idx))
+		count := t.u16(0)
+		for k := 0; k < count && off < 0; k++ {
+			rec := 2 + k*(2+size1+size2)
+			if t.u16(rec) == second {
+				off = rec + 2
+			}
+		}
+	case 2:
+		t = st
+		class1 := cache.class(st.sub(st.u16(8)))[b.glyphs[i].gid]
+		class2 := cache.class(st.sub(st.u16(10)))[second]
+		if class1 < st.u16(12) && class2 < st.u16(14) {
+			off = 16 + (class1*st.u16(14)+class2)*(size1+size2)
+		}
+	}
+	if off < 0 {
+		return 0, false
+	}
+	valueRecord(t, off, vf1, &b.glyphs[i])
+	valueRecord(t, off+size1, vf2, &b.glyphs[j])
+	if vf2 != 0 {
+		return j + 1, true
+	}
+	return j, true
+}
+
+// markPos applies a mark-to-base (4), mark-to-ligature (5) or mark-to-mark
+// (6) attachment positioning subtable at glyph i.
+func (b *shapeBufType) markPos(lookupType int, st otTable, cache *otCacheType, i int) (int, bool) {
+	g := &b.glyphs[i]
+	markIdx, ok := cache.cov(st.sub(st.u16(2)))[g.gid]
+	if !ok {
+		return 0, false
+	}
+	j := i - 1
+	if lookupType == 6 {
+		if j = b.prev(i); j < 0 || b.sh.glyphClass[b.glyphs[j].gid] != 3 {
+			return 0, false
+		}
+	} else {
+		for j >= 0 && b.sh.glyphClass[b.glyphs[j].gid] == 3 {
+			j--
+		}
+	}
+	if j < 0 {
+		return 0, false
+	}
+	baseIdx, ok := cache.cov(st.sub(st.u16(4)))[b.glyphs[j].gid]
+	if !ok {
+		return 0, false
+	}
+	classCount := st.u16(6)
+	marks := st.sub(st.u16(8))
+	if markIdx >= marks.u16(0) {
+		return 0, false
+	}
+	class := marks.u16(2 + 4*markIdx)
+	markAnchor := marks.sub(marks.u16(4 + 4*markIdx))
+	bases := st.sub(st.u16(10))
+	var baseAnchor otTable
+	if lookupType == 5 {
+		// Marks are attached to the last component of a ligature
+		lig := bases.sub(bases.u16(2 + 2*baseIdx))
+		if comp := lig.u16(0) - 1; comp >= 0 {
+			baseAnchor = lig.sub(lig.u16(2 + 2*(comp*classCount+class)))
+		}
+	} else {
+		baseAnchor = bases.sub(bases.u16(2 + 2*(baseIdx*classCount+class)))
+	}
+	if markAnchor == nil || baseAnchor == nil {
+		return 0, false
+	}
+	g.dx = baseAnchor.i16(2) - markAnchor.i16(2)
+	g.dy = baseAnchor.i16(4) - markAnchor.i16(4)
+	g.attach = j
+	return i + 1, true
+}
+
+// kernTable applies the pairs of the kern table to adjacent glyphs that are
+// not marks. In right-to-left text, the second glyph of a pair is adjusted.
+func (b *shapeBufType) kernTable(rtl bool) {
+	prev := -1
+	for j := range b.glyphs {
+		if b.sh.glyphClass[b.glyphs[j].gid] == 3 {
+			continue
+		}
+		if prev >= 0 {
+			v := b.sh.kern.pair(b.glyphs[prev].gid, b.glyphs[j].gid)
+			adj := int(math.Round(float64(v) / b.sh.scale))
+			if rtl {
+				b.glyphs[j].adv += adj
+			} else {
+				b.glyphs[prev].adv += adj
+			}
+		}
+		prev = j
+	}
+}
+
+// arabicJoiningRanges lists the joining types of the characters of the
+// Arabic blocks: 'D' for dual-joining, 'R' for right-joining, 'C' for
+// join-causing and 'U' for non-joining. Marks are transparent.
+var arabicJoiningRanges = []struct {
+	lo, hi rune
+	jt     byte
+}{
+	{0x0620, 0x0620, 'D'}, {0x0621, 0x0621, 'U'}, {0x0622, 0x0625, 'R'}, {0x0626, 0x0626, 'D'},
+	{0x0627, 0x0627, 'R'}, {0x0628, 0x0628, 'D'}, {0x0629, 0x0629, 'R'}, {0x062A, 0x062E, 'D'},
+	{0x062F, 0x0632, 'R'}, {0x0633, 0x063F, 'D'}, {0x0640, 0x0640, 'C'}, {0x0641, 0x0647, 'D'},
+	{0x0648, 0x0648, 'R'}, {0x0649, 0x064A, 'D'}, {0x066E, 0x066F, 'D'}, {0x0671, 0x0673, 'R'},
+	{0x0675, 0x0677, 'R'}, {0x0678, 0x0687, 'D'}, {0x0688, 0x0699, 'R'}, {0x069A, 0x06BF, 'D'},
+	{0x06C0, 0x06C0, 'R'}, {0x06C1, 0x06C2, 'D'}, {0x06C3, 0x06CB, 'R'}, {0x06CC, 0x06CC, 'D'},
+	{0x06CD, 0x06CD, 'R'}, {0x06CE, 0x06CE, 'D'}, {0x06CF, 0x06CF, 'R'}, {0x06D0, 0x06D1, 'D'},
+	{0x06D2, 0x06D3, 'R'}, {0x06D5, 0x06D5, 'R'}, {0x06EE, 0x06EF, 'R'}, {0x06FA, 0x06FC, 'D'},
+	{0x06FF, 0x06FF, 'D'}, {0x0750, 0x0758, 'D'}, {0x0759, 0x075B, 'R'}, {0x075C, 0x076A, 'D'},
+	{0x076B, 0x076C, 'R'}, {0x076D, 0x0770, 'D'}, {0x0771, 0x0771, 'R'}, {0x0772, 0x0772, 'D'},
+	{0x0773, 0x0774, 'R'}, {0x0775, 0x0777, 'D'}, {0x0778, 0x0779, 'R'}, {0x077A, 0x077F, 'D'},
+	{0x200D, 0x200D, 'C'},
+}
+
+// arabicJoiningType returns the joining type of r: 'D', 'R', 'C', 'U' or 'T'
+// for transparent characters.
+func arabicJoiningType(r rune) byte {
+	j := sort.Search(len(arabicJoiningRanges), func(j int) bool { return arabicJoiningRanges[j].hi >= r })
+	if j < len(arabicJoiningRanges) && arabicJoiningRanges[j].lo <= r {
+		return arabicJoiningRanges[j].jt
+	}
+	if r != 0x200C && unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 'T'
+	}
+	return 'U'
+}
+
+// arabicJoining marks each letter for its isolated, final, medial or initial
+// form, depending on whether it joins the letters before and after it.
+func (b *shapeBufType) arabicJoining() {
+	n := len(b.glyphs)
+	types := make([]byte, n)
+	joinPrev := make([]bool, n)
+	joinNext := make([]bool, n)
+	prev := -1
+	for j := range b.glyphs {
+		if len(b.glyphs[j].text) > 0 {
+			types[j] = arabicJoiningType(b.glyphs[j].text[0])
+		} else {
+			types[j] = 'T'
+		}
+		if types[j] == 'T' {
+			continue
+		}
+		if prev >= 0 && (types[prev] == 'D' || types[prev] == 'C') &&
+			(types[j] == 'D' || types[j] == 'R' || types[j] == 'C') {
+			joinNext[prev] = true
+			joinPrev[j] = true
+		}
+		prev = j
+	}
+	for j := range b.glyphs {
+		var form string
+		switch {
+		case types[j] == 'D' && joinPrev[j] && joinNext[j]:
+			form = "medi"
+		case (types[j] == 'D' || types[j] == 'R') && joinPrev[j]:
+			form = "fina"
+		case types[j] == 'D' && joinNext[j]:
+			form = "init"
+		case types[j] == 'D' || types[j] == 'R':
+			form = "isol"
+		default:
+			continue
+		}
+		b.glyphs[j].mask |= shapeMasks[form]
+	}
+}
+
+// Categories of Devanagari characters
+const (
+	devOther = iota
+	devConsonant
+	devNukta
+	devHalant
+	devMatra
+	devPreMatra
+	devVowel
+	devModifier
+	devJoiner
+)
+
+const devRa = 0x0930
+
+func devCategory(r rune) int {
+	switch {
+	case r >= 0x0915 && r <= 0x0939, r >= 0x0958 && r <= 0x095F, r >= 0x0978 && r <= 0x097F:
+		return devConsonant
+	case r == 0x093C:
+		return devNukta
+	case r == 0x094D:
+		return devHalant
+	case r == 0x093F, r == 0x094E:
+		return devPreMatra
+	case r >= 0x093A && r <= 0x094C, r == 0x094F, r >= 0x0955 && r <= 0x0957, r == 0x0962, r == 0x0963:
+		return devMatra
+	case r >= 0x0904 && r <= 0x0914, r == 0x0960, r == 0x0961, r >= 0x0972 && r <= 0x0977:
+		return devVowel
+	case r >= 0x0900 && r <= 0x0903:
+		return devModifier
+	case r == 0x200C, r == 0x200D:
+		return devJoiner
+	}
+	return devOther
+}
+
+// devCat returns the category of the character of glyph j, which is a single
+// character before substitutions are applied.
+func (b *shapeBufType) devCat(j int) int {
+	if j < 0 || j >= len(b.glyphs) || len(b.glyphs[j].text) == 0 {
+		return devOther
+	}
+	return devCategory(b.glyphs[j].text[0])
+}
+
+// devSyllables returns the start and end of each syllable of the glyphs.
+func (b *shapeBufType) devSyllables() (list [][2]int) {
+	n := len(b.glyphs)
+	for i := 0; i < n; {
+		start := i
+		switch b.devCat(i) {
+		case devConsonant:
+			for {
+				i++
+				if b.devCat(i) == devNukta {
+					i++
+				}
+				if b.devCat(i) != devHalant {
+					break
+				}
+				i++
+				if b.devCat(i) == devJoiner {
+					i++
+				}
+				if b.devCat(i) != devConsonant {
+					break
+				}
+			}
+			for c := b.devCat(i); c == devMatra || c == devPreMatra || c == devNukta || c == devHalant; c = b.devCat(i) {
+				i++
+			}
+		case devVowel:
+			i++
+			for c := b.devCat(i); c == devMatra || c == devPreMatra || c == devNukta; c = b.devCat(i) {
+				i++
+			}
+		default:
+			i++
+			list = append(list, [2]int{start, i})
+			continue
+		}
+		for b.devCat(i) == devModifier {
+			i++
+		}
+		list = append(list, [2]int{start, i})
+	}
+	return
+}
+
+// indicInitialReordering finds the base consonant of each syllable, marks
+// the glyphs before and after it for half and below-base forms, marks an
+// initial ra and halant for the reph form if the font has one and moves the
+// pre-base matra to the start of the syllable.
+func (b *shapeBufType) indicInitialReordering(hasReph bool) {
+	for k, syl := range b.devSyllables() {
+		start, end := syl[0], syl[1]
+		for j := start; j < end; j++ {
+			b.glyphs[j].syllable = k + 1
+		}
+		if b.devCat(start) != devConsonant {
+			continue
+		}
+		baseStart := start
+		if hasReph && end-start >= 3 && b.glyphs[start].text[0] == devRa &&
+			b.devCat(start+1) == devHalant && b.devCat(start+2) == devConsonant {
+			b.glyphs[start].mask |= shapeMasks["rphf"]
+			b.glyphs[start+1].mask |= shapeMasks["rphf"]
+			b.glyphs[start].reph = true
+			baseStart = start + 2
+		}
+		// The base is the last consonant, except for a final ra that takes
+		// its below-base form after a halant
+		base := -1
+		for j := end - 1; j >= baseStart && base < 0; j-- {
+			if b.devCat(j) != devConsonant {
+				continue
+			}
+			postBaseRa := b.glyphs[j].text[0] == devRa && b.devCat(j-1) == devHalant && j-1 > baseStart
+			if !postBaseRa || b.devCat(j+1) == devHalant {
+				base = j
+			} else {
+				// Only the last consonant can be a post-base ra
+				for j--; j > baseStart && b.devCat(j-1) != devConsonant; j-- {
+				}
+			}
+		}
+		if base < 0 {
+			base = baseStart
+		}
+		for j := baseStart; j < end; j++ {
+			switch {
+			case j < base:
+				b.glyphs[j].mask |= shapeMasks["half"]
+			case j > base:
+				b.glyphs[j].mask |= shapeMasks["blwf"] | shapeMasks["pstf"] | shapeMasks["abvf"] | shapeMasks["pref"]
+			}
+		}
+		for j := base + 1; j < end; j++ {
+			if b.devCat(j) == devPreMatra {
+				matra := b.glyphs[j]
+				copy(b.glyphs[baseStart+1:j+1], b.glyphs[baseStart:j])
+				b.glyphs[baseStart] = matra
+			}
+		}
+	}
+}
+
+// indicFinalReordering moves the reph to the end of its syllable, before
+// any syllable modifiers.
+func (b *shapeBufType) indicFinalReordering() {
+	for start := 0; start < len(b.glyphs); {
+		end := start + 1
+		for end < len(b.glyphs) && b.glyphs[end].syllable == b.glyphs[start].syllable {
+			end++
+		}
+		g := b.glyphs[start]
+		// The reph and halant have been combined into a single glyph
+		if g.reph && len(g.text) == 2 {
+			to := end - 1
+			for to > start && b.devCat(to) == devModifier {
+				to--
+			}
+			copy(b.glyphs[start:to], b.glyphs[start+1:to+1])
+			b.glyphs[to] = g
+		}
+		start = end
+	}
+}
+
+// SetTextShaping turns OpenType text shaping on or off for fonts added with
+// AddUTF8Font() and related methods. With shaping on, the GSUB table of the
+// font is used to replace characters with ligatures such as "fi", with the
+// contextual forms of Arabic letters and with the conjuncts of Devanagari
+// text, and the GPOS table is used to position marks. Each directional run
+// of a line is shaped in logical order and then laid out in its own
+// direction, so right-to-left text is shaped without calling RTL(), which
+// only sets the base direction of paragraphs. Shaping is taken into account
+// by GetStringWidth(), SplitText() and the methods that output text. It is
+// off by default.
+//
+// The SetTextShaping() example demonstrates this method.
+func (f *Fpdf) SetTextShaping(shaping bool) {
+	f.shaping = shaping
+}
+
+// GetTextShaping returns true if text shaping is turned on. See
+// SetTextShaping().
+func (f *Fpdf) GetTextShaping() bool {
+	return f.shaping
+}
+
+// shapeActive returns true if text in the current font is shaped.
+func (f *Fpdf) shapeActive() bool {
+	return f.shaping && f.isCurrentUTF8 && f.currentFont.utf8File != nil
+}
+
+// shaper returns the shaper of the current font.
+func (f *Fpdf) shaper() *otShaperType {
+	utf := f.currentFont.utf8File
+	if utf.shaper == nil {
+		utf.shaper = newShaper(utf)
+		utf.shapedGlyphs = make(map[int]int)
+	}
+	return utf.shaper
+}
+
+// shapeAdvances returns the widths of the characters of txt after shaping,
+// in thousandths of the font size, or nil if text is not shaped. The width
+// of a glyph that represents several characters is that of the first one.
+func (f *Fpdf) shapeAdvances(txt []rune) []int {
+	if !f.shapeActive() {
+		return nil
+	}
+	sh := f.shaper()
+	list := make([]int, len(txt))
+	for _, g := range sh.shape(txt, f.kerning) {
+		list[g.cluster] += sh.advance(g)
+	}
+	return list
+}
+
+// shapeCID returns the CID of a shaped glyph, allocating one for glyphs that
+// are not the glyph of the single character they represent.
+func (f *Fpdf) shapeCID(sh *otShaperType, g shapeGlyph) int {
+	if len(g.text) == 1 && g.text[0] <= 0xFFFF {
+		r := int(g.text[0])
+		if gid, ok := sh.cmap[r]; gid == g.gid && (ok || gid == 0) {
+			return r
+		}
+	}
+	if cid, ok := sh.glyphCID[g.gid]; ok {
+		return cid
+	}
+	if sh.nextCID > shapeLastCID {
+		f.err = fmt.Errorf("too many shaped glyphs in font %s", f.currentFont.Name)
+		return 0
+	}
+	cid := sh.nextCID
+	sh.nextCID++
+	sh.glyphCID[g.gid] = cid
+	sh.cidText[cid] = g.text
+	f.currentFont.utf8File.shapedGlyphs[cid] = g.gid
+	w := 0
+	if g.gid < len(sh.advances) {
+		w = int(math.Round(float64(sh.advances[g.gid]) * sh.scale))
+	}
+	if w == 0 {
+		// Marker width 65535 used for zero width symbols
+		w = 65535
+	}
+	f.currentFont.Cw[cid] = w
+	f.currentFont.usedRunes[cid] = cid
+	return cid
+}
+
+// cidWidth returns the width of a CID of the current font in thousandths of
+// the font size, as declared in the font's /W array.
+func (f *Fpdf) cidWidth(cid int) float64 {
+	switch w := f.currentFont.Cw[cid]; w {
+	case 65535:
+		return 0
+	case 0:
+		if f.currentFont.Desc.MissingWidth == 0 {
+			return 1000
+		}
+		return float64(f.currentFont.Desc.MissingWidth)
+	default:
+		return float64(w)
+	}
+}
+
+// shapeLine returns the glyphs of the directional runs of a line shaped,
+// with their origins in thousandths of the font size. wordSpacing, in
+// thousandths of the font size, is added before each space, and charSpacing
+// after each character but the last. Each run is shaped in logical order;
+// the glyphs of right-to-left runs are then reversed.
+func (f *Fpdf) shapeLine(runs []bidiRunType, wordSpacing, charSpacing float64) (glyphs []shapeGlyph, x, y []float64) {
+	sh := f.shaper()
+	for _, run := range runs {
+		list := sh.shape(run.text, f.kerning)
+		base, m := len(glyphs), len(list)
+		if run.rtl {
+			for j := 0; j < m/2; j++ {
+				list[j], list[m-1-j] = list[m-1-j], list[j]
+			}
+			for j := range list {
+				if list[j].attach >= 0 {
+					list[j].attach = m - 1 - list[j].attach
+				}
+			}
+		}
+		for j := range list {
+			if list[j].attach >= 0 {
+				list[j].attach += base
+			}
+		}
+		glyphs = append(glyphs, list...)
+	}
+	n := len(glyphs)
+	// Glyph origins in thousandths of the font size
+	x = make([]float64, n)
+	y = make([]float64, n)
+	var pen float64
+	for j, g := range glyphs {
+		if j > 0 {
+			pen += charSpacing * float64(len(glyphs[j-1].text))
+			if len(g.text) == 1 && g.text[0] == ' ' {
+				pen += wordSpacing
+			}
+		}
+		x[j] = pen + float64(g.dx)*sh.scale
+		y[j] = float64(g.dy) * sh.scale
+		pen += float64(sh.advance(g))
+	}
+	resolved := make([]bool, n)
+	var resolve func(j int)
+	resolve = func(j int) {
+		if resolved[j] {
+			return
+		}
+		resolved[j] = true
+		if a := glyphs[j].attach; a >= 0 && a < n {
+			resolve(a)
+			x[j] = x[a] + float64(glyphs[j].dx)*sh.scale
+			y[j] = y[a] + float64(glyphs[j].dy)*sh.scale
+		}
+	}
+	for j := range glyphs {
+		resolve(j)
+	}
+	return
+}
+
+// shapeTJ returns the elements of a TJ array that shows the directional
+// runs of a line shaped, as laid out by shapeLine(). Marks that are raised or
+// lowered are shown with a text rise, which ends the array and starts a new
+// one.
+func (f *Fpdf) shapeTJ(runs []bidiRunType, wordSpacing, charSpacing float64) string {
+	sh := f.shaper()
+	glyphs, x, y := f.shapeLine(runs, wordSpacing, charSpacing)
+	var s fmtBuffer
+	var codes []byte
+	flush := func() {
+		if len(codes) > 0 {
+			s.printf("(%s)", f.escape(string(codes)))
+			codes = codes[:0]
+		}
+	}
+	base := f.textRise * f.k
+	var pos, rise float64
+	for j, g := range glyphs {
+		cid := f.shapeCID(sh, g)
+		if math.Abs(y[j]-rise) >= 0.0005 {
+			flush()
+			rise = y[j]
+			s.printf("] TJ %.3f Ts [", base+rise*f.fontSizePt/1000)
+		}
+		if d := x[j] - pos; math.Abs(d) >= 0.0005 {
+			flush()
+			s.printf(" %.3f ", -d)
+		}
+		codes = append(codes, byte(cid>>8), byte(cid))
+		pos = x[j] + f.cidWidth(cid)
+	}
+	flush()
+	if rise != 0 {
+		s.printf("] TJ %.3f Ts [", base)
+	}
+	return s.String()
+}
+
+// advance returns the advance width of g in thousandths of the font size,
+// rounded like the widths of the font.
+func (sh *otShaperType) advance(g shapeGlyph) int {
+	return int(math.Round(float64(g.adv) * sh.scale))
+}
+
+// toUnicode returns a ToUnicode CMap that maps the CIDs allocated to shaped
+// glyphs to the characters they represent and all other CIDs to the code
+// point equal to them.
+func (sh *otShaperType) toUnicode() string {
+	cids := make([]int, 0, len(sh.cidText))
+	for cid, text := range sh.cidText {
+		if len(text) > 0 {
+			cids = append(cids, cid)
+		}
+	}
+	sort.Ints(cids)
+	var s fmtBuffer
+	s.printf("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n/CIDSystemInfo\n<</Registry (Adobe)\n/Ordering (UCS)\n/Supplement 0\n>> def\n")
+	s.printf("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	s.printf("2 beginbfrange\n<0000> <%04X> <0000>\n<%04X> <FFFF> <%04X>\nendbfrange\n",
+		shapeFirstCID-1, shapeLastCID+1, shapeLastCID+1)
+	for j := 0; j < len(cids); j += 100 {
+		block := cids[j:]
+		if len(block) > 100 {
+			block = block[:100]
+		}
+		s.printf("%d beginbfchar\n", len(block))
+		for _, cid := range block {
+			s.printf("<%04X> <", cid)
+			for _, u := range utf16.Encode(sh.cidText[cid]) {
+				s.printf("%04X", u)
+			}
+			s.printf(">\n")
+		}
+		s.printf("endbfchar\n")
+	}
+	s.printf("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return s.String()
+}
@@ -0,0 +1,368 @@
+package gofpdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// SignatureOptions holds the settings used by Sign() to digitally sign a
+// document.
+type SignatureOptions struct {
+	// Signer is the private key used to sign the document. RSA and ECDSA keys
+	// are supported.
+	Signer crypto.Signer
+	// Certificates is the certificate chain of the signer. The first
+	// certificate must be the one that belongs to Signer.
+	Certificates []*x509.Certificate
+	// Name, Reason, Location and ContactInfo are optional descriptive
+	// entries of the signature.
+	Name        string
+	Reason      string
+	Location    string
+	ContactInfo string
+	// SigningTime is the time of signing. The current time is used if it is
+	// zero.
+	SigningTime time.Time
+	// X, Y, W and H specify the rectangle of a visible signature on the page
+	// that is current when Sign() is called. The signature is invisible if W
+	// or H is zero.
+	X, Y, W, H float64
+	// Appearance is the template that is drawn, scaled to the signature
+	// rectangle, to show a visible signature.
+	Appearance Template
+	// TimestampURL is the address of an RFC 3161 time stamping authority. If
+	// it is not empty, a time stamp token of the signature is requested and
+	// included in the signature.
+	TimestampURL string
+	// TimestampClient is used to send requests to the time stamping
+	// authority. http.DefaultClient is used if it is nil.
+	TimestampClient *http.Client
+}
+
+type signatureRecType struct {
+	opts          SignatureOptions
+	byteRangePos  int // buffer offset of the /ByteRange placeholder
+	contentsStart int // buffer offset of the /Contents hex string
+	contentsLen   int // length of the /Contents hex string including delimiters
+}
+
+var (
+	oidData                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSHA256                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256       = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidAttrContentType       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningCertV2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidAttrTimeStampToken    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+	asn1Null                 = []byte{0x05, 0x00}
+	signatureByteRangeFmtStr = "/ByteRange [0 %010d %010d %010d]"
+)
+
+// Sign arranges for the document to be digitally signed when it is closed.
+// A signature field is added to the page that is current when this method is
+// called. The signature is a detached CMS signature conforming to PAdES
+// (subfilter ETSI.CAdES.detached) that covers the whole document. Only one
+// signature per document is supported and the document should not be
+// modified after it has been output.
+//
+// If opts.W and opts.H are greater than zero, the signature is visible at the
+// specified position and opts.Appearance, if not nil, is used to draw it. If
+// opts.TimestampURL is not empty, a time stamp token is obtained from the
+// specified RFC 3161 time stamping authority when the document is closed.
+//
+// The Sign() example demonstrates this method.
+func (f *Fpdf) Sign(opts SignatureOptions) {
+	if f.err != nil {
+		return
+	}
+	if f.signature != nil {
+		f.SetErrorf("document has already been signed")
+		return
+	}
+	if opts.Signer == nil || len(opts.Certificates) == 0 {
+		f.SetErrorf("signer and certificate are required to sign the document")
+		return
+	}
+	switch opts.Signer.Public().(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		f.SetErrorf("unsupported signature key type %T", opts.Signer.Public())
+		return
+	}
+	if !signaturePublicKeyEqual(opts.Signer.Public(), opts.Certificates[0].PublicKey) {
+		f.SetErrorf("signer does not match the first certificate")
+		return
+	}
+	if !f.formCheck("Signature1", false) {
+		return
+	}
+	if opts.SigningTime.IsZero() {
+		opts.SigningTime = time.Now()
+	}
+	fld := f.formNewField(formFieldSignature, "Signature1", FormFieldOptions{})
+	wd := formWidgetType{x: opts.X * f.k, y: f.hPt - opts.Y*f.k}
+	if opts.W > 0 && opts.H > 0 {
+		wd.w, wd.h = opts.W*f.k, opts.H*f.k
+		if opts.Appearance != nil {
+			f.templateRegister(opts.Appearance)
+			corner, size := opts.Appearance.Size()
+			sx, sy := opts.W/size.Wd, opts.H/size.Ht
+			wd.apOn = []byte(sprintf("q %.4f 0 0 %.4f %.4f %.4f cm /TPL%s Do Q",
+				sx, sy, -corner.X*f.k*sx, -corner.Y*f.k*sy, opts.Appearance.ID()))
+		}
+	}
+	fld.widgets = []formWidgetType{wd}
+	f.form.fields = append(f.form.fields, fld)
+	f.signature = &signatureRecType{opts: opts}
+}
+
+// signaturePublicKeyEqual returns true if the public keys a and b are identical.
+func signaturePublicKeyEqual(a, b crypto.PublicKey) bool {
+	ka, errA := x509.MarshalPKIXPublicKey(a)
+	kb, errB := x509.MarshalPKIXPublicKey(b)
+	return errA == nil && errB == nil && bytes.Equal(ka, kb)
+}
+
+// signatureSize returns the number of bytes reserved for the CMS signature.
+func (f *Fpdf) signatureSize() (size int) {
+	size = 2048
+	for _, cert := range f.signature.opts.Certificates {
+		size += len(cert.Raw)
+	}
+	if f.signature.opts.TimestampURL != "" {
+		size += 8192
+	}
+	return
+}
+
+// signaturePutDict writes the signature dictionary with placeholders for
+// the byte range and the signature itself. The placeholders are filled in by
+// signatureSign().
+func (f *Fpdf) signaturePutDict() {
+	sig := f.signature
+	f.newobj()
+	f.out("<</Type /Sig /Filter /Adobe.PPKLite /SubFilter /ETSI.CAdES.detached")
+	sig.byteRangePos = f.buffer.Len()
+	f.outf(signatureByteRangeFmtStr, 0, 0, 0)
+	sig.contentsStart = f.buffer.Len() + len("/Contents ")
+	sig.contentsLen = 2*f.signatureSize() + 2
+	f.outf("/Contents <%s>", bytes.Repeat([]byte{'0'}, sig.contentsLen-2))
+	f.outf("/M %s", f.textstring("D:"+pdfDateStr(sig.opts.SigningTime)))
+	for _, entry := range []struct{ key, val string }{
+		{"Name", sig.opts.Name}, {"Reason", sig.opts.Reason},
+		{"Location", sig.opts.Location}, {"ContactInfo", sig.opts.ContactInfo}} {
+		if entry.val != "" {
+			f.outf("/%s %s", entry.key, f.textstring(utf8toutf16(entry.val)))
+		}
+	}
+	f.out(">>")
+	f.out("endobj")
+}
+
+// signatureSign fills in the byte range and the signature of the completed
+// document.
+func (f *Fpdf) signatureSign() {
+	sig := f.signature
+	buf := f.buffer.Bytes()
+	contentsEnd := sig.contentsStart + sig.contentsLen
+	copy(buf[sig.byteRangePos:], sprintf(signatureByteRangeFmtStr,
+		sig.contentsStart, contentsEnd, len(buf)-contentsEnd))
+	h := sha256.New()
+	h.Write(buf[:sig.contentsStart])
+	h.Write(buf[contentsEnd:])
+	cms, err := signatureCMS(h.Sum(nil), sig.opts)
+	if err != nil {
+		f.err = err
+		return
+	}
+	hexStr := hex.EncodeToString(cms)
+	if len(hexStr) > sig.contentsLen-2 {
+		f.err = fmt.Errorf("signature size %d exceeds reserved size %d", len(cms), (sig.contentsLen-2)/2)
+		return
+	}
+	copy(buf[sig.contentsStart+1:], hexStr)
+}
+
+// asn1Wrap returns the DER encoding of an element with the specified tag
+// whose content is the concatenation of parts.
+func asn1Wrap(tag byte, parts ...[]byte) []byte {
+	var content []byte
+	for _, p := range parts {
+		content = append(content, p...)
+	}
+	n := len(content)
+	res := []byte{tag}
+	switch {
+	case n < 0x80:
+		res = append(res, byte(n))
+	case n < 0x100:
+		res = append(res, 0x81, byte(n))
+	case n < 0x10000:
+		res = append(res, 0x82, byte(n>>8), byte(n))
+	default:
+		res = append(res, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(res, content...)
+}
+
+// asn1Set returns the DER encoding of a SET OF the specified elements, which
+// are sorted as required by DER.
+func asn1Set(tag byte, elems ...[]byte) []byte {
+	sort.Slice(elems, func(i, j int) bool { return bytes.Compare(elems[i], elems[j]) < 0 })
+	return asn1Wrap(tag, elems...)
+}
+
+func asn1Must(v interface{}) []byte {
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func asn1Attribute(oid asn1.ObjectIdentifier, value []byte) []byte {
+	return asn1Wrap(0x30, asn1Must(oid), asn1Set(0x31, value))
+}
+
+// signatureCMS returns a DER encoded detached CMS SignedData structure for
+// the SHA-256 digest of the document.
+func signatureCMS(digest []byte, opts SignatureOptions) (cms []byte, err error) {
+	cert := opts.Certificates[0]
+	digestAlg := asn1Wrap(0x30, asn1Must(oidSHA256))
+	certHash := sha256.Sum256(cert.Raw)
+	signingCert := asn1Wrap(0x30, asn1Wrap(0x30, asn1Wrap(0x30, asn1Must(certHash[:]))))
+	attrs := [][]byte{
+		asn1Attribute(oidAttrContentType, asn1Must(oidData)),
+		asn1Attribute(oidAttrMessageDigest, asn1Must(digest)),
+		asn1Attribute(oidAttrSigningCertV2, signingCert),
+	}
+	// The signature is computed over the DER encoding of the attributes as
+	// an explicit SET OF
+	signedAttrs := asn1Set(0x31, attrs...)
+	attrDigest := sha256.Sum256(signedAttrs)
+	var sigValue, sigAlg []byte
+	sigValue, err = opts.Signer.Sign(rand.Reader, attrDigest[:], crypto.SHA256)
+	if err != nil {
+		return
+	}
+	if _, ok := opts.Signer.Public().(*rsa.PublicKey); ok {
+		sigAlg = asn1Wrap(0x30, asn1Must(oidRSAEncryption), asn1Null)
+	} else {
+		sigAlg = asn1Wrap(0x30, asn1Must(oidECDSAWithSHA256))
+	}
+	serial := asn1Must(cert.SerialNumber)
+	signerInfo := [][]byte{
+		asn1Must(1),
+		asn1Wrap(0x30, cert.RawIssuer, serial),
+		digestAlg,
+		// [0] IMPLICIT replaces the SET tag
+		append([]byte{0xa0}, signedAttrs[1:]...),
+		sigAlg,
+		asn1Must(sigValue),
+	}
+	if opts.TimestampURL != "" {
+		var token []byte
+		token, err = signatureTimestamp(opts, sigValue)
+		if err != nil {
+			return
+		}
+		signerInfo = append(signerInfo, asn1Set(0xa1, asn1Attribute(oidAttrTimeStampToken, token)))
+	}
+	var certs [][]byte
+	for _, c := range opts.Certificates {
+		certs = append(certs, c.Raw)
+	}
+	signedData := asn1Wrap(0x30,
+		asn1Must(1),
+		asn1Set(0x31, digestAlg),
+		asn1Wrap(0x30, asn1Must(oidData)),
+		asn1Set(0xa0, certs...),
+		asn1Set(0x31, asn1Wrap(0x30, signerInfo...)))
+	cms = asn1Wrap(0x30, asn1Must(oidSignedData), asn1Wrap(0xa0, signedData))
+	return
+}
+
+// signatureTimestamp requests an RFC 3161 time stamp token for the signature
+// value sigValue from the time stamping authority specified in opts.
+func signatureTimestamp(opts SignatureOptions, sigValue []byte) (token []byte, err error) {
+	type messageImprint struct {
+		HashAlgorithm struct {
+			Algorithm asn1.ObjectIdentifier
+		}
+		HashedMessage []byte
+	}
+	type timeStampReq struct {
+		Version        int
+		MessageImprint messageImprint
+		Nonce          *big.Int
+		CertReq        bool
+	}
+	type timeStampResp struct {
+		Status struct {
+			Status       int
+			StatusString []asn1.RawValue `asn1:"optional"`
+			FailInfo     asn1.BitString  `asn1:"optional"`
+		}
+		TimeStampToken asn1.RawValue `asn1:"optional"`
+	}
+	sum := sha256.Sum256(sigValue)
+	req := timeStampReq{Version: 1, CertReq: true}
+	req.MessageImprint.HashAlgorithm.Algorithm = oidSHA256
+	req.MessageImprint.HashedMessage = sum[:]
+	req.Nonce, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return
+	}
+	var reqBuf []byte
+	reqBuf, err = asn1.Marshal(req)
+	if err != nil {
+		return
+	}
+	client := opts.TimestampClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var resp *http.Response
+	resp, err = client.Post(opts.TimestampURL, "application/timestamp-query", bytes.NewReader(reqBuf))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("time stamp request failed: %s", resp.Status)
+		return
+	}
+	var respBuf []byte
+	respBuf, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	var tsResp timeStampResp
+	_, err = asn1.Unmarshal(respBuf, &tsResp)
+	if err != nil {
+		err = fmt.Errorf("invalid time stamp response: %s", err)
+		return
+	}
+	// 0: granted, 1: granted with modifications
+	if tsResp.Status.Status > 1 || len(tsResp.TimeStampToken.FullBytes) == 0 {
+		err = fmt.Errorf("time stamp request rejected with status %d", tsResp.Status.Status)
+		return
+	}
+	token = tsResp.TimeStampToken.FullBytes
+	return
+}
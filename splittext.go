@@ -12,24 +12,44 @@ import (
 // vertical placement purposes.
 func (f *Fpdf) SplitText(txt string, w float64) (lines []string) {
 	cw := f.currentFont.Cw
-	wmax := int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
+	wmax := int(math.Ceil(f.textWidthMax(w)))
+	cs := f.charSpacingUnits()
 	s := []rune(txt) // Return slice of UTF-8 runes
 	nb := len(s)
 	for nb > 0 && s[nb-1] == '\n' {
 		nb--
 	}
 	s = s[0:nb]
+	adv := f.textAdvances(s)
 	sep := -1
 	i := 0
 	j := 0
 	l := 0
 	for i < nb {
 		c := s[i]
-		l += cw[c]
+		l += cs
+		if adv != nil {
+			l += adv[i]
+		} else {
+			l += cw[c]
+			if i > j {
+				l += f.kern(s[i-1], c)
+			}
+		}
 		if unicode.IsSpace(c) || isChinese(c) {
 			sep = i
 		}
 		if c == '\n' || l > wmax {
+			if c != '\n' {
+				if hyph := f.hyphenBreak(s, j, i, wmax); hyph >= 0 {
+					lines = append(lines, string(s[j:hyph])+"-")
+					sep = -1
+					i = hyph
+					j = i
+					l = 0
+					continue
+				}
+			}
 			if sep == -1 {
 				if i == j {
 					i++
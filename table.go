@@ -0,0 +1,448 @@
+package gofpdf
+
+import (
+	"math"
+	"strings"
+)
+
+// TableColumnType describes a column of a table. See TableNew().
+type TableColumnType struct {
+	Width float64 // width of the column in the unit of measure specified in New()
+	Align string  // alignment of the cells of the column, as for TableCellStyleType
+}
+
+// TableCellStyleType describes the appearance of a table cell.
+type TableCellStyleType struct {
+	Border    string  // "" for no border, "1" for a frame, or any combination of "L", "T", "R" and "B"
+	Fill      bool    // paint the background of the cell with FillColor
+	FillColor RGBType // background color
+	TextColor RGBType // text color
+	FontStyle string  // font style, as for SetFont(), applied to the current font family
+	FontSize  float64 // font size in points, or 0 for the font size that is current when the table is output
+	Align     string  // "L", "C" or "R" and "T", "M" or "B"; empty for the alignment of the column, at the top of the cell
+	Padding   float64 // space between the border and the text of the cell
+}
+
+// TableCellType is a cell of a table row.
+type TableCellType struct {
+	Text    string              // text of the cell; lines are broken as by SplitLines()
+	ColSpan int                 // number of columns covered by the cell; 0 is the same as 1
+	RowSpan int                 // number of rows covered by the cell; 0 is the same as 1
+	Style   *TableCellStyleType // style of the cell, or nil for the style of the table or of its header
+}
+
+type tableRowType struct {
+	cells []TableCellType
+}
+
+// TableType is used to build a table whose rows are as high as their
+// tallest cell. Rows are not split across pages: a row that does not fit
+// below the previous one, together with the rows that its cells span, is
+// moved to a new page if AcceptPageBreak() allows it, and the header rows are
+// repeated at the top of the new page. Only a row that is too tall to fit on
+// a page by itself is split, with the lines of its cells divided between the
+// pages; rows joined by a cell that spans them cannot be split, and an error
+// is set if they are too tall for a page.
+type TableType struct {
+	pdf         *Fpdf
+	cols        []TableColumnType
+	header      []tableRowType
+	rows        []tableRowType
+	style       TableCellStyleType
+	headerStyle TableCellStyleType
+	lineHt      float64
+}
+
+// TableNew returns an empty table with the specified columns in the
+// specified PDF file. Header rows are added with AddHeaderRow() and the other
+// rows with AddRow(); the table is output with Render().
+//
+// By default, cells are framed, their padding is the current cell margin
+// (see SetCellMargin()) and header cells are bold. Lines of text are 1.25
+// times as high as their font size.
+func (f *Fpdf) TableNew(cols []TableColumnType) *TableType {
+	t := &TableType{pdf: f, cols: cols, lineHt: 1.25}
+	t.style = TableCellStyleType{Border: "1", Padding: f.cMargin}
+	t.headerStyle = t.style
+	t.headerStyle.FontStyle = "B"
+	return t
+}
+
+// Style returns the style of the cells of the table that are not header
+// cells and have no style of their own.
+func (t *TableType) Style() TableCellStyleType {
+	return t.style
+}
+
+// SetStyle sets the style of the cells of the table that are not header cells
+// and have no style of their own.
+func (t *TableType) SetStyle(style TableCellStyleType) {
+	t.style = style
+}
+
+// HeaderStyle returns the style of the header cells that have no style of
+// their own.
+func (t *TableType) HeaderStyle() TableCellStyleType {
+	return t.headerStyle
+}
+
+// SetHeaderStyle sets the style of the header cells that have no style of
+// their own.
+func (t *TableType) SetHeaderStyle(style TableCellStyleType) {
+	t.headerStyle = style
+}
+
+// SetLineHeight sets the height of the lines of text in the cells as a
+// multiple of their font size. The default is 1.25.
+func (t *TableType) SetLineHeight(factor float64) {
+	t.lineHt = factor
+}
+
+// AddHeaderRow appends a header row to the table. The header rows are output
+// at the top of the table and again after each page break.
+func (t *TableType) AddHeaderRow(cells ...TableCellType) {
+	t.header = append(t.header, tableRowType{cells: cells})
+}
+
+// AddRow appends a row to the table. Cells fill the columns from left to
+// right, skipping the columns covered by cells of previous rows that span
+// several rows.
+func (t *TableType) AddRow(cells ...TableCellType) {
+	t.rows = append(t.rows, tableRowType{cells: cells})
+}
+
+// AddRowStrings appends a row of cells with the specified texts and the
+// style of the table.
+func (t *TableType) AddRowStrings(texts ...string) {
+	cells := make([]TableCellType, len(texts))
+	for j, txt := range texts {
+		cells[j].Text = txt
+	}
+	t.AddRow(cells...)
+}
+
+// tablePlacedType is a cell with its position and lines of text.
+type tablePlacedType struct {
+	style            TableCellStyleType
+	row, col         int
+	rowSpan, colSpan int
+	lines            []string
+	lineHt           float64
+	h                float64 // height needed by the text
+}
+
+// setFont selects the font of a cell in the font family of st.
+func (t *TableType) setFont(style TableCellStyleType, st paraStateType) {
+	size := style.FontSize
+	if size == 0 {
+		size = st.sizePt
+	}
+	t.pdf.SetFont(st.family, style.FontStyle, size)
+}
+
+// place assigns the cells of rows to columns, breaks their text into lines
+// and returns them with the heights of the rows.
+func (t *TableType) place(rows []tableRowType, defStyle TableCellStyleType, st paraStateType) (cells []tablePlacedType, heights []float64) {
+	f := t.pdf
+	occupied := make(map[[2]int]bool)
+	heights = make([]float64, len(rows))
+	for r, row := range rows {
+		col := 0
+		for _, c := range row.cells {
+			for col < len(t.cols) && occupied[[2]int{r, col}] {
+				col++
+			}
+			if col >= len(t.cols) {
+				f.SetErrorf("table row has more cells than columns")
+				return
+			}
+			pc := tablePlacedType{style: defStyle, row: r, col: col, rowSpan: 1, colSpan: 1}
+			if c.Style != nil {
+				pc.style = *c.Style
+			}
+			if c.ColSpan > 1 {
+				pc.colSpan = c.ColSpan
+			}
+			if col+pc.colSpan > len(t.cols) {
+				pc.colSpan = len(t.cols) - col
+			}
+			if c.RowSpan > 1 {
+				pc.rowSpan = c.RowSpan
+			}
+			if r+pc.rowSpan > len(rows) {
+				pc.rowSpan = len(rows) - r
+			}
+			for rr := r; rr < r+pc.rowSpan; rr++ {
+				for cc := col; cc < col+pc.colSpan; cc++ {
+					occupied[[2]int{rr, cc}] = true
+				}
+			}
+			t.setFont(pc.style, st)
+			f.cMargin = pc.style.Padding
+			for _, line := range f.SplitLines([]byte(c.Text), t.width(col, pc.colSpan)) {
+				pc.lines = append(pc.lines, string(line))
+			}
+			pc.lineHt = f.fontSize * t.lineHt
+			n := len(pc.lines)
+			if n == 0 {
+				n = 1
+			}
+			pc.h = float64(n)*pc.lineHt + 2*pc.style.Padding
+			if pc.rowSpan == 1 && pc.h > heights[r] {
+				heights[r] = pc.h
+			}
+			cells = append(cells, pc)
+			col += pc.colSpan
+		}
+	}
+	// Cells that span several rows enlarge the last of them if needed
+	for _, pc := range cells {
+		if pc.rowSpan > 1 {
+			var h float64
+			for _, rh := range heights[pc.row : pc.row+pc.rowSpan] {
+				h += rh
+			}
+			if h < pc.h {
+				heights[pc.row+pc.rowSpan-1] += pc.h - h
+			}
+		}
+	}
+	return
+}
+
+// width returns the width of n columns starting at col.
+func (t *TableType) width(col, n int) (w float64) {
+	for _, c := range t.cols[col : col+n] {
+		w += c.Width
+	}
+	return
+}
+
+// drawRows outputs rows from to to of the placed cells at (x, y).
+func (t *TableType) drawRows(cells []tablePlacedType, heights []float64, from, to int, x, y float64, st paraStateType) {
+	f := t.pdf
+	for _, pc := range cells {
+		if pc.row < from || pc.row >= to {
+			continue
+		}
+		cx := x + t.width(0, pc.col)
+		cy := y
+		for _, rh := range heights[from:pc.row] {
+			cy += rh
+		}
+		w := t.width(pc.col, pc.colSpan)
+		var h float64
+		for _, rh := range heights[pc.row : pc.row+pc.rowSpan] {
+			h += rh
+		}
+		style := pc.style
+		if style.Fill {
+			f.SetFillColor(style.FillColor.R, style.FillColor.G, style.FillColor.B)
+		}
+		f.SetXY(cx, cy)
+		f.CellFormat(w, h, "", style.Border, 0, "", style.Fill, 0, "")
+		alignStr := style.Align
+		if alignStr == "" {
+			alignStr = t.cols[pc.col].Align
+		}
+		textHt := float64(len(pc.lines)) * pc.lineHt
+		ty := cy + style.Padding
+		switch {
+		case strings.Contains(alignStr, "M"):
+			ty = cy + (h-textHt)/2
+		case strings.Contains(alignStr, "B"):
+			ty = cy + h - style.Padding - textHt
+		}
+		alignStr = strings.NewReplacer("T", "", "M", "", "B", "").Replace(alignStr)
+		t.setFont(style, st)
+		f.SetTextColor(style.TextColor.R, style.TextColor.G, style.TextColor.B)
+		f.cMargin = style.Padding
+		for j, line := range pc.lines {
+			f.SetXY(cx, ty+float64(j)*pc.lineHt)
+			f.CellFormat(w, pc.lineHt, line, "", 0, alignStr, false, 0, "")
+		}
+	}
+}
+
+// Height returns the height of the table, including its header rows, if it
+// is output on a single page.
+func (t *TableType) Height() (h float64) {
+	f := t.pdf
+	if f.err != nil {
+		return
+	}
+	st := f.saveParaState()
+	margin := f.cMargin
+	defer func() {
+		f.cMargin = margin
+		f.restoreParaState(st)
+	}()
+	_, headerHeights := t.place(t.header, t.headerStyle, st)
+	_, heights := t.place(t.rows, t.style, st)
+	for _, rh := range append(headerHeights, heights...) {
+		h += rh
+	}
+	return
+}
+
+// Render outputs the table with its left side at the current abscissa,
+// starting at the current ordinate. The current position is then moved below
+// the table, at the same abscissa. The font, colors and cell margin that are
+// current before Render() is called are restored.
+func (t *TableType) Render() {
+	f := t.pdf
+	if f.err != nil {
+		return
+	}
+	if len(t.cols) == 0 {
+		f.SetErrorf("table has no columns")
+		return
+	}
+	st := f.saveParaState()
+	margin, autoBreak, fill := f.cMargin, f.autoPageBreak, f.color.fill
+	x, y := f.x, f.y
+	defer func() {
+		f.cMargin, f.autoPageBreak = margin, autoBreak
+		f.restoreParaState(st)
+		if f.color.fill.str != fill.str {
+			f.color.fill = fill
+			f.out(fill.str)
+		}
+		f.x, f.y = x, y
+	}()
+	headerCells, headerHeights := t.place(t.header, t.headerStyle, st)
+	cells, heights := t.place(t.rows, t.style, st)
+	if f.err != nil {
+		return
+	}
+	var headerHt float64
+	for _, rh := range headerHeights {
+		headerHt += rh
+	}
+	// Rows are kept together here rather than split by CellFormat()
+	draw := func(cells []tablePlacedType, heights []float64, from, to int) {
+		f.autoPageBreak = false
+		t.drawRows(cells, heights, from, to, x, y, st)
+		f.autoPageBreak = autoBreak
+	}
+	// Top of the rows below the header rows of a page, known once a page
+	// break has occurred and the page header has been output
+	bodyTop := f.tMargin + headerHt
+	// breakPage continues the table on a new page, below the page header, if
+	// page breaks are allowed
+	breakPage := func() bool {
+		if f.inHeader || f.inFooter || !f.acceptPageBreak() {
+			return false
+		}
+		f.AddPageFormat(f.curOrientation, f.curPageSize)
+		if f.err != nil {
+			return false
+		}
+		y = f.y
+		draw(headerCells, headerHeights, 0, len(t.header))
+		y += headerHt
+		bodyTop = y
+		return true
+	}
+	draw(headerCells, headerHeights, 0, len(t.header))
+	y += headerHt
+	for from := 0; from < len(t.rows); {
+		// A block is made of the rows spanned by the cells of its first row
+		to := from + 1
+		for _, pc := range cells {
+			if pc.row >= from && pc.row < to && pc.row+pc.rowSpan > to {
+				to = pc.row + pc.rowSpan
+			}
+		}
+		var blockHt float64
+		for _, rh := range heights[from:to] {
+			blockHt += rh
+		}
+		if y+blockHt > f.pageBreakTrigger && blockHt <= f.pageBreakTrigger-bodyTop {
+			if !breakPage() && f.err != nil {
+				return
+			}
+		}
+		// A block that does not fit at the top of a page, or that is taller
+		// than the body of the last page, is split
+		if y+blockHt > f.pageBreakTrigger && (y == bodyTop || blockHt > f.pageBreakTrigger-bodyTop) &&
+			autoBreak && !f.inHeader && !f.inFooter {
+			// The block does not fit on a page by itself
+			if to > from+1 {
+				f.SetErrorf("table rows %d to %d, joined by a cell spanning them, are too tall to fit on a page", from+1, to)
+				return
+			}
+			var row []tablePlacedType
+			for _, pc := range cells {
+				if pc.row == from {
+					pc.row = 0
+					row = append(row, pc)
+				}
+			}
+			for {
+				part, partHt, done := t.splitRow(row, f.pageBreakTrigger-y)
+				if partHt > 0 {
+					draw(part, []float64{partHt}, 0, 1)
+					y += partHt
+				}
+				if done {
+					break
+				}
+				if partHt == 0 && y == bodyTop {
+					f.SetErrorf("line of table row %d is too tall to fit on a page", from+1)
+					return
+				}
+				if !breakPage() {
+					if f.err != nil {
+						return
+					}
+					// Without a page break the rest of the row is drawn
+					// below
+					part, partHt, _ = t.splitRow(row, math.Inf(1))
+					draw(part, []float64{partHt}, 0, 1)
+					y += partHt
+					break
+				}
+			}
+			from = to
+			continue
+		}
+		draw(cells, heights, from, to)
+		y += blockHt
+		from = to
+	}
+}
+
+// splitRow returns the part of the cells of a row that fits in height room,
+// and its height. The lines of the part are removed from the cells. done is
+// true if all lines of the cells are in the part.
+func (t *TableType) splitRow(row []tablePlacedType, room float64) (part []tablePlacedType, partHt float64, done bool) {
+	done, fits := true, false
+	for j := range row {
+		pc := row[j]
+		n := len(pc.lines)
+		if float64(n)*pc.lineHt+2*pc.style.Padding > room+1e-9 {
+			n = int((room - 2*pc.style.Padding) / pc.lineHt)
+			if n < 0 {
+				n = 0
+			}
+			done = false
+		}
+		fits = fits || n > 0
+		pc.lines = pc.lines[:n]
+		part = append(part, pc)
+		row[j].lines = row[j].lines[n:]
+		if h := float64(n)*pc.lineHt + 2*pc.style.Padding; h > partHt {
+			partHt = h
+		}
+	}
+	switch {
+	case !fits && !done:
+		// Nothing fits; the row continues on the next page
+		return nil, 0, false
+	case !done:
+		// The part fills the room
+		partHt = room
+	}
+	return
+}
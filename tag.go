@@ -0,0 +1,475 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// Kinds of structure element content
+const (
+	tagKidElem = iota // child structure element
+	tagKidMCR         // marked-content sequence on a page
+	tagKidOBJR        // link annotation
+)
+
+// Marked-content state of the current page
+const (
+	tagNone     = -2 // no marked-content sequence is open
+	tagArtifact = -1 // content is marked as an artifact
+)
+
+// tagContainerMap holds the standard structure types that group other
+// structure elements rather than holding content themselves.
+var tagContainerMap = map[string]bool{
+	"Document": true, "Part": true, "Art": true, "Sect": true, "Div": true,
+	"BlockQuote": true, "TOC": true, "TOCI": true, "Index": true, "NonStruct": true,
+	"Private": true, "L": true, "LI": true, "LBody": true, "Table": true,
+	"THead": true, "TBody": true, "TFoot": true, "TR": true,
+}
+
+// TagOptions specifies optional attributes of a structure element started
+// with BeginTagExt().
+type TagOptions struct {
+	AltStr        string // alternate description, for example of a figure or formula
+	ActualTextStr string // exact replacement of the content, for example of a hyphenated word
+	LangStr       string // natural language of the content, for example "de-CH"
+}
+
+type tagKidType struct {
+	kind int // tagKidElem, tagKidMCR or tagKidOBJR
+	n    int // element index, MCID or index of the link in the page's links
+	page int // page of marked content or link
+}
+
+type tagElemType struct {
+	tag     string
+	opts    TagOptions
+	parent  int // index of parent element, -1 for the document element
+	kids    []tagKidType
+	page    int  // page of the first content, 0 if none
+	autoRow bool // table row opened by CellFormat(), closed when the row ends
+}
+
+type tagRecType struct {
+	on         bool
+	langStr    string
+	elems      []tagElemType // elems[0] is the document element
+	stack      []int         // indexes of open elements
+	mcElem     int           // element of the open marked-content sequence, tagArtifact or tagNone
+	pageBreak  bool          // set while the page footer and header are output
+	parentTree [][]int       // for each page (1-based), the element of each MCID
+	annotCount int           // number of tagged link annotations
+	rootObj    int           // object number of the structure tree root
+}
+
+// SetLang sets the natural language of the document, for example "en-US".
+// Screen readers and other assistive technology use it to pronounce the
+// text. The language of parts of the document can be set with
+// BeginTagExt().
+func (f *Fpdf) SetLang(langStr string) {
+	f.tag.langStr = langStr
+}
+
+// SetTagged turns the generation of a tagged PDF on or off. A tagged PDF
+// contains a logical structure tree that describes the reading order and the
+// role of the content, for example headings, paragraphs, tables and figures.
+// Tagged documents are required by accessibility standards such as PDF/UA.
+// This method must be called before the first page is added.
+//
+// When tagging is on, text output by Cell(), CellFormat(), MultiCell(),
+// Write(), VCellFormat(), VMultiCell(), TextOnPath() and
+// ParagraphType.Render() is tagged automatically as a paragraph (P), unless
+// it is output within a content element started with BeginTag(). Within a
+// table element (Table, THead, TBody or TFoot) each cell becomes a table cell
+// (TD, or TH in THead) of an automatically started row (TR) that ends when a
+// cell moves to the next line or Ln() is called. Images with
+// ImageOptions.AltText are tagged as figures. Links are tagged as Link
+// elements. Content of the page header and footer, and any other content
+// outside of a content element, is marked as an artifact, that is, content
+// that is not part of the logical structure.
+//
+// The SetTagged() example demonstrates this method.
+func (f *Fpdf) SetTagged(tagged bool) {
+	if f.err != nil {
+		return
+	}
+	if f.page > 0 {
+		f.err = fmt.Errorf("SetTagged must be called before the first page is added")
+		return
+	}
+	f.tag.on = tagged
+	f.tag.elems = []tagElemType{{tag: "Document", parent: -1}}
+	f.tag.stack = []int{0}
+	f.tag.mcElem = tagNone
+	f.tag.parentTree = [][]int{nil}
+}
+
+// BeginTag starts a structure element of the type specified by tagStr. The
+// standard structure types include the grouping elements Document, Part,
+// Art, Sect, Div, BlockQuote, TOC, TOCI, L, LI, LBody, Table, THead, TBody,
+// TFoot and TR, and the content elements P, H, H1 through H6, Lbl, TH, TD,
+// Span, Quote, Note, Reference, Code, Link, Figure, Formula and Caption. The
+// element ends with the matching call to EndTag(). Elements may be nested;
+// the order in which they are started is the reading order of the document.
+// Content elements may span several pages.
+//
+// This method does nothing if tagging has not been turned on with
+// SetTagged(), so the same layout code can produce tagged and untagged
+// documents. It also does nothing in the page header and footer, whose
+// content is always marked as an artifact.
+func (f *Fpdf) BeginTag(tagStr string) {
+	f.BeginTagExt(tagStr, TagOptions{})
+}
+
+// BeginTagExt is like BeginTag() but lets the alternate description,
+// replacement text and language of the element be specified. The alternate
+// description of a Link element is also used as the description of the link
+// annotations that are put within it.
+func (f *Fpdf) BeginTagExt(tagStr string, opts TagOptions) {
+	if f.err != nil || !f.tagActive() {
+		return
+	}
+	if tagStr == "" {
+		f.err = fmt.Errorf("structure type must not be empty")
+		return
+	}
+	f.tag.stack = append(f.tag.stack, f.tagAddElem(tagStr, opts))
+	f.tagSync()
+}
+
+// EndTag ends the structure element started by the most recent unmatched
+// call to BeginTag() or BeginTagExt().
+func (f *Fpdf) EndTag() {
+	if f.err != nil || !f.tagActive() {
+		return
+	}
+	f.tagPopRows()
+	if len(f.tag.stack) < 2 {
+		f.err = fmt.Errorf("EndTag called without a matching BeginTag")
+		return
+	}
+	f.tag.stack = f.tag.stack[:len(f.tag.stack)-1]
+	f.tagSync()
+}
+
+// tagActive returns true if structure elements can currently be started or
+// ended.
+func (f *Fpdf) tagActive() bool {
+	return f.tag.on && !f.inHeader && !f.inFooter
+}
+
+func (f *Fpdf) tagTop() int {
+	return f.tag.stack[len(f.tag.stack)-1]
+}
+
+// tagAddElem appends a new element to the children of the open element and
+// returns its index.
+func (f *Fpdf) tagAddElem(tagStr string, opts TagOptions) int {
+	parent := f.tagTop()
+	idx := len(f.tag.elems)
+	f.tag.elems = append(f.tag.elems, tagElemType{tag: tagStr, opts: opts, parent: parent})
+	f.tag.elems[parent].kids = append(f.tag.elems[parent].kids, tagKidType{kind: tagKidElem, n: idx})
+	return idx
+}
+
+// tagPopRows ends the automatically started table rows at the top of the
+// element stack.
+func (f *Fpdf) tagPopRows() {
+	for len(f.tag.stack) > 1 && f.tag.elems[f.tagTop()].autoRow {
+		f.tag.stack = f.tag.stack[:len(f.tag.stack)-1]
+	}
+}
+
+// tagSync makes sure that the marked-content sequence that is open on the
+// current page belongs to the innermost open content element, or marks the
+// content as an artifact if there is no such element.
+func (f *Fpdf) tagSync() {
+	if !f.tag.on || f.state != 2 {
+		return
+	}
+	want := tagArtifact
+	top := f.tagTop()
+	if !f.tag.pageBreak && !f.inHeader && !f.inFooter && !tagContainerMap[f.tag.elems[top].tag] {
+		want = top
+	}
+	if want == f.tag.mcElem {
+		return
+	}
+	if f.tag.mcElem != tagNone {
+		f.out("EMC")
+	}
+	if want == tagArtifact {
+		f.out("/Artifact BMC")
+	} else {
+		for len(f.tag.parentTree) <= f.page {
+			f.tag.parentTree = append(f.tag.parentTree, nil)
+		}
+		mcid := len(f.tag.parentTree[f.page])
+		f.tag.parentTree[f.page] = append(f.tag.parentTree[f.page], want)
+		el := &f.tag.elems[want]
+		el.kids = append(el.kids, tagKidType{kind: tagKidMCR, n: mcid, page: f.page})
+		if el.page == 0 {
+			el.page = f.page
+		}
+		f.outf("%s <</MCID %d>> BDC", formName(el.tag), mcid)
+	}
+	f.tag.mcElem = want
+}
+
+// tagPageBegin opens the marked content of a new page.
+func (f *Fpdf) tagPageBegin() {
+	f.tag.mcElem = tagNone
+	f.tagSync()
+}
+
+// tagPageEnd closes the marked content of the current page.
+func (f *Fpdf) tagPageEnd() {
+	if f.tag.on && f.tag.mcElem != tagNone {
+		f.out("EMC")
+		f.tag.mcElem = tagNone
+	}
+}
+
+// tagPageBreak is called with true before the page footer is output and with
+// false after the header of the next page has been output.
+func (f *Fpdf) tagPageBreak(brk bool) {
+	f.tag.pageBreak = brk
+	f.tagSync()
+}
+
+// tagEndDoc ends the automatically started table rows and verifies that all
+// other elements have been ended.
+func (f *Fpdf) tagEndDoc() {
+	if !f.tag.on || f.err != nil {
+		return
+	}
+	f.tagPopRows()
+	if len(f.tag.stack) > 1 {
+		f.err = fmt.Errorf("tag %s must be explicitly ended", f.tag.elems[f.tagTop()].tag)
+		return
+	}
+	f.tagSync()
+}
+
+// tagAuto starts the structure element of text output by CellFormat(),
+// MultiCell() or Write() if no content element is open. It returns true if
+// an element was started, in which case tagAutoEnd() must be called after
+// the text has been output. empty indicates that no text is output.
+func (f *Fpdf) tagAuto(empty bool) bool {
+	if !f.tagActive() {
+		return false
+	}
+	el := f.tag.elems[f.tagTop()]
+	switch el.tag {
+	case "Table", "THead", "TBody", "TFoot":
+		f.BeginTag("TR")
+		f.tag.elems[f.tagTop()].autoRow = true
+		el = f.tag.elems[f.tagTop()]
+		fallthrough
+	case "TR":
+		if f.tag.elems[el.parent].tag == "THead" {
+			f.BeginTag("TH")
+		} else {
+			f.BeginTag("TD")
+		}
+		return true
+	}
+	if empty || !tagContainerMap[el.tag] {
+		return false
+	}
+	f.BeginTag("P")
+	return true
+}
+
+// tagAutoEnd ends the element started by tagAuto(). The row of a table cell
+// is ended as well if ln is greater than zero.
+func (f *Fpdf) tagAutoEnd(ln int) {
+	f.EndTag()
+	if ln > 0 {
+		f.tagEndRow()
+	}
+}
+
+// tagEndRow ends an automatically started table row.
+func (f *Fpdf) tagEndRow() {
+	if f.tagActive() && f.tag.elems[f.tagTop()].autoRow {
+		f.tagPopRows()
+		f.tagSync()
+	}
+}
+
+// tagFigure starts a Figure element for an image with the specified
+// alternate description. It returns true if an element was started.
+func (f *Fpdf) tagFigure(altStr string) bool {
+	if !f.tagActive() {
+		return false
+	}
+	el := &f.tag.elems[f.tagTop()]
+	if el.tag == "Figure" {
+		if el.opts.AltStr == "" {
+			el.opts.AltStr = altStr
+		}
+		return false
+	}
+	if altStr == "" {
+		return false
+	}
+	f.BeginTagExt("Figure", TagOptions{AltStr: altStr})
+	return true
+}
+
+// tagLink associates the most recently added link of the current page with a
+// Link element. The open element is used if it is a Link element; otherwise
+// a new one is added to it.
+func (f *Fpdf) tagLink() {
+	if !f.tagActive() {
+		return
+	}
+	idx := f.tagTop()
+	if f.tag.elems[idx].tag != "Link" {
+		idx = f.tagAddElem("Link", TagOptions{})
+	}
+	el := &f.tag.elems[idx]
+	if el.page == 0 {
+		el.page = f.page
+	}
+	n := len(f.pageLinks[f.page]) - 1
+	el.kids = append(el.kids, tagKidType{kind: tagKidOBJR, n: n, page: f.page})
+	f.pageLinks[f.page][n].structElem = idx
+	if f.pageLinks[f.page][n].contentsStr == "" {
+		f.pageLinks[f.page][n].contentsStr = el.opts.AltStr
+	}
+}
+
+// tagAssignObjNums determines the object numbers and structure parent keys
+// of the tagged link annotations, beginning with object number n. The keys of
+// the annotations follow those of the pages.
+func (f *Fpdf) tagAssignObjNums(n int) {
+	if !f.tag.on {
+		return
+	}
+	key := f.page
+	for p := 1; p <= f.page; p++ {
+		for j := range f.pageLinks[p] {
+			pl := &f.pageLinks[p][j]
+			if pl.structElem > 0 {
+				pl.objNum = n
+				pl.structKey = key
+				n++
+				key++
+			}
+		}
+	}
+	f.tag.annotCount = key - f.page
+}
+
+// tagPutAnnotations writes the tagged link annotations in the order used by
+// tagAssignObjNums().
+func (f *Fpdf) tagPutAnnotations() {
+	if !f.tag.on {
+		return
+	}
+	for p := 1; p <= f.page; p++ {
+		for _, pl := range f.pageLinks[p] {
+			if pl.structElem > 0 {
+				f.newobj()
+				var annot fmtBuffer
+				f.putLinkAnnotation(&annot, pl)
+				f.out(annot.String())
+				f.out("endobj")
+			}
+		}
+	}
+}
+
+// tagPutStructTree writes the structure elements, the parent tree and the
+// structure tree root.
+func (f *Fpdf) tagPutStructTree() {
+	if !f.tag.on {
+		return
+	}
+	base := f.n + 1
+	parentTreeObj := base + len(f.tag.elems)
+	f.tag.rootObj = parentTreeObj + 1
+	for j, el := range f.tag.elems {
+		f.newobj()
+		parent := f.tag.rootObj
+		if el.parent >= 0 {
+			parent = base + el.parent
+		}
+		f.outf("<</Type /StructElem /S %s /P %d 0 R", formName(el.tag), parent)
+		if el.page > 0 {
+			f.outf("/Pg %d 0 R", f.pageObjNum(el.page))
+		}
+		if el.tag == "TH" {
+			f.out("/A <</O /Table /Scope /Column>>")
+		}
+		if el.opts.AltStr != "" {
+			f.outf("/Alt %s", f.textstring(utf8toutf16(el.opts.AltStr)))
+		}
+		if el.opts.ActualTextStr != "" {
+			f.outf("/ActualText %s", f.textstring(utf8toutf16(el.opts.ActualTextStr)))
+		}
+		if el.opts.LangStr != "" {
+			f.outf("/Lang %s", f.textstring(el.opts.LangStr))
+		}
+		var kids fmtBuffer
+		for _, kid := range el.kids {
+			switch kid.kind {
+			case tagKidElem:
+				kids.printf("%d 0 R ", base+kid.n)
+			case tagKidMCR:
+				if kid.page == el.page {
+					kids.printf("%d ", kid.n)
+				} else {
+					kids.printf("<</Type /MCR /Pg %d 0 R /MCID %d>> ", f.pageObjNum(kid.page), kid.n)
+				}
+			case tagKidOBJR:
+				kids.printf("<</Type /OBJR /Pg %d 0 R /Obj %d 0 R>> ",
+					f.pageObjNum(kid.page), f.pageLinks[kid.page][kid.n].objNum)
+			}
+		}
+		f.outf("/K [%s]>>", kids.String())
+		f.out("endobj")
+		if f.n != base+j {
+			f.err = fmt.Errorf("unexpected structure element object number %d", f.n)
+			return
+		}
+	}
+	// Parent tree: the elements of the marked content of each page,
+	// followed by the elements of the link annotations
+	f.newobj()
+	var nums fmtBuffer
+	for p := 1; p <= f.page; p++ {
+		nums.printf("%d [", p-1)
+		if p < len(f.tag.parentTree) {
+			for _, idx := range f.tag.parentTree[p] {
+				nums.printf("%d 0 R ", base+idx)
+			}
+		}
+		nums.printf("] ")
+	}
+	for p := 1; p <= f.page; p++ {
+		for _, pl := range f.pageLinks[p] {
+			if pl.structElem > 0 {
+				nums.printf("%d %d 0 R ", pl.structKey, base+pl.structElem)
+			}
+		}
+	}
+	f.outf("<</Nums [%s]>>", nums.String())
+	f.out("endobj")
+	f.newobj()
+	f.outf("<</Type /StructTreeRoot /K %d 0 R /ParentTree %d 0 R /ParentTreeNextKey %d>>",
+		base, parentTreeObj, f.page+f.tag.annotCount)
+	f.out("endobj")
+}
+
+func (f *Fpdf) tagPutCatalog() {
+	if f.tag.langStr != "" {
+		f.outf("/Lang %s", f.textstring(f.tag.langStr))
+	}
+	if f.tag.on {
+		f.out("/MarkInfo <</Marked true>>")
+		f.outf("/StructTreeRoot %d 0 R", f.tag.rootObj)
+		f.out("/ViewerPreferences <</DisplayDocTitle true>>")
+	}
+}
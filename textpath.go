@@ -0,0 +1,347 @@
+package gofpdf
+
+import (
+	"math"
+	"strings"
+)
+
+// PathType records a path built with the same methods as the path drawing
+// methods of Fpdf, so that the path can be drawn with DrawPath() and used to
+// lay out text with TextOnPath(). Coordinates are in the unit of measure
+// specified in New(). The path is stored as a list of absolute SVG segments
+// with the commands 'M', 'L', 'C' and 'Z'.
+type PathType struct {
+	pdf            *Fpdf
+	segs           []SVGBasicSegmentType
+	x, y           float64 // current point
+	startX, startY float64 // start of the current subpath
+}
+
+// PathNew returns an empty path. See PathType.
+func (f *Fpdf) PathNew() *PathType {
+	return &PathType{pdf: f}
+}
+
+// MoveTo starts a new subpath at (x, y). See Fpdf.MoveTo().
+func (p *PathType) MoveTo(x, y float64) {
+	p.segs = append(p.segs, SVGBasicSegmentType{Cmd: 'M', Arg: [6]float64{x, y}})
+	p.x, p.y, p.startX, p.startY = x, y, x, y
+}
+
+// LineTo adds a line from the current point to (x, y). See Fpdf.LineTo().
+func (p *PathType) LineTo(x, y float64) {
+	p.segs = append(p.segs, SVGBasicSegmentType{Cmd: 'L', Arg: [6]float64{x, y}})
+	p.x, p.y = x, y
+}
+
+// CurveTo adds a curve from the current point to (x, y) with the control
+// point (cx, cy), drawn as by Fpdf.CurveTo().
+func (p *PathType) CurveTo(cx, cy, x, y float64) {
+	p.CurveBezierCubicTo(p.x, p.y, cx, cy, x, y)
+}
+
+// CurveBezierCubicTo adds a cubic Bézier curve from the current point to
+// (x, y) with the control points (cx0, cy0) and (cx1, cy1). See
+// Fpdf.CurveBezierCubicTo().
+func (p *PathType) CurveBezierCubicTo(cx0, cy0, cx1, cy1, x, y float64) {
+	p.segs = append(p.segs, SVGBasicSegmentType{Cmd: 'C', Arg: [6]float64{cx0, cy0, cx1, cy1, x, y}})
+	p.x, p.y = x, y
+}
+
+// ArcTo adds an elliptical arc centered at (x, y), preceded by a line from
+// the current point to the start of the arc if they differ. The arguments
+// are those of Fpdf.ArcTo(); the arc is made of the same Bézier curves.
+func (p *PathType) ArcTo(x, y, rx, ry, degRotate, degStart, degEnd float64) {
+	segments := int(degEnd-degStart) / 60
+	if segments < 2 {
+		segments = 2
+	}
+	rot := degRotate * math.Pi / 180
+	// point returns the point of the arc at angle t and the derivative of the
+	// arc there, with the ordinate pointing down the page
+	point := func(t float64) (px, py, dx, dy float64) {
+		u, v := rx*math.Cos(t), ry*math.Sin(t)
+		du, dv := -rx*math.Sin(t), ry*math.Cos(t)
+		sin, cos := math.Sincos(rot)
+		return x + u*cos - v*sin, y - u*sin - v*cos, du*cos - dv*sin, -du*sin - dv*cos
+	}
+	angleStart := degStart * math.Pi / 180
+	dt := (degEnd - degStart) * math.Pi / 180 / float64(segments)
+	dtm := dt / 3
+	x0, y0, dx0, dy0 := point(angleStart)
+	if len(p.segs) == 0 {
+		p.MoveTo(x0, y0)
+	} else if p.x != x0 || p.y != y0 {
+		p.LineTo(x0, y0)
+	}
+	for j := 1; j <= segments; j++ {
+		x1, y1, dx1, dy1 := point(angleStart + float64(j)*dt)
+		p.CurveBezierCubicTo(x0+dx0*dtm, y0+dy0*dtm, x1-dx1*dtm, y1-dy1*dtm, x1, y1)
+		x0, y0, dx0, dy0 = x1, y1, dx1, dy1
+	}
+}
+
+// ClosePath adds a line from the current point to the start of the current
+// subpath and closes the subpath. See Fpdf.ClosePath().
+func (p *PathType) ClosePath() {
+	p.segs = append(p.segs, SVGBasicSegmentType{Cmd: 'Z'})
+	p.x, p.y = p.startX, p.startY
+}
+
+// Segments returns the segments of the path, which can be passed to
+// TextOnPath().
+func (p *PathType) Segments() []SVGBasicSegmentType {
+	return p.segs
+}
+
+// DrawPath draws the path. styleStr is as for Fpdf.DrawPath(). As with
+// Fpdf.MoveTo(), the current position is moved along the path.
+func (p *PathType) DrawPath(styleStr string) {
+	f := p.pdf
+	if f.err != nil || len(p.segs) == 0 {
+		return
+	}
+	for _, seg := range p.segs {
+		a := seg.Arg
+		switch seg.Cmd {
+		case 'M':
+			f.MoveTo(a[0], a[1])
+		case 'L':
+			f.LineTo(a[0], a[1])
+		case 'C':
+			f.CurveBezierCubicTo(a[0], a[1], a[2], a[3], a[4], a[5])
+		case 'Z':
+			f.ClosePath()
+		}
+	}
+	f.DrawPath(styleStr)
+}
+
+// ClipPath begins a clipping operation in which rendering is confined to the
+// inside of the path, as determined by the nonzero winding number rule.
+// outline is true to draw a border with the current draw color and line
+// width centered on the path. Only the outer half of the border will be
+// shown. Call Fpdf.ClipEnd() to restore unclipped operations.
+func (p *PathType) ClipPath(outline bool) {
+	f := p.pdf
+	if f.err != nil {
+		return
+	}
+	f.clipNest++
+	var s fmtBuffer
+	s.printf("q")
+	for _, seg := range p.segs {
+		a := seg.Arg
+		switch seg.Cmd {
+		case 'M':
+			s.printf(" %.5f %.5f m", a[0]*f.k, (f.h-a[1])*f.k)
+		case 'L':
+			s.printf(" %.5f %.5f l", a[0]*f.k, (f.h-a[1])*f.k)
+		case 'C':
+			s.printf(" %.5f %.5f %.5f %.5f %.5f %.5f c", a[0]*f.k, (f.h-a[1])*f.k,
+				a[2]*f.k, (f.h-a[3])*f.k, a[4]*f.k, (f.h-a[5])*f.k)
+		case 'Z':
+			s.printf(" h")
+		}
+	}
+	s.printf(" W %s", strIf(outline, "S", "n"))
+	f.out(s.String())
+}
+
+// TextPathOptions specifies how TextOnPath() lays text along a path.
+type TextPathOptions struct {
+	Align  string  // "L", "C" or "R" to align the text with the start, the middle or the end of the path; empty for "L"
+	Offset float64 // distance by which the text is moved along the path from its aligned position; negative values move it back
+	Rise   float64 // distance between the path and the baseline of the text; positive values raise the text
+}
+
+// pathPieceType is a straight piece of a flattened path.
+type pathPieceType struct {
+	x, y   float64 // start of the piece
+	dx, dy float64 // unit vector in the direction of the piece
+	len    float64
+}
+
+// pathPieces flattens the segments of a path into straight pieces. The moves
+// between subpaths are not part of the path.
+func (f *Fpdf) pathPieces(segs []SVGBasicSegmentType) (pieces []pathPieceType) {
+	// Number of pieces of a curve
+	const steps = 24
+	var x, y, startX, startY float64
+	line := func(x1, y1 float64) {
+		if l := math.Hypot(x1-x, y1-y); l > 0 {
+			pieces = append(pieces, pathPieceType{x, y, (x1 - x) / l, (y1 - y) / l, l})
+		}
+		x, y = x1, y1
+	}
+	cubic := func(cx0, cy0, cx1, cy1, x1, y1 float64) {
+		x0, y0 := x, y
+		for j := 1; j <= steps; j++ {
+			t := float64(j) / steps
+			u := 1 - t
+			line(u*u*u*x0+3*u*u*t*cx0+3*u*t*t*cx1+t*t*t*x1,
+				u*u*u*y0+3*u*u*t*cy0+3*u*t*t*cy1+t*t*t*y1)
+		}
+	}
+	for _, seg := range segs {
+		a := seg.Arg
+		switch seg.Cmd {
+		case 'M':
+			x, y, startX, startY = a[0], a[1], a[0], a[1]
+		case 'L':
+			line(a[0], a[1])
+		case 'H':
+			line(a[0], y)
+		case 'V':
+			line(x, a[0])
+		case 'C':
+			cubic(a[0], a[1], a[2], a[3], a[4], a[5])
+		case 'Q':
+			// Quadratic curve raised to a cubic one
+			cubic(x+(a[0]-x)*2/3, y+(a[1]-y)*2/3, a[2]+(a[0]-a[2])*2/3, a[3]+(a[1]-a[3])*2/3, a[2], a[3])
+		case 'Z':
+			line(startX, startY)
+		default:
+			f.SetErrorf("Unexpected path command '%c'", seg.Cmd)
+			return nil
+		}
+	}
+	return
+}
+
+// pathPoint returns the point at distance s along the pieces of a path, and
+// the unit vector of the direction of the path there. Distances before the
+// start and after the end of the path extend its first and last pieces.
+func pathPoint(pieces []pathPieceType, s float64) (x, y, dx, dy float64) {
+	pc := pieces[0]
+	for j := 0; s > 0 && j < len(pieces); j++ {
+		pc = pieces[j]
+		if s <= pc.len || j == len(pieces)-1 {
+			break
+		}
+		s -= pc.len
+	}
+	return pc.x + pc.dx*s, pc.y + pc.dy*s, pc.dx, pc.dy
+}
+
+// TextOnPath lays txtStr along a path with the current font, text color and
+// character spacing. Each character is rotated so that its baseline is
+// tangent to the path at the middle of the character. segs is the list of
+// segments returned by PathType.Segments(), or a list of absolute segments
+// such as those of an SVGBasicType, whose coordinates are taken in the unit
+// of measure specified in New(). The commands 'M', 'L', 'H', 'V', 'C', 'Q' and
+// 'Z' are supported; text skips the moves between subpaths.
+//
+// opts specifies the alignment of the text with the path, its offset along
+// the path and the distance of its baseline from the path. Text that
+// extends beyond the start or the end of the path continues in the direction
+// of the path there. Characters are placed individually, separated by their
+// widths with kerning and character spacing. Characters that the current
+// font lacks are shown in its fallback fonts (see SetFontFallback()), but
+// text is not shaped (see SetTextShaping()), so complex scripts are shown
+// with the unshaped glyphs of their characters. The current position is not
+// changed.
+//
+// The TextOnPath() example demonstrates this method.
+func (f *Fpdf) TextOnPath(txtStr string, segs []SVGBasicSegmentType, opts TextPathOptions) {
+	if f.err != nil {
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.SetErrorf("font has not been set; unable to render text")
+		return
+	}
+	pieces := f.pathPieces(segs)
+	if f.err != nil || len(pieces) == 0 || txtStr == "" {
+		return
+	}
+	if f.tagAuto(false) {
+		defer f.tagAutoEnd(0)
+	}
+	// The kerning of each character with the previous one moves it along the
+	// path; its advance includes the character spacing. Characters are shown
+	// in the fonts of their fallback runs and measured unshaped, as they are
+	// placed one by one.
+	var chars []string
+	var fonts []fontDefType
+	var kerns, advs []float64
+	scale := f.fontSize / 1000 * f.hScaling / 100
+	cs := f.charSpacingUnits()
+	if f.isCurrentUTF8 {
+		txt := []rune(txtStr)
+		runs := f.fallbackRuns(txt)
+		if runs == nil {
+			runs = []fallbackRunType{{text: txt, font: f.currentFont}}
+		}
+		for _, run := range runs {
+			f.withFont(run.font, func() {
+				for j, r := range run.text {
+					chars = append(chars, string(r))
+					fonts = append(fonts, run.font)
+					f.currentFont.usedRunes[int(r)] = int(r)
+					kern := 0
+					if j > 0 {
+						kern = f.kern(run.text[j-1], r)
+					}
+					kerns = append(kerns, float64(kern)*scale)
+					advs = append(advs, float64(f.runeWidth(r)+cs)*scale)
+				}
+			})
+		}
+	} else {
+		for j := 0; j < len(txtStr); j++ {
+			chars = append(chars, txtStr[j:j+1])
+			kerns = append(kerns, 0)
+			advs = append(advs, float64(f.currentFont.Cw[txtStr[j]]+cs)*scale)
+		}
+	}
+	var total, width float64
+	for _, pc := range pieces {
+		total += pc.len
+	}
+	for j := range chars {
+		width += kerns[j] + advs[j]
+	}
+	s := opts.Offset
+	switch strings.ToUpper(opts.Align) {
+	case "C":
+		s += (total - width) / 2
+	case "R":
+		s += total - width
+	}
+	var b strings.Builder
+	b.WriteString("BT")
+	font := f.currentFont
+	wd := 0.0
+	for j, ch := range chars {
+		if fonts != nil && fonts[j].Name != font.Name {
+			font = fonts[j]
+			b.WriteString(sprintf(" /F%s %.2f Tf", font.i, f.fontSizePt))
+		}
+		wd += kerns[j]
+		adv := advs[j]
+		x, y, dx, dy := pathPoint(pieces, s+wd+adv/2)
+		// The origin of the character is half its advance back along the
+		// tangent, raised perpendicularly to it
+		ox, oy := x-dx*adv/2+dy*opts.Rise, y-dy*adv/2-dx*opts.Rise
+		var txt string
+		if f.isCurrentUTF8 {
+			txt = f.escape(utf8toutf16(ch, false))
+		} else {
+			txt = f.escape(ch)
+		}
+		// The ordinate of the page points up; 0 - dy is never -0
+		b.WriteString(sprintf(" %.5f %.5f %.5f %.5f %.2f %.2f Tm (%s) Tj",
+			dx, 0-dy, dy, dx, ox*f.k, (f.h-oy)*f.k, txt))
+		wd += adv
+	}
+	if font.Name != f.currentFont.Name {
+		b.WriteString(sprintf(" /F%s %.2f Tf", f.currentFont.i, f.fontSizePt))
+	}
+	b.WriteString(" ET")
+	str := b.String()
+	if f.colorFlag {
+		str = sprintf("q %s %s Q", f.color.text.str, str)
+	}
+	f.out(str)
+}
@@ -0,0 +1,419 @@
+package gofpdf
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// TocOptions specifies how the table of contents is output. See ReserveToc()
+// and InsertToc().
+type TocOptions struct {
+	Title      string  // title printed at the top of the table, or empty for none
+	FontFamily string  // font family of the table, or empty for the font family that is current when the table is set up
+	FontSize   float64 // font size in points of the entries, or 0 for the font size that is current when the table is set up
+	Indent     float64 // indentation of each level of entries, in the unit of measure specified in New()
+	LineHt     float64 // height of the lines of the entries, or 0 for 1.5 times the font size
+	Leader     string  // string repeated between the text of an entry and its page number; empty for "."
+}
+
+// TocEntryType is an entry of the table of contents. See TocEntry().
+type TocEntryType struct {
+	Text  string // text of the entry
+	Level int    // level of the entry; 0 is the top level
+	Page  int    // page of the entry
+	Link  int    // internal link to the position of the entry, as returned by AddLink()
+}
+
+type tocEntryType struct {
+	text  string
+	level int
+	link  int
+}
+
+// tocRecType holds the entries and the setup of the table of contents.
+type tocRecType struct {
+	entries []tocEntryType
+	mode    int // tocNone, tocReserved or tocInserted
+	opts    TocOptions
+	family  string
+	sizePt  float64
+	pages   []int     // pages of the table
+	tops    []float64 // ordinate below the header of each page of the table
+	at      int       // page before which the table is inserted
+}
+
+const (
+	tocNone = iota
+	tocReserved
+	tocInserted
+)
+
+// tocPlaceType is the position of the title or of an entry in the table.
+type tocPlaceType struct {
+	entry int // index of the entry, -1 for the title
+	idx   int // index of the page in the pages of the table
+	y     float64
+	lines []string
+}
+
+// TocEntry adds an entry with the specified text and level to the table of
+// contents. The entry refers to the current position on the current page; an
+// internal link to this position is created for it. Like Bookmark(), this
+// method is usually called when a chapter or section heading is output.
+func (f *Fpdf) TocEntry(txtStr string, level int) {
+	if f.err != nil {
+		return
+	}
+	if f.page <= 0 {
+		f.SetErrorf("cannot add table of contents entry %s without first adding a page", txtStr)
+		return
+	}
+	link := f.AddLink()
+	f.SetLink(link, -1, -1)
+	f.toc.entries = append(f.toc.entries, tocEntryType{text: txtStr, level: level, link: link})
+}
+
+// TocEntries returns the entries added with TocEntry(). The page numbers
+// are final once the document is closed, after the pages of a table of
+// contents set up with InsertToc() have been inserted.
+func (f *Fpdf) TocEntries() (list []TocEntryType) {
+	for _, e := range f.toc.entries {
+		list = append(list, TocEntryType{Text: e.text, Level: e.level, Page: f.links[e.link].page, Link: e.link})
+	}
+	return
+}
+
+// ReserveToc adds the specified number of pages to the document, in which
+// the table of contents is output when the document is closed. Entries are
+// added with TocEntry(). The table starts below the header of the first
+// reserved page. Use this method when the number of pages needed by the table
+// is known in advance; it is an error for the table to need more pages.
+//
+// Each entry of the table is output with its text, indented according to its
+// level, followed by a leader and its page number, which is right aligned at
+// the right margin. Entries of level 0 are bold. Text that does not fit is
+// wrapped on several lines. The entries link to their position in the
+// document.
+//
+// Only one table of contents can be set up with ReserveToc() or InsertToc().
+// The ReserveToc() example demonstrates this method.
+func (f *Fpdf) ReserveToc(pages int, opts TocOptions) {
+	if !f.tocSetup(opts) {
+		return
+	}
+	if pages < 1 {
+		f.SetErrorf("at least one page must be reserved for the table of contents")
+		return
+	}
+	f.toc.mode = tocReserved
+	for j := 0; j < pages && f.err == nil; j++ {
+		f.AddPage()
+		f.toc.pages = append(f.toc.pages, f.page)
+		f.toc.tops = append(f.toc.tops, f.y)
+	}
+}
+
+// InsertToc arranges for the table of contents to be output on new pages
+// when the document is closed, and for these pages to be inserted before the
+// specified page. The pages at this position and after it, and the page
+// numbers of the entries on them, are shifted by the number of pages of the
+// table. If page is less than 1 or greater than the number of pages, the
+// table is output after the last page. See ReserveToc() for the appearance of
+// the table.
+//
+// Page numbers printed on pages with PageNo(), for example in a footer, are
+// not updated when pages are inserted. Use ReserveToc() in that case.
+func (f *Fpdf) InsertToc(page int, opts TocOptions) {
+	if !f.tocSetup(opts) {
+		return
+	}
+	f.toc.mode = tocInserted
+	f.toc.at = page
+}
+
+// tocSetup records the options of the table of contents and the current
+// font, and returns false if the table cannot be set up.
+func (f *Fpdf) tocSetup(opts TocOptions) bool {
+	if f.err != nil {
+		return false
+	}
+	if f.toc.mode != tocNone {
+		f.SetErrorf("table of contents has already been set up")
+		return false
+	}
+	f.toc.opts = opts
+	f.toc.family, f.toc.sizePt = opts.FontFamily, opts.FontSize
+	if f.toc.family == "" {
+		f.toc.family = f.fontFamily
+	}
+	if f.toc.sizePt == 0 {
+		f.toc.sizePt = f.fontSizePt
+	}
+	if f.toc.family == "" {
+		f.SetErrorf("font family of the table of contents is not set")
+		return false
+	}
+	return true
+}
+
+// tocSetPage makes page n, which has already been added, the current page.
+func (f *Fpdf) tocSetPage(n int) {
+	sz, ok := f.pageSizes[n]
+	if !ok {
+		if f.defOrientation == "P" {
+			sz = SizeType{f.defPageSize.Wd * f.k, f.defPageSize.Ht * f.k}
+		} else {
+			sz = SizeType{f.defPageSize.Ht * f.k, f.defPageSize.Wd * f.k}
+		}
+	}
+	f.page = n
+	f.wPt, f.hPt = sz.Wd, sz.Ht
+	f.w, f.h = sz.Wd/f.k, sz.Ht/f.k
+	f.pageBreakTrigger = f.h - f.bMargin
+	// Font and text state are output again on this page
+	f.fontFamily = ""
+}
+
+// tocFont selects the font of the title (level -1) or of an entry.
+func (f *Fpdf) tocFont(level int) {
+	switch {
+	case level < 0:
+		f.SetFont(f.toc.family, "B", f.toc.sizePt*1.5)
+	case level == 0:
+		f.SetFont(f.toc.family, "B", f.toc.sizePt)
+	default:
+		f.SetFont(f.toc.family, "", f.toc.sizePt)
+	}
+}
+
+// tocPut outputs the table of contents as the document is closed.
+func (f *Fpdf) tocPut() {
+	toc := &f.toc
+	if f.err != nil || toc.mode == tocNone {
+		return
+	}
+	st := f.saveParaState()
+	page, x, y := f.page, f.x, f.y
+	w, h, wPt, hPt, trigger := f.w, f.h, f.wPt, f.hPt, f.pageBreakTrigger
+	cs, hs, rise := f.charSpacing, f.hScaling, f.textRise
+	autoBreak := f.autoPageBreak
+	f.SetColumns(0, 0)
+	f.charSpacing, f.hScaling, f.textRise = 0, 100, 0
+	f.autoPageBreak = false
+	opts := toc.opts
+	if opts.Leader == "" {
+		opts.Leader = "."
+	}
+	if opts.LineHt == 0 {
+		opts.LineHt = 1.5 * toc.sizePt / f.k
+	}
+	// Lay out the title and the entries on the pages of the table
+	if toc.mode == tocInserted {
+		f.AddPage()
+		toc.pages = []int{f.page}
+		toc.tops = []float64{f.y}
+	} else {
+		f.tocSetPage(toc.pages[0])
+	}
+	idx := 0
+	pos := toc.tops[0]
+	var places []tocPlaceType
+	place := func(entry int, lines []string, ht float64) bool {
+		if pos+ht > f.pageBreakTrigger && pos > toc.tops[idx] {
+			idx++
+			if toc.mode == tocInserted {
+				f.AddPage()
+				toc.pages = append(toc.pages, f.page)
+				toc.tops = append(toc.tops, f.y)
+			} else if idx >= len(toc.pages) {
+				f.SetErrorf("table of contents needs more than %d reserved pages", len(toc.pages))
+				return false
+			} else {
+				f.tocSetPage(toc.pages[idx])
+			}
+			pos = toc.tops[idx]
+		}
+		places = append(places, tocPlaceType{entry: entry, idx: idx, y: pos, lines: lines})
+		pos += ht
+		return true
+	}
+	if opts.Title != "" {
+		f.tocFont(-1)
+		place(-1, []string{opts.Title}, 2*f.fontSize)
+	}
+	numStr := strings.Repeat("0", len(strconv.Itoa(f.PageCount()+len(toc.entries)+1)))
+	for j, e := range toc.entries {
+		f.tocFont(e.level)
+		left := f.lMargin + float64(e.level)*opts.Indent
+		textW := f.w - f.rMargin - left - f.GetStringWidth(strings.Repeat(opts.Leader, 3)+" "+numStr)
+		var lines []string
+		if f.isCurrentUTF8 {
+			lines = f.SplitText(e.text, textW)
+		} else {
+			for _, line := range f.SplitLines([]byte(e.text), textW) {
+				lines = append(lines, string(line))
+			}
+		}
+		if len(lines) == 0 {
+			lines = []string{""}
+		}
+		if !place(j, lines, float64(len(lines))*opts.LineHt) {
+			break
+		}
+	}
+	// Output the title and the entries now that their pages are known. The
+	// pages of an inserted table are moved by tocMove() once the footer of
+	// its last page has been output.
+	pageNum := func(p int) int { return p }
+	if f.tocMoving() {
+		from, n, to := toc.pages[0], len(toc.pages), toc.at
+		pageNum = func(p int) int { return movedPage(p, from, n, to) }
+	}
+	cur := -1
+	for _, pl := range places {
+		if f.err != nil {
+			break
+		}
+		if pl.idx != cur {
+			if cur >= 0 {
+				f.out("Q")
+			}
+			cur = pl.idx
+			f.tocSetPage(toc.pages[cur])
+			f.out("q 0 Tw 0 Tc 100 Tz 0 Ts")
+		}
+		if pl.entry < 0 {
+			f.tocFont(-1)
+			f.SetXY(f.lMargin, pl.y)
+			f.CellFormat(0, f.fontSize, pl.lines[0], "", 0, "L", false, 0, "")
+			continue
+		}
+		e := toc.entries[pl.entry]
+		f.tocFont(e.level)
+		left := f.lMargin + float64(e.level)*opts.Indent
+		wd := f.w - f.rMargin - left
+		for j, line := range pl.lines {
+			f.SetXY(left, pl.y+float64(j)*opts.LineHt)
+			if j < len(pl.lines)-1 {
+				f.CellFormat(wd, opts.LineHt, line, "", 0, "L", false, e.link, "")
+				continue
+			}
+			tw := f.GetStringWidth(line) + 2*f.cMargin
+			f.CellFormat(tw, opts.LineHt, line, "", 0, "L", false, e.link, "")
+			num := " " + strconv.Itoa(pageNum(f.links[e.link].page))
+			count := int((wd - tw - f.GetStringWidth(num) - 2*f.cMargin) / f.GetStringWidth(opts.Leader))
+			if count < 0 {
+				count = 0
+			}
+			f.CellFormat(wd-tw, opts.LineHt, strings.Repeat(opts.Leader, count)+num, "", 0, "R", false, e.link, "")
+		}
+	}
+	if cur >= 0 {
+		f.out("Q")
+	}
+	if toc.mode == tocInserted {
+		// The last page of the table is the current page
+		f.tocSetPage(toc.pages[len(toc.pages)-1])
+		x, y = f.lMargin, f.tMargin
+	} else {
+		f.page = page
+		f.w, f.h, f.wPt, f.hPt, f.pageBreakTrigger = w, h, wPt, hPt, trigger
+		f.fontFamily = ""
+	}
+	f.restoreParaState(st)
+	f.charSpacing, f.hScaling, f.textRise = cs, hs, rise
+	f.autoPageBreak = autoBreak
+	f.x, f.y = x, y
+}
+
+// tocMoving returns true if the pages of the table of contents are to be
+// moved before the page specified with InsertToc().
+func (f *Fpdf) tocMoving() bool {
+	toc := &f.toc
+	return toc.mode == tocInserted && len(toc.pages) > 0 && toc.at >= 1 && toc.at < toc.pages[0]
+}
+
+// tocMove inserts the pages of the table of contents at their final
+// position once the document is complete.
+func (f *Fpdf) tocMove() {
+	if f.err == nil && f.tocMoving() {
+		f.movePages(f.toc.pages[0], len(f.toc.pages), f.toc.at)
+		for j := range f.toc.pages {
+			f.toc.pages[j] = f.toc.at + j
+		}
+	}
+}
+
+// movedPage returns the number of page p after the n pages that start at
+// page from have been moved to page to.
+func movedPage(p, from, n, to int) int {
+	switch {
+	case p < to || p >= from+n:
+		return p
+	case p < from:
+		return p + n
+	}
+	return to + p - from
+}
+
+// movePages moves the n pages that start at page from so that they start at
+// page to, which is less than from. The pages in between are shifted, and
+// the links, bookmarks, form fields and structure elements that refer to
+// them follow them.
+func (f *Fpdf) movePages(from, n, to int) {
+	count := len(f.pages) - 1
+	newPage := make([]int, count+1)
+	for p := 1; p <= count; p++ {
+		newPage[p] = movedPage(p, from, n, to)
+	}
+	pages := make([]*bytes.Buffer, count+1)
+	pageLinks := make([][]linkType, count+1)
+	pageAttachments := make([][]annotationAttach, count+1)
+	pageSizes := make(map[int]SizeType)
+	pageBoxes := make(map[int]map[string]PageBox)
+	for p := 1; p <= count; p++ {
+		np := newPage[p]
+		pages[np], pageLinks[np], pageAttachments[np] = f.pages[p], f.pageLinks[p], f.pageAttachments[p]
+		if sz, ok := f.pageSizes[p]; ok {
+			pageSizes[np] = sz
+		}
+		if pb, ok := f.pageBoxes[p]; ok {
+			pageBoxes[np] = pb
+		}
+	}
+	pages[0], pageLinks[0], pageAttachments[0] = f.pages[0], f.pageLinks[0], f.pageAttachments[0]
+	f.pages, f.pageLinks, f.pageAttachments = pages, pageLinks, pageAttachments
+	f.pageSizes, f.pageBoxes = pageSizes, pageBoxes
+	for j := range f.links {
+		if p := f.links[j].page; p > 0 && p <= count {
+			f.links[j].page = newPage[p]
+		}
+	}
+	for j := range f.outlines {
+		if p := f.outlines[j].p; p > 0 && p <= count {
+			f.outlines[j].p = newPage[p]
+		}
+	}
+	for j := range f.form.fields {
+		f.form.fields[j].page = newPage[f.form.fields[j].page]
+	}
+	if len(f.tag.parentTree) > 0 {
+		parentTree := make([][]int, count+1)
+		for p := 1; p < len(f.tag.parentTree) && p <= count; p++ {
+			parentTree[newPage[p]] = f.tag.parentTree[p]
+		}
+		f.tag.parentTree = parentTree
+	}
+	for j := range f.tag.elems {
+		el := &f.tag.elems[j]
+		if el.page > 0 {
+			el.page = newPage[el.page]
+		}
+		for k := range el.kids {
+			if el.kids[k].kind != tagKidElem && el.kids[k].page > 0 {
+				el.kids[k].page = newPage[el.kids[k].page]
+			}
+		}
+	}
+}
@@ -55,6 +55,11 @@ type utf8FontFile struct {
 	DefaultWidth         float64
 	symbolData           map[int]map[string][]int
 	CodeSymbolDictionary map[int]int
+	kern                 *kernTableType
+	shaper               *otShaperType
+	shapedGlyphs         map[int]int // glyph IDs of the CIDs allocated to shaped glyphs
+	vert                 *vertMetricsType
+	outline              *outlineFontType
 }
 
 type tableDescription struct {
@@ -102,16 +107,19 @@ func (utf *utf8FontFile) parseFile() error {
 	utf.Ascent = 0
 	utf.Descent = 0
 	codeType := uint32(utf.readUint32())
-	if codeType == 0x4F54544F {
-		return fmt.Errorf("not supported\n ")
-	}
 	if codeType == 0x74746366 {
 		return fmt.Errorf("not supported\n ")
 	}
-	if codeType != 0x00010000 && codeType != 0x74727565 {
+	if codeType != 0x00010000 && codeType != 0x74727565 && codeType != 0x4F54544F {
 		return fmt.Errorf("Not a TrueType font: codeType=%v\n ", codeType)
 	}
 	utf.generateTableDescriptions()
+	if codeType == 0x4F54544F {
+		// OpenType font with CFF outlines
+		if _, err := parseCFF(utf.getTableData("CFF ")); err != nil {
+			return err
+		}
+	}
 	utf.parseTables()
 	return nil
 }
@@ -492,6 +500,8 @@ func (utf *utf8FontFile) parseTables() {
 
 	scale := 1000.0 / float64(utf.fontElementSize)
 	utf.parseHMTXTable(n, numSymbols, symbolCharDictionary, scale)
+	utf.charSymbolDictionary = charSymbolDictionary
+	utf.parseKerning()
 }
 
 func (utf *utf8FontFile) generateCMAP() map[int][]int {
@@ -528,16 +538,13 @@ func (utf *utf8FontFile) generateCMAP() map[int][]int {
 	return symbolCharDictionary
 }
 
-func (utf *utf8FontFile) parseSymbols(usedRunes map[int]int) (map[int]int, map[int]int, map[int]int, []int) {
+func (utf *utf8FontFile) parseSymbols(cidSymbols map[int]int) (map[int]int, map[int]int, map[int]int, []int) {
 	symbolCollection := map[int]int{0: 0}
 	charSymbolPairCollection := make(map[int]int)
-	for _, char := range usedRunes {
-		if _, OK := utf.charSymbolDictionary[char]; OK {
-			symbolCollection[utf.charSymbolDictionary[char]] = char
-			charSymbolPairCollection[char] = utf.charSymbolDictionary[char]
-
-		}
-		utf.LastRune = max(utf.LastRune, char)
+	for cid, symbol := range cidSymbols {
+		symbolCollection[symbol] = cid
+		charSymbolPairCollection[cid] = symbol
+		utf.LastRune = max(utf.LastRune, cid)
 	}
 
 	begin := utf.tableDescriptions["glyf"].position
@@ -636,6 +643,7 @@ func (utf *utf8FontFile) generateCMAPTable(cidSymbolPairCollection map[int]int,
 }
 
 //GenerateCutFont fill utf8FontFile from .utf file, only with runes from usedRunes
+//and the glyphs of the CIDs allocated by text shaping
 func (utf *utf8FontFile) GenerateCutFont(usedRunes map[int]int) []byte {
 	utf.fileReader.readerPosition = 0
 	utf.symbolPosition = make([]int, 0)
@@ -668,9 +676,13 @@ func (utf *utf8FontFile) GenerateCutFont(usedRunes map[int]int) []byte {
 
 	utf.parseHMTXTable(metricsCount, numSymbols, symbolCharDictionary, 1.0)
 
+	if utf.tableDescriptions["CFF "] != nil {
+		return utf.generateCutCFF(usedRunes, oldMetrics)
+	}
+
 	utf.parseLOCATable(LocaFormat, numSymbols)
 
-	cidSymbolPairCollection, symbolArray, symbolCollection, symbolCollectionKeys := utf.parseSymbols(usedRunes)
+	cidSymbolPairCollection, symbolArray, symbolCollection, symbolCollectionKeys := utf.parseSymbols(utf.usedSymbols(usedRunes))
 
 	metricsCount = len(symbolCollection)
 	numSymbols = metricsCount
@@ -686,8 +698,14 @@ func (utf *utf8FontFile) GenerateCutFont(usedRunes map[int]int) []byte {
 	utf.setOutTable("post", postTable)
 
 	delete(cidSymbolPairCollection, 0)
+	cmapCollection := make(map[int]int)
+	for cid, symbol := range cidSymbolPairCollection {
+		if _, OK := utf.shapedGlyphs[cid]; !OK {
+			cmapCollection[cid] = symbol
+		}
+	}
 
-	utf.setOutTable("cmap", utf.generateCMAPTable(cidSymbolPairCollection, numSymbols))
+	utf.setOutTable("cmap", utf.generateCMAPTable(cmapCollection, numSymbols))
 
 	symbolData := utf.getTableData("glyf")
 
@@ -790,6 +808,22 @@ func (utf *utf8FontFile) GenerateCutFont(usedRunes map[int]int) []byte {
 	return utf.assembleTables()
 }
 
+// usedSymbols returns the glyph IDs of the CIDs of usedRunes. Glyphs are
+// selected by glyph ID so that glyphs produced by shaping, which have no
+// entry in the cmap table, are embedded as well.
+func (utf *utf8FontFile) usedSymbols(usedRunes map[int]int) map[int]int {
+	cidSymbols := make(map[int]int)
+	for _, char := range usedRunes {
+		if symbol, OK := utf.shapedGlyphs[char]; OK {
+			cidSymbols[char] = symbol
+		} else if symbol, OK := utf.charSymbolDictionary[char]; OK {
+			cidSymbols[char] = symbol
+		}
+		utf.LastRune = max(utf.LastRune, char)
+	}
+	return cidSymbols
+}
+
 func (utf *utf8FontFile) getSymbols(originalSymbolIdx int, start *int, symbolSet map[int]int, SymbolsCollection map[int]int, SymbolsCollectionKeys []int) (*int, map[int]int, map[int]int, []int) {
 	symbolPos := utf.symbolPosition[originalSymbolIdx]
 	symbolSize := utf.symbolPosition[originalSymbolIdx+1] - symbolPos
@@ -994,7 +1028,11 @@ func (utf *utf8FontFile) assembleTables() []byte {
 	findSize = findSize * 16
 	rOffset := tablesCount*16 - findSize
 
-	answer = append(answer, packHeader(0x00010000, tablesCount, findSize, writer, rOffset)...)
+	version := uint32(0x00010000)
+	if _, ok := utf.outTablesData["CFF "]; ok {
+		version = 0x4F54544F
+	}
+	answer = append(answer, packHeader(version, tablesCount, findSize, writer, rOffset)...)
 
 	tables := utf.outTablesData
 	tablesNames := keySortStrings(tables)
@@ -1142,7 +1180,8 @@ func keySortArrayRangeMap(s map[int][]int) []int {
 
 // UTF8CutFont is a utility function that generates a TrueType font composed
 // only of the runes included in cutset. The rune glyphs are copied from This
-// function is demonstrated in ExampleUTF8CutFont().
+// function is demonstrated in ExampleUTF8CutFont(). The subset of an OpenType
+// font with CFF outlines is an OpenType font with a CID-keyed CFF table.
 func UTF8CutFont(inBuf []byte, cutset string) (outBuf []byte) {
 	f := newUTF8Font(&fileReader{readerPosition: 0, array: inBuf})
 	runes := map[int]int{}
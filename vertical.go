@@ -0,0 +1,423 @@
+package gofpdf
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Vertical text is shown with an Identity-V version of a UTF-8 font, which
+// shares the CIDFont of the horizontal version. The glyphs of a vertical
+// font advance downward by the advance heights of the font's vmtx table and
+// are centered horizontally on the line of text; the CIDFont gives these
+// metrics in its /DW2 and /W2 entries.
+
+// vertMetricsType holds the tables of a font needed for its vertical
+// metrics.
+type vertMetricsType struct {
+	scale               float64 // thousandths of the font size per font unit
+	vmtx                otTable
+	numMetrics          int
+	glyf, loca          otTable
+	longLoca            bool
+	origins             map[int]int // vertical origins of the VORG table
+	vorg                bool
+	defOrigin           int
+	ascender, descender int
+}
+
+// vertMetrics returns the vertical metrics of the font.
+func (utf *utf8FontFile) vertMetrics() *vertMetricsType {
+	if utf.vert != nil {
+		return utf.vert
+	}
+	vm := &vertMetricsType{
+		scale:   1000.0 / float64(utf.fontElementSize),
+		glyf:    otTable(utf.getTableData("glyf")),
+		loca:    otTable(utf.getTableData("loca")),
+		origins: make(map[int]int),
+	}
+	vm.longLoca = otTable(utf.getTableData("head")).u16(50) == 1
+	hhea := otTable(utf.getTableData("hhea"))
+	vm.ascender, vm.descender = hhea.i16(4), hhea.i16(6)
+	if vhea := otTable(utf.getTableData("vhea")); vhea != nil {
+		vm.vmtx = otTable(utf.getTableData("vmtx"))
+		vm.numMetrics = vhea.u16(34)
+	}
+	if vorg := otTable(utf.getTableData("VORG")); vorg != nil {
+		vm.vorg = true
+		vm.defOrigin = vorg.i16(4)
+		count := vorg.u16(6)
+		for j := 0; j < count; j++ {
+			vm.origins[vorg.u16(8+4*j)] = vorg.i16(10 + 4*j)
+		}
+	}
+	utf.vert = vm
+	return vm
+}
+
+// yMax returns the top of the bounding box of a glyph of a font with
+// TrueType outlines, and false if the glyph has no outline.
+func (vm *vertMetricsType) yMax(gid int) (int, bool) {
+	var start, end int
+	if vm.longLoca {
+		start, end = vm.loca.u32(4*gid), vm.loca.u32(4*gid+4)
+	} else {
+		start, end = 2*vm.loca.u16(2*gid), 2*vm.loca.u16(2*gid+2)
+	}
+	if end <= start || end > len(vm.glyf) {
+		return 0, false
+	}
+	return vm.glyf.i16(start + 8), true
+}
+
+// defaults returns the advance height and vertical origin, in thousandths
+// of the font size, of glyphs without vertical metrics: the advance is the
+// distance between the ascender and the descender, and the origin is at the
+// ascender.
+func (vm *vertMetricsType) defaults() (advance, origin int) {
+	origin = vm.ascender
+	if vm.vorg {
+		origin = vm.defOrigin
+	}
+	return vm.round(vm.ascender - vm.descender), vm.round(origin)
+}
+
+func (vm *vertMetricsType) round(v int) int {
+	return int(float64(v)*vm.scale + 0.5)
+}
+
+// metrics returns the advance height of a glyph and the height of its
+// vertical origin above the horizontal one, in thousandths of the font size.
+func (vm *vertMetricsType) metrics(gid int) (advance, origin int) {
+	advance, origin = vm.defaults()
+	if vm.numMetrics == 0 {
+		return
+	}
+	j := gid
+	if j >= vm.numMetrics {
+		j = vm.numMetrics - 1
+	}
+	advance = vm.round(vm.vmtx.u16(4 * j))
+	var tsb int
+	if gid < vm.numMetrics {
+		tsb = vm.vmtx.i16(4*gid + 2)
+	} else {
+		tsb = vm.vmtx.i16(4*vm.numMetrics + 2*(gid-vm.numMetrics))
+	}
+	if y, ok := vm.origins[gid]; ok {
+		origin = vm.round(y)
+	} else if y, ok := vm.yMax(gid); ok && !vm.vorg {
+		origin = vm.round(y + tsb)
+	}
+	return
+}
+
+// vertical returns the glyphs of the characters of txt set vertically, in
+// which the vert and vrt2 features substitute the vertical forms of
+// characters such as brackets and punctuation.
+func (sh *otShaperType) vertical(txt []rune) []shapeGlyph {
+	features, ok := sh.features["GSUB vert"]
+	if !ok {
+		features = otScriptFeatures(sh.gsub, "hani", "kana", "hang")
+		sh.features["GSUB vert"] = features
+	}
+	b := shapeBufType{sh: sh, glyphs: make([]shapeGlyph, len(txt))}
+	for j, r := range txt {
+		b.glyphs[j] = shapeGlyph{
+			gid:     sh.cmap[int(r)],
+			cluster: j,
+			text:    []rune{r},
+			mask:    shapeGlobalMask,
+			attach:  -1,
+		}
+	}
+	b.stages(features, []string{"vert", "vrt2"})
+	return b.glyphs
+}
+
+// vertAdvances returns the advance heights of the characters of txt set
+// vertically in the current font, in thousandths of the font size.
+func (f *Fpdf) vertAdvances(txt []rune) []int {
+	vm := f.currentFont.utf8File.vertMetrics()
+	cs := f.charSpacingUnits()
+	list := make([]int, len(txt))
+	for _, g := range f.shaper().vertical(txt) {
+		advance, _ := vm.metrics(g.gid)
+		list[g.cluster] += advance + cs
+	}
+	return list
+}
+
+// vertText returns the codes that show txt vertically in the current font
+// and their total advance height in thousandths of the font size.
+func (f *Fpdf) vertText(txt []rune) (codes []byte, length int) {
+	vm := f.currentFont.utf8File.vertMetrics()
+	sh := f.shaper()
+	cs := f.charSpacingUnits()
+	for _, g := range sh.vertical(txt) {
+		cid := f.shapeCID(sh, g)
+		f.currentFont.usedRunes[cid] = cid
+		codes = append(codes, byte(cid>>8), byte(cid))
+		advance, _ := vm.metrics(g.gid)
+		length += advance + cs
+	}
+	return
+}
+
+// vertFont checks that the current font can be used for vertical text and
+// notes that its Identity-V version is needed.
+func (f *Fpdf) vertFont() bool {
+	if f.err != nil {
+		return false
+	}
+	if !f.isCurrentUTF8 || f.currentFont.utf8File == nil {
+		f.SetErrorf("vertical text requires a UTF-8 font")
+		return false
+	}
+	if f.verticalFonts == nil {
+		f.verticalFonts = make(map[string]int)
+	}
+	key := f.fontFamily + f.fontStyle
+	if _, ok := f.verticalFonts[key]; !ok {
+		f.verticalFonts[key] = 0
+	}
+	return true
+}
+
+// GetStringHeight returns the length in user units of a string set
+// vertically in the current font, which must be a UTF-8 font. See
+// VCellFormat().
+func (f *Fpdf) GetStringHeight(s string) float64 {
+	if f.err != nil || !f.isCurrentUTF8 || f.currentFont.utf8File == nil {
+		return 0
+	}
+	l := 0
+	for _, advance := range f.vertAdvances([]rune(s)) {
+		l += advance
+	}
+	return float64(l) * f.fontSize / 1000
+}
+
+// VCellFormat prints a rectangular cell like CellFormat(), with its text set
+// vertically from top to bottom, as in Japanese or Chinese. The current font
+// must be a UTF-8 font added with AddUTF8Font() or a related method. Vertical
+// text uses the advance heights of the font's vmtx table and the vertical
+// forms of characters such as brackets and punctuation given by the vert
+// feature of its GSUB table.
+//
+// w and h are the width and height of the cell. The text is centered on a
+// vertical line within the cell. If w is 0, the cell is as wide as the font
+// size plus the cell margins. If h is 0, the cell extends to the bottom
+// margin.
+//
+// borderStr, fill, link and linkStr are as in CellFormat().
+//
+// ln indicates where the current position should go after the call. Possible
+// values are 0 (below the cell, where the text continues), 1 (to the top
+// margin of the next column, which is to the left of the cell) and 2 (to the
+// left of the cell).
+//
+// alignStr specifies how the text is to be positioned within the cell.
+// Vertical alignment is controlled by including "T" (top, the default), "M"
+// (middle) or "B" (bottom). Horizontal alignment is controlled by including
+// "L" (left), "C" (center, the default) or "R" (right).
+func (f *Fpdf) VCellFormat(w, h float64, txtStr, borderStr string, ln int,
+	alignStr string, fill bool, link int, linkStr string) {
+	if !f.vertFont() {
+		return
+	}
+	if w == 0 {
+		w = f.fontSize + 2*f.cMargin
+	}
+	if h == 0 {
+		h = f.pageBreakTrigger - f.y
+	}
+	if f.tagAuto(len(txtStr) == 0) {
+		defer f.tagAutoEnd(0)
+	}
+	x := f.x
+	f.CellFormat(w, h, "", borderStr, 0, "", fill, 0, "")
+	if f.err != nil {
+		return
+	}
+	f.x = x
+	y := f.y
+	if len(txtStr) > 0 {
+		k := f.k
+		codes, length := f.vertText([]rune(txtStr))
+		l := float64(length) * f.fontSize / 1000
+		var dx, dy float64
+		switch {
+		case strings.Contains(alignStr, "L"):
+			dx = f.cMargin + f.fontSize/2
+		case strings.Contains(alignStr, "R"):
+			dx = w - f.cMargin - f.fontSize/2
+		default:
+			dx = w / 2
+		}
+		switch {
+		case strings.Contains(alignStr, "B"):
+			dy = h - f.cMargin - l
+		case strings.Contains(alignStr, "M"):
+			dy = (h - l) / 2
+		default:
+			dy = f.cMargin
+		}
+		var s fmtBuffer
+		s.printf("q ")
+		if f.colorFlag {
+			s.printf("%s ", f.color.text.str)
+		}
+		s.printf("BT /F%sV %.2f Tf %.2f %.2f Td (%s) Tj ET Q", f.currentFont.i, f.fontSizePt,
+			(x+dx)*k, (f.h-(y+dy))*k, f.escape(string(codes)))
+		f.out(s.String())
+		if link > 0 || len(linkStr) > 0 {
+			f.newLink(x+dx-f.fontSize/2, y+dy, f.fontSize, l, link, linkStr, txtStr)
+		}
+	}
+	switch ln {
+	case 0:
+		f.y = y + h
+	case 1:
+		f.x = x - w
+		f.y = f.tMargin
+	default:
+		f.x = x - w
+	}
+}
+
+// VCell is a simpler version of VCellFormat with no fill, border, links or
+// special alignment. The cell is positioned below the previous one.
+func (f *Fpdf) VCell(w, h float64, txtStr string) {
+	f.VCellFormat(w, h, txtStr, "", 0, "", false, 0, "")
+}
+
+// VMultiCell prints text set vertically, like VCellFormat(), in columns of
+// width w and height h that run from right to left. The text is broken into
+// columns automatically at the height of the cells, and explicitly with the
+// \n character. Characters of Chinese, Japanese and Korean text can be
+// separated by breaks; other text is broken at spaces. Punctuation such as
+// commas, full stops and closing brackets does not begin a column.
+//
+// The first column is placed at the current position. When the next column
+// would pass the left margin, a new page is added and the columns continue
+// from the right margin. Afterwards, the current position is to the left of
+// the last column, at its top.
+//
+// w and h are as in VCellFormat(); borderStr and fill apply to each column,
+// and alignStr positions the text within each column as in VCellFormat().
+func (f *Fpdf) VMultiCell(w, h float64, txtStr, borderStr, alignStr string, fill bool) {
+	if !f.vertFont() {
+		return
+	}
+	if w == 0 {
+		w = f.fontSize + 2*f.cMargin
+	}
+	if h == 0 {
+		h = f.pageBreakTrigger - f.y
+	}
+	// The columns form a single paragraph of a tagged document
+	if f.tagAuto(len(txtStr) == 0) {
+		defer f.tagAutoEnd(0)
+	}
+	top := f.y
+	for j, column := range f.splitVertical([]rune(txtStr), h) {
+		if j > 0 && f.x < f.lMargin && !f.inHeader && !f.inFooter && f.acceptPageBreak() {
+			f.AddPageFormat(f.curOrientation, f.curPageSize)
+			if f.err != nil {
+				return
+			}
+			f.x = f.w - f.rMargin - w
+			top = f.y
+		}
+		f.y = top
+		f.VCellFormat(w, h, string(column), borderStr, 2, alignStr, fill, 0, "")
+		top = f.y
+	}
+}
+
+// vertNoStart lists the characters that do not begin a column of vertical
+// text.
+const vertNoStart = "、。，．,.・：；？！:;?!)]}）］｝〕〉》」』】〙〗’”ー々ぁぃぅぇぉっゃゅょゎァィゥェォッャュョヮヵヶ"
+
+// vertBreakable returns true if a break is allowed on either side of r.
+func vertBreakable(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		r >= 0x3000 && r <= 0x303f || r >= 0xff00 && r <= 0xffef
+}
+
+// splitVertical breaks txt into columns of vertical text no longer than h.
+func (f *Fpdf) splitVertical(txt []rune, h float64) (columns [][]rune) {
+	adv := f.vertAdvances(txt)
+	limit := int((h - 2*f.cMargin) * 1000 / f.fontSize)
+	start, end, next, l := 0, -1, -1, 0
+	for i := 0; i < len(txt); i++ {
+		c := txt[i]
+		if c == '\n' {
+			columns = append(columns, txt[start:i])
+			start, end, l = i+1, -1, 0
+			continue
+		}
+		if i > start && c != ' ' && (vertBreakable(c) || vertBreakable(txt[i-1])) &&
+			!strings.ContainsRune(vertNoStart, c) {
+			end, next = i, i
+		}
+		l += adv[i]
+		if l > limit && i > start {
+			switch {
+			case c == ' ':
+				end, next = i, i+1
+			case end <= start:
+				end, next = i, i
+			}
+			columns = append(columns, txt[start:end])
+			start, end, l = next, -1, 0
+			i = next - 1
+			continue
+		}
+		if c == ' ' {
+			end, next = i, i+1
+		}
+	}
+	if start < len(txt) || len(columns) == 0 {
+		columns = append(columns, txt[start:])
+	}
+	return
+}
+
+// putVerticalMetrics outputs the /DW2 and /W2 entries of the CIDFont of a
+// font used for vertical text. Glyphs are listed in /W2 if their metrics
+// differ from the defaults of /DW2.
+func (f *Fpdf) putVerticalMetrics(font *fontDefType) {
+	utf := font.utf8File
+	vm := utf.vertMetrics()
+	advance, origin := vm.defaults()
+	f.outf("/DW2 [%d %d]", origin, -advance)
+	var cids []int
+	for cid := range font.usedRunes {
+		if cid > 0 && cid < len(font.Cw) && font.Cw[cid] != 0 {
+			cids = append(cids, cid)
+		}
+	}
+	sort.Ints(cids)
+	var s fmtBuffer
+	for _, cid := range cids {
+		gid, ok := utf.shapedGlyphs[cid]
+		if !ok {
+			gid = utf.charSymbolDictionary[cid]
+		}
+		adv, y := vm.metrics(gid)
+		if adv == advance && y == origin {
+			continue
+		}
+		w := font.Cw[cid]
+		if w == 65535 {
+			w = 0
+		}
+		s.printf("%d [%d %.1f %d] ", cid, -adv, float64(w)/2, y)
+	}
+	if s.Len() > 0 {
+		f.outf("/W2 [%s]", strings.TrimSpace(s.String()))
+	}
+}
@@ -0,0 +1,617 @@
+package gofpdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Font files come as single TrueType or OpenType fonts, as collections of
+// them (.ttc) that share tables, or wrapped in the compressed WOFF and WOFF2
+// formats used on the web. WOFF2 data is compressed with Brotli, which the
+// standard library does not provide, so it is decompressed by a function set
+// with SetWOFF2Decoder(). The tables of the face to use are extracted from
+// any of these and assembled into a plain font, which is then parsed as
+// usual.
+
+const (
+	sfntTagTTC   = 0x74746366 // ttcf
+	sfntTagWOFF  = 0x774F4646 // wOFF
+	sfntTagWOFF2 = 0x774F4632 // wOF2
+)
+
+// sfntFont returns the font data of face number face of data, which holds a
+// font, a font collection or a WOFF or WOFF2 file. decoder decompresses the
+// data of WOFF2 files.
+func sfntFont(data []byte, face int, decoder func(io.Reader) io.Reader) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("font file too short")
+	}
+	var tables map[string][]byte
+	var err error
+	switch binary.BigEndian.Uint32(data) {
+	case sfntTagTTC:
+		tables, err = ttcTables(data, face)
+	case sfntTagWOFF:
+		if face != 0 {
+			return nil, fmt.Errorf("WOFF file has a single face")
+		}
+		tables, err = woffTables(data)
+	case sfntTagWOFF2:
+		tables, err = woff2Tables(data, face, decoder)
+	default:
+		if face != 0 {
+			return nil, fmt.Errorf("font file is not a collection")
+		}
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tables["head"] == nil {
+		return nil, fmt.Errorf("font has no head table")
+	}
+	return (&utf8FontFile{outTablesData: tables}).assembleTables(), nil
+}
+
+// sfntRange returns length bytes of data at offset, or nil if they are out of
+// range.
+func sfntRange(data []byte, offset, length int) []byte {
+	if offset < 0 || length < 0 || offset+length > len(data) || offset+length < offset {
+		return nil
+	}
+	return data[offset : offset+length]
+}
+
+// ttcTables returns the tables of face number face of a TrueType collection
+func ttcTables(data []byte, face int) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("invalid font collection")
+	}
+	count := int(binary.BigEndian.Uint32(data[8:]))
+	if face < 0 || face >= count {
+		return nil, fmt.Errorf("face %d not found in font collection of %d faces", face, count)
+	}
+	b := sfntRange(data, 12+4*face, 4)
+	if b == nil {
+		return nil, fmt.Errorf("invalid font collection")
+	}
+	offset := int(binary.BigEndian.Uint32(b))
+	header := sfntRange(data, offset, 12)
+	if header == nil {
+		return nil, fmt.Errorf("invalid font collection")
+	}
+	numTables := int(binary.BigEndian.Uint16(header[4:]))
+	tables := make(map[string][]byte)
+	for j := 0; j < numTables; j++ {
+		rec := sfntRange(data, offset+12+16*j, 16)
+		if rec == nil {
+			return nil, fmt.Errorf("invalid font collection")
+		}
+		table := sfntRange(data, int(binary.BigEndian.Uint32(rec[8:])), int(binary.BigEndian.Uint32(rec[12:])))
+		if table == nil {
+			return nil, fmt.Errorf("invalid font collection")
+		}
+		tables[string(rec[:4])] = table
+	}
+	return tables, nil
+}
+
+// woffTables returns the tables of a WOFF file, each of which may be
+// compressed with zlib.
+func woffTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 44 {
+		return nil, fmt.Errorf("invalid WOFF file")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[12:]))
+	tables := make(map[string][]byte)
+	for j := 0; j < numTables; j++ {
+		rec := sfntRange(data, 44+20*j, 20)
+		if rec == nil {
+			return nil, fmt.Errorf("invalid WOFF file")
+		}
+		compLength := int(binary.BigEndian.Uint32(rec[8:]))
+		origLength := int(binary.BigEndian.Uint32(rec[12:]))
+		table := sfntRange(data, int(binary.BigEndian.Uint32(rec[4:])), compLength)
+		if table == nil {
+			return nil, fmt.Errorf("invalid WOFF file")
+		}
+		if compLength < origLength {
+			r, err := zlib.NewReader(bytes.NewReader(table))
+			if err != nil {
+				return nil, err
+			}
+			table, err = ioutil.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(table) != origLength {
+			return nil, fmt.Errorf("invalid WOFF table %s", rec[:4])
+		}
+		tables[string(rec[:4])] = table
+	}
+	return tables, nil
+}
+
+// woff2KnownTags lists the tables whose tag is given by its index in a
+// WOFF2 table directory.
+var woff2KnownTags = []string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post", "cvt ",
+	"fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT", "EBLC", "gasp",
+	"hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea", "vmtx", "BASE", "GDEF",
+	"GPOS", "GSUB", "EBSC", "JSTF", "MATH", "CBDT", "CBLC", "COLR", "CPAL",
+	"SVG ", "sbix", "acnt", "avar", "bdat", "bloc", "bsln", "cvar", "fdsc",
+	"feat", "fmtx", "fvar", "gvar", "hsty", "just", "lcar", "mort", "morx",
+	"opbd", "prop", "trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+// woff2Reader reads the variable-length numbers of WOFF2 data
+type woff2Reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *woff2Reader) bytes(n int) []byte {
+	b := sfntRange(r.data, r.pos, n)
+	if b == nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("invalid WOFF2 data")
+		}
+		return make([]byte, n)
+	}
+	r.pos += n
+	return b
+}
+
+func (r *woff2Reader) uint8() int {
+	return int(r.bytes(1)[0])
+}
+
+func (r *woff2Reader) uint16() int {
+	return int(binary.BigEndian.Uint16(r.bytes(2)))
+}
+
+func (r *woff2Reader) int16() int {
+	return int(int16(binary.BigEndian.Uint16(r.bytes(2))))
+}
+
+func (r *woff2Reader) uint32() int {
+	return int(binary.BigEndian.Uint32(r.bytes(4)))
+}
+
+// uint255 reads a 255UInt16
+func (r *woff2Reader) uint255() int {
+	switch code := r.uint8(); code {
+	case 253:
+		return r.uint16()
+	case 254:
+		return r.uint8() + 253*2
+	case 255:
+		return r.uint8() + 253
+	default:
+		return code
+	}
+}
+
+// base128 reads a UIntBase128
+func (r *woff2Reader) base128() int {
+	var v int
+	for j := 0; j < 5; j++ {
+		b := r.uint8()
+		if j == 0 && b == 0x80 || v>>25 != 0 {
+			r.err = fmt.Errorf("invalid WOFF2 number")
+			return 0
+		}
+		v = v<<7 | b&0x7f
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.err = fmt.Errorf("invalid WOFF2 number")
+	return 0
+}
+
+// woff2Table is an entry of a WOFF2 table directory
+type woff2Table struct {
+	tag         string
+	transformed bool
+	length      int // length of the data in the decompressed stream
+	data        []byte
+}
+
+// woff2Tables returns the tables of face number face of a WOFF2 file. The
+// tables are compressed together with Brotli; glyf, loca and hmtx may be
+// stored in a transformed form, which is reversed. decoder decompresses the
+// Brotli stream.
+func woff2Tables(data []byte, face int, decoder func(io.Reader) io.Reader) (map[string][]byte, error) {
+	r := &woff2Reader{data: data, pos: 4}
+	flavor := r.uint32()
+	r.bytes(4)
+	numTables := r.uint16()
+	r.bytes(6)
+	compressedSize := r.uint32()
+	r.bytes(24)
+	list := make([]woff2Table, numTables)
+	for j := range list {
+		flags := r.uint8()
+		if flags&0x3f == 0x3f {
+			list[j].tag = string(r.bytes(4))
+		} else {
+			list[j].tag = woff2KnownTags[flags&0x3f]
+		}
+		version := flags >> 6
+		if list[j].tag == "glyf" || list[j].tag == "loca" {
+			list[j].transformed = version == 0
+		} else {
+			list[j].transformed = version != 0
+		}
+		list[j].length = r.base128()
+		if list[j].transformed {
+			list[j].length = r.base128()
+		}
+	}
+	// Indexes of the tables of the face
+	var indexes []int
+	if flavor == sfntTagTTC {
+		r.uint32()
+		count := r.uint255()
+		if face < 0 || face >= count {
+			return nil, fmt.Errorf("face %d not found in font collection of %d faces", face, count)
+		}
+		for j := 0; j <= face; j++ {
+			n := r.uint255()
+			r.uint32()
+			indexes = indexes[:0]
+			for k := 0; k < n; k++ {
+				indexes = append(indexes, r.uint255())
+			}
+		}
+	} else {
+		if face != 0 {
+			return nil, fmt.Errorf("font file is not a collection")
+		}
+		for j := range list {
+			indexes = append(indexes, j)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if decoder == nil {
+		return nil, fmt.Errorf("WOFF2 font requires a decoder; see SetWOFF2Decoder()")
+	}
+	stream, err := ioutil.ReadAll(decoder(bytes.NewReader(r.bytes(compressedSize))))
+	if err != nil {
+		return nil, err
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	pos := 0
+	for j := range list {
+		list[j].data = sfntRange(stream, pos, list[j].length)
+		if list[j].data == nil {
+			return nil, fmt.Errorf("invalid WOFF2 table %s", list[j].tag)
+		}
+		pos += list[j].length
+	}
+	tables := make(map[string][]byte)
+	transformed := make(map[string]bool)
+	for _, j := range indexes {
+		if j < 0 || j >= len(list) {
+			return nil, fmt.Errorf("invalid WOFF2 collection")
+		}
+		tables[list[j].tag] = list[j].data
+		transformed[list[j].tag] = list[j].transformed
+	}
+	var xMin []int
+	if transformed["glyf"] {
+		var indexFormat int
+		tables["glyf"], tables["loca"], indexFormat, xMin, err = woff2Glyf(tables["glyf"])
+		if err != nil {
+			return nil, err
+		}
+		if len(tables["head"]) < 54 {
+			return nil, fmt.Errorf("invalid head table")
+		}
+		head := append([]byte(nil), tables["head"]...)
+		binary.BigEndian.PutUint16(head[50:], uint16(indexFormat))
+		tables["head"] = head
+	}
+	if transformed["hmtx"] {
+		tables["hmtx"], err = woff2Hmtx(tables, xMin)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// woff2Triplet returns the point coordinate deltas encoded by flag and the
+// bytes of glyph data that follow it.
+func woff2Triplet(flag int, r *woff2Reader) (dx, dy int) {
+	sign := func(flag, v int) int {
+		if flag&1 != 0 {
+			return v
+		}
+		return -v
+	}
+	switch {
+	case flag < 10:
+		dy = sign(flag, (flag&14)<<7+r.uint8())
+	case flag < 20:
+		dx = sign(flag, ((flag-10)&14)<<7+r.uint8())
+	case flag < 84:
+		b0, b1 := flag-20, r.uint8()
+		dx = sign(flag, 1+b0&0x30+b1>>4)
+		dy = sign(flag>>1, 1+(b0&0x0c)<<2+b1&0x0f)
+	case flag < 120:
+		b0 := flag - 84
+		dx = sign(flag, 1+(b0/12)<<8+r.uint8())
+		dy = sign(flag>>1, 1+((b0%12)>>2)<<8+r.uint8())
+	case flag < 124:
+		b := r.bytes(3)
+		dx = sign(flag, int(b[0])<<4+int(b[1])>>4)
+		dy = sign(flag>>1, int(b[1]&0x0f)<<8+int(b[2]))
+	default:
+		b := r.bytes(4)
+		dx = sign(flag, int(b[0])<<8+int(b[1]))
+		dy = sign(flag>>1, int(b[2])<<8+int(b[3]))
+	}
+	return
+}
+
+// woff2Glyf reverses the WOFF2 transform of the glyf table, returning the
+// glyf and loca tables, the loca format and the minimum x of each glyph.
+func woff2Glyf(data []byte) (glyf, loca []byte, indexFormat int, xMin []int, err error) {
+	r := &woff2Reader{data: data}
+	r.uint16()
+	options := r.uint16()
+	numGlyphs := r.uint16()
+	indexFormat = r.uint16()
+	streams := make([]*woff2Reader, 7)
+	sizes := make([]int, len(streams))
+	for j := range sizes {
+		sizes[j] = r.uint32()
+	}
+	for j := range streams {
+		streams[j] = &woff2Reader{data: r.bytes(sizes[j])}
+	}
+	var overlap []byte
+	if options&1 != 0 {
+		overlap = r.bytes((numGlyphs + 7) >> 3)
+	}
+	if r.err != nil {
+		return nil, nil, 0, nil, fmt.Errorf("invalid WOFF2 glyf table")
+	}
+	nContours, nPoints, flags, glyphs, composites, bboxes, instructions := streams[0], streams[1], streams[2], streams[3], streams[4], streams[5], streams[6]
+	bitmap := bboxes.bytes(((numGlyphs + 31) >> 5) << 2)
+	offsets := make([]int, numGlyphs+1)
+	xMin = make([]int, numGlyphs)
+	var buf bytes.Buffer
+	put := func(v ...int) {
+		for _, n := range v {
+			buf.WriteByte(byte(n >> 8))
+			buf.WriteByte(byte(n))
+		}
+	}
+	for gid := 0; gid < numGlyphs; gid++ {
+		offsets[gid] = buf.Len()
+		hasBBox := bitmap[gid>>3]&(0x80>>uint(gid&7)) != 0
+		n := nContours.int16()
+		switch {
+		case n == 0:
+			if hasBBox {
+				err = fmt.Errorf("invalid WOFF2 empty glyph %d", gid)
+			}
+		case n < 0:
+			// Composite glyph, copied from the composite stream
+			if !hasBBox {
+				err = fmt.Errorf("invalid WOFF2 composite glyph %d", gid)
+				break
+			}
+			bbox := []int{bboxes.int16(), bboxes.int16(), bboxes.int16(), bboxes.int16()}
+			xMin[gid] = bbox[0]
+			start := composites.pos
+			more, haveInstructions := true, false
+			for more {
+				flag := composites.uint16()
+				composites.uint16()
+				size := 2
+				if flag&0x0001 != 0 {
+					size = 4
+				}
+				switch {
+				case flag&0x0008 != 0:
+					size += 2
+				case flag&0x0040 != 0:
+					size += 4
+				case flag&0x0080 != 0:
+					size += 8
+				}
+				composites.bytes(size)
+				more = flag&0x0020 != 0
+				haveInstructions = haveInstructions || flag&0x0100 != 0
+			}
+			put(-1)
+			put(bbox...)
+			buf.Write(composites.data[start:composites.pos])
+			if haveInstructions {
+				length := glyphs.uint255()
+				put(length)
+				buf.Write(instructions.bytes(length))
+			}
+		default:
+			// Simple glyph, whose points are decoded from the triplet stream
+			ends := make([]int, n)
+			total := 0
+			for j := range ends {
+				total += nPoints.uint255()
+				ends[j] = total - 1
+			}
+			xs, ys, on := make([]int, total), make([]int, total), make([]bool, total)
+			x, y := 0, 0
+			for j := 0; j < total; j++ {
+				flag := flags.uint8()
+				on[j] = flag&0x80 == 0
+				dx, dy := woff2Triplet(flag&0x7f, glyphs)
+				x, y = x+dx, y+dy
+				xs[j], ys[j] = x, y
+			}
+			length := glyphs.uint255()
+			var bbox []int
+			if hasBBox {
+				bbox = []int{bboxes.int16(), bboxes.int16(), bboxes.int16(), bboxes.int16()}
+			} else if total > 0 {
+				bbox = []int{xs[0], ys[0], xs[0], ys[0]}
+				for j := 1; j < total; j++ {
+					if xs[j] < bbox[0] {
+						bbox[0] = xs[j]
+					}
+					if ys[j] < bbox[1] {
+						bbox[1] = ys[j]
+					}
+					if xs[j] > bbox[2] {
+						bbox[2] = xs[j]
+					}
+					if ys[j] > bbox[3] {
+						bbox[3] = ys[j]
+					}
+				}
+			} else {
+				bbox = []int{0, 0, 0, 0}
+			}
+			xMin[gid] = bbox[0]
+			put(n)
+			put(bbox...)
+			put(ends...)
+			put(length)
+			buf.Write(instructions.bytes(length))
+			// Point flags with repeats, then the x and y coordinates
+			var xBuf, yBuf bytes.Buffer
+			lastFlag, repeat := -1, 0
+			x, y = 0, 0
+			for j := 0; j < total; j++ {
+				flag := 0
+				if on[j] {
+					flag = 0x01
+				}
+				if j == 0 && overlap != nil && overlap[gid>>3]&(0x80>>uint(gid&7)) != 0 {
+					flag |= 0x40
+				}
+				dx, dy := xs[j]-x, ys[j]-y
+				x, y = xs[j], ys[j]
+				switch {
+				case dx == 0:
+					flag |= 0x10
+				case dx > -256 && dx < 256:
+					flag |= 0x02
+					if dx > 0 {
+						flag |= 0x10
+					} else {
+						dx = -dx
+					}
+					xBuf.WriteByte(byte(dx))
+				default:
+					xBuf.WriteByte(byte(dx >> 8))
+					xBuf.WriteByte(byte(dx))
+				}
+				switch {
+				case dy == 0:
+					flag |= 0x20
+				case dy > -256 && dy < 256:
+					flag |= 0x04
+					if dy > 0 {
+						flag |= 0x20
+					} else {
+						dy = -dy
+					}
+					yBuf.WriteByte(byte(dy))
+				default:
+					yBuf.WriteByte(byte(dy >> 8))
+					yBuf.WriteByte(byte(dy))
+				}
+				if flag == lastFlag && repeat < 255 {
+					b := buf.Bytes()
+					if repeat == 0 {
+						b[len(b)-1] |= 0x08
+						buf.WriteByte(1)
+					} else {
+						b[len(b)-1]++
+					}
+					repeat++
+				} else {
+					buf.WriteByte(byte(flag))
+					lastFlag, repeat = flag, 0
+				}
+			}
+			buf.Write(xBuf.Bytes())
+			buf.Write(yBuf.Bytes())
+		}
+		for buf.Len()&3 != 0 {
+			buf.WriteByte(0)
+		}
+		if err != nil {
+			return nil, nil, 0, nil, err
+		}
+	}
+	offsets[numGlyphs] = buf.Len()
+	for _, s := range streams {
+		if s.err != nil {
+			return nil, nil, 0, nil, fmt.Errorf("invalid WOFF2 glyf table")
+		}
+	}
+	glyf = buf.Bytes()
+	buf = bytes.Buffer{}
+	for _, offset := range offsets {
+		if indexFormat == 0 {
+			put(offset >> 1)
+		} else {
+			put(offset>>16, offset)
+		}
+	}
+	return glyf, buf.Bytes(), indexFormat, xMin, nil
+}
+
+// woff2Hmtx reverses the WOFF2 transform of the hmtx table, in which the
+// left side bearings equal to the minimum x of the glyphs may be omitted.
+func woff2Hmtx(tables map[string][]byte, xMin []int) ([]byte, error) {
+	if len(tables["hhea"]) < 36 || len(tables["maxp"]) < 6 {
+		return nil, fmt.Errorf("invalid WOFF2 hmtx table")
+	}
+	numMetrics := int(binary.BigEndian.Uint16(tables["hhea"][34:]))
+	numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+	if numMetrics > numGlyphs || len(xMin) < numGlyphs {
+		return nil, fmt.Errorf("invalid WOFF2 hmtx table")
+	}
+	r := &woff2Reader{data: tables["hmtx"]}
+	flags := r.uint8()
+	widths := make([]int, numMetrics)
+	for j := range widths {
+		widths[j] = r.uint16()
+	}
+	lsbs := make([]int, numGlyphs)
+	for j := range lsbs {
+		if j < numMetrics && flags&1 == 0 || j >= numMetrics && flags&2 == 0 {
+			lsbs[j] = r.int16()
+		} else {
+			lsbs[j] = xMin[j]
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid WOFF2 hmtx table")
+	}
+	hmtx := make([]byte, 0, 4*numMetrics+2*(numGlyphs-numMetrics))
+	for j, lsb := range lsbs {
+		if j < numMetrics {
+			hmtx = append(hmtx, byte(widths[j]>>8), byte(widths[j]))
+		}
+		hmtx = append(hmtx, byte(lsb>>8), byte(lsb))
+	}
+	return hmtx, nil
+}